@@ -0,0 +1,182 @@
+package sessionup
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrAccessDenied is returned when the session is valid, but one of the
+// access rules (see AccessPolicy) does not allow it to be used for the
+// request, e.g. outside of business hours.
+var ErrAccessDenied = errors.New("access denied by policy")
+
+// AccessRule checks whether the session may be used for the request.
+type AccessRule func(s Session, r *http.Request) bool
+
+// AccessPolicy sets the function which will be called during
+// authentication to determine the access rules of the session, e.g.
+// based on its user key. All of the returned rules must allow the
+// request, otherwise it is rejected with ErrAccessDenied.
+// By default it is not set.
+func AccessPolicy(fn func(s Session) []AccessRule) Option {
+	return func(m *Manager) {
+		m.access = fn
+	}
+}
+
+// AllowNetworks produces a rule that allows only requests whose remote
+// IP address (see http.Request's RemoteAddr) belongs to one of the
+// provided CIDR ranges (e.g. "10.0.0.0/8"). Forwarding headers are
+// ignored, since clients can set them freely; use AllowNetworksBehind
+// if the application is behind a proxy.
+// An error is returned if any of the ranges is invalid.
+func AllowNetworks(cidrs ...string) (AccessRule, error) {
+	return AllowNetworksBehind(nil, cidrs...)
+}
+
+// AllowNetworksBehind works like AllowNetworks, but requests whose
+// remote IP address belongs to one of the trusted proxies' CIDR ranges
+// are matched by the address found in their X-Forwarded-For header
+// instead: the header is read from right to left and the first address
+// that does not belong to a trusted proxy is used.
+// An error is returned if any of the ranges is invalid.
+func AllowNetworksBehind(proxies []string, cidrs ...string) (AccessRule, error) {
+	trusted, err := parseNetworks(proxies)
+	if err != nil {
+		return nil, err
+	}
+
+	nets, err := parseNetworks(cidrs)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(_ Session, r *http.Request) bool {
+		return containsIP(nets, forwardedIP(r, trusted))
+	}, nil
+}
+
+// parseNetworks parses the provided CIDR ranges.
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+
+		nets[i] = n
+	}
+
+	return nets, nil
+}
+
+// containsIP checks whether the IP address belongs to any of the
+// networks.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedIP returns the address of the client that sent the request
+// through the trusted proxies. Nil is returned if any of the examined
+// addresses is invalid.
+func forwardedIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	ip := net.ParseIP(host)
+	if !containsIP(trusted, ip) {
+		return ip
+	}
+
+	xff := r.Header["X-Forwarded-For"]
+	if len(xff) == 0 {
+		// the proxy itself sent the request.
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(xff, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(hops[i]))
+		if !containsIP(trusted, ip) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+// AllowHours produces a rule that allows only requests made during the
+// daily time window between the provided offsets from midnight (e.g.
+// 9h and 17h), in the provided location (UTC, if nil). Windows whose
+// end precedes their start span midnight. If days are provided,
+// requests are allowed on those weekdays only; windows spanning
+// midnight belong to the day they start on, e.g. a Friday 22h to 2h
+// window allows requests made early on Saturday.
+func AllowHours(loc *time.Location, from, to time.Duration, days ...time.Weekday) AccessRule {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	return func(_ Session, _ *http.Request) bool {
+		return inHours(time.Now().In(loc), from, to, days)
+	}
+}
+
+// inHours checks whether the time is inside the daily time window on
+// one of the provided weekdays (any, if none are provided) or not.
+func inHours(now time.Time, from, to time.Duration, days []time.Weekday) bool {
+	y, mo, d := now.Date()
+	offset := now.Sub(time.Date(y, mo, d, 0, 0, 0, 0, now.Location()))
+	day := now.Weekday()
+
+	switch {
+	case from <= to:
+		if offset < from || offset >= to {
+			return false
+		}
+	case offset < to:
+		// the window started on the previous day.
+		day = (day + 6) % 7
+	case offset < from:
+		return false
+	}
+
+	if len(days) == 0 {
+		return true
+	}
+
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkAccess checks the session against its access rules.
+func (m *Manager) checkAccess(r *http.Request, s Session) error {
+	if m.access == nil {
+		return nil
+	}
+
+	for _, rule := range m.access(s) {
+		if !rule(s, r) {
+			return ErrAccessDenied
+		}
+	}
+
+	return nil
+}
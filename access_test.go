@@ -0,0 +1,207 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAllowNetworks(t *testing.T) {
+	if _, err := AllowNetworks("10.0.0.0/8", "invalid"); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+
+	rule, err := AllowNetworks("10.0.0.0/8", "2001:db8::/32")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	cc := map[string]struct {
+		Addr   string
+		XFF    string
+		Result bool
+	}{
+		"IPv4 inside":  {Addr: "10.1.2.3:1234", Result: true},
+		"IPv4 outside": {Addr: "192.168.1.1:1234"},
+		"IPv6 inside":  {Addr: "[2001:db8::1]:1234", Result: true},
+		"IPv6 outside": {Addr: "[2001:db9::1]:1234"},
+		"Invalid":      {Addr: "invalid"},
+		"Spoofed":      {Addr: "192.168.1.1:1234", XFF: "10.1.2.3"},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = c.Addr
+			if c.XFF != "" {
+				req.Header.Set("X-Forwarded-For", c.XFF)
+			}
+
+			if res := rule(Session{}, req); res != c.Result {
+				t.Errorf("want %v, got %v", c.Result, res)
+			}
+		})
+	}
+}
+
+func TestAllowNetworksBehind(t *testing.T) {
+	if _, err := AllowNetworksBehind([]string{"invalid"}, "10.0.0.0/8"); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+
+	rule, err := AllowNetworksBehind([]string{"192.168.0.0/16"}, "10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	cc := map[string]struct {
+		Addr   string
+		XFF    []string
+		Result bool
+	}{
+		"Direct inside":           {Addr: "10.1.2.3:1234", Result: true},
+		"Direct spoofed":          {Addr: "172.16.0.1:1234", XFF: []string{"10.1.2.3"}},
+		"Proxied inside":          {Addr: "192.168.1.1:1234", XFF: []string{"10.1.2.3"}, Result: true},
+		"Proxied outside":         {Addr: "192.168.1.1:1234", XFF: []string{"172.16.0.1"}},
+		"Proxied spoofed":         {Addr: "192.168.1.1:1234", XFF: []string{"10.1.2.3, 172.16.0.1"}},
+		"Proxied through proxies": {Addr: "192.168.1.1:1234", XFF: []string{"172.16.0.1, 10.1.2.3", "192.168.1.2"}, Result: true},
+		"Proxied invalid":         {Addr: "192.168.1.1:1234", XFF: []string{"invalid"}},
+		"Proxy itself":            {Addr: "192.168.1.1:1234"},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = c.Addr
+			req.Header["X-Forwarded-For"] = c.XFF
+			if res := rule(Session{}, req); res != c.Result {
+				t.Errorf("want %v, got %v", c.Result, res)
+			}
+		})
+	}
+}
+
+func TestAllowHours(t *testing.T) {
+	now := time.Now().UTC()
+	y, mo, d := now.Date()
+	offset := now.Sub(time.Date(y, mo, d, 0, 0, 0, 0, time.UTC))
+	other := (now.Weekday() + 1) % 7
+
+	// windows are kept away from midnight so that the test does not
+	// depend on the time it is run at.
+	shift := func(d time.Duration) time.Duration {
+		return (offset + d + time.Hour*24) % (time.Hour * 24)
+	}
+
+	cc := map[string]struct {
+		From   time.Duration
+		To     time.Duration
+		Days   []time.Weekday
+		Result bool
+	}{
+		"Inside":            {From: shift(-time.Minute), To: shift(time.Minute), Result: true},
+		"Outside":           {From: shift(time.Minute), To: shift(time.Minute * 2)},
+		"Spanning midnight": {From: shift(time.Minute), To: shift(-time.Minute)},
+		"Spanning inside":   {From: shift(-time.Minute), To: shift(-time.Minute * 2), Result: true},
+		"Allowed day":       {From: shift(-time.Minute), To: shift(time.Minute), Days: []time.Weekday{other, now.Weekday()}, Result: true},
+		"Other day":         {From: shift(-time.Minute), To: shift(time.Minute), Days: []time.Weekday{other}},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			if offset < time.Minute*5 || offset > time.Hour*24-time.Minute*5 {
+				t.Skip("too close to midnight")
+			}
+
+			rule := AllowHours(nil, c.From, c.To, c.Days...)
+			if res := rule(Session{}, httptest.NewRequest("GET", "/", nil)); res != c.Result {
+				t.Errorf("want %v, got %v", c.Result, res)
+			}
+		})
+	}
+}
+
+func TestInHours(t *testing.T) {
+	// 2021-01-01 is a Friday.
+	at := func(d time.Weekday, h int) time.Time {
+		return time.Date(2021, 1, 1+int(d-time.Friday+7)%7, h, 30, 0, 0, time.UTC)
+	}
+
+	fri := []time.Weekday{time.Friday}
+
+	cc := map[string]struct {
+		Now    time.Time
+		From   time.Duration
+		To     time.Duration
+		Days   []time.Weekday
+		Result bool
+	}{
+		"Inside":                      {Now: at(time.Friday, 10), From: time.Hour * 9, To: time.Hour * 17, Result: true},
+		"Before start":                {Now: at(time.Friday, 8), From: time.Hour * 9, To: time.Hour * 17},
+		"After end":                   {Now: at(time.Friday, 17), From: time.Hour * 9, To: time.Hour * 17},
+		"Allowed day":                 {Now: at(time.Friday, 10), From: time.Hour * 9, To: time.Hour * 17, Days: fri, Result: true},
+		"Other day":                   {Now: at(time.Saturday, 10), From: time.Hour * 9, To: time.Hour * 17, Days: fri},
+		"Spanning midnight before it": {Now: at(time.Friday, 23), From: time.Hour * 22, To: time.Hour * 2, Result: true},
+		"Spanning midnight after it":  {Now: at(time.Saturday, 1), From: time.Hour * 22, To: time.Hour * 2, Result: true},
+		"Spanning midnight outside":   {Now: at(time.Friday, 12), From: time.Hour * 22, To: time.Hour * 2},
+		"Allowed day before midnight": {Now: at(time.Friday, 23), From: time.Hour * 22, To: time.Hour * 2, Days: fri, Result: true},
+		"Allowed day after midnight":  {Now: at(time.Saturday, 1), From: time.Hour * 22, To: time.Hour * 2, Days: fri, Result: true},
+		"Previous day after midnight": {Now: at(time.Friday, 1), From: time.Hour * 22, To: time.Hour * 2, Days: fri},
+		"Next day before midnight":    {Now: at(time.Saturday, 23), From: time.Hour * 22, To: time.Hour * 2, Days: fri},
+		"Saturday window on Sunday":   {Now: at(time.Sunday, 1), From: time.Hour * 22, To: time.Hour * 2, Days: []time.Weekday{time.Saturday}, Result: true},
+		"Sunday window on Monday":     {Now: at(time.Monday, 1), From: time.Hour * 22, To: time.Hour * 2, Days: []time.Weekday{time.Sunday}, Result: true},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			if res := inHours(c.Now, c.From, c.To, c.Days); res != c.Result {
+				t.Errorf("want %v, got %v", c.Result, res)
+			}
+		})
+	}
+}
+
+func TestAccessPolicy(t *testing.T) {
+	store := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id, UserKey: id}, true, nil
+		},
+	}
+
+	deny := func(_ Session, _ *http.Request) bool { return false }
+	allow := func(_ Session, _ *http.Request) bool { return true }
+
+	m := NewManager(store, Validate(false), AccessPolicy(func(s Session) []AccessRule {
+		if s.UserKey == "contractor" {
+			return []AccessRule{allow, deny}
+		}
+
+		return nil
+	}))
+
+	for key, want := range map[string]error{"employee": nil, "contractor": ErrAccessDenied} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: defaultName, Value: key})
+		if _, err := m.authenticate(httptest.NewRecorder(), req, nil); err != want {
+			t.Errorf("%s: want %v, got %v", key, want, err)
+		}
+	}
+}
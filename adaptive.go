@@ -0,0 +1,116 @@
+package sessionup
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// activityMetaKey holds the metadata key of the activity history
+	// used by AdaptiveTTL.
+	activityMetaKey = "sessionup_activity"
+
+	defaultActivityHalfLife = time.Hour * 24 * 7
+	defaultActivityPivot    = 10
+)
+
+// AdaptiveConfig holds the data needed to compute adaptive renewal
+// TTLs (see AdaptiveTTL).
+type AdaptiveConfig struct {
+	// Min specifies the TTL of renewals of sessions without any
+	// activity history.
+	Min time.Duration
+
+	// Max specifies the TTL that renewals of the most active sessions
+	// approach.
+	Max time.Duration
+
+	// HalfLife specifies the period of time after which the weight of
+	// past renewals is halved.
+	// Defaults to the value stored in defaultActivityHalfLife.
+	HalfLife time.Duration
+
+	// Pivot specifies the (decayed) number of renewals at which the
+	// renewal TTL is halfway between Min and Max.
+	// Defaults to the value stored in defaultActivityPivot.
+	Pivot float64
+}
+
+// AdaptiveTTL makes session renewals (see HeartbeatHandler) depend on
+// how actively sessions are used: every renewal is recorded in the
+// session's activity history (kept in its metadata) as a score that
+// decays over time, and the renewal TTL grows with the score from Min
+// towards Max. Frequently used sessions therefore live longer, while
+// abandoned ones expire soon, keeping the store small.
+// The TTLs of new sessions are not affected; temporary sessions are
+// never renewed.
+// By default it is not set and sessions are renewed with the same TTL
+// they were created with.
+func AdaptiveTTL(cfg AdaptiveConfig) Option {
+	if cfg.HalfLife <= 0 {
+		cfg.HalfLife = defaultActivityHalfLife
+	}
+
+	if cfg.Pivot <= 0 {
+		cfg.Pivot = defaultActivityPivot
+	}
+
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+
+	return func(m *Manager) {
+		m.adaptive = &cfg
+	}
+}
+
+// renewal returns the TTL with which the session should be renewed at
+// the provided point in time and, with adaptive TTLs, the session's
+// metadata that records the renewal. Zero TTL means that the session
+// must not be renewed.
+func (m *Manager) renewal(s Session, now time.Time) (time.Duration, map[string]string) {
+	ttl := m.storeTTL(s.UserKey)
+	if ttl == 0 || m.adaptive == nil {
+		return ttl, s.Meta
+	}
+
+	cfg := m.adaptive
+	score := activityScore(s.Meta[activityMetaKey], now, cfg.HalfLife) + 1
+
+	meta := make(map[string]string, len(s.Meta)+1)
+	for k, v := range s.Meta {
+		meta[k] = v
+	}
+
+	meta[activityMetaKey] = strconv.FormatFloat(score, 'f', 3, 64) + ":" + strconv.FormatInt(now.Unix(), 10)
+
+	return cfg.Min + time.Duration(float64(cfg.Max-cfg.Min)*score/(score+cfg.Pivot)), meta
+}
+
+// activityScore parses the activity history and decays its score to
+// the provided point in time. Malformed histories have zero score.
+func activityScore(v string, now time.Time, halfLife time.Duration) float64 {
+	i := strings.IndexByte(v, ':')
+	if i < 0 {
+		return 0
+	}
+
+	score, err := strconv.ParseFloat(v[:i], 64)
+	if err != nil || score < 0 || math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0
+	}
+
+	sec, err := strconv.ParseInt(v[i+1:], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	elapsed := now.Sub(time.Unix(sec, 0))
+	if elapsed <= 0 {
+		return score
+	}
+
+	return score * math.Pow(0.5, float64(elapsed)/float64(halfLife))
+}
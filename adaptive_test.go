@@ -0,0 +1,143 @@
+package sessionup
+
+import (
+	"context"
+	"math"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTTL(t *testing.T) {
+	m := Manager{}
+	AdaptiveTTL(AdaptiveConfig{Min: time.Hour, Max: time.Minute})(&m)
+	if m.adaptive == nil {
+		t.Fatal("want non-nil, got nil")
+	}
+
+	if m.adaptive.Max != time.Hour {
+		t.Errorf("want %v, got %v", time.Hour, m.adaptive.Max)
+	}
+
+	if m.adaptive.HalfLife != defaultActivityHalfLife {
+		t.Errorf("want %v, got %v", defaultActivityHalfLife, m.adaptive.HalfLife)
+	}
+
+	if m.adaptive.Pivot != defaultActivityPivot {
+		t.Errorf("want %v, got %v", defaultActivityPivot, m.adaptive.Pivot)
+	}
+}
+
+func TestRenewal(t *testing.T) {
+	now := time.Now()
+	cfg := AdaptiveConfig{Min: time.Hour, Max: time.Hour * 11, HalfLife: time.Hour, Pivot: 1}
+	history := func(score float64, at time.Time) map[string]string {
+		return map[string]string{
+			"key":           "value",
+			activityMetaKey: strconv.FormatFloat(score, 'f', 3, 64) + ":" + strconv.FormatInt(at.Unix(), 10),
+		}
+	}
+
+	cc := map[string]struct {
+		ExpiresIn time.Duration
+		Adaptive  bool
+		Meta      map[string]string
+		TTL       time.Duration
+		Score     float64
+	}{
+		"Temporary session": {
+			Adaptive: true,
+		},
+		"Fixed TTL": {
+			ExpiresIn: time.Hour * 2,
+			TTL:       time.Hour * 2,
+		},
+		"No history": {
+			ExpiresIn: time.Hour * 2,
+			Adaptive:  true,
+			TTL:       time.Hour * 6,
+			Score:     1,
+		},
+		"Malformed history": {
+			ExpiresIn: time.Hour * 2,
+			Adaptive:  true,
+			Meta:      map[string]string{activityMetaKey: "NaN:1"},
+			TTL:       time.Hour * 6,
+			Score:     1,
+		},
+		"Active session": {
+			ExpiresIn: time.Hour * 2,
+			Adaptive:  true,
+			Meta:      history(3, now),
+			TTL:       time.Hour * 9,
+			Score:     4,
+		},
+		"Decayed history": {
+			ExpiresIn: time.Hour * 2,
+			Adaptive:  true,
+			Meta:      history(6, now.Add(-time.Hour)),
+			TTL:       time.Hour * 9,
+			Score:     4,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m := Manager{expiresIn: c.ExpiresIn}
+			if c.Adaptive {
+				AdaptiveTTL(cfg)(&m)
+			}
+
+			ttl, meta := m.renewal(Session{Meta: c.Meta}, now)
+			// histories store whole seconds, so they decay a bit.
+			if d := ttl - c.TTL; d > time.Minute || d < -time.Minute {
+				t.Errorf("want %v, got %v", c.TTL, ttl)
+			}
+
+			if c.Score == 0 {
+				if meta[activityMetaKey] != c.Meta[activityMetaKey] {
+					t.Errorf("want unchanged history, got %q", meta[activityMetaKey])
+				}
+
+				return
+			}
+
+			if score := activityScore(meta[activityMetaKey], now, cfg.HalfLife); math.Abs(score-c.Score) > 0.01 {
+				t.Errorf("want %v, got %v", c.Score, score)
+			}
+
+			if c.Meta != nil && c.Meta["key"] != meta["key"] {
+				t.Errorf("want %q, got %q", c.Meta["key"], meta["key"])
+			}
+		})
+	}
+}
+
+func TestHeartbeatHandlerAdaptive(t *testing.T) {
+	var stored Session
+	store := &updateStoreMock{
+		StoreMock: &StoreMock{},
+		UpdateFunc: func(_ context.Context, s Session) error {
+			stored = s
+			return nil
+		},
+	}
+
+	m := NewManager(store, ExpiresIn(time.Hour), AdaptiveTTL(AdaptiveConfig{Min: time.Hour * 2, Max: time.Hour * 4, Pivot: 1}))
+
+	ctx := NewContext(context.Background(), Session{ID: "id", ExpiresAt: time.Now().Add(time.Minute)})
+	m.HeartbeatHandler(time.Minute).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	if d := time.Until(stored.ExpiresAt); d < time.Hour*3-time.Minute || d > time.Hour*3 {
+		t.Errorf("want %v, got %v", time.Hour*3, d)
+	}
+
+	if stored.Meta[activityMetaKey] == "" {
+		t.Error("want activity history, got none")
+	}
+}
@@ -0,0 +1,35 @@
+package sessionup
+
+import "net/http"
+
+// Affinity sets the routing hint (e.g. region or node name) that will
+// be stored in sessions created by this manager. Sticky routing layers
+// can use it to send subsequent requests of the session to the node
+// that holds warm caches for it.
+// By default it is not set.
+func Affinity(a string) Option {
+	return func(m *Manager) {
+		m.affinity.value = a
+	}
+}
+
+// AffinityHeader sets the name of the response header to which
+// the session's affinity will be written by Auth and Public
+// middlewares.
+// By default it is not set and the header is omitted.
+func AffinityHeader(h string) Option {
+	return func(m *Manager) {
+		m.affinity.header = h
+	}
+}
+
+// SetAffinityHeader writes the session's affinity to the response
+// header with the provided name.
+// Function will be no-op, if session's affinity is not set.
+func SetAffinityHeader(w http.ResponseWriter, s Session, name string) {
+	if s.Affinity == "" {
+		return
+	}
+
+	w.Header().Set(name, s.Affinity)
+}
@@ -0,0 +1,70 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAffinity(t *testing.T) {
+	m := Manager{}
+	val := "eu-1"
+	Affinity(val)(&m)
+	if m.affinity.value != val {
+		t.Errorf("want %q, got %q", val, m.affinity.value)
+	}
+}
+
+func TestAffinityHeader(t *testing.T) {
+	m := Manager{}
+	val := "X-Affinity"
+	AffinityHeader(val)(&m)
+	if m.affinity.header != val {
+		t.Errorf("want %q, got %q", val, m.affinity.header)
+	}
+}
+
+func TestSetAffinityHeader(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SetAffinityHeader(rec, Session{}, "X-Affinity")
+	if _, ok := rec.Header()["X-Affinity"]; ok {
+		t.Error("want no header, got one")
+	}
+
+	SetAffinityHeader(rec, Session{Affinity: "eu-1"}, "X-Affinity")
+	if v := rec.Header().Get("X-Affinity"); v != "eu-1" {
+		t.Errorf("want %q, got %q", "eu-1", v)
+	}
+}
+
+func TestAffinityAuth(t *testing.T) {
+	var stored Session
+	store := &StoreMock{
+		CreateFunc: func(_ context.Context, s Session) error {
+			stored = s
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+			return stored, true, nil
+		},
+	}
+
+	m := NewManager(store, Affinity("eu-1"), AffinityHeader("X-Affinity"))
+	rec := httptest.NewRecorder()
+	if err := m.Init(rec, httptest.NewRequest("GET", "http://example.com/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if stored.Affinity != "eu-1" {
+		t.Errorf("want %q, got %q", "eu-1", stored.Affinity)
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.AddCookie(rec.Result().Cookies()[0])
+	rec = httptest.NewRecorder()
+	m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(rec, req)
+	if v := rec.Header().Get("X-Affinity"); v != "eu-1" {
+		t.Errorf("want %q, got %q", "eu-1", v)
+	}
+}
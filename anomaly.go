@@ -0,0 +1,161 @@
+package sessionup
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultBurstWindow = time.Minute * 10
+	defaultBurstLimit  = 5
+)
+
+// AnomalyConfig holds the data needed to compute login anomaly signals.
+type AnomalyConfig struct {
+	// BurstWindow specifies the period of time in which sessions are
+	// counted towards the burst limit.
+	// Defaults to the value stored in defaultBurstWindow.
+	BurstWindow time.Duration
+
+	// BurstLimit specifies the number of sessions created during
+	// the burst window (including the new one) that is considered
+	// a burst.
+	// Defaults to the value stored in defaultBurstLimit.
+	BurstLimit int
+
+	// Country specifies the function used to resolve the country of
+	// an IP address. If not set, the NewCountry signal is never
+	// raised.
+	Country func(ip net.IP) string
+}
+
+// Anomaly holds the signals computed by comparing the new session with
+// the user's existing sessions.
+type Anomaly struct {
+	// NewIP specifies whether the new session's IP address differs
+	// from the addresses of all existing sessions or not.
+	NewIP bool
+
+	// NewCountry specifies whether the new session's country differs
+	// from the countries of all existing sessions or not.
+	NewCountry bool
+
+	// NewAgent specifies whether the new session's OS and browser
+	// combination differs from those of all existing sessions or not.
+	NewAgent bool
+
+	// Burst specifies whether too many sessions were created during
+	// the burst window or not.
+	Burst bool
+
+	// Recent specifies the number of sessions (including the new one)
+	// created during the burst window.
+	Recent int
+}
+
+// Any checks whether at least one signal was raised or not.
+func (a Anomaly) Any() bool {
+	return a.NewIP || a.NewCountry || a.NewAgent || a.Burst
+}
+
+// OnAnomaly sets the function which will be called during Init, before
+// the new session is stored, when at least one anomaly signal (see
+// Anomaly) is raised. The new session is passed to the function
+// redacted (see Redaction). Non-nil error returned by the function
+// cancels the session's creation and is returned by Init, e.g. to
+// require step-up authentication.
+// By default it is not set and the user's existing sessions are not
+// fetched during Init.
+func OnAnomaly(cfg AnomalyConfig, fn func(r *http.Request, s Session, a Anomaly) error) Option {
+	if cfg.BurstWindow <= 0 {
+		cfg.BurstWindow = defaultBurstWindow
+	}
+
+	if cfg.BurstLimit <= 0 {
+		cfg.BurstLimit = defaultBurstLimit
+	}
+
+	return func(m *Manager) {
+		m.anomaly.cfg = cfg
+		m.anomaly.fn = fn
+	}
+}
+
+// checkAnomaly computes the anomaly signals of the new session and
+// notifies the function set with OnAnomaly.
+func (m *Manager) checkAnomaly(r *http.Request, s Session) (Anomaly, error) {
+	if m.anomaly.fn == nil {
+		return Anomaly{}, nil
+	}
+
+	ss, err := m.store.FetchByUserKey(r.Context(), s.UserKey)
+	if err != nil {
+		return Anomaly{}, err
+	}
+
+	cfg := m.anomaly.cfg
+	c := m.Config()
+	cfg.BurstWindow, cfg.BurstLimit = c.BurstWindow, c.BurstLimit
+
+	a := detectAnomaly(cfg, s, ss)
+	if !a.Any() {
+		return a, nil
+	}
+
+	if err = m.anomaly.fn(r, m.Redacted(s), a); err != nil {
+		m.notify(s.UserKey, Event{Type: EventAnomaly, Session: s, Anomaly: a})
+		return a, err
+	}
+
+	return a, nil
+}
+
+// detectAnomaly compares the new session with the existing ones.
+func detectAnomaly(cfg AnomalyConfig, s Session, ss []Session) Anomaly {
+	a := Anomaly{Recent: 1}
+
+	var (
+		known   int
+		ip      bool
+		agent   bool
+		country bool
+		cn      string
+	)
+
+	if cfg.Country != nil && len(s.IP) != 0 {
+		cn = cfg.Country(s.IP)
+	}
+
+	for _, es := range ss {
+		if es.Kind != "" {
+			continue
+		}
+
+		known++
+		if s.CreatedAt.Sub(es.CreatedAt) <= cfg.BurstWindow {
+			a.Recent++
+		}
+
+		if len(s.IP) == 0 || s.IP.Equal(es.IP) {
+			ip = true
+		}
+
+		if es.Agent == s.Agent {
+			agent = true
+		}
+
+		if cn == "" || (len(es.IP) != 0 && cfg.Country(es.IP) == cn) {
+			country = true
+		}
+	}
+
+	if known > 0 {
+		a.NewIP = !ip
+		a.NewAgent = !agent
+		a.NewCountry = !country
+	}
+
+	a.Burst = a.Recent >= cfg.BurstLimit
+	return a
+}
@@ -0,0 +1,157 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOnAnomaly(t *testing.T) {
+	m := Manager{}
+	OnAnomaly(AnomalyConfig{}, func(_ *http.Request, _ Session, _ Anomaly) error { return nil })(&m)
+	if m.anomaly.fn == nil {
+		t.Error("want non-nil, got nil")
+	}
+
+	if m.anomaly.cfg.BurstWindow != defaultBurstWindow {
+		t.Errorf("want %v, got %v", defaultBurstWindow, m.anomaly.cfg.BurstWindow)
+	}
+
+	if m.anomaly.cfg.BurstLimit != defaultBurstLimit {
+		t.Errorf("want %d, got %d", defaultBurstLimit, m.anomaly.cfg.BurstLimit)
+	}
+}
+
+func TestDetectAnomaly(t *testing.T) {
+	now := time.Now()
+	country := func(ip net.IP) string {
+		if ip[0] == 10 {
+			return "LT"
+		}
+		return "US"
+	}
+
+	ses := func(ip string, os string, age time.Duration) Session {
+		s := Session{CreatedAt: now.Add(-age), IP: net.ParseIP(ip).To4()}
+		s.Agent.OS = os
+		return s
+	}
+
+	cc := map[string]struct {
+		Existing []Session
+		Anomaly  Anomaly
+	}{
+		"First session": {
+			Anomaly: Anomaly{Recent: 1},
+		},
+		"Known IP and agent": {
+			Existing: []Session{ses("10.0.0.1", "Linux", time.Hour)},
+			Anomaly:  Anomaly{Recent: 1},
+		},
+		"New IP in the same country": {
+			Existing: []Session{ses("10.0.0.2", "Linux", time.Hour)},
+			Anomaly:  Anomaly{NewIP: true, Recent: 1},
+		},
+		"New IP in another country with another agent": {
+			Existing: []Session{ses("8.8.8.8", "Windows", time.Hour)},
+			Anomaly:  Anomaly{NewIP: true, NewCountry: true, NewAgent: true, Recent: 1},
+		},
+		"Canaries are ignored": {
+			Existing: []Session{{Kind: KindCanary, CreatedAt: now}},
+			Anomaly:  Anomaly{Recent: 1},
+		},
+		"Burst of logins": {
+			Existing: []Session{
+				ses("10.0.0.1", "Linux", time.Minute),
+				ses("10.0.0.1", "Linux", time.Minute*2),
+				ses("10.0.0.1", "Linux", time.Hour),
+			},
+			Anomaly: Anomaly{Burst: true, Recent: 3},
+		},
+	}
+
+	cfg := AnomalyConfig{BurstWindow: time.Minute * 5, BurstLimit: 3, Country: country}
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			a := detectAnomaly(cfg, ses("10.0.0.1", "Linux", 0), c.Existing)
+			if a != c.Anomaly {
+				t.Errorf("want %+v, got %+v", c.Anomaly, a)
+			}
+		})
+	}
+}
+
+func TestInitAnomaly(t *testing.T) {
+	errFetch, errHook := errors.New("error"), errors.New("step-up")
+	cc := map[string]struct {
+		Existing []Session
+		FetchErr error
+		HookErr  error
+		Err      error
+		Called   bool
+		Created  bool
+	}{
+		"Error returned by store.FetchByUserKey": {
+			FetchErr: errFetch,
+			Err:      errFetch,
+		},
+		"No anomaly": {
+			Created: true,
+		},
+		"Anomaly accepted by hook": {
+			Existing: []Session{{IP: net.ParseIP("8.8.8.8")}},
+			Called:   true,
+			Created:  true,
+		},
+		"Anomaly rejected by hook": {
+			Existing: []Session{{IP: net.ParseIP("8.8.8.8")}},
+			HookErr:  errHook,
+			Err:      errHook,
+			Called:   true,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			s := &StoreMock{
+				FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+					return c.Existing, c.FetchErr
+				},
+				CreateFunc: func(_ context.Context, _ Session) error {
+					return nil
+				},
+			}
+
+			var called bool
+			m := NewManager(s, WithAgent(false), OnAnomaly(AnomalyConfig{}, func(_ *http.Request, _ Session, a Anomaly) error {
+				called = true
+				if !a.NewIP {
+					t.Error("want new IP signal, got none")
+				}
+				return c.HookErr
+			}))
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			err := m.Init(httptest.NewRecorder(), req, "key")
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if called != c.Called {
+				t.Errorf("want %t, got %t", c.Called, called)
+			}
+
+			if created := len(s.CreateCalls()) == 1; created != c.Created {
+				t.Errorf("want %t, got %t", c.Created, created)
+			}
+		})
+	}
+}
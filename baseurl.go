@@ -0,0 +1,41 @@
+package sessionup
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// BaseURL sets cookie's Domain, Path and Secure attributes from the URL
+// the application is served at, so that the cookie scope and the
+// serving origin can't disagree. Domain is set to the URL's host name
+// (unless it is an IP address or a single-label name, e.g. localhost,
+// which browsers don't accept as cookie domains), Path to the URL's
+// path ("/" if empty) and Secure to whether the scheme is https.
+// Options applied after it override the derived attributes.
+// Invalid or relative URLs are ignored (and reported by New).
+// By default it is not set.
+func BaseURL(rawurl string) Option {
+	return func(m *Manager) {
+		u, err := url.Parse(rawurl)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			m.invalid("BaseURL", ErrInvalidURL)
+			return
+		}
+
+		host := u.Hostname()
+		if net.ParseIP(host) == nil && strings.Contains(host, ".") {
+			m.cookie.domain = host
+		} else {
+			m.cookie.domain = ""
+		}
+
+		m.cookie.path = "/"
+		if u.Path != "" {
+			m.cookie.path = path.Clean("/" + u.Path)
+		}
+
+		m.cookie.secure = strings.EqualFold(u.Scheme, "https")
+	}
+}
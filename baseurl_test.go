@@ -0,0 +1,67 @@
+package sessionup
+
+import "testing"
+
+func TestBaseURL(t *testing.T) {
+	cc := map[string]struct {
+		URL    string
+		Domain string
+		Path   string
+		Secure bool
+	}{
+		"Invalid URL": {
+			URL:    "://",
+			Domain: "old.example.com",
+			Path:   "/old",
+		},
+		"Relative URL": {
+			URL:    "/app",
+			Domain: "old.example.com",
+			Path:   "/old",
+		},
+		"HTTPS URL": {
+			URL:    "https://app.example.com",
+			Domain: "app.example.com",
+			Path:   "/",
+			Secure: true,
+		},
+		"URL with path and port": {
+			URL:    "HTTPS://app.example.com:8443/admin/",
+			Domain: "app.example.com",
+			Path:   "/admin",
+			Secure: true,
+		},
+		"HTTP localhost URL": {
+			URL:  "http://localhost:8080",
+			Path: "/",
+		},
+		"IP URL": {
+			URL:  "http://127.0.0.1/app",
+			Path: "/app",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m := Manager{}
+			m.cookie.domain = "old.example.com"
+			m.cookie.path = "/old"
+			BaseURL(c.URL)(&m)
+
+			if m.cookie.domain != c.Domain {
+				t.Errorf("want %q, got %q", c.Domain, m.cookie.domain)
+			}
+
+			if m.cookie.path != c.Path {
+				t.Errorf("want %q, got %q", c.Path, m.cookie.path)
+			}
+
+			if m.cookie.secure != c.Secure {
+				t.Errorf("want %t, got %t", c.Secure, m.cookie.secure)
+			}
+		})
+	}
+}
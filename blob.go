@@ -0,0 +1,149 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+)
+
+// BlobThreshold sets the maximum length of a metadata value that is
+// kept inline in the session record. Longer values are moved into
+// separate records, if the store implements BlobStore interface,
+// and can be retrieved with the FetchMeta method.
+// Setting it to 0 keeps all values inline.
+// By default it is not set.
+func BlobThreshold(n int) Option {
+	return func(m *Manager) {
+		if n < 0 {
+			m.invalid("BlobThreshold", ErrNegative)
+		}
+
+		m.blobThreshold = n
+	}
+}
+
+// FetchMeta retrieves the metadata value, associated with the provided
+// key, of the session stored in the context. The value is read either
+// directly from the session or, if it was moved into a separate record,
+// from the BlobStore.
+// The second returned value indicates whether the value was found
+// or not (true == found).
+func (m *Manager) FetchMeta(ctx context.Context, key string) (string, bool, error) {
+	if ctx == nil {
+		return "", false, ErrNilContext
+	}
+
+	s, ok := FromContext(ctx)
+	if !ok {
+		return "", false, nil
+	}
+
+	if v, ok := s.Meta[key]; ok {
+		return v, true, nil
+	}
+
+	ref, ok := s.Blobs[key]
+	if !ok {
+		return "", false, nil
+	}
+
+	bs, ok := m.store.(BlobStore)
+	if !ok {
+		return "", false, ErrNotSupported
+	}
+
+	data, ok, err := bs.FetchBlob(ctx, ref)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	return string(data), true, nil
+}
+
+// blobsEnabled reports whether sessions may reference blobs, i.e.
+// whether the threshold is set and the store supports blobs.
+func (m *Manager) blobsEnabled() bool {
+	if m.blobThreshold <= 0 {
+		return false
+	}
+
+	_, ok := m.store.(BlobStore)
+	return ok
+}
+
+// createBlobs moves session's metadata values that exceed the
+// threshold into separate BlobStore records.
+// Function will be no-op, if threshold is not set or store does not
+// support blobs.
+func (m *Manager) createBlobs(ctx context.Context, s *Session) error {
+	if !m.blobsEnabled() {
+		return nil
+	}
+
+	bs := m.store.(BlobStore)
+
+	for k, v := range s.Meta {
+		if len(v) <= m.blobThreshold {
+			continue
+		}
+
+		ref := s.ID + ":" + k
+		err := bs.CreateBlob(ctx, ref, []byte(v), s.ExpiresAt)
+		if errors.Is(err, ErrNotSupported) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if s.Blobs == nil {
+			s.Blobs = make(map[string]string)
+		}
+
+		s.Blobs[k] = ref
+		delete(s.Meta, k)
+	}
+
+	return nil
+}
+
+// extendBlobs moves the expiration time of all BlobStore records
+// referenced by the session to the session's expiration time.
+func (m *Manager) extendBlobs(ctx context.Context, s Session) error {
+	if len(s.Blobs) == 0 {
+		return nil
+	}
+
+	bs, ok := m.store.(BlobStore)
+	if !ok {
+		return nil
+	}
+
+	for _, ref := range s.Blobs {
+		if err := ignoreNotFound(bs.ExtendBlob(ctx, ref, s.ExpiresAt)); err != nil && !errors.Is(err, ErrNotSupported) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteBlobs deletes all BlobStore records referenced by the session.
+func (m *Manager) deleteBlobs(ctx context.Context, s Session) error {
+	if len(s.Blobs) == 0 {
+		return nil
+	}
+
+	bs, ok := m.store.(BlobStore)
+	if !ok {
+		return nil
+	}
+
+	for _, ref := range s.Blobs {
+		if err := bs.DeleteBlob(ctx, ref); err != nil && !errors.Is(err, ErrNotSupported) {
+			return err
+		}
+	}
+
+	return nil
+}
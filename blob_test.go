@@ -0,0 +1,268 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// blobStoreMock is a Store with BlobStore capability, backed by
+// StoreMock and a simple map. Blobs expire only if exps map, which
+// records their expiration times, is set.
+type blobStoreMock struct {
+	*StoreMock
+	blobs map[string][]byte
+	exps  map[string]time.Time
+	err   error
+}
+
+func (b *blobStoreMock) CreateBlob(_ context.Context, ref string, data []byte, exp time.Time) error {
+	if b.err != nil {
+		return b.err
+	}
+
+	b.blobs[ref] = data
+	if b.exps != nil {
+		b.exps[ref] = exp
+	}
+
+	return nil
+}
+
+func (b *blobStoreMock) FetchBlob(_ context.Context, ref string) ([]byte, bool, error) {
+	data, ok := b.blobs[ref]
+	if exp, set := b.exps[ref]; set && !exp.After(time.Now()) {
+		data, ok = nil, false
+	}
+
+	return data, ok, b.err
+}
+
+func (b *blobStoreMock) DeleteBlob(_ context.Context, ref string) error {
+	delete(b.blobs, ref)
+	return b.err
+}
+
+func (b *blobStoreMock) ExtendBlob(_ context.Context, ref string, exp time.Time) error {
+	if _, ok := b.exps[ref]; ok {
+		b.exps[ref] = exp
+	}
+
+	return b.err
+}
+
+func TestBlobThreshold(t *testing.T) {
+	m := Manager{}
+	val := 10
+	BlobThreshold(val)(&m)
+	if m.blobThreshold != val {
+		t.Errorf("want %d, got %d", val, m.blobThreshold)
+	}
+}
+
+func TestCreateBlobs(t *testing.T) {
+	ctx := context.Background()
+	meta := func() map[string]string {
+		return map[string]string{"small": "1", "large": "1234567890"}
+	}
+
+	cc := map[string]struct {
+		Store     Store
+		Threshold int
+		Err       bool
+		Meta      map[string]string
+		Blobs     map[string]string
+	}{
+		"Threshold not set": {
+			Store: &blobStoreMock{StoreMock: &StoreMock{}, blobs: map[string][]byte{}},
+			Meta:  meta(),
+		},
+		"Store without blob support": {
+			Store:     &StoreMock{},
+			Threshold: 5,
+			Meta:      meta(),
+		},
+		"Blobs not supported by wrapped store": {
+			Store:     prefixStore{Store: &StoreMock{}, prefix: "p"},
+			Threshold: 5,
+			Meta:      meta(),
+		},
+		"Error returned by store.CreateBlob": {
+			Store:     &blobStoreMock{StoreMock: &StoreMock{}, err: errors.New("error")},
+			Threshold: 5,
+			Err:       true,
+			Meta:      meta(),
+		},
+		"Successful blobs creation": {
+			Store:     &blobStoreMock{StoreMock: &StoreMock{}, blobs: map[string][]byte{}},
+			Threshold: 5,
+			Meta:      map[string]string{"small": "1"},
+			Blobs:     map[string]string{"large": "id:large"},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := Manager{store: c.Store, blobThreshold: c.Threshold}
+			s := Session{ID: "id", Meta: meta()}
+			err := m.createBlobs(ctx, &s)
+			if c.Err {
+				if err == nil {
+					t.Error("want non-nil, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if !reflect.DeepEqual(c.Meta, s.Meta) {
+				t.Errorf("want %v, got %v", c.Meta, s.Meta)
+			}
+
+			if !reflect.DeepEqual(c.Blobs, s.Blobs) {
+				t.Errorf("want %v, got %v", c.Blobs, s.Blobs)
+			}
+		})
+	}
+}
+
+func TestFetchMeta(t *testing.T) {
+	bs := &blobStoreMock{StoreMock: &StoreMock{}, blobs: map[string][]byte{"ref": []byte("large")}}
+	s := Session{
+		Meta:  map[string]string{"small": "1"},
+		Blobs: map[string]string{"large": "ref", "missing": "ref1"},
+	}
+	ctx := NewContext(context.Background(), s)
+
+	cc := map[string]struct {
+		Store Store
+		Ctx   context.Context
+		Key   string
+		Value string
+		OK    bool
+		Err   bool
+	}{
+		"No session in the context": {
+			Store: bs,
+			Ctx:   context.Background(),
+			Key:   "small",
+		},
+		"Inline value": {
+			Store: bs,
+			Ctx:   ctx,
+			Key:   "small",
+			Value: "1",
+			OK:    true,
+		},
+		"Unknown key": {
+			Store: bs,
+			Ctx:   ctx,
+			Key:   "unknown",
+		},
+		"Store without blob support": {
+			Store: &StoreMock{},
+			Ctx:   ctx,
+			Key:   "large",
+			Err:   true,
+		},
+		"Missing blob": {
+			Store: bs,
+			Ctx:   ctx,
+			Key:   "missing",
+		},
+		"Successful blob fetch": {
+			Store: bs,
+			Ctx:   ctx,
+			Key:   "large",
+			Value: "large",
+			OK:    true,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := Manager{store: c.Store}
+			v, ok, err := m.FetchMeta(c.Ctx, c.Key)
+			if c.Err && err == nil {
+				t.Error("want non-nil, got nil")
+			} else if !c.Err && err != nil {
+				t.Errorf("want nil, got %v", err)
+			}
+
+			if v != c.Value || ok != c.OK {
+				t.Errorf("want %q/%t, got %q/%t", c.Value, c.OK, v, ok)
+			}
+		})
+	}
+}
+
+func TestDeleteBlobs(t *testing.T) {
+	bs := &blobStoreMock{StoreMock: &StoreMock{}, blobs: map[string][]byte{"ref": nil, "ref1": nil}}
+	m := Manager{store: bs}
+	err := m.deleteBlobs(context.Background(), Session{Blobs: map[string]string{"large": "ref"}})
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(bs.blobs) != 1 {
+		t.Errorf("want %d, got %d", 1, len(bs.blobs))
+	}
+}
+
+func TestRevokeDeletesBlobs(t *testing.T) {
+	ctx := context.Background()
+	cc := map[string]func(m *Manager, s Session) error{
+		"RevokeByID": func(m *Manager, s Session) error {
+			return m.RevokeByID(ctx, s.ID)
+		},
+		"RevokeByIDExt": func(m *Manager, s Session) error {
+			return m.RevokeByIDExt(NewContext(ctx, Session{ID: "current", UserKey: s.UserKey}), s.ID)
+		},
+		"RevokeByUserKey": func(m *Manager, s Session) error {
+			return m.RevokeByUserKey(ctx, s.UserKey)
+		},
+		"RevokeOther": func(m *Manager, s Session) error {
+			return m.RevokeOther(NewContext(ctx, Session{ID: "current", UserKey: s.UserKey}))
+		},
+		"Failed fingerprint check": func(m *Manager, s Session) error {
+			RevokeOnFailure(nil)(m)
+			m.failed(httptest.NewRequest("GET", "/", nil), s)
+			return nil
+		},
+	}
+
+	for cn, fn := range cc {
+		fn := fn
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			s := Session{ID: "id", UserKey: "key", Blobs: map[string]string{"large": "id:large"}}
+			ms := mapStore()
+			ms.Create(ctx, s)
+			ms.FetchByUserKeyFunc = func(_ context.Context, _ string) ([]Session, error) {
+				return []Session{s}, nil
+			}
+			ms.DeleteByUserKeyFunc = func(_ context.Context, _ string, _ ...string) error {
+				return nil
+			}
+
+			bs := &blobStoreMock{StoreMock: ms, blobs: map[string][]byte{"id:large": nil}}
+			m := NewManager(bs, BlobThreshold(1))
+			if err := fn(m, s); err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if len(bs.blobs) != 0 {
+				t.Errorf("want %d, got %d", 0, len(bs.blobs))
+			}
+		})
+	}
+}
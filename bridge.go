@@ -0,0 +1,179 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// KindBridge marks header tokens created by BridgeHandler.
+	KindBridge = "bridge"
+
+	// BridgeField is the name of the form field from which
+	// RequireBridge reads the token posted by the form of
+	// BridgeHandler.
+	BridgeField = "bridge_token"
+
+	defaultBridgeTTL = time.Minute * 5
+
+	// bridgeOriginMeta is the metadata key under which the origin of
+	// the API is stored in bridge tokens.
+	bridgeOriginMeta = "sessionup_bridge_origin"
+)
+
+// BridgeConfig holds the data needed to exchange the session cookie for
+// header tokens.
+type BridgeConfig struct {
+	// Origin specifies the origin (scheme and host, e.g.
+	// "https://api.example.net") of the API the tokens are scoped to.
+	Origin string
+
+	// TTL specifies the lifetime of the tokens. It is capped by the
+	// session's expiration time.
+	// Defaults to the value stored in defaultBridgeTTL.
+	TTL time.Duration
+
+	// FormAction specifies the URL of the API endpoint to which the
+	// token is posted by the script-free HTML form (in the field
+	// named BridgeField). If it is not set, the token is returned as
+	// JSON object holding the token ('token') and its expiration
+	// time ('expires_at').
+	FormAction string
+}
+
+// bridgeForm is the HTML page that posts the token to the API. It uses
+// no scripts or inline styles, so it works with strict Content Security
+// Policies.
+var bridgeForm = template.Must(template.New("bridge").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="referrer" content="no-referrer"><title>Continue</title></head>
+<body>
+<form method="post" action="{{ .Action }}">
+<input type="hidden" name="{{ .Field }}" value="{{ .Token }}">
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// BridgeHandler produces a handler that exchanges the session cookie
+// for a short-lived token scoped to the API served at another domain,
+// so that single-page applications can call the API without the
+// session cookie losing its HttpOnly attribute. The token is sent to
+// the API in the 'Authorization' header (as a bearer token) or posted
+// by the script-free HTML form (see BridgeConfig.FormAction), and is
+// checked by RequireBridge.
+// Tokens are stored as separate records (of KindBridge) under the
+// session's user key; revoking the session invalidates its tokens.
+// The handler must be used inside Auth middleware.
+func (m *Manager) BridgeHandler(cfg BridgeConfig) http.Handler {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultBridgeTTL
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := FromContext(r.Context())
+		if !ok {
+			m.reject(ErrUnauthorized).ServeHTTP(w, r)
+			return
+		}
+
+		meta := map[string]string{bridgeOriginMeta: cfg.Origin}
+		s, err := m.createChild(r.Context(), p, KindBridge, cfg.TTL, meta)
+		if err != nil {
+			m.reject(err).ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		if cfg.FormAction != "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			bridgeForm.Execute(w, map[string]string{
+				"Action": cfg.FormAction,
+				"Field":  BridgeField,
+				"Token":  s.ID,
+			})
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":      s.ID,
+			"expires_at": s.ExpiresAt,
+		})
+	})
+}
+
+// RequireBridge produces a middleware that activates the wrapped
+// handler only if the request carries a valid token, produced by
+// BridgeHandler for the provided origin, in the 'Authorization' header
+// or, for POST requests, in the form field named BridgeField. The
+// token's session is added to the request's context. Otherwise the
+// manager's rejection function is called with ErrUnauthorized.
+// The API must use the same store as the application that issued the
+// token.
+func (m *Manager) RequireBridge(origin string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, err := m.redeemBridge(r.Context(), bridgeToken(r), origin)
+			if err != nil {
+				m.reject(err).ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), s)))
+		})
+	}
+}
+
+// bridgeToken extracts the bridge token from the request.
+func bridgeToken(r *http.Request) string {
+	const prefix = "Bearer "
+
+	if h := r.Header.Get("Authorization"); len(h) > len(prefix) && strings.EqualFold(h[:len(prefix)], prefix) {
+		return h[len(prefix):]
+	}
+
+	if r.Method == http.MethodPost {
+		return r.PostFormValue(BridgeField)
+	}
+
+	return ""
+}
+
+// redeemBridge checks the bridge token and returns the session it is
+// bound to.
+func (m *Manager) redeemBridge(ctx context.Context, token, origin string) (Session, error) {
+	if token == "" {
+		return Session{}, ErrUnauthorized
+	}
+
+	t, ok, err := m.store.FetchByID(ctx, token)
+	if err = m.storeErr(err); err != nil {
+		return Session{}, err
+	}
+
+	if !ok || t.Kind != KindBridge || t.ExpiresAt.Before(time.Now().Add(-m.Config().ClockSkew)) ||
+		!strings.EqualFold(strings.TrimSuffix(t.Meta[bridgeOriginMeta], "/"), strings.TrimSuffix(origin, "/")) {
+		return Session{}, ErrUnauthorized
+	}
+
+	s, ok, err := m.store.FetchByID(ctx, t.ParentID)
+	if err = m.storeErr(err); err != nil {
+		return Session{}, err
+	}
+
+	if !ok || s.Kind != "" || IsExpired(s, time.Now()) {
+		return Session{}, ErrUnauthorized
+	}
+
+	return s, nil
+}
@@ -0,0 +1,107 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBridge(t *testing.T) {
+	store := mapStore()
+	m := NewManager(store)
+
+	p := Session{ID: "parent", UserKey: "key", ExpiresAt: time.Now().Add(time.Hour)}
+	store.Create(context.Background(), p)
+
+	issue := func(cfg BridgeConfig, ctx context.Context) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/bridge", nil).WithContext(ctx)
+		m.BridgeHandler(cfg).ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	serve := func(origin string, req *http.Request) (Session, int) {
+		var s Session
+
+		rec := httptest.NewRecorder()
+		m.RequireBridge(origin)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			s, _ = FromContext(r.Context())
+		})).ServeHTTP(rec, req)
+
+		return s, rec.Code
+	}
+
+	header := func(tok string) *http.Request {
+		req := httptest.NewRequest("GET", "/api", nil)
+		req.Header.Set("Authorization", "Bearer "+tok)
+		return req
+	}
+
+	if rec := issue(BridgeConfig{}, context.Background()); rec.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	ctx := NewContext(context.Background(), p)
+	rec := issue(BridgeConfig{Origin: "https://api.example.net"}, ctx)
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var res struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if d := time.Until(res.ExpiresAt); d > defaultBridgeTTL || d < defaultBridgeTTL-time.Minute {
+		t.Errorf("want %v, got %v", defaultBridgeTTL, d)
+	}
+
+	if s, code := serve("https://api.example.net/", header(res.Token)); code != http.StatusOK || s.ID != p.ID {
+		t.Errorf("want %q, got %q (%d)", p.ID, s.ID, code)
+	}
+
+	if _, code := serve("https://other.example.net", header(res.Token)); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	if _, code := serve("https://api.example.net", header(p.ID)); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	if _, code := serve("https://api.example.net", httptest.NewRequest("GET", "/api", nil)); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	rec = issue(BridgeConfig{Origin: "https://api.example.net", FormAction: "https://api.example.net/login"}, ctx)
+	body := rec.Body.String()
+	if !strings.Contains(body, `action="https://api.example.net/login"`) || strings.Contains(body, "<script") {
+		t.Errorf("want script-free form, got %s", body)
+	}
+
+	match := regexp.MustCompile(`name="` + BridgeField + `" value="([^"]+)"`).FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("want token, got %s", body)
+	}
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(url.Values{BridgeField: {match[1]}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if s, code := serve("https://api.example.net", req); code != http.StatusOK || s.ID != p.ID {
+		t.Errorf("want %q, got %q (%d)", p.ID, s.ID, code)
+	}
+
+	store.DeleteByID(context.Background(), p.ID)
+	if _, code := serve("https://api.example.net", header(res.Token)); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+}
@@ -0,0 +1,65 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	// KindCanary marks decoy sessions created with PlantCanary.
+	KindCanary = "canary"
+
+	canaryTTL = time.Hour * 24 * 365
+)
+
+// OnCanary sets the function which will be called when a decoy session,
+// created with PlantCanary, is used in a request. The function receives
+// the request, the redacted (see Redaction) decoy session and the error
+// (if any) that occurred while revoking the user's real sessions.
+// By default it is not set.
+func OnCanary(fn func(r *http.Request, s Session, err error)) Option {
+	return func(m *Manager) {
+		m.onCanary = fn
+	}
+}
+
+// PlantCanary creates a decoy session under the provided user key and
+// returns its ID. The decoy is never listed by FetchAll, but if its ID
+// is ever used in a request (which indicates a compromised store or a
+// leaked cookie), all real sessions of the user are revoked, the
+// function set with OnCanary is called and the request is rejected.
+// Decoy sessions expire after a year.
+func (m *Manager) PlantCanary(ctx context.Context, key string) (string, error) {
+	if ctx == nil {
+		return "", ErrNilContext
+	}
+
+	s := Session{
+		CreatedAt: monotonicNow(),
+		ExpiresAt: time.Now().Add(canaryTTL),
+		ID:        m.genID(),
+		UserKey:   key,
+		Kind:      KindCanary,
+	}
+
+	if s.ID == "" {
+		return "", ErrGenID
+	}
+
+	if err := m.storeErr(m.store.Create(ctx, s)); err != nil {
+		return "", err
+	}
+
+	return s.ID, nil
+}
+
+// triggerCanary revokes all real sessions of the decoy session's user
+// and notifies the function set with OnCanary.
+func (m *Manager) triggerCanary(r *http.Request, s Session) {
+	err := m.revokeByUserKey(r.Context(), Revocation{UserKey: s.UserKey, ExceptID: s.ID})
+
+	if m.onCanary != nil {
+		m.onCanary(r, m.Redacted(s), err)
+	}
+}
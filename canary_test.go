@@ -0,0 +1,129 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOnCanary(t *testing.T) {
+	m := Manager{}
+	OnCanary(func(_ *http.Request, _ Session, _ error) {})(&m)
+	if m.onCanary == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestPlantCanary(t *testing.T) {
+	cc := map[string]struct {
+		GenID func() string
+		Err   error
+		ID    string
+	}{
+		"Error generating ID": {
+			GenID: func() string { return "" },
+			Err:   ErrGenID,
+		},
+		"Error returned by store.Create": {
+			GenID: func() string { return "id" },
+			Err:   errors.New("error"),
+		},
+		"Successful canary creation": {
+			GenID: func() string { return "id" },
+			ID:    "id",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			s := &StoreMock{
+				CreateFunc: func(_ context.Context, _ Session) error {
+					if c.Err == ErrGenID {
+						return nil
+					}
+					return c.Err
+				},
+			}
+
+			m := Manager{store: s, genID: c.GenID}
+			id, err := m.PlantCanary(context.Background(), "key")
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if id != c.ID {
+				t.Errorf("want %q, got %q", c.ID, id)
+			}
+
+			if c.Err == ErrGenID {
+				return
+			}
+
+			ses := s.CreateCalls()[0].S
+			if ses.Kind != KindCanary || ses.UserKey != "key" {
+				t.Errorf("want %q/%q, got %q/%q", KindCanary, "key", ses.Kind, ses.UserKey)
+			}
+		})
+	}
+}
+
+func TestCanaryAuth(t *testing.T) {
+	s := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id, UserKey: "key", Kind: KindCanary}, true, nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}
+
+	var triggered bool
+	m := NewManager(s, OnCanary(func(_ *http.Request, ses Session, err error) {
+		triggered = true
+		if ses.ID != "canary" || err != nil {
+			t.Errorf("want %q/nil, got %q/%v", "canary", ses.ID, err)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.AddCookie(&http.Cookie{Name: defaultName, Value: "canary"})
+	m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("canary session must not be accepted")
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	if !triggered {
+		t.Error("want triggered hook, got none")
+	}
+
+	ff := s.DeleteByUserKeyCalls()
+	if len(ff) != 1 || ff[0].Key != "key" || len(ff[0].ExpID) != 1 || ff[0].ExpID[0] != "canary" {
+		t.Errorf("want revocation of %q sessions except %q, got %v", "key", "canary", ff)
+	}
+}
+
+func TestFetchAllHidesCanaries(t *testing.T) {
+	s := &StoreMock{
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return []Session{{ID: "id"}, {ID: "canary", Kind: KindCanary}}, nil
+		},
+	}
+
+	m := Manager{store: s}
+	ss, err := m.FetchAll(NewContext(context.Background(), Session{ID: "id"}))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(ss) != 1 || ss[0].ID != "id" || !ss[0].Current {
+		t.Errorf("want only current %q, got %v", "id", ss)
+	}
+}
@@ -0,0 +1,37 @@
+package sessionup
+
+// StoreMiddleware decorates the provided store, e.g. with caching,
+// auditing, metrics, retries or a circuit breaker.
+// Middlewares should implement the optional Store capabilities (e.g.
+// UpdateStore or BlobStore) of the wrapped store that they want to
+// keep available to the manager; hidden capabilities are treated as
+// unsupported.
+type StoreMiddleware func(Store) Store
+
+// ChainStore wraps the provided store with all of the provided
+// middlewares. The first middleware is the outermost one: it receives
+// the calls first and passes them towards the store through the rest
+// of the chain, in the provided order. Nil middlewares are skipped.
+func ChainStore(s Store, mw ...StoreMiddleware) Store {
+	for i := len(mw) - 1; i >= 0; i-- {
+		if mw[i] != nil {
+			s = mw[i](s)
+		}
+	}
+
+	return s
+}
+
+// UseStore wraps the manager's store with the provided middlewares (see
+// ChainStore for their order), so that all decorators are configured in
+// a single place.
+// Calling it more than once (e.g. via Clone) places the new
+// middlewares outside of the already configured ones. Middlewares see
+// prefixed session IDs and user keys only if KeyPrefix is set after
+// this option; a prefix set before it can no longer be replaced.
+// By default it is not set.
+func UseStore(mw ...StoreMiddleware) Option {
+	return func(m *Manager) {
+		m.store = ChainStore(m.store, mw...)
+	}
+}
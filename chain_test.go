@@ -0,0 +1,53 @@
+package sessionup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type tracingStore struct {
+	Store
+	name  string
+	calls *[]string
+}
+
+func (t tracingStore) FetchByID(ctx context.Context, id string) (Session, bool, error) {
+	*t.calls = append(*t.calls, t.name+":"+id)
+	return t.Store.FetchByID(ctx, id)
+}
+
+func tracing(name string, calls *[]string) StoreMiddleware {
+	return func(s Store) Store {
+		return tracingStore{Store: s, name: name, calls: calls}
+	}
+}
+
+func TestChainStore(t *testing.T) {
+	ms := mapStore()
+	if s := ChainStore(ms); s != ms {
+		t.Errorf("want %v, got %v", ms, s)
+	}
+
+	var calls []string
+	s := ChainStore(ms, tracing("cache", &calls), nil, tracing("metrics", &calls))
+	s.FetchByID(context.Background(), "id")
+
+	want := []string{"cache:id", "metrics:id"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("want %v, got %v", want, calls)
+	}
+}
+
+func TestUseStore(t *testing.T) {
+	var calls []string
+	m := NewManager(mapStore(), UseStore(tracing("retry", &calls)), KeyPrefix("app:"))
+	m = m.Clone(UseStore(tracing("audit", &calls)))
+
+	m.store.FetchByID(context.Background(), "id")
+
+	want := []string{"audit:id", "retry:app:id"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("want %v, got %v", want, calls)
+	}
+}
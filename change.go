@@ -0,0 +1,66 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// ChangeHeader sets the name of the header used to notify clients about
+// session changes (e.g. updated metadata holding permissions). Auth and
+// Public middlewares write the session's version to the response header
+// with this name whenever it differs from the value the client sent in
+// the request header with the same name, so that SPAs know when to
+// refetch their profile without polling.
+// By default it is not set and the header is omitted.
+func ChangeHeader(h string) Option {
+	return func(m *Manager) {
+		m.changeHeader = h
+	}
+}
+
+// UpdateMeta applies the provided metadata changes to the session with
+// the provided ID and increments its version, which is reported to
+// clients via the ChangeHeader.
+// The store must implement either CASStore or UpdateStore interface,
+// otherwise ErrNotSupported is returned. ErrConflict is returned if the
+// session was modified concurrently.
+// Function will be no-op and return nil, if no session is found.
+func (m *Manager) UpdateMeta(ctx context.Context, id string, mm ...Meta) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	s, ok, err := m.store.FetchByID(ctx, id)
+	if err = m.storeErr(err); err != nil {
+		return err
+	}
+
+	if !ok {
+		return nil
+	}
+
+	meta := make(map[string]string, len(s.Meta)+len(mm))
+	for k, v := range s.Meta {
+		meta[k] = v
+	}
+
+	for _, apply := range mm {
+		apply(meta)
+	}
+
+	s.Meta = meta
+	_, err = m.update(ctx, s)
+	return ignoreNotFound(err)
+}
+
+// notifyChange writes the session's version to the change header, if
+// the client has not seen it yet.
+func (m *Manager) notifyChange(w http.ResponseWriter, r *http.Request, s Session) {
+	v := strconv.FormatUint(s.Version, 10)
+	if r.Header.Get(m.changeHeader) == v {
+		return
+	}
+
+	w.Header().Set(m.changeHeader, v)
+}
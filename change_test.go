@@ -0,0 +1,131 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChangeHeader(t *testing.T) {
+	m := Manager{}
+	ChangeHeader("X-Session-Version")(&m)
+	if m.changeHeader != "X-Session-Version" {
+		t.Errorf("want %q, got %q", "X-Session-Version", m.changeHeader)
+	}
+}
+
+func TestUpdateMeta(t *testing.T) {
+	cc := map[string]struct {
+		Found     bool
+		FetchErr  error
+		UpdateErr error
+		Err       error
+		Updated   int
+	}{
+		"Fetch error": {
+			FetchErr: errors.New("error"),
+			Err:      errors.New("error"),
+		},
+		"Session not found": {},
+		"Update error": {
+			Found:     true,
+			UpdateErr: errors.New("error"),
+			Err:       errors.New("error"),
+			Updated:   1,
+		},
+		"Successful update": {
+			Found:   true,
+			Updated: 1,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			store := &updateStoreMock{
+				StoreMock: &StoreMock{
+					FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+						return Session{ID: "id", Version: 1, Meta: map[string]string{"role": "user", "old": "1"}}, c.Found, c.FetchErr
+					},
+				},
+				UpdateFunc: func(_ context.Context, _ Session) error {
+					return c.UpdateErr
+				},
+			}
+
+			m := NewManager(store)
+			err := m.UpdateMeta(context.Background(), "id", MetaEntry("role", "admin"), func(meta map[string]string) {
+				delete(meta, "old")
+			})
+			if (c.Err == nil) != (err == nil) {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if len(store.updated) != c.Updated {
+				t.Fatalf("want %d, got %d", c.Updated, len(store.updated))
+			}
+
+			if c.Updated == 0 {
+				return
+			}
+
+			s := store.updated[0]
+			if s.Version != 2 {
+				t.Errorf("want %d, got %d", 2, s.Version)
+			}
+
+			if len(s.Meta) != 1 || s.Meta["role"] != "admin" {
+				t.Errorf("want %v, got %v", map[string]string{"role": "admin"}, s.Meta)
+			}
+		})
+	}
+}
+
+func TestNotifyChange(t *testing.T) {
+	cc := map[string]struct {
+		Seen   string
+		Header string
+	}{
+		"First request": {
+			Header: "3",
+		},
+		"Outdated version": {
+			Seen:   "2",
+			Header: "3",
+		},
+		"Current version": {
+			Seen: "3",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			store := &StoreMock{
+				FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+					return Session{ID: "id", Version: 3}, true, nil
+				},
+			}
+
+			m := NewManager(store, ChangeHeader("X-Session-Version"))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(&http.Cookie{Name: defaultName, Value: "id"})
+			if c.Seen != "" {
+				req.Header.Set("X-Session-Version", c.Seen)
+			}
+
+			rec := httptest.NewRecorder()
+			m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(rec, req)
+
+			if h := rec.Header().Get("X-Session-Version"); h != c.Header {
+				t.Errorf("want %q, got %q", c.Header, h)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Command storediff compares two session exports in the NDJSON format
+// (see sessionup.WriteNDJSON and storetest.Snapshot), e.g. taken from
+// the old and the new store during a dual-write migration, and reports
+// the sessions that are missing from the new store, exist only in it
+// or differ. It exits with status 1 if the exports have not converged.
+//
+// Usage:
+//
+//	go run ./cmd/storediff -max 20 old.ndjson new.ndjson
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/memstore"
+	"github.com/swithek/sessionup/mirrorstore"
+)
+
+func main() {
+	maxDetails := flag.Int("max", 20, "maximum number of reported differences")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: storediff [-max n] primary.ndjson secondary.ndjson")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	primary, err := load(ctx, flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	secondary, err := load(ctx, flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rep, err := mirrorstore.Compare(ctx, primary, secondary, mirrorstore.CompareConfig{MaxDetails: *maxDetails})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report(os.Stdout, rep)
+
+	if !rep.Converged() {
+		os.Exit(1)
+	}
+}
+
+// load reads the export into a new in-memory store.
+func load(ctx context.Context, path string) (*memstore.MemStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ss, err := sessionup.ReadNDJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	store := memstore.New(0)
+	for _, s := range ss {
+		if err = store.Create(ctx, s); err != nil {
+			return nil, fmt.Errorf("%s: session %q: %w", path, s.ID, err)
+		}
+	}
+
+	return store, nil
+}
+
+// report writes the report in a human-readable form.
+func report(w io.Writer, rep mirrorstore.Report) {
+	fmt.Fprintf(w, "checked %d sessions: %d missing, %d extra, %d mismatched\n",
+		rep.Checked, rep.Missing, rep.Extra, rep.Mismatched)
+
+	for _, d := range rep.Details {
+		fmt.Fprintf(w, "%-8s %s\n", d.Kind, d.ID)
+	}
+
+	if n := rep.Missing + rep.Extra + rep.Mismatched - len(rep.Details); n > 0 {
+		fmt.Fprintf(w, "... and %d more\n", n)
+	}
+}
@@ -0,0 +1,145 @@
+// Command vectors generates the canonical session fixtures in the
+// NDJSON format (see sessionup.WriteNDJSON) and its JSON schema, so
+// that services written in other languages can verify that they read
+// and write exported sessions compatibly.
+//
+// Usage (from the repository's root):
+//
+//	go run ./cmd/vectors -dir testdata
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+func main() {
+	dir := flag.String("dir", "testdata", "output directory")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := sessionup.WriteNDJSON(&buf, fixtures()); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(*dir, "sessions.ndjson"), buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := json.MarshalIndent(schema(), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(*dir, "sessions.schema.json"), append(data, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// fixtures returns sessions that cover all persisted fields and their
+// edge cases. Times are fixed, so that the output is reproducible.
+func fixtures() []sessionup.Session {
+	created := time.Date(2020, time.January, 2, 3, 4, 5, 600000000, time.UTC)
+
+	minimal := sessionup.Session{
+		CreatedAt: created,
+		ExpiresAt: created.Add(time.Hour),
+		ID:        "minimal",
+		UserKey:   "user1",
+	}
+
+	full := sessionup.Session{
+		CreatedAt:       created,
+		ExpiresAt:       created.Add(time.Hour * 24 * 30),
+		CookieExpiresAt: created.Add(time.Hour*24*30 - time.Second*5),
+		ID:              "full",
+		UserKey:         "user1",
+		OrgKey:          "org1",
+		IP:              net.ParseIP("203.0.113.7"),
+		Version:         7,
+		Affinity:        "eu-west-1",
+		Meta:            map[string]string{"device": "laptop", "sessionup_verified_email": "1577934245"},
+		Blobs:           map[string]string{"avatar": "full:avatar"},
+		CSRFToken:       "csrf0123456789",
+	}
+	full.Agent.OS = "Linux"
+	full.Agent.Browser = "Firefox"
+	full.Agent.Device = "desktop"
+	full.Agent.Version = "72"
+
+	child := sessionup.Session{
+		CreatedAt: created.Add(time.Minute),
+		ExpiresAt: created.Add(time.Minute * 16),
+		ID:        "child",
+		UserKey:   "user1",
+		IP:        net.ParseIP("2001:db8::1"),
+		ParentID:  "full",
+		Scope:     "chat",
+	}
+
+	trusted := sessionup.Session{
+		CreatedAt: created,
+		ExpiresAt: created.Add(time.Hour * 24 * 90),
+		ID:        "trusted",
+		UserKey:   "user2",
+		Kind:      sessionup.KindTrustedDevice,
+	}
+
+	unicode := sessionup.Session{
+		CreatedAt: created,
+		ExpiresAt: created.Add(time.Hour),
+		ID:        "unicode",
+		UserKey:   "žmogus@example.com",
+		Meta:      map[string]string{"name": "Jonas \"Joe\" Žemaitis", "emoji": "🚀"},
+	}
+
+	return []sessionup.Session{minimal, full, child, trusted, unicode}
+}
+
+// schema returns the JSON schema of a single NDJSON record.
+func schema() map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	ts := map[string]interface{}{"type": "string", "format": "date-time"}
+	strMap := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": str,
+	}
+
+	return map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "sessionup session record",
+		"description": "A single line of the NDJSON session export. Zero times are encoded as 0001-01-01T00:00:00Z.",
+		"type":        "object",
+		"required":    []string{"id", "user_key", "created_at", "expires_at", "cookie_expires_at"},
+		"properties": map[string]interface{}{
+			"id":                str,
+			"user_key":          str,
+			"org_key":           str,
+			"created_at":        ts,
+			"expires_at":        ts,
+			"cookie_expires_at": ts,
+			"ip":                str,
+			"os":                str,
+			"browser":           str,
+			"device":            str,
+			"agent_version":     str,
+			"parent_id":         str,
+			"scope":             str,
+			"kind":              str,
+			"version":           map[string]interface{}{"type": "integer", "minimum": 0},
+			"affinity":          str,
+			"meta":              strMap,
+			"blobs":             strMap,
+			"csrf_token":        str,
+		},
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,149 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// CoalesceFetches makes concurrent authentications of the same session
+// ID (e.g. bursts of requests sent by single-page applications or
+// multiplexed over HTTP/2) share a single FetchByID call to the store:
+// the first request fetches the session, while the rest wait for its
+// result instead of issuing their own calls. Every request gets its
+// own copy of the session's metadata, so they can modify them freely.
+// Revocations performed by the manager while a call is in progress
+// make the waiting requests fetch the session themselves, so revoked
+// sessions are never shared.
+// The shared call uses the context of the request that started it; if
+// that request is cancelled, the waiting requests fetch the session
+// themselves. Clones of the manager do not share calls with it.
+// By default it is not set.
+func CoalesceFetches(v bool) Option {
+	return func(m *Manager) {
+		m.flight = nil
+		if v {
+			m.flight = newFlight()
+		}
+	}
+}
+
+// flight holds the FetchByID calls that are in progress.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall holds the result of a single FetchByID call.
+type flightCall struct {
+	done  chan struct{}
+	s     Session
+	ok    bool
+	err   error
+	stale bool
+}
+
+// newFlight returns a fresh instance of flight.
+func newFlight() *flight {
+	return &flight{calls: make(map[string]*flightCall)}
+}
+
+// fetch retrieves the session by its ID from the store, sharing the
+// call with concurrent fetches of the same ID if CoalesceFetches is
+// set.
+func (m *Manager) fetch(ctx context.Context, id string) (Session, bool, error) {
+	f := m.flight
+	if f == nil {
+		return m.store.FetchByID(ctx, id)
+	}
+
+	f.mu.Lock()
+	if c, ok := f.calls[id]; ok {
+		f.mu.Unlock()
+
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return Session{}, false, ctx.Err()
+		}
+
+		f.mu.Lock()
+		stale := c.stale
+		f.mu.Unlock()
+
+		if stale || (errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+			return m.store.FetchByID(ctx, id)
+		}
+
+		m.stat(statCoalescedFetches)
+		return copyMaps(c.s), c.ok, c.err
+	}
+
+	c := &flightCall{done: make(chan struct{})}
+	f.calls[id] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		if f.calls[id] == c {
+			delete(f.calls, id)
+		}
+		f.mu.Unlock()
+		close(c.done)
+	}()
+
+	s, ok, err := m.store.FetchByID(ctx, id)
+
+	// the waiting requests copy the shared result only after the call
+	// is done, when the caller may already be modifying its session,
+	// so the shared result must not hold the caller's maps.
+	c.s, c.ok, c.err = copyMaps(s), ok, err
+	return s, ok, err
+}
+
+// forget makes the calls in progress, whose sessions might be matched
+// by the revocation, unusable for the waiting requests and removes
+// them, so that subsequent requests fetch the sessions anew. Calls
+// are matched by ID only, since their user and organization keys are
+// not known until they are done.
+func (f *flight) forget(r Revocation) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, c := range f.calls {
+		switch {
+		case id == r.ExceptID:
+		case r.ID == "" || id == r.ID:
+			c.stale = true
+			delete(f.calls, id)
+		}
+	}
+}
+
+// copyMaps returns the session with copies of its metadata and blob
+// references, so that they can be modified independently.
+func copyMaps(s Session) Session {
+	if s.Meta != nil {
+		meta := make(map[string]string, len(s.Meta))
+		for k, v := range s.Meta {
+			meta[k] = v
+		}
+
+		s.Meta = meta
+	}
+
+	if s.Blobs != nil {
+		blobs := make(map[string]string, len(s.Blobs))
+		for k, v := range s.Blobs {
+			blobs[k] = v
+		}
+
+		s.Blobs = blobs
+	}
+
+	return s
+}
@@ -0,0 +1,227 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestCoalesceFetches(t *testing.T) {
+	m := Manager{}
+	CoalesceFetches(true)(&m)
+	if m.flight == nil {
+		t.Fatal("want non-nil, got nil")
+	}
+
+	if cm := m.Clone(); cm.flight == nil || cm.flight == m.flight {
+		t.Errorf("want separate flight, got %p and %p", cm.flight, m.flight)
+	}
+
+	CoalesceFetches(false)(&m)
+	if m.flight != nil {
+		t.Errorf("want nil, got %v", m.flight)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	fetch := func(_ context.Context, id string) (Session, bool, error) {
+		return Session{ID: id, Meta: map[string]string{"k": "store"}}, true, nil
+	}
+
+	shared := Session{ID: "id", Meta: map[string]string{"k": "shared"}}
+
+	cc := map[string]struct {
+		Flight   bool
+		Shared   error
+		Cancel   bool
+		Meta     string
+		Err      error
+		Calls    int
+		Finished bool
+		Stale    bool
+	}{
+		"Not coalesced":         {Meta: "store", Calls: 1},
+		"Leader":                {Flight: true, Meta: "store", Calls: 1},
+		"Follower":              {Flight: true, Meta: "shared", Finished: true},
+		"Follower of cancelled": {Flight: true, Shared: context.Canceled, Meta: "store", Calls: 1, Finished: true},
+		"Cancelled follower":    {Flight: true, Cancel: true, Err: context.Canceled},
+		"Follower of revoked":   {Flight: true, Meta: "store", Calls: 1, Finished: true, Stale: true},
+		"Follower of timed out": {Flight: true, Shared: context.DeadlineExceeded, Meta: "store", Calls: 1, Finished: true},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			st := &StoreMock{FetchByIDFunc: fetch}
+			m := NewManager(st, CoalesceFetches(c.Flight))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if c.Cancel {
+				cancel()
+			}
+
+			if c.Finished || c.Cancel {
+				call := &flightCall{done: make(chan struct{}), s: shared, ok: true, err: c.Shared, stale: c.Stale}
+				if c.Finished {
+					close(call.done)
+				}
+
+				m.flight.calls["id"] = call
+			}
+
+			s, ok, err := m.fetch(ctx, "id")
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if len(st.FetchByIDCalls()) != c.Calls {
+				t.Errorf("want %d, got %d", c.Calls, len(st.FetchByIDCalls()))
+			}
+
+			if c.Err != nil {
+				return
+			}
+
+			if !ok || s.Meta["k"] != c.Meta {
+				t.Errorf("want %q, got %v", c.Meta, s)
+			}
+
+			if c.Meta == "shared" {
+				s.Meta["k"] = "changed"
+				if shared.Meta["k"] != "shared" {
+					t.Error("want copied metadata, got shared")
+				}
+			}
+
+			if c.Flight && !c.Finished && len(m.flight.calls) != 0 {
+				t.Errorf("want no calls in progress, got %v", m.flight.calls)
+			}
+		})
+	}
+}
+
+func TestFlightForget(t *testing.T) {
+	m := NewManager(&StoreMock{
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}, CoalesceFetches(true))
+
+	calls := func(ids ...string) map[string]*flightCall {
+		cc := make(map[string]*flightCall)
+		for _, id := range ids {
+			cc[id] = &flightCall{done: make(chan struct{})}
+		}
+
+		return cc
+	}
+
+	// use installs copies of the maps, so that the removed calls can
+	// still be inspected.
+	use := func(cc map[string]*flightCall) {
+		m.flight.calls = make(map[string]*flightCall)
+		for id, c := range cc {
+			m.flight.calls[id] = c
+		}
+	}
+
+	stale := func(cc map[string]*flightCall) []string {
+		var ids []string
+		for id, c := range cc {
+			if c.stale {
+				ids = append(ids, id)
+			}
+		}
+
+		sort.Strings(ids)
+		return ids
+	}
+
+	cc := calls("id1", "id2", "id3")
+	use(cc)
+	if err := m.RevokeByID(context.Background(), "id1"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if want := []string{"id1"}; !reflect.DeepEqual(stale(cc), want) || len(m.flight.calls) != 2 {
+		t.Errorf("want %v stale of %d, got %v of %d", want, 2, stale(cc), len(m.flight.calls))
+	}
+
+	cc = calls("id1", "id2", "id3")
+	use(cc)
+	ctx := NewContext(context.Background(), Session{ID: "id2", UserKey: "key"})
+	if err := m.RevokeOther(ctx); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if want := []string{"id1", "id3"}; !reflect.DeepEqual(stale(cc), want) || len(m.flight.calls) != 1 {
+		t.Errorf("want %v stale of %d, got %v of %d", want, 1, stale(cc), len(m.flight.calls))
+	}
+}
+
+func TestFetchConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	store := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			<-release
+			return Session{ID: id, UserKey: "key", Meta: map[string]string{"k": "v"}}, true, nil
+		},
+	}
+
+	m := NewManager(store, Validate(false), CoalesceFetches(true))
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		res []Session
+	)
+
+	h := m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		s, _ := FromContext(r.Context())
+		s.Meta["k"] = "changed"
+
+		mu.Lock()
+		res = append(res, s)
+		mu.Unlock()
+	}))
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(&http.Cookie{Name: defaultName, Value: "id"})
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+
+	close(release)
+	wg.Wait()
+
+	if len(res) != 20 {
+		t.Fatalf("want %d, got %d", 20, len(res))
+	}
+
+	for _, s := range res {
+		if !reflect.DeepEqual(s.Meta, map[string]string{"k": "changed"}) || s.ID != "id" {
+			t.Errorf("want session %q, got %v", "id", s)
+		}
+	}
+
+	if n := len(store.FetchByIDCalls()); n < 1 || n > 20 {
+		t.Errorf("want between %d and %d calls, got %d", 1, 20, n)
+	}
+}
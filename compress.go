@@ -0,0 +1,156 @@
+package sessionup
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+)
+
+// Prefixes of the encoded sessions, which tell Codec's Decode whether
+// the payload that follows them is compressed.
+const (
+	encodingRaw        byte = 'r'
+	encodingCompressed byte = 'c'
+)
+
+// ErrInvalidEncoding is returned when the encoded session is malformed
+// or when it is compressed but the codec has no compressor to
+// decompress it with.
+var ErrInvalidEncoding = errors.New("invalid session encoding")
+
+// Compressor compresses and decompresses serialized sessions (see
+// Codec). Implementations must be safe for concurrent use.
+// Snappy or zstd compressors can be plugged in by wrapping the
+// libraries that implement them; FlateCompressor is provided for
+// deployments that want to avoid extra dependencies.
+type Compressor interface {
+	// Compress returns the compressed data.
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress returns the data that was compressed by Compress.
+	Decompress(data []byte) ([]byte, error)
+}
+
+// FlateCompressor returns a Compressor that uses the DEFLATE algorithm
+// with the provided level (see compress/flate package's constants).
+func FlateCompressor(level int) Compressor {
+	return flateCompressor{level: level}
+}
+
+// flateCompressor is a Compressor that uses the DEFLATE algorithm.
+type flateCompressor struct {
+	level int
+}
+
+// Compress implements Compressor interface's Compress method.
+func (c flateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decompress implements Compressor interface's Decompress method.
+func (c flateCompressor) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+// Codec serializes sessions for stores that keep them as bytes (e.g.
+// in Redis or in a blob column), using the same fields as WriteNDJSON,
+// and optionally compresses them, which keeps the memory used by
+// sessions with large metadata predictable.
+// Decoding is transparent: each payload records whether it was
+// compressed, so compression can be enabled, disabled or its threshold
+// changed without migrating the already stored sessions. Switching to
+// a different compression algorithm, however, requires the old
+// sessions to be re-encoded or to expire.
+type Codec struct {
+	// Compressor specifies the compressor of the serialized sessions.
+	// If it is nil, sessions are not compressed.
+	Compressor Compressor
+
+	// MinSize specifies the size (in bytes) of the serialized session
+	// below which it is not compressed, since small payloads tend to
+	// grow when compressed.
+	// Defaults to 0, i.e. all sessions are compressed.
+	MinSize int
+}
+
+// Encode serializes and, if needed, compresses the session.
+func (c Codec) Encode(s Session) ([]byte, error) {
+	data, err := json.Marshal(newSessionRecord(s))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Compressor == nil || len(data) < c.MinSize {
+		return append([]byte{encodingRaw}, data...), nil
+	}
+
+	data, err = c.Compressor.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{encodingCompressed}, data...), nil
+}
+
+// Decode decompresses, if needed, and deserializes the session encoded
+// by Encode.
+func (c Codec) Decode(data []byte) (Session, error) {
+	if len(data) == 0 {
+		return Session{}, ErrInvalidEncoding
+	}
+
+	payload := data[1:]
+
+	switch data[0] {
+	case encodingRaw:
+	case encodingCompressed:
+		if c.Compressor == nil {
+			return Session{}, ErrInvalidEncoding
+		}
+
+		var err error
+		payload, err = c.Compressor.Decompress(payload)
+		if err != nil {
+			return Session{}, err
+		}
+	default:
+		return Session{}, ErrInvalidEncoding
+	}
+
+	var rec sessionRecord
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return Session{}, err
+	}
+
+	return rec.session(), nil
+}
+
+// Size returns the number of bytes that the encoded session takes,
+// e.g. for per-user memory accounting.
+func (c Codec) Size(s Session) (int, error) {
+	data, err := c.Encode(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
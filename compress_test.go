@@ -0,0 +1,117 @@
+package sessionup
+
+import (
+	"compress/flate"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingCompressor struct{}
+
+func (failingCompressor) Compress(_ []byte) ([]byte, error) {
+	return nil, errors.New("error")
+}
+
+func (failingCompressor) Decompress(_ []byte) ([]byte, error) {
+	return nil, errors.New("error")
+}
+
+func TestCodec(t *testing.T) {
+	now := time.Now().UTC().Round(0)
+	s := Session{
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+		ID:        "id",
+		UserKey:   "key",
+		IP:        net.ParseIP("127.0.0.1"),
+		Meta:      map[string]string{"large": strings.Repeat("value", 100)},
+	}
+	s.Agent.Browser = "Firefox"
+
+	flateC := FlateCompressor(flate.BestCompression)
+	raw, err := Codec{}.Size(s)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	cc := map[string]struct {
+		Codec      Codec
+		Compressed bool
+		Err        bool
+	}{
+		"No compressor":      {Codec: Codec{}},
+		"Below min size":     {Codec: Codec{Compressor: flateC, MinSize: raw + 1}},
+		"Compressed":         {Codec: Codec{Compressor: flateC}, Compressed: true},
+		"At min size":        {Codec: Codec{Compressor: flateC, MinSize: raw - 1}, Compressed: true},
+		"Invalid level":      {Codec: Codec{Compressor: FlateCompressor(100)}, Err: true},
+		"Failing compressor": {Codec: Codec{Compressor: failingCompressor{}}, Err: true},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := c.Codec.Encode(s)
+			if c.Err {
+				if err == nil {
+					t.Error("want non-nil, got nil")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if compressed := data[0] == encodingCompressed; compressed != c.Compressed {
+				t.Errorf("want %v, got %v", c.Compressed, compressed)
+			}
+
+			if c.Compressed && len(data) >= raw {
+				t.Errorf("want less than %d, got %d", raw, len(data))
+			}
+
+			res, err := c.Codec.Decode(data)
+			if err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if !reflect.DeepEqual(res, s) {
+				t.Errorf("want %v, got %v", s, res)
+			}
+
+			// decoding is transparent for codecs with a compressor.
+			res, err = Codec{Compressor: flateC}.Decode(data)
+			if err != nil || !reflect.DeepEqual(res, s) {
+				t.Errorf("want %v, got %v and %v", s, res, err)
+			}
+		})
+	}
+
+	compressed, _ := Codec{Compressor: flateC}.Encode(s)
+	dd := map[string]struct {
+		Codec Codec
+		Data  []byte
+		Err   error
+	}{
+		"Empty":              {Codec: Codec{Compressor: flateC}, Err: ErrInvalidEncoding},
+		"Unknown prefix":     {Codec: Codec{Compressor: flateC}, Data: []byte("x{}"), Err: ErrInvalidEncoding},
+		"No compressor":      {Codec: Codec{}, Data: compressed, Err: ErrInvalidEncoding},
+		"Failing compressor": {Codec: Codec{Compressor: failingCompressor{}}, Data: compressed},
+		"Invalid JSON":       {Codec: Codec{}, Data: []byte("r{")},
+	}
+
+	for dn, d := range dd {
+		_, err := d.Codec.Decode(d.Data)
+		if err == nil || (d.Err != nil && err != d.Err) {
+			t.Errorf("%s: want error, got %v", dn, err)
+		}
+	}
+}
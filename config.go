@@ -0,0 +1,243 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds the session policies that can be changed at runtime
+// with Reload. Its fields correspond to the options with the same
+// names.
+type Config struct {
+	// ExpiresIn specifies the lifetime of new sessions (see
+	// ExpiresIn option).
+	ExpiresIn time.Duration
+
+	// WithIP specifies whether the IP address should be stored in new
+	// sessions (see WithIP option).
+	WithIP bool
+
+	// WithAgent specifies whether the User-Agent data should be
+	// stored in new sessions (see WithAgent option).
+	WithAgent bool
+
+	// Validate specifies whether the request's properties should be
+	// checked against the session's (see Validate option).
+	Validate bool
+
+	// ClockSkew specifies the tolerated clock drift (see ClockSkew
+	// option).
+	ClockSkew time.Duration
+
+	// BurstWindow and BurstLimit specify the login burst detection
+	// parameters (see AnomalyConfig).
+	BurstWindow time.Duration
+	BurstLimit  int
+}
+
+// configJSON is the JSON representation of Config, used in
+// configuration files. Durations are stored as strings accepted by
+// time.ParseDuration.
+type configJSON struct {
+	ExpiresIn   *string `json:"expires_in"`
+	WithIP      *bool   `json:"with_ip"`
+	WithAgent   *bool   `json:"with_agent"`
+	Validate    *bool   `json:"validate"`
+	ClockSkew   *string `json:"clock_skew"`
+	BurstWindow *string `json:"burst_window"`
+	BurstLimit  *int    `json:"burst_limit"`
+}
+
+// Config returns the session policies currently in use.
+func (m *Manager) Config() Config {
+	if c, ok := m.loadReloaded(); ok {
+		return c
+	}
+
+	return m.options()
+}
+
+// loadReloaded returns the policies set by Reload, if any.
+func (m *Manager) loadReloaded() (Config, bool) {
+	if m.reloaded == nil {
+		return Config{}, false
+	}
+
+	c, ok := m.reloaded.Load().(Config)
+	return c, ok
+}
+
+// options returns the session policies set by options.
+func (m *Manager) options() Config {
+	return Config{
+		ExpiresIn:   m.expiresIn,
+		WithIP:      m.withIP,
+		WithAgent:   m.withAgent,
+		Validate:    m.validate,
+		ClockSkew:   m.clockSkew,
+		BurstWindow: m.anomaly.cfg.BurstWindow,
+		BurstLimit:  m.anomaly.cfg.BurstLimit,
+	}
+}
+
+// Reload atomically replaces the session policies of the manager. The
+// changes apply to all subsequent requests; existing sessions keep
+// their expiration times. Non-positive burst parameters are replaced
+// with the defaults.
+// Reload is safe for concurrent use, unlike options. Clones of the
+// manager (see Clone) follow its reloads, unless their own options
+// change any of the policies.
+func (m *Manager) Reload(c Config) {
+	if c.BurstWindow <= 0 {
+		c.BurstWindow = defaultBurstWindow
+	}
+
+	if c.BurstLimit <= 0 {
+		c.BurstLimit = defaultBurstLimit
+	}
+
+	if m.reloaded == nil {
+		m.reloaded = &atomic.Value{}
+	}
+
+	m.reloaded.Store(c)
+}
+
+// mergeConfig returns the reloaded policies with the fields that
+// differ between the before and after policies replaced by the after
+// values.
+func mergeConfig(c, before, after Config) Config {
+	if after.ExpiresIn != before.ExpiresIn {
+		c.ExpiresIn = after.ExpiresIn
+	}
+
+	if after.WithIP != before.WithIP {
+		c.WithIP = after.WithIP
+	}
+
+	if after.WithAgent != before.WithAgent {
+		c.WithAgent = after.WithAgent
+	}
+
+	if after.Validate != before.Validate {
+		c.Validate = after.Validate
+	}
+
+	if after.ClockSkew != before.ClockSkew {
+		c.ClockSkew = after.ClockSkew
+	}
+
+	if after.BurstWindow != before.BurstWindow {
+		c.BurstWindow = after.BurstWindow
+	}
+
+	if after.BurstLimit != before.BurstLimit {
+		c.BurstLimit = after.BurstLimit
+	}
+
+	return c
+}
+
+// ReloadFile reads the JSON configuration file at the provided path and
+// reloads the manager's policies (see Reload). Fields missing from the
+// file keep their current values. Durations are written as strings,
+// e.g. "24h". It can be called e.g. upon receiving SIGHUP.
+// File example:
+//
+//	{"expires_in": "24h", "validate": true, "burst_limit": 3}
+func (m *Manager) ReloadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var cj configJSON
+	if err = json.Unmarshal(data, &cj); err != nil {
+		return err
+	}
+
+	c := m.Config()
+	for _, d := range []struct {
+		src *string
+		dst *time.Duration
+	}{
+		{cj.ExpiresIn, &c.ExpiresIn},
+		{cj.ClockSkew, &c.ClockSkew},
+		{cj.BurstWindow, &c.BurstWindow},
+	} {
+		if d.src == nil {
+			continue
+		}
+
+		if *d.dst, err = time.ParseDuration(*d.src); err != nil {
+			return err
+		}
+	}
+
+	for _, b := range []struct {
+		src *bool
+		dst *bool
+	}{
+		{cj.WithIP, &c.WithIP},
+		{cj.WithAgent, &c.WithAgent},
+		{cj.Validate, &c.Validate},
+	} {
+		if b.src != nil {
+			*b.dst = *b.src
+		}
+	}
+
+	if cj.BurstLimit != nil {
+		c.BurstLimit = *cj.BurstLimit
+	}
+
+	m.Reload(c)
+	return nil
+}
+
+// WatchConfig reloads the manager's policies from the JSON configuration
+// file at the provided path (see ReloadFile) immediately and then every
+// time its modification time changes, checking it at the provided
+// interval. Errors that occur after the initial load are passed to the
+// onErr function (if it is not nil) and the previous policies are kept.
+// It blocks until the context is done.
+func (m *Manager) WatchConfig(ctx context.Context, path string, interval time.Duration, onErr func(error)) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if err = m.ReloadFile(path); err != nil {
+		return err
+	}
+
+	mod := fi.ModTime()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		fi, err := os.Stat(path)
+		if err == nil && fi.ModTime().Equal(mod) {
+			continue
+		}
+
+		if err == nil {
+			mod = fi.ModTime()
+			err = m.ReloadFile(path)
+		}
+
+		if err != nil && onErr != nil {
+			onErr(err)
+		}
+	}
+}
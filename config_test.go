@@ -0,0 +1,236 @@
+package sessionup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	m := NewManager(&StoreMock{}, ExpiresIn(time.Hour), Validate(true), ClockSkew(time.Second))
+	exp := Config{
+		ExpiresIn: time.Hour,
+		WithIP:    true,
+		WithAgent: true,
+		Validate:  true,
+		ClockSkew: time.Second,
+	}
+
+	if c := m.Config(); !reflect.DeepEqual(exp, c) {
+		t.Errorf("want %v, got %v", exp, c)
+	}
+}
+
+func TestReload(t *testing.T) {
+	m := NewManager(&StoreMock{}, ExpiresIn(time.Hour))
+	m.Reload(Config{ExpiresIn: time.Minute, Validate: true})
+
+	exp := Config{
+		ExpiresIn:   time.Minute,
+		Validate:    true,
+		BurstWindow: defaultBurstWindow,
+		BurstLimit:  defaultBurstLimit,
+	}
+
+	if c := m.Config(); !reflect.DeepEqual(exp, c) {
+		t.Errorf("want %v, got %v", exp, c)
+	}
+
+	if ttl := m.ttl("key"); ttl != time.Minute {
+		t.Errorf("want %v, got %v", time.Minute, ttl)
+	}
+}
+
+func TestReloadClone(t *testing.T) {
+	m := NewManager(&StoreMock{}, ExpiresIn(time.Hour))
+	m.Reload(Config{ExpiresIn: time.Minute, Validate: true})
+
+	follower := m.Clone(CookieName("other"))
+	cm := m.Clone(ExpiresIn(time.Hour * 2))
+
+	exp := Config{
+		ExpiresIn:   time.Hour * 2,
+		Validate:    true,
+		BurstWindow: defaultBurstWindow,
+		BurstLimit:  defaultBurstLimit,
+	}
+
+	if c := cm.Config(); !reflect.DeepEqual(exp, c) {
+		t.Errorf("want %v, got %v", exp, c)
+	}
+
+	m.Reload(Config{ExpiresIn: time.Second})
+
+	if c := follower.Config(); c.ExpiresIn != time.Second {
+		t.Errorf("want %v, got %v", time.Second, c.ExpiresIn)
+	}
+
+	if c := cm.Config(); c.ExpiresIn != time.Hour*2 {
+		t.Errorf("want %v, got %v", time.Hour*2, c.ExpiresIn)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 100; i++ {
+			m.Reload(Config{ExpiresIn: time.Duration(i)})
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		m.Scoped("chat").Config()
+	}
+
+	<-done
+}
+
+func TestReloadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+		return path
+	}
+
+	cc := map[string]struct {
+		Data   string
+		Err    bool
+		Config Config
+	}{
+		"Invalid JSON": {
+			Data: `{`,
+			Err:  true,
+		},
+		"Invalid duration": {
+			Data: `{"expires_in": "forever"}`,
+			Err:  true,
+		},
+		"Partial config": {
+			Data: `{"expires_in": "24h", "validate": true, "burst_limit": 3}`,
+			Config: Config{
+				ExpiresIn:   time.Hour * 24,
+				WithIP:      true,
+				WithAgent:   true,
+				Validate:    true,
+				ClockSkew:   time.Second,
+				BurstWindow: defaultBurstWindow,
+				BurstLimit:  3,
+			},
+		},
+		"Full config": {
+			Data: `{"expires_in": "1m", "with_ip": false, "with_agent": false, "validate": false,
+				"clock_skew": "2s", "burst_window": "1h", "burst_limit": 10}`,
+			Config: Config{
+				ExpiresIn:   time.Minute,
+				ClockSkew:   time.Second * 2,
+				BurstWindow: time.Hour,
+				BurstLimit:  10,
+			},
+		},
+	}
+
+	for cn, c := range cc {
+		path := write(cn, c.Data)
+		t.Run(cn, func(t *testing.T) {
+			m := NewManager(&StoreMock{}, ExpiresIn(time.Hour), ClockSkew(time.Second))
+			orig := m.Config()
+
+			err := m.ReloadFile(path)
+			if c.Err {
+				if err == nil {
+					t.Error("want non-nil, got nil")
+				}
+
+				if cfg := m.Config(); !reflect.DeepEqual(orig, cfg) {
+					t.Errorf("want %v, got %v", orig, cfg)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if cfg := m.Config(); !reflect.DeepEqual(c.Config, cfg) {
+				t.Errorf("want %v, got %v", c.Config, cfg)
+			}
+		})
+	}
+
+	if err = NewManager(&StoreMock{}).ReloadFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	m := NewManager(&StoreMock{})
+
+	ctx := context.Background()
+	if err = m.WatchConfig(ctx, path, time.Millisecond, nil); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+
+	ioutil.WriteFile(path, []byte(`{"expires_in": "1h"}`), 0600)
+
+	ctx, cancel := context.WithCancel(ctx)
+	errs := make(chan error, 10)
+	done := make(chan error)
+	go func() {
+		done <- m.WatchConfig(ctx, path, time.Millisecond, func(err error) { errs <- err })
+	}()
+
+	waitFor := func(exp time.Duration) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for m.Config().ExpiresIn != exp {
+			if time.Now().After(deadline) {
+				t.Fatalf("want %v, got %v", exp, m.Config().ExpiresIn)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitFor(time.Hour)
+
+	ioutil.WriteFile(path, []byte(`{"expires_in": "1m"}`), 0600)
+	os.Chtimes(path, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	waitFor(time.Minute)
+
+	ioutil.WriteFile(path, []byte(`{`), 0600)
+	os.Chtimes(path, time.Now().Add(time.Hour*2), time.Now().Add(time.Hour*2))
+
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Error("want error, got timeout")
+	}
+
+	if exp := m.Config().ExpiresIn; exp != time.Minute {
+		t.Errorf("want %v, got %v", time.Minute, exp)
+	}
+
+	cancel()
+	if err = <-done; err != context.Canceled {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,165 @@
+package sessionup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// MaxIDLength specifies the maximum length (in bytes) of session
+	// IDs, not counting the prefix set with KeyPrefix.
+	MaxIDLength = 128
+
+	// MaxUserKeyLength specifies the maximum length (in bytes) of user
+	// and organization keys, not counting the prefix set with KeyPrefix.
+	MaxUserKeyLength = 256
+
+	// MaxMetaKeyLength specifies the maximum length (in bytes) of
+	// session metadata keys.
+	MaxMetaKeyLength = 128
+
+	// IDCharset specifies the characters used by DefaultGenID.
+	// IDs produced by custom generation functions may also contain
+	// any other characters allowed in cookie values (printable ASCII
+	// characters except space, '"', ',', ';' and '\').
+	IDCharset = idChars
+
+	// UserKeyIndexPrefix specifies the prefix of the keys produced by
+	// UserKeyIndexKey. It contains a character that is never allowed in
+	// session IDs, so index keys cannot collide with them.
+	UserKeyIndexPrefix = "user\x00"
+)
+
+// ErrInvalidSession is matched (via errors.Is) by all ValidationError
+// values.
+var ErrInvalidSession = errors.New("invalid session")
+
+// ValidationError is returned by ValidateSession when a session field
+// breaks the rules that Store implementations may rely upon.
+type ValidationError struct {
+	// Field specifies the name of the invalid field.
+	Field string
+
+	// Reason specifies why the field is invalid.
+	Reason string
+}
+
+// Error implements error interface's Error method.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid session %s: %s", e.Field, e.Reason)
+}
+
+// Is allows ValidationError to be matched with ErrInvalidSession.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidSession
+}
+
+// StoreError may be returned by Store implementations to provide
+// context about the failed operation without hiding the underlying
+// error: errors.Is(err, ErrNotFound) and similar checks still work
+// on the wrapped error.
+type StoreError struct {
+	// Op specifies the name of the failed Store method, e.g. "Create".
+	Op string
+
+	// Key specifies the session ID, user key or other lookup key the
+	// operation was called with.
+	Key string
+
+	// Err specifies the underlying error.
+	Err error
+}
+
+// Error implements error interface's Error method.
+func (e *StoreError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("sessionup store: %s: %v", e.Op, e.Err)
+	}
+
+	return fmt.Sprintf("sessionup store: %s %q: %v", e.Op, e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateSession checks whether the session's fields follow the rules
+// that Manager guarantees for all sessions passed to Store.Create:
+// non-empty ID, no longer than MaxIDLength, consisting of cookie value
+// characters only; user and organization keys no longer than
+// MaxUserKeyLength and without control characters; non-empty metadata
+// keys no longer than MaxMetaKeyLength and without control characters.
+// Returned errors are of *ValidationError type.
+func ValidateSession(s Session) error {
+	switch {
+	case s.ID == "":
+		return &ValidationError{Field: "ID", Reason: "empty"}
+	case len(s.ID) > MaxIDLength:
+		return &ValidationError{Field: "ID", Reason: "too long"}
+	case strings.IndexFunc(s.ID, isNotCookieChar) >= 0:
+		return &ValidationError{Field: "ID", Reason: "invalid character"}
+	}
+
+	for _, f := range [...]struct{ name, key string }{{"UserKey", s.UserKey}, {"OrgKey", s.OrgKey}} {
+		if len(f.key) > MaxUserKeyLength {
+			return &ValidationError{Field: f.name, Reason: "too long"}
+		}
+
+		if strings.IndexFunc(f.key, unicode.IsControl) >= 0 {
+			return &ValidationError{Field: f.name, Reason: "invalid character"}
+		}
+	}
+
+	for k := range s.Meta {
+		switch {
+		case k == "":
+			return &ValidationError{Field: "Meta", Reason: "empty key"}
+		case len(k) > MaxMetaKeyLength:
+			return &ValidationError{Field: "Meta", Reason: "key too long"}
+		case strings.IndexFunc(k, unicode.IsControl) >= 0:
+			return &ValidationError{Field: "Meta", Reason: "invalid key character"}
+		}
+	}
+
+	return nil
+}
+
+// isNotCookieChar checks whether the rune is not allowed in cookie
+// values (RFC 6265) or not.
+func isNotCookieChar(r rune) bool {
+	return r <= ' ' || r >= 0x7f || r == '"' || r == ',' || r == ';' || r == '\\'
+}
+
+// IsExpired checks whether the session is expired at the provided
+// point in time or not. Sessions that expire exactly at that point
+// are considered expired.
+func IsExpired(s Session, t time.Time) bool {
+	return !s.ExpiresAt.After(t)
+}
+
+// ExpiredFilter returns the sessions that are not expired at the
+// provided point in time, in their original order. The provided slice
+// is not modified. Nil is returned if no sessions remain, so the
+// result can be returned from FetchByUserKey directly.
+func ExpiredFilter(ss []Session, t time.Time) []Session {
+	var res []Session
+	for _, s := range ss {
+		if !IsExpired(s, t) {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
+
+// UserKeyIndexKey produces the key under which key-value stores should
+// keep the index of sessions of the provided user key (e.g. a set of
+// session IDs), so that it never collides with session records stored
+// under their IDs.
+func UserKeyIndexKey(key string) string {
+	return UserKeyIndexPrefix + key
+}
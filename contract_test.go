@@ -0,0 +1,149 @@
+package sessionup
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSession(t *testing.T) {
+	cc := map[string]struct {
+		Session Session
+		Field   string
+	}{
+		"Empty ID": {
+			Session: Session{},
+			Field:   "ID",
+		},
+		"ID too long": {
+			Session: Session{ID: strings.Repeat("a", MaxIDLength+1)},
+			Field:   "ID",
+		},
+		"ID with space": {
+			Session: Session{ID: "a b"},
+			Field:   "ID",
+		},
+		"ID with semicolon": {
+			Session: Session{ID: "a;b"},
+			Field:   "ID",
+		},
+		"User key too long": {
+			Session: Session{ID: "id", UserKey: strings.Repeat("a", MaxUserKeyLength+1)},
+			Field:   "UserKey",
+		},
+		"User key with control character": {
+			Session: Session{ID: "id", UserKey: "a\x00"},
+			Field:   "UserKey",
+		},
+		"Org key with control character": {
+			Session: Session{ID: "id", OrgKey: "a\n"},
+			Field:   "OrgKey",
+		},
+		"Empty meta key": {
+			Session: Session{ID: "id", Meta: map[string]string{"": "1"}},
+			Field:   "Meta",
+		},
+		"Meta key too long": {
+			Session: Session{ID: "id", Meta: map[string]string{strings.Repeat("a", MaxMetaKeyLength+1): "1"}},
+			Field:   "Meta",
+		},
+		"Valid session": {
+			Session: Session{
+				ID:      strings.Repeat("a", MaxIDLength-3) + "-_:",
+				UserKey: "org/użytkownik",
+				OrgKey:  "org",
+				Meta:    map[string]string{"key": "\x00"},
+			},
+		},
+		"Valid session with default ID": {
+			Session: Session{ID: DefaultGenID()},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateSession(c.Session)
+			if c.Field == "" {
+				if err != nil {
+					t.Errorf("want nil, got %v", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("want %v, got %v", ErrInvalidSession, err)
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("want *ValidationError, got %T", err)
+			}
+
+			if verr.Field != c.Field {
+				t.Errorf("want %q, got %q", c.Field, verr.Field)
+			}
+		})
+	}
+}
+
+func TestStoreError(t *testing.T) {
+	err := error(&StoreError{Op: "DeleteByID", Key: "id", Err: ErrNotFound})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("want %v, got %v", ErrNotFound, err)
+	}
+
+	if ignoreNotFound(err) != nil {
+		t.Errorf("want nil, got %v", ignoreNotFound(err))
+	}
+
+	exp := `sessionup store: DeleteByID "id": session not found`
+	if err.Error() != exp {
+		t.Errorf("want %q, got %q", exp, err.Error())
+	}
+
+	err = &StoreError{Op: "Create", Err: ErrDuplicateID}
+	exp = "sessionup store: Create: duplicate ID"
+	if err.Error() != exp {
+		t.Errorf("want %q, got %q", exp, err.Error())
+	}
+}
+
+func TestExpiredFilter(t *testing.T) {
+	now := time.Now()
+	ss := []Session{
+		{ID: "1", ExpiresAt: now.Add(time.Hour)},
+		{ID: "2", ExpiresAt: now},
+		{ID: "3", ExpiresAt: now.Add(-time.Hour)},
+		{ID: "4", ExpiresAt: now.Add(time.Minute)},
+	}
+
+	res := ExpiredFilter(ss, now)
+	if exp := []Session{ss[0], ss[3]}; !reflect.DeepEqual(exp, res) {
+		t.Errorf("want %v, got %v", exp, res)
+	}
+
+	if len(ss) != 4 || ss[1].ID != "2" {
+		t.Errorf("want unmodified, got %v", ss)
+	}
+
+	if res = ExpiredFilter(ss[1:3], now); res != nil {
+		t.Errorf("want nil, got %v", res)
+	}
+}
+
+func TestUserKeyIndexKey(t *testing.T) {
+	key := UserKeyIndexKey("key")
+	if key != UserKeyIndexPrefix+"key" {
+		t.Errorf("want %q, got %q", UserKeyIndexPrefix+"key", key)
+	}
+
+	if ValidateSession(Session{ID: key}) == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
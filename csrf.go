@@ -0,0 +1,189 @@
+package sessionup
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCSRFHeader = "X-CSRF-Token"
+	csrfCookieSuffix  = "_csrf"
+)
+
+var (
+	// ErrCSRF is returned when a state-changing request fails
+	// cross-site request forgery checks.
+	ErrCSRF = errors.New("cross-site request forgery check failed")
+)
+
+// CheckOrigin determines whether the 'Origin' (or 'Referer', if the
+// former is missing) header of state-changing requests should be
+// checked against the trusted origins or not.
+// The check is activated only when SameSite is set to
+// http.SameSiteNoneMode.
+// Defaults to true.
+func CheckOrigin(c bool) Option {
+	return func(m *Manager) {
+		m.csrf.origin = c
+	}
+}
+
+// CheckToken determines whether state-changing requests should
+// carry a valid anti-CSRF token in the header set by CSRFHeader
+// or not. The token is issued during Init as a companion, non-HttpOnly
+// cookie and can also be retrieved via CSRFToken function.
+// The check is activated only when SameSite is set to
+// http.SameSiteNoneMode.
+// Defaults to true.
+func CheckToken(c bool) Option {
+	return func(m *Manager) {
+		m.csrf.token = c
+	}
+}
+
+// TrustedOrigins sets the origins (scheme and host, e.g.
+// "https://example.com") that are allowed to send state-changing
+// requests.
+// If none are set, only the origin matching the request's scheme and
+// host is trusted (see TrustedProxies).
+func TrustedOrigins(oo ...string) Option {
+	return func(m *Manager) {
+		for _, o := range oo {
+			if !validOrigin(o) {
+				m.invalid("TrustedOrigins", ErrInvalidOrigin)
+			}
+		}
+
+		m.csrf.origins = oo
+	}
+}
+
+// TrustedProxies sets the CIDR ranges (e.g. "10.0.0.0/8") of the
+// proxies whose X-Forwarded-Proto header is trusted. It is used to
+// determine the request's scheme when no trusted origins are set, since
+// TLS is usually terminated by the proxy; the scheme of requests from
+// other addresses is determined by whether the connection is encrypted.
+// The proxies must overwrite the header instead of appending to it.
+// Invalid ranges are ignored (and reported by New).
+// By default it is not set.
+func TrustedProxies(cidrs ...string) Option {
+	return func(m *Manager) {
+		nets, err := parseNetworks(cidrs)
+		if err != nil {
+			m.invalid("TrustedProxies", ErrInvalidNetwork)
+			return
+		}
+
+		m.csrf.proxies = nets
+	}
+}
+
+// CSRFHeader sets the name of the header from which the anti-CSRF
+// token will be extracted.
+// Defaults to the value stored in defaultCSRFHeader.
+func CSRFHeader(h string) Option {
+	return func(m *Manager) {
+		m.csrf.header = h
+	}
+}
+
+// CSRFToken extracts the anti-CSRF token of the session stored in
+// the context. Useful when the token needs to be embedded into
+// server-rendered pages.
+// Empty string is returned if context session is not set.
+func CSRFToken(ctx context.Context) string {
+	s, _ := FromContext(ctx)
+	return s.CSRFToken
+}
+
+// csrfActive checks whether CSRF checks should be performed or not.
+func (m *Manager) csrfActive() bool {
+	return m.cookie.sameSite == http.SameSiteNoneMode && (m.csrf.origin || m.csrf.token)
+}
+
+// checkCSRF checks whether the request passes all enabled CSRF
+// checks or not. Requests with safe methods are always accepted.
+func (m *Manager) checkCSRF(r *http.Request, s Session) bool {
+	if safeMethod(r.Method) {
+		return true
+	}
+
+	if m.csrf.origin && !m.trustedOrigin(r) {
+		return false
+	}
+
+	if m.csrf.token {
+		tok := r.Header.Get(m.csrf.header)
+		if tok == "" || s.CSRFToken == "" ||
+			subtle.ConstantTimeCompare([]byte(tok), []byte(s.CSRFToken)) != 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// trustedOrigin checks whether the request was initiated by one of
+// the trusted origins or not.
+func (m *Manager) trustedOrigin(r *http.Request) bool {
+	o := r.Header.Get("Origin")
+	if o == "" || o == "null" {
+		o = r.Header.Get("Referer")
+	}
+
+	u, err := url.Parse(o)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if len(m.csrf.origins) == 0 {
+		return strings.EqualFold(u.Scheme, m.requestScheme(r)) && strings.EqualFold(u.Host, r.Host)
+	}
+
+	for _, to := range m.csrf.origins {
+		if strings.EqualFold(strings.TrimSuffix(to, "/"), u.Scheme+"://"+u.Host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestScheme returns the scheme the client used to send the
+// request: the one set in the X-Forwarded-Proto header by a trusted
+// proxy or, if the request was not sent by one, the one determined by
+// whether the connection is encrypted.
+func (m *Manager) requestScheme(r *http.Request) string {
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" && containsIP(m.csrf.proxies, net.ParseIP(host)) {
+		return strings.TrimSpace(p)
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// setCSRFCookie creates a companion cookie that holds the anti-CSRF
+// token and can be read by client-side scripts.
+func (m *Manager) setCSRFCookie(w http.ResponseWriter, exp time.Time, tok string) {
+	c := &http.Cookie{
+		Name:     m.cookie.name + csrfCookieSuffix,
+		Value:    tok,
+		Path:     m.cookie.path,
+		Domain:   m.cookie.domain,
+		Expires:  exp,
+		Secure:   m.cookie.secure,
+		SameSite: m.cookie.sameSite,
+	}
+
+	m.writeCookie(w, c)
+}
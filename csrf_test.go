@@ -0,0 +1,294 @@
+package sessionup
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	m := Manager{}
+	val := true
+	CheckOrigin(val)(&m)
+	if m.csrf.origin != val {
+		t.Errorf("want %t, got %t", val, m.csrf.origin)
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	m := Manager{}
+	val := true
+	CheckToken(val)(&m)
+	if m.csrf.token != val {
+		t.Errorf("want %t, got %t", val, m.csrf.token)
+	}
+}
+
+func TestTrustedOrigins(t *testing.T) {
+	m := Manager{}
+	TrustedOrigins("https://a.com", "https://b.com")(&m)
+	if len(m.csrf.origins) != 2 {
+		t.Errorf("want %d, got %d", 2, len(m.csrf.origins))
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	m := Manager{}
+	TrustedProxies("10.0.0.0/8", "192.168.0.0/16")(&m)
+	if len(m.csrf.proxies) != 2 {
+		t.Errorf("want %d, got %d", 2, len(m.csrf.proxies))
+	}
+
+	m1 := Manager{}
+	TrustedProxies("10.0.0.0")(&m1)
+	if len(m1.optErrs) != 1 || !errors.Is(m1.optErrs[0], ErrInvalidNetwork) {
+		t.Errorf("want %v, got %v", ErrInvalidNetwork, m1.optErrs)
+	}
+}
+
+func TestCSRFHeader(t *testing.T) {
+	m := Manager{}
+	val := "X-Token"
+	CSRFHeader(val)(&m)
+	if m.csrf.header != val {
+		t.Errorf("want %q, got %q", val, m.csrf.header)
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	if tok := CSRFToken(context.Background()); tok != "" {
+		t.Errorf("want %q, got %q", "", tok)
+	}
+
+	ctx := NewContext(context.Background(), Session{CSRFToken: "tok"})
+	if tok := CSRFToken(ctx); tok != "tok" {
+		t.Errorf("want %q, got %q", "tok", tok)
+	}
+}
+
+func TestCSRFActive(t *testing.T) {
+	m := Manager{}
+	m.Defaults()
+	if m.csrfActive() {
+		t.Errorf("want %t, got %t", false, true)
+	}
+
+	m.cookie.sameSite = http.SameSiteNoneMode
+	if !m.csrfActive() {
+		t.Errorf("want %t, got %t", true, false)
+	}
+
+	m.csrf.origin = false
+	m.csrf.token = false
+	if m.csrfActive() {
+		t.Errorf("want %t, got %t", false, true)
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	s := Session{CSRFToken: "tok"}
+
+	req := func(method, origin, referer, tok string) *http.Request {
+		r := httptest.NewRequest(method, "http://example.com/", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+
+		if referer != "" {
+			r.Header.Set("Referer", referer)
+		}
+
+		if tok != "" {
+			r.Header.Set(defaultCSRFHeader, tok)
+		}
+
+		return r
+	}
+
+	encrypted := func(r *http.Request) *http.Request {
+		r.TLS = &tls.ConnectionState{}
+		return r
+	}
+
+	forwarded := func(r *http.Request, proto string) *http.Request {
+		r.Header.Set("X-Forwarded-Proto", proto)
+		return r
+	}
+
+	cc := map[string]struct {
+		Origins []string
+		Proxies []string
+		Origin  bool
+		Token   bool
+		Req     *http.Request
+		Res     bool
+	}{
+		"Safe method": {
+			Origin: true,
+			Token:  true,
+			Req:    req(http.MethodGet, "", "", ""),
+			Res:    true,
+		},
+		"Missing origin": {
+			Origin: true,
+			Req:    req(http.MethodPost, "", "", ""),
+			Res:    false,
+		},
+		"Foreign origin": {
+			Origin: true,
+			Req:    req(http.MethodPost, "http://evil.com", "", ""),
+			Res:    false,
+		},
+		"Same host referer": {
+			Origin: true,
+			Req:    req(http.MethodPost, "", "http://example.com/page", ""),
+			Res:    true,
+		},
+		"Same host origin with HTTP scheme over TLS": {
+			Origin: true,
+			Req:    encrypted(req(http.MethodPost, "http://example.com", "", "")),
+			Res:    false,
+		},
+		"Same host origin with HTTPS scheme over TLS": {
+			Origin: true,
+			Req:    encrypted(req(http.MethodPost, "https://example.com", "", "")),
+			Res:    true,
+		},
+		"Same host origin with HTTPS scheme without TLS": {
+			Origin: true,
+			Req:    req(http.MethodPost, "https://example.com", "", ""),
+			Res:    false,
+		},
+		"Same host origin with HTTP scheme behind HTTPS proxy": {
+			Proxies: []string{"192.0.2.0/24"},
+			Origin:  true,
+			Req:     forwarded(req(http.MethodPost, "http://example.com", "", ""), "https"),
+			Res:     false,
+		},
+		"Same host origin with HTTPS scheme behind HTTPS proxy": {
+			Proxies: []string{"192.0.2.0/24"},
+			Origin:  true,
+			Req:     forwarded(req(http.MethodPost, "https://example.com", "", ""), "https"),
+			Res:     true,
+		},
+		"Same host origin with HTTPS scheme behind untrusted proxy": {
+			Proxies: []string{"10.0.0.0/8"},
+			Origin:  true,
+			Req:     forwarded(req(http.MethodPost, "https://example.com", "", ""), "https"),
+			Res:     false,
+		},
+		"Untrusted origin": {
+			Origins: []string{"https://widget.com"},
+			Origin:  true,
+			Req:     req(http.MethodPost, "http://example.com", "", ""),
+			Res:     false,
+		},
+		"Trusted origin": {
+			Origins: []string{"https://widget.com/"},
+			Origin:  true,
+			Req:     req(http.MethodPost, "https://widget.com", "", ""),
+			Res:     true,
+		},
+		"Missing token": {
+			Token: true,
+			Req:   req(http.MethodPost, "", "", ""),
+			Res:   false,
+		},
+		"Invalid token": {
+			Token: true,
+			Req:   req(http.MethodPost, "", "", "123"),
+			Res:   false,
+		},
+		"Successful checks": {
+			Origin: true,
+			Token:  true,
+			Req:    req(http.MethodDelete, "http://example.com", "", "tok"),
+			Res:    true,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := Manager{}
+			m.Defaults()
+			m.csrf.origins = c.Origins
+			TrustedProxies(c.Proxies...)(&m)
+			m.csrf.origin = c.Origin
+			m.csrf.token = c.Token
+			res := m.checkCSRF(c.Req, s)
+			if res != c.Res {
+				t.Errorf("want %t, got %t", c.Res, res)
+			}
+		})
+	}
+}
+
+func TestCSRFInitAndAuth(t *testing.T) {
+	var stored Session
+	store := &StoreMock{
+		CreateFunc: func(_ context.Context, s Session) error {
+			stored = s
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+			return stored, true, nil
+		},
+	}
+
+	m := NewManager(store, SameSite(http.SameSiteNoneMode))
+
+	rec := httptest.NewRecorder()
+	if err := m.Init(rec, httptest.NewRequest("GET", "http://example.com/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if stored.CSRFToken == "" {
+		t.Fatal("want non-empty, got empty")
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("want %d, got %d", 2, len(cookies))
+	}
+
+	if cookies[1].Name != defaultName+csrfCookieSuffix || cookies[1].Value != stored.CSRFToken || cookies[1].HttpOnly {
+		t.Errorf("want readable %q cookie, got %s", defaultName+csrfCookieSuffix, cookies[1].String())
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "http://example.com/", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set("Origin", "http://example.com")
+	m.Auth(next).ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req.Header.Set(defaultCSRFHeader, stored.CSRFToken)
+	m.Auth(next).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("want %d, got %d", http.StatusNoContent, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	m.deleteCookie(rec)
+	cookies = rec.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("want %d, got %d", 2, len(cookies))
+	}
+
+	if !cookies[1].Expires.Before(time.Now()) {
+		t.Errorf("want %s, got %v", "<now", cookies[1].Expires)
+	}
+}
@@ -0,0 +1,154 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DedupeAction determines what happens when Init finds a live session
+// of the same user created on the same device (see DedupeDevices).
+type DedupeAction int
+
+const (
+	// DedupeReplace creates a new session and revokes the existing
+	// ones created on the same device.
+	DedupeReplace DedupeAction = iota
+
+	// DedupeReuse replaces the most recent existing session created on
+	// the same device with its copy under a new ID and sets its cookie,
+	// instead of creating a new session. The existing session's
+	// metadata and expiration time are kept, while its old ID (which
+	// might have been planted by an attacker before the login) stops
+	// working.
+	DedupeReuse
+)
+
+// DedupeConfig holds the data needed to deduplicate sessions of the
+// same device at Init.
+type DedupeConfig struct {
+	// Action specifies what happens with the existing sessions.
+	// Defaults to DedupeReplace.
+	Action DedupeAction
+
+	// DeviceMeta specifies the metadata key under which the device ID
+	// is set with Init's Meta. Sessions with equal, non-empty device
+	// IDs are considered to be created on the same device.
+	// If it is not set, sessions with equal IP addresses, operating
+	// systems, browsers and device types are.
+	DeviceMeta string
+}
+
+// DedupeDevices activates the deduplication of sessions at Init, so
+// that logging in again on the same device does not stack a new
+// session on top of the existing one, keeping the lists of users'
+// sessions clean and the store size bounded. Only regular sessions are
+// deduplicated: derived sessions, tickets, trusted devices and other
+// special sessions are never reused or replaced.
+// By default it is not set.
+func DedupeDevices(cfg DedupeConfig) Option {
+	return func(m *Manager) {
+		m.dedupe = &cfg
+	}
+}
+
+// reuse moves the most recent live session of the user, created on
+// the same device as the request, under a new ID and sets its cookie,
+// if the session exists.
+// The returned value indicates whether the session was reused or not.
+func (m *Manager) reuse(w http.ResponseWriter, r *http.Request, key string, meta map[string]string) (bool, error) {
+	if err := m.drainErr(); err != nil {
+		return false, err
+	}
+
+	ss, err := m.sameDevice(r.Context(), m.requestSession(r, key, meta))
+	if err != nil || len(ss) == 0 {
+		return false, err
+	}
+
+	s := ss[0]
+	for _, ds := range ss[1:] {
+		if ds.CreatedAt.After(s.CreatedAt) {
+			s = ds
+		}
+	}
+
+	old := s.ID
+	s.ID, s.Version = m.genID(), 0
+	if s.ID == "" {
+		return false, ErrGenID
+	}
+
+	if m.csrfActive() {
+		s.CSRFToken = m.genID()
+		if s.CSRFToken == "" {
+			return false, ErrGenID
+		}
+	}
+
+	if err = m.recreate(r.Context(), s, old); err != nil {
+		return false, err
+	}
+
+	val, err := m.cookieValue(s)
+	if err != nil {
+		return false, err
+	}
+
+	m.setCookie(w, s.CookieExpiresAt, val)
+	if m.csrfActive() {
+		m.setCSRFCookie(w, s.CookieExpiresAt, s.CSRFToken)
+	}
+
+	return true, nil
+}
+
+// replace revokes the live sessions of the user, created on the same
+// device as the provided new session.
+func (m *Manager) replace(ctx context.Context, s Session) error {
+	ss, err := m.sameDevice(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	for _, ds := range ss {
+		if err = m.revokeSession(ctx, ds); err != nil {
+			return err
+		}
+
+		m.notifyRevoked(ds.UserKey, ds)
+	}
+
+	return nil
+}
+
+// sameDevice retrieves the live regular sessions of the user, other than
+// the provided one, that were created on the same device.
+func (m *Manager) sameDevice(ctx context.Context, s Session) ([]Session, error) {
+	ss, err := m.store.FetchByUserKey(ctx, s.UserKey)
+	if err = m.storeErr(err); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	var res []Session
+	for _, ds := range ss {
+		if ds.ID == s.ID || ds.Kind != "" || ds.Scope != "" || ds.ParentID != "" || IsExpired(ds, now) {
+			continue
+		}
+
+		if k := m.dedupe.DeviceMeta; k != "" {
+			if v := s.Meta[k]; v == "" || ds.Meta[k] != v {
+				continue
+			}
+		} else if !ds.IP.Equal(s.IP) || ds.Agent.OS != s.Agent.OS || ds.Agent.Browser != s.Agent.Browser ||
+			ds.Agent.Device != s.Agent.Device {
+			continue
+		}
+
+		res = append(res, ds)
+	}
+
+	return res, nil
+}
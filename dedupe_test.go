@@ -0,0 +1,128 @@
+package sessionup
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDedupeDevices(t *testing.T) {
+	login := func(m *Manager, ip, device string) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":3000"
+		req.Header.Set("User-Agent", chrome120)
+
+		var mm []Meta
+		if device != "" {
+			mm = append(mm, MetaEntry("device", device))
+		}
+
+		rec := httptest.NewRecorder()
+		if err := m.Init(rec, req, "key", mm...); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return rec.Result().Cookies()[0].Value
+	}
+
+	cc := map[string]struct {
+		Config  *DedupeConfig
+		Devices [3]string
+		Count   int
+		Reused  bool
+	}{
+		"Disabled": {
+			Count: 3,
+		},
+		"Replaced sessions": {
+			Config: &DedupeConfig{},
+			Count:  2,
+		},
+		"Reused session": {
+			Config: &DedupeConfig{Action: DedupeReuse},
+			Count:  2,
+			Reused: true,
+		},
+		"Device IDs": {
+			Config:  &DedupeConfig{Action: DedupeReuse, DeviceMeta: "device"},
+			Devices: [3]string{"a", "a", "b"},
+			Count:   2,
+			Reused:  true,
+		},
+		"Missing device IDs": {
+			Config: &DedupeConfig{Action: DedupeReuse, DeviceMeta: "device"},
+			Count:  3,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			store := mapStore()
+			store.FetchByUserKeyFunc = func(ctx context.Context, key string) ([]Session, error) {
+				var ss []Session
+				for _, cl := range store.CreateCalls() {
+					if s, ok, _ := store.FetchByID(ctx, cl.S.ID); ok && s.UserKey == key {
+						ss = append(ss, s)
+					}
+				}
+
+				return ss, nil
+			}
+
+			var opts []Option
+			if c.Config != nil {
+				opts = append(opts, DedupeDevices(*c.Config))
+			}
+
+			m := NewManager(store, opts...)
+			id1 := login(m, "192.168.1.1", c.Devices[0])
+			s1, _, _ := store.FetchByID(context.Background(), id1)
+			id2 := login(m, "192.168.1.1", c.Devices[1])
+			login(m, "192.168.1.2", c.Devices[2])
+
+			// reused sessions are moved under a new ID.
+			if id1 == id2 {
+				t.Errorf("want new ID, got %q", id2)
+			}
+
+			s2, _, _ := store.FetchByID(context.Background(), id2)
+			if reused := s2.CreatedAt.Equal(s1.CreatedAt); reused != c.Reused {
+				t.Errorf("want %v, got %v", c.Reused, reused)
+			}
+
+			if _, ok, _ := store.FetchByID(context.Background(), id1); ok && c.Reused {
+				t.Errorf("want %q deleted, got kept", id1)
+			}
+
+			ss, _ := store.FetchByUserKey(context.Background(), "key")
+			if len(ss) != c.Count {
+				t.Errorf("want %d, got %d", c.Count, len(ss))
+			}
+		})
+	}
+
+	store := mapStore()
+	store.FetchByUserKeyFunc = func(_ context.Context, _ string) ([]Session, error) {
+		return []Session{{
+			ExpiresAt: time.Now().Add(time.Hour),
+			ID:        "other",
+			UserKey:   "key",
+			Kind:      KindTrustedDevice,
+			Meta:      map[string]string{"device": "a"},
+		}}, nil
+	}
+
+	m := NewManager(store, DedupeDevices(DedupeConfig{DeviceMeta: "device"}))
+	if err := m.Init(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "key", MetaEntry("device", "a")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(store.DeleteByIDCalls()) != 0 {
+		t.Errorf("want no revocations, got %v", store.DeleteByIDCalls())
+	}
+}
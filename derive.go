@@ -0,0 +1,139 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// scopeSeparator separates the session cookie name and the scope in
+// the names of scoped session cookies.
+const scopeSeparator = "-"
+
+// ErrNoScope is returned when a child session is being derived without
+// a scope.
+var ErrNoScope = errors.New("child session scope is not set")
+
+// Scoped returns a copy of the manager that works with child sessions
+// of the provided scope, created by Derive. Its middlewares read the
+// session ID from the '<cookie name>-<scope>' cookie and accept only
+// sessions of that scope, while middlewares of regular managers never
+// accept scoped sessions.
+func (m *Manager) Scoped(scope string) *Manager {
+	return m.Clone(func(cm *Manager) {
+		cm.cookie.name = m.cookie.name + scopeSeparator + scope
+		cm.cookie.oldNames = nil
+		cm.scope = scope
+	})
+}
+
+// Derive creates a child session of the session stored in the context
+// with the provided scope and TTL, and sets its cookie (see Scoped).
+// Child sessions can be used to hand limited credentials to embedded
+// components, e.g. a support chat widget. They never outlive their
+// parent: the TTL is capped by parent's expiration time (TTL <= 0 uses
+// all of it) and child sessions are rejected (and deleted) once the
+// parent is revoked.
+// ErrUnauthorized is returned if the context session is not set.
+func (m *Manager) Derive(ctx context.Context, w http.ResponseWriter, scope string, ttl time.Duration) (Session, error) {
+	if ctx == nil {
+		return Session{}, ErrNilContext
+	}
+
+	if w == nil {
+		return Session{}, ErrNilWriter
+	}
+
+	if scope == "" {
+		return Session{}, ErrNoScope
+	}
+
+	if err := m.drainErr(); err != nil {
+		return Session{}, err
+	}
+
+	p, ok := FromContext(ctx)
+	if !ok {
+		return Session{}, ErrUnauthorized
+	}
+
+	s := Session{
+		CreatedAt: monotonicNow(),
+		ExpiresAt: p.ExpiresAt,
+		ID:        m.genID(),
+		UserKey:   p.UserKey,
+		OrgKey:    p.OrgKey,
+		IP:        p.IP,
+		Agent:     p.Agent,
+		Affinity:  p.Affinity,
+		ParentID:  p.ID,
+		Scope:     scope,
+	}
+
+	if s.ID == "" {
+		return Session{}, ErrGenID
+	}
+
+	if exp := s.CreatedAt.Add(ttl); ttl > 0 && (s.ExpiresAt.IsZero() || exp.Before(s.ExpiresAt)) {
+		s.ExpiresAt = exp
+	}
+
+	if s.ExpiresAt.IsZero() {
+		s.ExpiresAt = time.Now().Add(time.Hour * 24) // for temporary sessions
+	}
+
+	sm := m.Scoped(scope)
+	if sm.csrfActive() {
+		s.CSRFToken = m.genID()
+		if s.CSRFToken == "" {
+			return Session{}, ErrGenID
+		}
+	}
+
+	exp := sm.cookieExpiry(s.ExpiresAt)
+	s.CookieExpiresAt = exp
+
+	val, err := sm.cookieValue(s)
+	if err != nil {
+		return Session{}, err
+	}
+
+	if err := m.storeErr(m.store.Create(ctx, s)); err != nil {
+		return Session{}, err
+	}
+
+	m.hints.remember(s)
+	m.stat(statCreated)
+	sm.setCookie(w, exp, val)
+	if sm.csrfActive() {
+		sm.setCSRFCookie(w, exp, s.CSRFToken)
+	}
+
+	return s, nil
+}
+
+// checkScope checks whether the session's scope is accepted by the
+// manager and, for child sessions, whether their parent still exists.
+// Orphaned child sessions are deleted.
+func (m *Manager) checkScope(ctx context.Context, s Session) error {
+	if s.Scope != m.scope {
+		return ErrUnauthorized
+	}
+
+	if s.ParentID == "" {
+		return nil
+	}
+
+	_, ok, err := m.store.FetchByID(ctx, s.ParentID)
+	if err = m.storeErr(err); err != nil {
+		return err
+	}
+
+	if !ok {
+		m.revokeSession(ctx, s)
+		return ErrUnauthorized
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapStore returns a StoreMock backed by a map of sessions.
+func mapStore() *StoreMock {
+	var mu sync.Mutex
+	ss := make(map[string]Session)
+
+	return &StoreMock{
+		CreateFunc: func(_ context.Context, s Session) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ss[s.ID] = s
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			s, ok := ss[id]
+			return s, ok, nil
+		},
+		FetchByUserKeyFunc: func(_ context.Context, key string) ([]Session, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			var res []Session
+			for _, s := range ss {
+				if s.UserKey == key {
+					res = append(res, s)
+				}
+			}
+			return res, nil
+		},
+		DeleteByIDFunc: func(_ context.Context, id string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(ss, id)
+			return nil
+		},
+	}
+}
+
+func TestScoped(t *testing.T) {
+	m := NewManager(&StoreMock{}, OldCookieNames("old"))
+	sm := m.Scoped("chat")
+	if sm.cookie.name != defaultName+"-chat" {
+		t.Errorf("want %q, got %q", defaultName+"-chat", sm.cookie.name)
+	}
+
+	if sm.scope != "chat" {
+		t.Errorf("want %q, got %q", "chat", sm.scope)
+	}
+
+	if sm.cookie.oldNames != nil {
+		t.Errorf("want nil, got %v", sm.cookie.oldNames)
+	}
+
+	if m.scope != "" || m.cookie.name != defaultName {
+		t.Error("want original manager to be unmodified")
+	}
+}
+
+func TestDerive(t *testing.T) {
+	parentExp := time.Now().Add(time.Hour)
+
+	cc := map[string]struct {
+		Scope string
+		TTL   time.Duration
+		Ctx   bool
+		Err   error
+		Exp   time.Time
+	}{
+		"No context session": {
+			Scope: "chat",
+			Err:   ErrUnauthorized,
+		},
+		"No scope": {
+			Ctx: true,
+			Err: ErrNoScope,
+		},
+		"Short TTL": {
+			Scope: "chat",
+			TTL:   time.Minute,
+			Ctx:   true,
+			Exp:   time.Now().Add(time.Minute),
+		},
+		"TTL capped by parent": {
+			Scope: "chat",
+			TTL:   time.Hour * 2,
+			Ctx:   true,
+			Exp:   parentExp,
+		},
+		"No TTL": {
+			Scope: "chat",
+			Ctx:   true,
+			Exp:   parentExp,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m := NewManager(mapStore())
+			ctx := context.Background()
+			if c.Ctx {
+				ctx = NewContext(ctx, Session{ID: "parent", UserKey: "key", ExpiresAt: parentExp})
+			}
+
+			rec := httptest.NewRecorder()
+			s, err := m.Derive(ctx, rec, c.Scope, c.TTL)
+			if err != c.Err {
+				t.Fatalf("want %v, got %v", c.Err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if s.ParentID != "parent" || s.UserKey != "key" || s.Scope != c.Scope {
+				t.Errorf("want child of %q, got %v", "parent", s)
+			}
+
+			if d := s.ExpiresAt.Sub(c.Exp); d > time.Second || d < -time.Second {
+				t.Errorf("want %v, got %v", c.Exp, s.ExpiresAt)
+			}
+
+			cookies := rec.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != defaultName+"-"+c.Scope || cookies[0].Value != s.ID {
+				t.Errorf("want scoped cookie, got %v", cookies)
+			}
+		})
+	}
+}
+
+func TestDeriveAuth(t *testing.T) {
+	store := mapStore()
+	m := NewManager(store)
+	parent := Session{ID: "parent", UserKey: "key", ExpiresAt: time.Now().Add(time.Hour)}
+	store.Create(context.Background(), parent)
+
+	child, err := m.Derive(NewContext(context.Background(), parent), httptest.NewRecorder(), "chat", time.Minute)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	auth := func(m *Manager, name, id string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: name, Value: id})
+		rec := httptest.NewRecorder()
+		m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := auth(m, defaultName, child.ID); code != http.StatusUnauthorized {
+		t.Errorf("regular manager: want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	if code := auth(m.Scoped("chat"), defaultName+"-chat", parent.ID); code != http.StatusUnauthorized {
+		t.Errorf("scoped manager with parent: want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	if code := auth(m.Scoped("other"), defaultName+"-other", child.ID); code != http.StatusUnauthorized {
+		t.Errorf("manager of another scope: want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	if code := auth(m.Scoped("chat"), defaultName+"-chat", child.ID); code != http.StatusOK {
+		t.Errorf("scoped manager: want %d, got %d", http.StatusOK, code)
+	}
+
+	store.DeleteByID(context.Background(), parent.ID)
+
+	if code := auth(m.Scoped("chat"), defaultName+"-chat", child.ID); code != http.StatusUnauthorized {
+		t.Errorf("revoked parent: want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	if _, ok, _ := store.FetchByID(context.Background(), child.ID); ok {
+		t.Error("want orphaned child session to be deleted")
+	}
+}
@@ -0,0 +1,78 @@
+package sessionup
+
+import (
+	"context"
+
+	"xojoc.pw/useragent"
+)
+
+const (
+	// DeviceMobile is the device type of phones and tablets.
+	DeviceMobile = "mobile"
+
+	// DeviceDesktop is the device type of desktop browsers.
+	DeviceDesktop = "desktop"
+
+	// DeviceBot is the device type of crawlers and other automated
+	// clients.
+	DeviceBot = "bot"
+
+	// DeviceUnknown is the device type of clients whose User-Agent
+	// could not be recognized or was not parsed.
+	DeviceUnknown = "unknown"
+)
+
+// deviceType determines the device type of the parsed User-Agent.
+func deviceType(a *useragent.UserAgent) string {
+	switch {
+	case a == nil:
+		return DeviceUnknown
+	case a.Type == useragent.Crawler:
+		return DeviceBot
+	case a.Mobile || a.Tablet:
+		return DeviceMobile
+	case a.Type == useragent.Browser:
+		return DeviceDesktop
+	default:
+		return DeviceUnknown
+	}
+}
+
+// RevokeByDevice deletes all sessions under the provided user key that
+// were created on the provided device type (one of DeviceMobile,
+// DeviceDesktop, DeviceBot or DeviceUnknown). Sessions created without
+// User-Agent data are treated as DeviceUnknown.
+// Function will be no-op and return nil, if no sessions are found.
+func (m *Manager) RevokeByDevice(ctx context.Context, key, device string) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	ss, err := m.store.FetchByUserKey(ctx, key)
+	if err = m.storeErr(err); err != nil {
+		return err
+	}
+
+	for _, s := range ss {
+		if s.Kind != "" {
+			continue
+		}
+
+		dev := s.Agent.Device
+		if dev == "" {
+			dev = DeviceUnknown
+		}
+
+		if dev != device {
+			continue
+		}
+
+		if err := m.revokeSession(ctx, s); err != nil {
+			return err
+		}
+
+		m.notifyRevoked(key, s)
+	}
+
+	return nil
+}
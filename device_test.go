@@ -0,0 +1,129 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"xojoc.pw/useragent"
+)
+
+func TestDeviceType(t *testing.T) {
+	cc := map[string]struct {
+		Agent  *useragent.UserAgent
+		Device string
+	}{
+		"Missing agent": {
+			Device: DeviceUnknown,
+		},
+		"Crawler": {
+			Agent:  &useragent.UserAgent{Type: useragent.Crawler, Mobile: true},
+			Device: DeviceBot,
+		},
+		"Phone": {
+			Agent:  &useragent.UserAgent{Type: useragent.Browser, Mobile: true},
+			Device: DeviceMobile,
+		},
+		"Tablet": {
+			Agent:  &useragent.UserAgent{Type: useragent.Browser, Tablet: true},
+			Device: DeviceMobile,
+		},
+		"Desktop": {
+			Agent:  &useragent.UserAgent{Type: useragent.Browser},
+			Device: DeviceDesktop,
+		},
+		"Unrecognized agent": {
+			Agent:  &useragent.UserAgent{Type: useragent.Unknown},
+			Device: DeviceUnknown,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			if d := deviceType(c.Agent); d != c.Device {
+				t.Errorf("want %q, got %q", c.Device, d)
+			}
+		})
+	}
+}
+
+func TestRevokeByDevice(t *testing.T) {
+	ses := func(id, dev string) Session {
+		s := Session{ID: id}
+		s.Agent.Device = dev
+		return s
+	}
+
+	cc := map[string]struct {
+		FetchErr  error
+		DeleteErr error
+		Device    string
+		Err       error
+		Deleted   []string
+	}{
+		"Error returned by store.FetchByUserKey": {
+			FetchErr: errors.New("error"),
+			Device:   DeviceMobile,
+			Err:      errors.New("error"),
+		},
+		"Error returned by store.DeleteByID": {
+			DeleteErr: errors.New("error"),
+			Device:    DeviceMobile,
+			Err:       errors.New("error"),
+			Deleted:   []string{"m1"},
+		},
+		"Mobile sessions revoked": {
+			Device:  DeviceMobile,
+			Deleted: []string{"m1", "m2"},
+		},
+		"Unknown sessions revoked": {
+			Device:  DeviceUnknown,
+			Deleted: []string{"u1", "u2"},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			s := &StoreMock{
+				FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+					if c.FetchErr != nil {
+						return nil, c.FetchErr
+					}
+
+					return []Session{
+						ses("m1", DeviceMobile),
+						ses("d1", DeviceDesktop),
+						ses("u1", ""),
+						{ID: "canary", Kind: KindCanary},
+						ses("m2", DeviceMobile),
+						ses("u2", DeviceUnknown),
+					}, nil
+				},
+				DeleteByIDFunc: func(_ context.Context, _ string) error {
+					return c.DeleteErr
+				},
+			}
+
+			m := Manager{store: s}
+			err := m.RevokeByDevice(context.Background(), "key", c.Device)
+			if (err == nil) != (c.Err == nil) {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			ff := s.DeleteByIDCalls()
+			if len(ff) != len(c.Deleted) {
+				t.Fatalf("want %d, got %d", len(c.Deleted), len(ff))
+			}
+
+			for i, f := range ff {
+				if f.ID != c.Deleted[i] {
+					t.Errorf("want %q, got %q", c.Deleted[i], f.ID)
+				}
+			}
+		})
+	}
+}
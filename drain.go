@@ -0,0 +1,173 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+)
+
+const (
+	statActiveSessions = "active_sessions"
+
+	// activeWindow specifies how long a session is considered active
+	// after its last successful authentication during draining.
+	activeWindow = time.Minute * 5
+)
+
+// ErrDraining is returned by Init and Derive while the manager is
+// draining (see Drain).
+var ErrDraining = errors.New("new sessions are not issued during draining")
+
+// DrainError sets the error returned by Init and Derive while the
+// manager is draining, e.g. an error that the application maps to
+// a redirect toward the new fleet.
+// Defaults to ErrDraining.
+func DrainError(err error) Option {
+	return func(m *Manager) {
+		m.drain.err = err
+	}
+}
+
+// drainState holds the draining mode data shared by a manager and its
+// clones.
+type drainState struct {
+	mu     sync.Mutex
+	active bool
+	seen   map[string]drainSeen
+}
+
+// drainSeen holds the data of a session authenticated during draining.
+type drainSeen struct {
+	at  time.Time
+	exp time.Time
+}
+
+// Drain switches the manager into draining mode, in which Init and
+// Derive are refused (see DrainError), but existing sessions keep
+// being validated, so that live users of the old instances can finish
+// their work during blue/green deploys. Drain blocks until the provided
+// point in time, after which draining mode remains on and nil is
+// returned, or until the context is cancelled, in which case draining
+// is aborted (new sessions are issued again) and the context's error is
+// returned. Draining mode is shared with the manager's clones.
+func (m *Manager) Drain(ctx context.Context, until time.Time) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if m.drain.state == nil {
+		m.drain.state = &drainState{}
+	}
+
+	d := m.drain.state
+	d.mu.Lock()
+	d.active = true
+	d.seen = make(map[string]drainSeen)
+	d.mu.Unlock()
+
+	if m.stats != nil {
+		m.stats.Set(statActiveSessions, expvar.Func(func() interface{} {
+			return m.ActiveSessions()
+		}))
+	}
+
+	t := time.NewTimer(time.Until(until))
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		d.mu.Lock()
+		d.active = false
+		d.seen = nil
+		d.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// Draining checks whether the manager is in draining mode or not.
+func (m *Manager) Draining() bool {
+	d := m.drain.state
+	if d == nil {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.active
+}
+
+// ActiveSessions returns the number of sessions that were successfully
+// authenticated by the manager (or its clones) during draining in the
+// last 5 minutes and are not expired yet. It is also published as
+// active_sessions in the expvar map (see Expvar) once draining starts.
+// Zero is returned if the manager is not draining.
+func (m *Manager) ActiveSessions() int {
+	d := m.drain.state
+	if d == nil {
+		return 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+
+	var n int
+	for id, ds := range d.seen {
+		if now.Sub(ds.at) > activeWindow || !ds.exp.After(now) {
+			delete(d.seen, id)
+			continue
+		}
+
+		n++
+	}
+
+	return n
+}
+
+// drainErr returns the error that should be returned instead of
+// issuing a new session, if the manager is draining.
+func (m *Manager) drainErr() error {
+	if !m.Draining() {
+		return nil
+	}
+
+	if m.drain.err != nil {
+		return m.drain.err
+	}
+
+	return ErrDraining
+}
+
+// trackActive remembers the authenticated session, if the manager is
+// draining.
+func (m *Manager) trackActive(s Session) {
+	d := m.drain.state
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	if d.active {
+		d.seen[s.ID] = drainSeen{at: time.Now(), exp: s.ExpiresAt}
+	}
+	d.mu.Unlock()
+}
+
+// forgetActive removes the revoked session from the active sessions.
+func (m *Manager) forgetActive(id string) {
+	d := m.drain.state
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	delete(d.seen, id)
+	d.mu.Unlock()
+}
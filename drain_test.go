@@ -0,0 +1,158 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrainError(t *testing.T) {
+	err := errors.New("error")
+	m := Manager{}
+	DrainError(err)(&m)
+	if m.drain.err != err {
+		t.Errorf("want %v, got %v", err, m.drain.err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	m := NewManager(mapStore(), Expvar("sessionup_drain_test"))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if err := m.Init(rec, req, "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	cookie := rec.Result().Cookies()[0]
+	auth := func() int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.AddCookie(cookie)
+		m.Auth(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- m.Drain(ctx, time.Now().Add(time.Hour))
+	}()
+
+	for !m.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !m.Clone().Draining() {
+		t.Error("want true, got false")
+	}
+
+	if err := m.Init(httptest.NewRecorder(), req, "key"); err != ErrDraining {
+		t.Errorf("want %v, got %v", ErrDraining, err)
+	}
+
+	if _, err := m.Derive(NewContext(ctx, Session{ID: "id"}), httptest.NewRecorder(), "chat", 0); err != ErrDraining {
+		t.Errorf("want %v, got %v", ErrDraining, err)
+	}
+
+	if n := m.ActiveSessions(); n != 0 {
+		t.Errorf("want %d, got %d", 0, n)
+	}
+
+	if code := auth(); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+
+	if n := m.ActiveSessions(); n != 1 {
+		t.Errorf("want %d, got %d", 1, n)
+	}
+
+	if v := m.stats.Get(statActiveSessions).String(); v != "1" {
+		t.Errorf("want %q, got %q", "1", v)
+	}
+
+	if err := m.RevokeByID(ctx, cookie.Value); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if n := m.ActiveSessions(); n != 0 {
+		t.Errorf("want %d, got %d", 0, n)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+
+	if m.Draining() {
+		t.Error("want false, got true")
+	}
+
+	if err := m.Init(httptest.NewRecorder(), req, "key"); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
+
+func TestDrainUntil(t *testing.T) {
+	err := errors.New("error")
+	m := NewManager(mapStore(), DrainError(err))
+
+	if res := m.Drain(context.Background(), time.Now().Add(-time.Second)); res != nil {
+		t.Errorf("want nil, got %v", res)
+	}
+
+	if !m.Draining() {
+		t.Error("want true, got false")
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if res := m.Init(httptest.NewRecorder(), req, "key"); res != err {
+		t.Errorf("want %v, got %v", err, res)
+	}
+}
+
+func TestRevokeByIDExtActive(t *testing.T) {
+	m := NewManager(&StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id, UserKey: "key"}, true, nil
+		},
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return nil
+		},
+	})
+
+	m.drain.state.seen = map[string]drainSeen{"other": {at: time.Now(), exp: time.Now().Add(time.Hour)}}
+	ctx := NewContext(context.Background(), Session{ID: "id", UserKey: "key"})
+	if err := m.RevokeByIDExt(ctx, "other"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(m.drain.state.seen) != 0 {
+		t.Errorf("want no active sessions, got %v", m.drain.state.seen)
+	}
+}
+
+func TestActiveSessions(t *testing.T) {
+	m := NewManager(&StoreMock{})
+	if n := m.ActiveSessions(); n != 0 {
+		t.Errorf("want %d, got %d", 0, n)
+	}
+
+	m.drain.state.active = true
+	m.drain.state.seen = map[string]drainSeen{
+		"1": {at: time.Now(), exp: time.Now().Add(time.Hour)},
+		"2": {at: time.Now().Add(-activeWindow * 2), exp: time.Now().Add(time.Hour)},
+		"3": {at: time.Now(), exp: time.Now().Add(-time.Second)},
+	}
+
+	if n := m.ActiveSessions(); n != 1 {
+		t.Errorf("want %d, got %d", 1, n)
+	}
+
+	if len(m.drain.state.seen) != 1 {
+		t.Errorf("want %d, got %d", 1, len(m.drain.state.seen))
+	}
+}
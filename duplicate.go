@@ -0,0 +1,93 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrAmbiguousCookie is returned when the request carries multiple
+// session cookies with different values and the duplicate cookie
+// policy does not accept them (see DuplicateCookies).
+var ErrAmbiguousCookie = errors.New("ambiguous session cookies")
+
+// DuplicatePolicy determines how requests with multiple session cookies
+// of the same name (e.g. planted by a sibling subdomain with a more
+// specific path or a broader domain) are handled.
+type DuplicatePolicy int
+
+const (
+	// DuplicateMostSpecific uses the first cookie of the request.
+	// Browsers send cookies with longer paths first, so it is the most
+	// specific one, but cookies that differ only in their domains are
+	// sent in an undefined order.
+	DuplicateMostSpecific DuplicatePolicy = iota
+
+	// DuplicateReject rejects the request with ErrAmbiguousCookie.
+	DuplicateReject
+
+	// DuplicateAgree checks all cookies and rejects the request with
+	// ErrAmbiguousCookie, unless all of them belong to valid sessions
+	// of the same user key. The first cookie's session is used.
+	DuplicateAgree
+)
+
+// DuplicateCookies sets the policy used when the request carries
+// multiple session cookies with different values. Identical cookies
+// are never considered ambiguous.
+// Defaults to DuplicateMostSpecific.
+func DuplicateCookies(p DuplicatePolicy) Option {
+	return func(m *Manager) {
+		m.duplicates = p
+	}
+}
+
+// checkDuplicates checks the other cookies of the request, that have
+// the same name as the provided one, according to the duplicate cookie
+// policy. The provided session must be the one of the provided cookie.
+func (m *Manager) checkDuplicates(ctx context.Context, r *http.Request, c *http.Cookie, s Session) error {
+	if m.duplicates == DuplicateMostSpecific {
+		return nil
+	}
+
+	for _, dc := range r.Cookies() {
+		if dc.Name != c.Name || dc.Value == c.Value {
+			continue
+		}
+
+		if m.duplicates == DuplicateReject {
+			return ErrAmbiguousCookie
+		}
+
+		// the other sessions must not modify the response, e.g. by
+		// refreshing their stateless cookies.
+		ds, ok, err := m.loadSession(ctx, discardWriter{}, dc.Value)
+		if err != nil {
+			return err
+		}
+
+		if !ok || ds.Kind != "" || ds.Scope != s.Scope || ds.UserKey != s.UserKey {
+			return ErrAmbiguousCookie
+		}
+	}
+
+	return nil
+}
+
+// discardWriter is a response writer that discards everything written
+// to it.
+type discardWriter struct{}
+
+// Header implements http.ResponseWriter interface's Header method.
+func (discardWriter) Header() http.Header {
+	return http.Header{}
+}
+
+// Write implements http.ResponseWriter interface's Write method.
+func (discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// WriteHeader implements http.ResponseWriter interface's WriteHeader
+// method.
+func (discardWriter) WriteHeader(_ int) {}
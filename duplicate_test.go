@@ -0,0 +1,78 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDuplicateCookies(t *testing.T) {
+	ms := mapStore()
+	exp := time.Now().Add(time.Hour)
+	ms.Create(context.Background(), Session{ID: "alice1", UserKey: "alice", ExpiresAt: exp})
+	ms.Create(context.Background(), Session{ID: "alice2", UserKey: "alice", ExpiresAt: exp})
+	ms.Create(context.Background(), Session{ID: "bob", UserKey: "bob", ExpiresAt: exp})
+
+	cc := map[string]struct {
+		Policy DuplicatePolicy
+		Values []string
+		Err    error
+	}{
+		"Most specific": {
+			Policy: DuplicateMostSpecific,
+			Values: []string{"alice1", "bob"},
+		},
+		"Reject single": {
+			Policy: DuplicateReject,
+			Values: []string{"alice1"},
+		},
+		"Reject identical": {
+			Policy: DuplicateReject,
+			Values: []string{"alice1", "alice1"},
+		},
+		"Reject": {
+			Policy: DuplicateReject,
+			Values: []string{"alice1", "alice2"},
+			Err:    ErrAmbiguousCookie,
+		},
+		"Agree": {
+			Policy: DuplicateAgree,
+			Values: []string{"alice1", "alice2"},
+		},
+		"Agree with other user": {
+			Policy: DuplicateAgree,
+			Values: []string{"alice1", "bob"},
+			Err:    ErrAmbiguousCookie,
+		},
+		"Agree with missing session": {
+			Policy: DuplicateAgree,
+			Values: []string{"alice1", "missing"},
+			Err:    ErrAmbiguousCookie,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m := NewManager(ms, Validate(false), DuplicateCookies(c.Policy))
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			for _, v := range c.Values {
+				req.AddCookie(&http.Cookie{Name: defaultName, Value: v})
+			}
+
+			s, err := m.authenticate(httptest.NewRecorder(), req, nil)
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if err == nil && s.ID != c.Values[0] {
+				t.Errorf("want %q, got %q", c.Values[0], s.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+const (
+	defaultEnrichInterval = time.Hour * 24
+	defaultEnrichMinAge   = time.Hour * 24
+)
+
+// Enricher is used to refresh the fields of a session that are derived
+// from its other data (e.g. the location resolved from the IP address),
+// since data computed when the session was created gets stale over the
+// lifetime of long-lived sessions.
+type Enricher interface {
+	// Enrich should return the session with its derived fields
+	// refreshed and whether any of them changed. The session's
+	// metadata map must not be modified in place.
+	// Error should be returned on system errors only.
+	Enrich(ctx context.Context, s Session) (Session, bool, error)
+}
+
+// EnricherFunc is an adapter that allows ordinary functions to be used
+// as Enrichers.
+type EnricherFunc func(ctx context.Context, s Session) (Session, bool, error)
+
+// Enrich implements Enricher interface.
+func (f EnricherFunc) Enrich(ctx context.Context, s Session) (Session, bool, error) {
+	return f(ctx, s)
+}
+
+// GeoEnricher produces an Enricher that stores the location of the
+// session's IP address, resolved with the provided function (e.g. a
+// GeoIP database lookup), in the session's metadata under the provided
+// key. Sessions without IP addresses and unresolved locations are left
+// unchanged.
+func GeoEnricher(key string, locate func(ip net.IP) string) Enricher {
+	return EnricherFunc(func(_ context.Context, s Session) (Session, bool, error) {
+		if len(s.IP) == 0 {
+			return s, false, nil
+		}
+
+		loc := locate(s.IP)
+		if loc == "" || s.Meta[key] == loc {
+			return s, false, nil
+		}
+
+		meta := make(map[string]string, len(s.Meta)+1)
+		for k, v := range s.Meta {
+			meta[k] = v
+		}
+
+		meta[key] = loc
+		s.Meta = meta
+
+		return s, true, nil
+	})
+}
+
+// EnrichConfig holds the data needed to refresh the derived fields of
+// long-lived sessions.
+type EnrichConfig struct {
+	// Enrichers specifies the enrichers applied to each session, in
+	// order.
+	Enrichers []Enricher
+
+	// Interval specifies how often the sessions are refreshed by
+	// WatchEnrichment.
+	// Defaults to the value stored in defaultEnrichInterval.
+	Interval time.Duration
+
+	// MinAge specifies the age a session must reach to be refreshed,
+	// so that sessions enriched at creation are not refreshed again
+	// right away.
+	// Defaults to the value stored in defaultEnrichMinAge.
+	MinAge time.Duration
+
+	// OnError specifies the function that is called when
+	// WatchEnrichment fails to refresh the sessions.
+	// By default it is not set.
+	OnError func(error)
+}
+
+// Enrich iterates over all live regular sessions of the store that are
+// older than the configured minimum age, applies the configured
+// enrichers to them and saves the changed ones. The number of saved
+// sessions is returned. Sessions modified concurrently (see CASStore)
+// or revoked in the meantime are skipped until the next run.
+// The store must implement IterStore interface and either CASStore or
+// UpdateStore interface, otherwise ErrNotSupported is returned.
+func (m *Manager) Enrich(ctx context.Context, cfg EnrichConfig) (int, error) {
+	if ctx == nil {
+		return 0, ErrNilContext
+	}
+
+	is, ok := m.enrichStore()
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	if cfg.MinAge <= 0 {
+		cfg.MinAge = defaultEnrichMinAge
+	}
+
+	now := time.Now()
+
+	var (
+		changed []Session
+		eerr    error
+	)
+
+	err := is.Iterate(ctx, func(s Session) bool {
+		if s.Kind != "" || IsExpired(s, now) || now.Sub(s.CreatedAt) < cfg.MinAge {
+			return ctx.Err() == nil
+		}
+
+		var dirty bool
+		for _, e := range cfg.Enrichers {
+			es, ok, err := e.Enrich(ctx, s)
+			if err != nil {
+				eerr = err
+				return false
+			}
+
+			if ok {
+				s, dirty = es, true
+			}
+		}
+
+		if dirty {
+			changed = append(changed, s)
+		}
+
+		return ctx.Err() == nil
+	})
+	if err = m.storeErr(err); err != nil {
+		return 0, err
+	}
+
+	if eerr != nil {
+		return 0, eerr
+	}
+
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// sessions are saved after the iteration, since stores are not
+	// required to support modifications during it.
+	var n int
+	for _, s := range changed {
+		_, err = m.update(ctx, s)
+		switch {
+		case err == nil:
+			n++
+		case errors.Is(err, ErrConflict), errors.Is(err, ErrNotFound):
+		default:
+			return n, m.storeErr(err)
+		}
+	}
+
+	return n, nil
+}
+
+// WatchEnrichment refreshes the sessions (see Enrich) immediately and
+// then periodically, passing the errors to the configured function. It
+// blocks until the context is done.
+func (m *Manager) WatchEnrichment(ctx context.Context, cfg EnrichConfig) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if _, ok := m.enrichStore(); !ok {
+		return ErrNotSupported
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultEnrichInterval
+	}
+
+	t := time.NewTicker(cfg.Interval)
+	defer t.Stop()
+
+	for {
+		_, err := m.Enrich(ctx, cfg)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil && cfg.OnError != nil:
+			cfg.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// enrichStore returns the store as IterStore, if it implements the
+// interfaces needed to refresh sessions.
+func (m *Manager) enrichStore() (IterStore, bool) {
+	is, ok := m.store.(IterStore)
+	_, cas := m.store.(CASStore)
+	_, upd := m.store.(UpdateStore)
+
+	return is, ok && (cas || upd)
+}
@@ -0,0 +1,154 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type enrichStoreMock struct {
+	*iterStoreMock
+	UpdateFunc func(ctx context.Context, s Session) error
+	updated    []Session
+}
+
+func (e *enrichStoreMock) Update(ctx context.Context, s Session) error {
+	e.updated = append(e.updated, s)
+	return e.UpdateFunc(ctx, s)
+}
+
+func TestGeoEnricher(t *testing.T) {
+	e := GeoEnricher("geo", func(ip net.IP) string {
+		if ip.Equal(net.ParseIP("10.0.0.1")) {
+			return "LT"
+		}
+
+		return ""
+	})
+
+	cc := map[string]struct {
+		Session Session
+		Meta    map[string]string
+		Changed bool
+	}{
+		"No IP":          {Session: Session{Meta: map[string]string{"a": "b"}}, Meta: map[string]string{"a": "b"}},
+		"Unknown IP":     {Session: Session{IP: net.ParseIP("10.0.0.2")}},
+		"Same location":  {Session: Session{IP: net.ParseIP("10.0.0.1"), Meta: map[string]string{"geo": "LT"}}, Meta: map[string]string{"geo": "LT"}},
+		"Stale location": {Session: Session{IP: net.ParseIP("10.0.0.1"), Meta: map[string]string{"geo": "DE", "a": "b"}}, Meta: map[string]string{"geo": "LT", "a": "b"}, Changed: true},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			s, ok, err := e.Enrich(context.Background(), c.Session)
+			if err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if ok != c.Changed {
+				t.Errorf("want %v, got %v", c.Changed, ok)
+			}
+
+			if !reflect.DeepEqual(c.Meta, s.Meta) {
+				t.Errorf("want %v, got %v", c.Meta, s.Meta)
+			}
+
+			if c.Changed && reflect.DeepEqual(c.Session.Meta, s.Meta) {
+				t.Error("want copied metadata, got modified")
+			}
+		})
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	now := time.Now()
+	ss := []Session{
+		{ID: "old", CreatedAt: now.Add(-time.Hour * 48), ExpiresAt: now.Add(time.Hour)},
+		{ID: "young", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{ID: "expired", CreatedAt: now.Add(-time.Hour * 48), ExpiresAt: now.Add(-time.Hour)},
+		{ID: "ticket", CreatedAt: now.Add(-time.Hour * 48), ExpiresAt: now.Add(time.Hour), Kind: KindTicket},
+		{ID: "conflict", CreatedAt: now.Add(-time.Hour * 48), ExpiresAt: now.Add(time.Hour)},
+	}
+
+	store := &enrichStoreMock{
+		iterStoreMock: garbageStore(ss, nil),
+		UpdateFunc: func(_ context.Context, s Session) error {
+			if s.ID == "conflict" {
+				return ErrNotFound
+			}
+
+			return nil
+		},
+	}
+
+	mark := EnricherFunc(func(_ context.Context, s Session) (Session, bool, error) {
+		s.Meta = map[string]string{"enriched": "1"}
+		return s, true, nil
+	})
+
+	var ctx context.Context
+	m := NewManager(store)
+	if _, err := m.Enrich(ctx, EnrichConfig{}); err != ErrNilContext {
+		t.Errorf("want %v, got %v", ErrNilContext, err)
+	}
+
+	n, err := m.Enrich(context.Background(), EnrichConfig{Enrichers: []Enricher{mark}})
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if n != 1 {
+		t.Errorf("want %d, got %d", 1, n)
+	}
+
+	if len(store.updated) != 2 || store.updated[0].ID != "old" || store.updated[0].Meta["enriched"] != "1" || store.updated[0].Version != 1 {
+		t.Errorf("want old and conflict sessions, got %v", store.updated)
+	}
+
+	fail := EnricherFunc(func(_ context.Context, s Session) (Session, bool, error) {
+		return s, false, errors.New("error")
+	})
+
+	if _, err = m.Enrich(context.Background(), EnrichConfig{Enrichers: []Enricher{fail}}); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+
+	m = NewManager(garbageStore(ss, nil))
+	if _, err = m.Enrich(context.Background(), EnrichConfig{}); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+
+	if err = m.WatchEnrichment(context.Background(), EnrichConfig{}); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestWatchEnrichment(t *testing.T) {
+	m := NewManager(&enrichStoreMock{
+		iterStoreMock: garbageStore(nil, errors.New("error")),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int
+	err := m.WatchEnrichment(ctx, EnrichConfig{
+		Interval: time.Millisecond,
+		OnError: func(_ error) {
+			calls++
+			if calls == 3 {
+				cancel()
+			}
+		},
+	})
+
+	if err != context.Canceled {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+}
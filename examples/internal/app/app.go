@@ -0,0 +1,172 @@
+// Package app implements the JSON API shared by the example servers:
+// the login, session list, "revoke other sessions" and step-up
+// authentication flows. The examples differ only in the store (and
+// options) their managers are created with.
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+const (
+	// stepUpKey is the metadata key under which the time of the last
+	// password confirmation is stored.
+	stepUpKey = "step_up"
+
+	// stepUpAge specifies how long the password confirmation is
+	// valid for sensitive routes.
+	stepUpAge = time.Minute * 5
+)
+
+// passwords holds the users' passwords. Real applications should store
+// password hashes in a database.
+var passwords = map[string]string{
+	"alice": "wonderland",
+	"bob":   "builder",
+}
+
+// NewServer registers the example's routes.
+func NewServer(manager *sessionup.Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/login", post(login(manager)))
+	mux.Handle("/logout", post(manager.Auth(logout(manager))))
+	mux.Handle("/sessions", manager.Auth(sessions(manager)))
+	mux.Handle("/revoke-other", post(manager.Auth(revokeOther(manager))))
+	mux.Handle("/step-up", post(manager.Auth(stepUp(manager))))
+	mux.Handle("/settings", manager.RequireStepUp(stepUpKey, stepUpAge)(http.HandlerFunc(settings)))
+
+	return mux
+}
+
+// post rejects requests with methods other than POST.
+func post(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized checks the user's password.
+func authorized(name, password string) bool {
+	p, ok := passwords[name]
+	return ok && p == password
+}
+
+func login(manager *sessionup.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.FormValue("name")
+		if !authorized(name, r.FormValue("password")) {
+			http.Error(w, "invalid name or password", http.StatusUnauthorized)
+			return
+		}
+
+		if err := manager.Init(w, r, name); err != nil {
+			internalError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func logout(manager *sessionup.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := manager.Revoke(r.Context(), w); err != nil {
+			internalError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// SessionView is the JSON representation of a session in the list.
+type SessionView struct {
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+	OS        string    `json:"os"`
+	Browser   string    `json:"browser"`
+}
+
+func sessions(manager *sessionup.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ss, err := manager.FetchAll(r.Context())
+		if err != nil {
+			internalError(w, err)
+			return
+		}
+
+		vv := make([]SessionView, len(ss))
+		for i, s := range ss {
+			vv[i] = SessionView{
+				Current:   s.Current,
+				CreatedAt: s.CreatedAt,
+				OS:        s.Agent.OS,
+				Browser:   s.Agent.Browser,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(vv)
+	})
+}
+
+// revokeOther revokes other sessions of the user. In stateless mode
+// their cookies are accepted until the refresh interval passes.
+func revokeOther(manager *sessionup.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := manager.RevokeOther(r.Context()); err != nil {
+			internalError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// stepUp confirms the user's password. The confirmation time is stored
+// in a new session that replaces the current one, so that it works
+// with stores that can't update sessions and in stateless mode, where
+// the cookie holds the whole session.
+func stepUp(manager *sessionup.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := sessionup.FromContext(r.Context())
+		if !authorized(s.UserKey, r.FormValue("password")) {
+			http.Error(w, "invalid password", http.StatusUnauthorized)
+			return
+		}
+
+		if err := manager.Revoke(r.Context(), w); err != nil {
+			internalError(w, err)
+			return
+		}
+
+		if err := manager.Init(w, r, s.UserKey, sessionup.MetaTime(stepUpKey, time.Now())); err != nil {
+			internalError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func settings(w http.ResponseWriter, r *http.Request) {
+	s, _ := sessionup.FromContext(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"user": s.UserKey})
+}
+
+func internalError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
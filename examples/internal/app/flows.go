@@ -0,0 +1,95 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// RunFlows drives the flows of the provided server (see NewServer)
+// end-to-end, on behalf of two clients (a laptop and a phone) of the
+// same user. The store of the server's manager must be empty.
+func RunFlows(t *testing.T, h http.Handler) {
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	client := func() *http.Client {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return &http.Client{Jar: jar}
+	}
+
+	send := func(c *http.Client, path string, form url.Values) *http.Response {
+		var (
+			res *http.Response
+			err error
+		)
+
+		if form == nil {
+			res, err = c.Get(srv.URL + path)
+		} else {
+			res, err = c.PostForm(srv.URL+path, form)
+		}
+
+		if err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return res
+	}
+
+	expect := func(res *http.Response, code int) {
+		t.Helper()
+		res.Body.Close()
+
+		if res.StatusCode != code {
+			t.Errorf("%s %s: want %d, got %d", res.Request.Method, res.Request.URL.Path, code, res.StatusCode)
+		}
+	}
+
+	count := func(c *http.Client) int {
+		res := send(c, "/sessions", nil)
+		defer res.Body.Close()
+
+		var vv []SessionView
+		if err := json.NewDecoder(res.Body).Decode(&vv); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return len(vv)
+	}
+
+	laptop, phone := client(), client()
+	expect(send(laptop, "/login", url.Values{"name": {"alice"}, "password": {"invalid"}}), http.StatusUnauthorized)
+	expect(send(laptop, "/sessions", nil), http.StatusUnauthorized)
+
+	expect(send(laptop, "/login", url.Values{"name": {"alice"}, "password": {"wonderland"}}), http.StatusNoContent)
+	expect(send(phone, "/login", url.Values{"name": {"alice"}, "password": {"wonderland"}}), http.StatusNoContent)
+
+	if n := count(laptop); n != 2 {
+		t.Errorf("want %d, got %d", 2, n)
+	}
+
+	expect(send(laptop, "/revoke-other", url.Values{}), http.StatusNoContent)
+	if n := count(laptop); n != 1 {
+		t.Errorf("want %d, got %d", 1, n)
+	}
+
+	expect(send(laptop, "/settings", nil), http.StatusUnauthorized)
+	expect(send(laptop, "/step-up", url.Values{"password": {"invalid"}}), http.StatusUnauthorized)
+	expect(send(laptop, "/step-up", url.Values{"password": {"wonderland"}}), http.StatusNoContent)
+	expect(send(laptop, "/settings", nil), http.StatusOK)
+
+	if n := count(laptop); n != 1 {
+		t.Errorf("want %d, got %d", 1, n)
+	}
+
+	expect(send(laptop, "/logout", url.Values{}), http.StatusNoContent)
+	expect(send(laptop, "/sessions", nil), http.StatusUnauthorized)
+}
@@ -0,0 +1,34 @@
+// Package record encodes sessions for the example stores, which keep
+// each session as a single opaque value.
+package record
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/swithek/sessionup"
+)
+
+// Encode produces the stored representation of the session.
+func Encode(s sessionup.Session) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := sessionup.WriteNDJSON(&buf, []sessionup.Session{s}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decode parses the stored representation of the session.
+func Decode(data []byte) (sessionup.Session, error) {
+	ss, err := sessionup.ReadNDJSON(bytes.NewReader(data))
+	if err != nil {
+		return sessionup.Session{}, err
+	}
+
+	if len(ss) != 1 {
+		return sessionup.Session{}, errors.New("malformed session record")
+	}
+
+	return ss[0], nil
+}
@@ -0,0 +1,10 @@
+module github.com/swithek/sessionup/examples/postgres
+
+go 1.13
+
+require (
+	github.com/lib/pq v1.10.9
+	github.com/swithek/sessionup v0.0.0
+)
+
+replace github.com/swithek/sessionup => ../..
@@ -0,0 +1,101 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/examples/internal/app"
+	"github.com/swithek/sessionup/storetest"
+)
+
+// tableSeq numbers the tables produced by testStore.
+var tableSeq uint64
+
+// testDB connects to the PostgreSQL server at POSTGRES_DSN (see
+// ../docker-compose.yml). The test is skipped if it is not set.
+func testDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	return db
+}
+
+// testStore creates a store with a table that was not used yet, so that
+// every store starts empty. The tables are removed together with the
+// container. It may be called from subtests, hence the failure is not
+// fatal.
+func testStore(t *testing.T, db *sql.DB) *Store {
+	n := atomic.AddUint64(&tableSeq, 1)
+	st := NewStore(db, "sessions_test_"+strconv.FormatInt(time.Now().UnixNano(), 36)+"_"+strconv.FormatUint(n, 10))
+	if err := st.Migrate(context.Background()); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	return st
+}
+
+func TestStore(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	storetest.RunStoreTests(t, func() sessionup.Store {
+		return testStore(t, db)
+	})
+}
+
+func TestDeleteExpired(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	st := testStore(t, db)
+	s := storetest.NewSession("id", "key")
+	s.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := st.Create(ctx, s); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err := st.DeleteExpired(ctx); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM ` + st.table).Scan(&n); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if n != 0 {
+		t.Errorf("want %d, got %d", 0, n)
+	}
+}
+
+func TestServer(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	manager, err := sessionup.New(testStore(t, db),
+		sessionup.Secure(false),
+		sessionup.ExpiresIn(time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	app.RunFlows(t, app.NewServer(manager))
+}
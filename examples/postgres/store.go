@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/examples/internal/record"
+)
+
+// uniqueViolation is the PostgreSQL error code returned when a unique
+// constraint is violated.
+const uniqueViolation = "23505"
+
+// Store is a sessionup.Store that keeps sessions in a PostgreSQL table.
+// Each session is encoded with sessionup.WriteNDJSON and stored next to
+// the columns used for lookups. Expired sessions are ignored by all
+// queries and can be removed with DeleteExpired.
+type Store struct {
+	db    *sql.DB
+	name  string
+	table string
+}
+
+// NewStore creates a new Store that keeps sessions in the provided
+// table. Migrate must be called before the store is used for the first
+// time.
+func NewStore(db *sql.DB, table string) *Store {
+	return &Store{db: db, name: table, table: pq.QuoteIdentifier(table)}
+}
+
+// Migrate creates the store's table and its indexes, if they don't
+// exist yet.
+func (st *Store) Migrate(ctx context.Context) error {
+	_, err := st.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+st.table+` (
+			id TEXT PRIMARY KEY,
+			user_key TEXT NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			data BYTEA NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS `+pq.QuoteIdentifier(st.name+"_user_key")+`
+			ON `+st.table+` (user_key);
+		CREATE INDEX IF NOT EXISTS `+pq.QuoteIdentifier(st.name+"_expires_at")+`
+			ON `+st.table+` (expires_at)`)
+	if err != nil {
+		return storeErr("Migrate", "", err)
+	}
+
+	return nil
+}
+
+// Create implements sessionup.Store interface's Create method. An
+// expired session with the same ID is replaced.
+func (st *Store) Create(ctx context.Context, s sessionup.Session) error {
+	data, err := record.Encode(s)
+	if err != nil {
+		return storeErr("Create", s.ID, err)
+	}
+
+	res, err := st.db.ExecContext(ctx, `
+		INSERT INTO `+st.table+` AS s (id, user_key, expires_at, data)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE
+		SET user_key = EXCLUDED.user_key, expires_at = EXCLUDED.expires_at, data = EXCLUDED.data
+		WHERE s.expires_at <= now()`,
+		s.ID, s.UserKey, s.ExpiresAt, data)
+	if err != nil {
+		var perr *pq.Error
+		if errors.As(err, &perr) && perr.Code == uniqueViolation {
+			return sessionup.ErrDuplicateID
+		}
+
+		return storeErr("Create", s.ID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return storeErr("Create", s.ID, err)
+	}
+
+	if n == 0 {
+		return sessionup.ErrDuplicateID
+	}
+
+	return nil
+}
+
+// FetchByID implements sessionup.Store interface's FetchByID method.
+func (st *Store) FetchByID(ctx context.Context, id string) (sessionup.Session, bool, error) {
+	var data []byte
+	err := st.db.QueryRowContext(ctx, `
+		SELECT data FROM `+st.table+`
+		WHERE id = $1 AND expires_at > now()`,
+		id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sessionup.Session{}, false, nil
+	}
+
+	if err != nil {
+		return sessionup.Session{}, false, storeErr("FetchByID", id, err)
+	}
+
+	s, err := record.Decode(data)
+	if err != nil {
+		return sessionup.Session{}, false, storeErr("FetchByID", id, err)
+	}
+
+	return s, true, nil
+}
+
+// FetchByUserKey implements sessionup.Store interface's FetchByUserKey
+// method.
+func (st *Store) FetchByUserKey(ctx context.Context, key string) ([]sessionup.Session, error) {
+	rows, err := st.db.QueryContext(ctx, `
+		SELECT data FROM `+st.table+`
+		WHERE user_key = $1 AND expires_at > now()`,
+		key)
+	if err != nil {
+		return nil, storeErr("FetchByUserKey", key, err)
+	}
+	defer rows.Close()
+
+	var ss []sessionup.Session
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return nil, storeErr("FetchByUserKey", key, err)
+		}
+
+		s, err := record.Decode(data)
+		if err != nil {
+			return nil, storeErr("FetchByUserKey", key, err)
+		}
+
+		ss = append(ss, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, storeErr("FetchByUserKey", key, err)
+	}
+
+	return ss, nil
+}
+
+// DeleteByID implements sessionup.Store interface's DeleteByID method.
+func (st *Store) DeleteByID(ctx context.Context, id string) error {
+	_, err := st.db.ExecContext(ctx, `DELETE FROM `+st.table+` WHERE id = $1`, id)
+	if err != nil {
+		return storeErr("DeleteByID", id, err)
+	}
+
+	return nil
+}
+
+// DeleteByUserKey implements sessionup.Store interface's DeleteByUserKey
+// method.
+func (st *Store) DeleteByUserKey(ctx context.Context, key string, expID ...string) error {
+	if expID == nil {
+		expID = []string{}
+	}
+
+	_, err := st.db.ExecContext(ctx, `
+		DELETE FROM `+st.table+`
+		WHERE user_key = $1 AND NOT (id = ANY($2))`,
+		key, pq.Array(expID))
+	if err != nil {
+		return storeErr("DeleteByUserKey", key, err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes all expired sessions from the table.
+func (st *Store) DeleteExpired(ctx context.Context) error {
+	_, err := st.db.ExecContext(ctx, `DELETE FROM `+st.table+` WHERE expires_at <= now()`)
+	if err != nil {
+		return storeErr("DeleteExpired", "", err)
+	}
+
+	return nil
+}
+
+// Cleanup calls DeleteExpired at the provided interval until the
+// context is cancelled.
+func (st *Store) Cleanup(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			st.DeleteExpired(ctx)
+		}
+	}
+}
+
+// storeErr wraps the error with the failed operation's context.
+func storeErr(op, key string, err error) error {
+	return &sessionup.StoreError{Op: op, Key: key, Err: err}
+}
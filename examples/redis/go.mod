@@ -0,0 +1,10 @@
+module github.com/swithek/sessionup/examples/redis
+
+go 1.13
+
+require (
+	github.com/gomodule/redigo v1.8.9
+	github.com/swithek/sessionup v0.0.0
+)
+
+replace github.com/swithek/sessionup => ../..
@@ -0,0 +1,52 @@
+// Command redis is an example server that keeps sessions in Redis (see
+// Store). It demonstrates the login, session list, "revoke other
+// sessions" and step-up authentication flows as a small JSON API.
+//
+// Usage:
+//
+//	docker compose -f examples/docker-compose.yml up -d redis
+//	cd examples/redis && REDIS_ADDR=localhost:6379 go run .
+//
+// REDIS_ADDR defaults to localhost:6379.
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/examples/internal/app"
+)
+
+func main() {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+
+	manager, err := sessionup.New(NewStore(newPool(addr), "sessionup:"),
+		sessionup.Secure(false),
+		sessionup.ExpiresIn(time.Hour*24),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Fatal(http.ListenAndServe(":8080", app.NewServer(manager)))
+}
+
+// newPool creates a pool of connections to the Redis server at the
+// provided address.
+func newPool(addr string) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: time.Minute * 5,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialContext(ctx, "tcp", addr)
+		},
+	}
+}
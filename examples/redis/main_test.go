@@ -0,0 +1,62 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/examples/internal/app"
+	"github.com/swithek/sessionup/storetest"
+)
+
+// prefixSeq numbers the key prefixes produced by testPrefix.
+var prefixSeq uint64
+
+// testPrefix produces a key prefix that was not used yet, so that every
+// store starts empty.
+func testPrefix() string {
+	n := atomic.AddUint64(&prefixSeq, 1)
+	return "sessionup-test:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":" + strconv.FormatUint(n, 10) + ":"
+}
+
+// testPool connects to the Redis server at REDIS_ADDR (see
+// ../docker-compose.yml). The test is skipped if it is not set.
+func testPool(t *testing.T) *redis.Pool {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+
+	return newPool(addr)
+}
+
+func TestStore(t *testing.T) {
+	pool := testPool(t)
+	defer pool.Close()
+
+	storetest.RunStoreTests(t, func() sessionup.Store {
+		return NewStore(pool, testPrefix())
+	})
+}
+
+func TestServer(t *testing.T) {
+	pool := testPool(t)
+	defer pool.Close()
+
+	manager, err := sessionup.New(NewStore(pool, testPrefix()),
+		sessionup.Secure(false),
+		sessionup.ExpiresIn(time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	app.RunFlows(t, app.NewServer(manager))
+}
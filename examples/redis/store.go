@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/examples/internal/record"
+)
+
+// createScript inserts the session (KEYS[1]) with the provided ID,
+// data and TTL in milliseconds (ARGV[1], ARGV[2] and ARGV[3]), unless
+// the ID is taken, and adds the ID to the user's index set (KEYS[2]),
+// which expires with the user's last session.
+var createScript = redis.NewScript(2, `
+if not redis.call('SET', KEYS[1], ARGV[2], 'NX', 'PX', ARGV[3]) then
+	return 0
+end
+
+redis.call('SADD', KEYS[2], ARGV[1])
+if redis.call('PTTL', KEYS[2]) < tonumber(ARGV[3]) then
+	redis.call('PEXPIRE', KEYS[2], ARGV[3])
+end
+
+return 1
+`)
+
+// deleteUserScript deletes the sessions, whose IDs are in the user's
+// index set (KEYS[1]), except those whose IDs are provided after the
+// session key prefix (ARGV[1]).
+var deleteUserScript = redis.NewScript(1, `
+local keep = {}
+for i = 2, #ARGV do
+	keep[ARGV[i]] = true
+end
+
+for _, id in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+	if not keep[id] then
+		redis.call('DEL', ARGV[1] .. id)
+		redis.call('SREM', KEYS[1], id)
+	end
+end
+
+return 0
+`)
+
+// Store is a sessionup.Store that keeps sessions in Redis. Each session
+// is encoded with sessionup.WriteNDJSON and stored under its own key,
+// that expires together with the session. The IDs of the sessions of
+// each user are kept in an index set; IDs of expired sessions are
+// removed from it when the user's sessions are retrieved.
+type Store struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// NewStore creates a new Store that uses the provided connection pool
+// and prepends the provided prefix to all its keys.
+func NewStore(pool *redis.Pool, prefix string) *Store {
+	return &Store{pool: pool, prefix: prefix}
+}
+
+// Create implements sessionup.Store interface's Create method.
+func (st *Store) Create(ctx context.Context, s sessionup.Session) error {
+	ttl := time.Until(s.ExpiresAt).Milliseconds()
+	if ttl <= 0 {
+		// expired sessions would never be retrieved anyway.
+		return nil
+	}
+
+	data, err := record.Encode(s)
+	if err != nil {
+		return storeErr("Create", s.ID, err)
+	}
+
+	conn, err := st.pool.GetContext(ctx)
+	if err != nil {
+		return storeErr("Create", s.ID, err)
+	}
+	defer conn.Close()
+
+	ok, err := redis.Bool(createScript.Do(conn, st.sessionKey(s.ID), st.userKey(s.UserKey), s.ID, data, ttl))
+	if err != nil {
+		return storeErr("Create", s.ID, err)
+	}
+
+	if !ok {
+		return sessionup.ErrDuplicateID
+	}
+
+	return nil
+}
+
+// FetchByID implements sessionup.Store interface's FetchByID method.
+func (st *Store) FetchByID(ctx context.Context, id string) (sessionup.Session, bool, error) {
+	conn, err := st.pool.GetContext(ctx)
+	if err != nil {
+		return sessionup.Session{}, false, storeErr("FetchByID", id, err)
+	}
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("GET", st.sessionKey(id)))
+	if errors.Is(err, redis.ErrNil) {
+		return sessionup.Session{}, false, nil
+	}
+
+	if err != nil {
+		return sessionup.Session{}, false, storeErr("FetchByID", id, err)
+	}
+
+	s, err := record.Decode(data)
+	if err != nil {
+		return sessionup.Session{}, false, storeErr("FetchByID", id, err)
+	}
+
+	return s, true, nil
+}
+
+// FetchByUserKey implements sessionup.Store interface's FetchByUserKey
+// method.
+func (st *Store) FetchByUserKey(ctx context.Context, key string) ([]sessionup.Session, error) {
+	conn, err := st.pool.GetContext(ctx)
+	if err != nil {
+		return nil, storeErr("FetchByUserKey", key, err)
+	}
+	defer conn.Close()
+
+	ids, err := redis.Strings(conn.Do("SMEMBERS", st.userKey(key)))
+	if err != nil {
+		return nil, storeErr("FetchByUserKey", key, err)
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = st.sessionKey(id)
+	}
+
+	vals, err := redis.ByteSlices(conn.Do("MGET", keys...))
+	if err != nil {
+		return nil, storeErr("FetchByUserKey", key, err)
+	}
+
+	var (
+		ss      []sessionup.Session
+		expired = []interface{}{st.userKey(key)}
+	)
+
+	for i, data := range vals {
+		if data == nil {
+			expired = append(expired, ids[i])
+			continue
+		}
+
+		s, err := record.Decode(data)
+		if err != nil {
+			return nil, storeErr("FetchByUserKey", key, err)
+		}
+
+		ss = append(ss, s)
+	}
+
+	if len(expired) > 1 {
+		// the index is cleaned up on a best-effort basis.
+		conn.Do("SREM", expired...)
+	}
+
+	return ss, nil
+}
+
+// DeleteByID implements sessionup.Store interface's DeleteByID method.
+// The ID is removed from the user's index set when the user's sessions
+// are retrieved.
+func (st *Store) DeleteByID(ctx context.Context, id string) error {
+	conn, err := st.pool.GetContext(ctx)
+	if err != nil {
+		return storeErr("DeleteByID", id, err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Do("DEL", st.sessionKey(id)); err != nil {
+		return storeErr("DeleteByID", id, err)
+	}
+
+	return nil
+}
+
+// DeleteByUserKey implements sessionup.Store interface's DeleteByUserKey
+// method.
+func (st *Store) DeleteByUserKey(ctx context.Context, key string, expID ...string) error {
+	conn, err := st.pool.GetContext(ctx)
+	if err != nil {
+		return storeErr("DeleteByUserKey", key, err)
+	}
+	defer conn.Close()
+
+	args := make([]interface{}, 0, len(expID)+2)
+	args = append(args, st.userKey(key), st.prefix+"session:")
+	for _, id := range expID {
+		args = append(args, id)
+	}
+
+	if _, err = deleteUserScript.Do(conn, args...); err != nil {
+		return storeErr("DeleteByUserKey", key, err)
+	}
+
+	return nil
+}
+
+// sessionKey produces the key of the session with the provided ID.
+func (st *Store) sessionKey(id string) string {
+	return st.prefix + "session:" + id
+}
+
+// userKey produces the key of the index set of the user with the
+// provided key.
+func (st *Store) userKey(key string) string {
+	return st.prefix + "user:" + key
+}
+
+// storeErr wraps the error with the failed operation's context.
+func storeErr(op, key string, err error) error {
+	return &sessionup.StoreError{Op: op, Key: key, Err: err}
+}
@@ -0,0 +1,62 @@
+// Command stateless is an example server that keeps whole sessions in
+// encrypted cookies (see sessionup.Stateless), so that authenticated
+// requests don't query the store until the refresh interval passes. It
+// demonstrates the login, session list, "revoke other sessions" and
+// step-up authentication flows as a small JSON API.
+//
+// Usage:
+//
+//	SESSION_KEY=<64 hex characters> go run ./examples/stateless
+//
+// The key is generated on startup if SESSION_KEY is not set, which logs
+// all users out on every restart.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/examples/internal/app"
+	"github.com/swithek/sessionup/memstore"
+	"github.com/swithek/sessionup/token"
+)
+
+func main() {
+	key, err := sessionKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	manager, err := sessionup.New(memstore.New(time.Minute*5),
+		sessionup.Secure(false),
+		sessionup.ExpiresIn(time.Hour*24),
+		sessionup.Stateless(key, time.Minute),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Fatal(http.ListenAndServe(":8080", app.NewServer(manager)))
+}
+
+// sessionKey decodes the key stored in the SESSION_KEY environment
+// variable or, if it is not set, generates a random one.
+func sessionKey() ([]byte, error) {
+	if v := os.Getenv("SESSION_KEY"); v != "" {
+		return hex.DecodeString(v)
+	}
+
+	log.Println("SESSION_KEY is not set, generating a random key")
+
+	key := make([]byte, token.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
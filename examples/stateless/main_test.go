@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/examples/internal/app"
+	"github.com/swithek/sessionup/memstore"
+)
+
+func TestServer(t *testing.T) {
+	manager, err := sessionup.New(memstore.New(0),
+		sessionup.Secure(false),
+		sessionup.ExpiresIn(time.Hour),
+		sessionup.Stateless(make([]byte, 32), time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	app.RunFlows(t, app.NewServer(manager))
+}
@@ -0,0 +1,40 @@
+package sessionup
+
+import "net/http"
+
+// RevokeOnFailure makes Auth (and other middlewares) revoke the stored
+// session when the request fails the fingerprint checks hard, i.e. when
+// the Validate checks fail or the fingerprint policy's action is
+// ActionReject. Without it only the request is rejected and the stolen
+// cookie keeps working from the original device.
+// The revocation is emitted as the EventRevoked event (see Notifications)
+// and fn, if not nil, receives the request, the redacted (see Redaction)
+// revoked session and the error (if any) that occurred while revoking
+// it. Challenged requests (see ActionChallenge) never cause revocations.
+// By default it is not set.
+func RevokeOnFailure(fn func(r *http.Request, s Session, err error)) Option {
+	return func(m *Manager) {
+		m.failure.revoke = true
+		m.failure.fn = fn
+	}
+}
+
+// failed revokes the session that failed the fingerprint checks, if
+// RevokeOnFailure is set, and returns ErrUnauthorized.
+func (m *Manager) failed(r *http.Request, s Session) error {
+	if !m.failure.revoke {
+		return ErrUnauthorized
+	}
+
+	ctx := r.Context()
+	err := m.revokeSession(ctx, s)
+	if err == nil {
+		m.notifyRevoked(s.UserKey, s)
+	}
+
+	if m.failure.fn != nil {
+		m.failure.fn(r, m.Redacted(s), err)
+	}
+
+	return ErrUnauthorized
+}
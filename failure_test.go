@@ -0,0 +1,133 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRevokeOnFailure(t *testing.T) {
+	serve := func(m *Manager, ip string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = "192.168.1.10:3000"
+		if err := m.Init(rec, req, "key"); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		req = httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = ip + ":3000"
+		req.AddCookie(rec.Result().Cookies()[0])
+		rec = httptest.NewRecorder()
+		m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	cc := map[string]struct {
+		Opts    []Option
+		IP      string
+		Revoked bool
+	}{
+		"Valid request": {
+			IP: "192.168.1.10",
+		},
+		"Validate checks failure": {
+			Opts:    []Option{Validate(true)},
+			IP:      "10.0.0.1",
+			Revoked: true,
+		},
+		"Rejected fingerprint": {
+			Opts:    []Option{Fingerprint(NewFingerprintPolicy().IP(IPExact(), ActionReject))},
+			IP:      "10.0.0.1",
+			Revoked: true,
+		},
+		"Challenged fingerprint": {
+			Opts: []Option{Fingerprint(NewFingerprintPolicy().IP(IPExact(), ActionChallenge))},
+			IP:   "10.0.0.1",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				called bool
+				rs     Session
+			)
+
+			events := make(chan Event, 1)
+			n := NewNotifier(time.Millisecond, func(d Digest) {
+				for _, e := range d.Events {
+					if e.Type == EventRevoked {
+						events <- e
+					}
+				}
+			})
+
+			store := mapStore()
+			m := NewManager(store, append(c.Opts, Notifications(n), RevokeOnFailure(func(_ *http.Request, s Session, err error) {
+				called, rs = true, s
+				if err != nil {
+					t.Errorf("want nil, got %v", err)
+				}
+			}))...)
+
+			serve(m, c.IP)
+
+			if called != c.Revoked {
+				t.Fatalf("want %v, got %v", c.Revoked, called)
+			}
+
+			if len(store.DeleteByIDCalls()) > 0 != c.Revoked {
+				t.Errorf("want %v, got %v", c.Revoked, !c.Revoked)
+			}
+
+			if !c.Revoked {
+				return
+			}
+
+			if rs.UserKey != "key" {
+				t.Errorf("want %q, got %q", "key", rs.UserKey)
+			}
+
+			select {
+			case e := <-events:
+				if e.Revocation.ID != rs.ID {
+					t.Errorf("want %q, got %q", rs.ID, e.Revocation.ID)
+				}
+			case <-time.After(time.Second):
+				t.Error("want event, got none")
+			}
+		})
+	}
+
+	m := NewManager(mapStore(), Validate(true))
+	if rec := serve(m, "10.0.0.1"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	if len(m.store.(*StoreMock).DeleteByIDCalls()) != 0 {
+		t.Error("want no revocations, got some")
+	}
+
+	store := mapStore()
+	store.DeleteByIDFunc = func(_ context.Context, _ string) error {
+		return errors.New("error")
+	}
+
+	var err error
+	m = NewManager(store, Validate(true), RevokeOnFailure(func(_ *http.Request, _ Session, e error) {
+		err = e
+	}))
+
+	if rec := serve(m, "10.0.0.1"); rec.Code != http.StatusUnauthorized || err == nil {
+		t.Errorf("want %d and non-nil, got %d and %v", http.StatusUnauthorized, rec.Code, err)
+	}
+}
@@ -0,0 +1,389 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"xojoc.pw/useragent"
+)
+
+// kindRotated marks sessions that are being replaced by rotate, so that
+// concurrent requests carrying their cookie are rejected instead of
+// rotating them again.
+const kindRotated = "session-rotated"
+
+// ErrChallenge is returned when the request's fingerprint differs from
+// the session's and the FingerprintPolicy requires the client to prove
+// its identity again (e.g. with step-up authentication).
+var ErrChallenge = errors.New("additional verification required")
+
+// Action specifies what happens when a dimension of the request's
+// fingerprint does not match the session's. Actions are ordered by
+// their severity: when several dimensions mismatch, the most severe
+// action is taken.
+type Action int
+
+const (
+	// ActionAllow accepts the request as is.
+	ActionAllow Action = iota
+
+	// ActionRotate accepts the request, but replaces the session with
+	// a new one (with a new ID and the request's fingerprint) and
+	// updates the cookie. Child sessions and tickets of the replaced
+	// session are moved to the new one. Only one of concurrent requests
+	// carrying the same cookie rotates the session, the rest are
+	// rejected with ErrUnauthorized. The store must implement CASStore
+	// interface, otherwise ErrNotSupported is returned.
+	ActionRotate
+
+	// ActionChallenge rejects the request with ErrChallenge, but keeps
+	// the session.
+	ActionChallenge
+
+	// ActionReject rejects the request with ErrUnauthorized.
+	ActionReject
+)
+
+// String returns the name of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionAllow:
+		return "allow"
+	case ActionRotate:
+		return "rotate"
+	case ActionChallenge:
+		return "challenge"
+	case ActionReject:
+		return "reject"
+	default:
+		return "unknown"
+	}
+}
+
+// FingerprintCheck compares a single dimension of the request's
+// fingerprint with the session's. It returns true if they match.
+type FingerprintCheck func(s Session, r *http.Request) bool
+
+// Ignore produces a check that matches all requests.
+func Ignore() FingerprintCheck {
+	return func(Session, *http.Request) bool {
+		return true
+	}
+}
+
+// IPExact produces a check that requires the request's IP address to be
+// equal to the session's. Sessions without an IP address match all
+// requests.
+func IPExact() FingerprintCheck {
+	return func(s Session, r *http.Request) bool {
+		return len(s.IP) == 0 || s.IP.Equal(readIP(r))
+	}
+}
+
+// IPSameSubnet produces a check that requires the request's IP address
+// to be in the same subnet as the session's, e.g. IPSameSubnet(24)
+// tolerates address changes within a /24 network. IPv6 addresses are
+// compared by their /64 prefix. Sessions without an IP address match
+// all requests.
+func IPSameSubnet(bits int) FingerprintCheck {
+	return func(s Session, r *http.Request) bool {
+		if len(s.IP) == 0 {
+			return true
+		}
+
+		ip := readIP(r)
+		if ip == nil {
+			return false
+		}
+
+		mask := net.CIDRMask(64, 128)
+		if s.IP.To4() != nil {
+			mask = net.CIDRMask(bits, 32)
+		}
+
+		if mask == nil {
+			return false
+		}
+
+		return s.IP.Mask(mask).Equal(ip.Mask(mask))
+	}
+}
+
+// AgentExact produces a check that requires the OS and browser of the
+// request's User-Agent to be equal to the session's. Empty session
+// values match all requests.
+func AgentExact() FingerprintCheck {
+	return func(s Session, r *http.Request) bool {
+		a := useragent.Parse(r.Header.Get("User-Agent"))
+		if a == nil {
+			a = &useragent.UserAgent{}
+		}
+
+		return (s.Agent.OS == "" || s.Agent.OS == a.OS) &&
+			(s.Agent.Browser == "" || s.Agent.Browser == a.Name)
+	}
+}
+
+// AgentMajorVersion produces a check that, in addition to AgentExact
+// rules, requires the major version of the request's browser to be
+// equal to the session's, so that browser updates are detected.
+// Sessions without a recorded version are checked with AgentExact
+// rules only.
+func AgentMajorVersion() FingerprintCheck {
+	exact := AgentExact()
+
+	return func(s Session, r *http.Request) bool {
+		if !exact(s, r) {
+			return false
+		}
+
+		if s.Agent.Version == "" {
+			return true
+		}
+
+		ua := r.Header.Get("User-Agent")
+		return agentVersion(ua, s.Agent.Browser) == s.Agent.Version
+	}
+}
+
+// agentVersion extracts the major version of the browser with the
+// provided name from the raw User-Agent string.
+func agentVersion(ua, name string) string {
+	if name == "" {
+		return ""
+	}
+
+	tok := name + "/"
+	if name == "Safari" {
+		// Safari reports its own version in the Version token.
+		tok = "Version/"
+	}
+
+	i := strings.Index(ua, tok)
+	if i < 0 {
+		return ""
+	}
+
+	v := ua[i+len(tok):]
+	if j := strings.IndexFunc(v, func(r rune) bool { return r < '0' || r > '9' }); j >= 0 {
+		v = v[:j]
+	}
+
+	return v
+}
+
+// fingerprintRule holds a single dimension of the FingerprintPolicy.
+type fingerprintRule struct {
+	name   string
+	check  FingerprintCheck
+	action Action
+}
+
+// FingerprintPolicy specifies how each dimension of the request's
+// fingerprint is compared with the session's and what happens when
+// they do not match. Policies are built by chaining the IP, Agent and
+// Dimension methods:
+//
+//	NewFingerprintPolicy().
+//		IP(IPSameSubnet(24), ActionChallenge).
+//		Agent(AgentMajorVersion(), ActionRotate)
+//
+// Each method returns a new policy, so partially built policies can be
+// shared.
+type FingerprintPolicy struct {
+	rules []fingerprintRule
+}
+
+// NewFingerprintPolicy returns an empty policy that allows all requests.
+func NewFingerprintPolicy() FingerprintPolicy {
+	return FingerprintPolicy{}
+}
+
+// IP returns a copy of the policy with the "ip" dimension compared
+// by the provided check.
+func (p FingerprintPolicy) IP(check FingerprintCheck, mismatch Action) FingerprintPolicy {
+	return p.Dimension("ip", check, mismatch)
+}
+
+// Agent returns a copy of the policy with the "agent" dimension
+// compared by the provided check.
+func (p FingerprintPolicy) Agent(check FingerprintCheck, mismatch Action) FingerprintPolicy {
+	return p.Dimension("agent", check, mismatch)
+}
+
+// Dimension returns a copy of the policy with the dimension of the
+// provided name compared by the provided check. The mismatch action is
+// taken when the check fails. Setting the same dimension again
+// replaces it.
+func (p FingerprintPolicy) Dimension(name string, check FingerprintCheck, mismatch Action) FingerprintPolicy {
+	rules := make([]fingerprintRule, 0, len(p.rules)+1)
+	for _, r := range p.rules {
+		if r.name != name {
+			rules = append(rules, r)
+		}
+	}
+
+	return FingerprintPolicy{rules: append(rules, fingerprintRule{name: name, check: check, action: mismatch})}
+}
+
+// FingerprintResult holds the outcome of FingerprintPolicy evaluation.
+type FingerprintResult struct {
+	// Action specifies the most severe action of all mismatched
+	// dimensions, or ActionAllow if all of them match.
+	Action Action
+
+	// Mismatched specifies the names of the mismatched dimensions in
+	// the order they were added to the policy.
+	Mismatched []string
+}
+
+// Evaluate compares the request's fingerprint with the session's
+// according to the policy.
+func (p FingerprintPolicy) Evaluate(s Session, r *http.Request) FingerprintResult {
+	var res FingerprintResult
+	for _, rule := range p.rules {
+		if rule.check == nil || rule.check(s, r) {
+			continue
+		}
+
+		res.Mismatched = append(res.Mismatched, rule.name)
+		if rule.action > res.Action {
+			res.Action = rule.action
+		}
+	}
+
+	return res
+}
+
+// Fingerprint sets the policy that is evaluated in Auth (and other
+// middlewares) instead of the checks enabled with Validate. The result
+// is recorded on the context session (see Session.Fingerprint).
+// By default it is not set.
+func Fingerprint(p FingerprintPolicy) Option {
+	return func(m *Manager) {
+		m.fingerprint = &p
+	}
+}
+
+// checkFingerprint evaluates the fingerprint policy (or the Validate
+// checks, if the policy is not set) and takes its action.
+func (m *Manager) checkFingerprint(w http.ResponseWriter, r *http.Request, s Session) (Session, error) {
+	if m.fingerprint == nil {
+		if m.Config().Validate && !s.IsValid(r) {
+			return Session{}, m.failed(r, s)
+		}
+
+		return s, nil
+	}
+
+	s.Fingerprint = m.fingerprint.Evaluate(s, r)
+
+	switch s.Fingerprint.Action {
+	case ActionReject:
+		return Session{}, m.failed(r, s)
+	case ActionChallenge:
+		return Session{}, ErrChallenge
+	case ActionRotate:
+		return m.rotate(w, r, s)
+	default:
+		return s, nil
+	}
+}
+
+// rotate replaces the session with a new one that has a new ID and the
+// request's fingerprint, and sets its cookie. The old session is
+// claimed with a compare-and-swap first, so that it is replaced only
+// once. The returned session's Fingerprint holds the evaluation of the
+// request against the new session.
+func (m *Manager) rotate(w http.ResponseWriter, r *http.Request, s Session) (Session, error) {
+	cs, ok := m.store.(CASStore)
+	if !ok {
+		return Session{}, ErrNotSupported
+	}
+
+	ns := m.newSession(r, s.UserKey, nil)
+	if ns.ID == "" {
+		return Session{}, ErrGenID
+	}
+
+	ctx := r.Context()
+	if err := m.claimRotation(ctx, cs, s.ID); err != nil {
+		return Session{}, err
+	}
+
+	old := s.ID
+	s.ID, s.IP, s.Agent, s.Version = ns.ID, ns.IP, ns.Agent, 0
+	s.Fingerprint = FingerprintResult{}
+
+	exp := m.sessionCookieExpiry(s.UserKey, s.ExpiresAt)
+	s.CookieExpiresAt = exp
+
+	if err := m.storeErr(m.store.Create(ctx, s)); err != nil {
+		return Session{}, err
+	}
+
+	m.hints.remember(s)
+	m.reparent(ctx, cs, s, old)
+
+	if err := m.deleteSession(ctx, old); err != nil {
+		return Session{}, err
+	}
+
+	if err := m.setSessionCookie(w, exp, s); err != nil {
+		return Session{}, err
+	}
+
+	s.Fingerprint = m.fingerprint.Evaluate(s, r)
+	return s, nil
+}
+
+// claimRotation marks the stored session of the provided ID as being
+// rotated. ErrUnauthorized is returned if the session no longer exists
+// or was claimed by a concurrent request.
+func (m *Manager) claimRotation(ctx context.Context, cs CASStore, id string) error {
+	s, ok, err := m.store.FetchByID(ctx, id)
+	if err = m.storeErr(err); err != nil {
+		return err
+	}
+
+	if !ok || s.Kind != "" {
+		return ErrUnauthorized
+	}
+
+	old := s.Version
+	s.Version++
+	s.Kind = kindRotated
+
+	swapped, err := cs.CompareAndSwap(ctx, old, s)
+	if errors.Is(err, ErrNotFound) || err == nil && !swapped {
+		return ErrUnauthorized
+	}
+
+	return m.storeErr(err)
+}
+
+// reparent moves the child sessions and tickets of the session of the
+// old ID to the provided session, so that they outlive the rotation.
+// Records modified concurrently are skipped and, just like on store
+// errors, they are invalidated together with the old session.
+func (m *Manager) reparent(ctx context.Context, cs CASStore, s Session, old string) {
+	ss, err := m.store.FetchByUserKey(ctx, s.UserKey)
+	if m.storeErr(err) != nil {
+		return
+	}
+
+	for _, c := range ss {
+		if c.ParentID != old {
+			continue
+		}
+
+		v := c.Version
+		c.ParentID, c.Version = s.ID, v+1
+		if _, err = cs.CompareAndSwap(ctx, v, c); m.storeErr(ignoreNotFound(err)) != nil {
+			return
+		}
+	}
+}
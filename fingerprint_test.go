@@ -0,0 +1,420 @@
+package sessionup
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	chrome120 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.71 Safari/537.36"
+	chrome121 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.6167.85 Safari/537.36"
+	safari17  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_2) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15"
+)
+
+func TestActionString(t *testing.T) {
+	cc := map[Action]string{
+		ActionAllow:     "allow",
+		ActionRotate:    "rotate",
+		ActionChallenge: "challenge",
+		ActionReject:    "reject",
+		Action(10):      "unknown",
+	}
+
+	for a, exp := range cc {
+		if res := a.String(); res != exp {
+			t.Errorf("want %q, got %q", exp, res)
+		}
+	}
+}
+
+func TestFingerprintChecks(t *testing.T) {
+	req := func(ip, ua string) *http.Request {
+		r := httptest.NewRequest("GET", "http://example.com/", nil)
+		r.RemoteAddr = ip + ":3000"
+		r.Header.Set("User-Agent", ua)
+		return r
+	}
+
+	sess := func(ip, os, browser, version string) Session {
+		s := Session{IP: net.ParseIP(ip)}
+		s.Agent.OS, s.Agent.Browser, s.Agent.Version = os, browser, version
+		return s
+	}
+
+	cc := map[string]struct {
+		Check   FingerprintCheck
+		Session Session
+		Request *http.Request
+		Match   bool
+	}{
+		"Ignore": {
+			Check:   Ignore(),
+			Session: sess("127.0.0.1", "Windows", "Chrome", "120"),
+			Request: req("10.0.0.1", safari17),
+			Match:   true,
+		},
+		"IPExact with equal addresses": {
+			Check:   IPExact(),
+			Session: sess("127.0.0.1", "", "", ""),
+			Request: req("127.0.0.1", ""),
+			Match:   true,
+		},
+		"IPExact with different addresses": {
+			Check:   IPExact(),
+			Session: sess("127.0.0.1", "", "", ""),
+			Request: req("127.0.0.2", ""),
+		},
+		"IPExact without session address": {
+			Check:   IPExact(),
+			Request: req("127.0.0.2", ""),
+			Match:   true,
+		},
+		"IPSameSubnet within subnet": {
+			Check:   IPSameSubnet(24),
+			Session: sess("192.168.1.10", "", "", ""),
+			Request: req("192.168.1.200", ""),
+			Match:   true,
+		},
+		"IPSameSubnet outside subnet": {
+			Check:   IPSameSubnet(24),
+			Session: sess("192.168.1.10", "", "", ""),
+			Request: req("192.168.2.10", ""),
+		},
+		"IPSameSubnet with IPv6 within subnet": {
+			Check:   IPSameSubnet(24),
+			Session: sess("2001:db8::1", "", "", ""),
+			Request: req("[2001:db8::ffff]", ""),
+			Match:   true,
+		},
+		"IPSameSubnet with IPv6 outside subnet": {
+			Check:   IPSameSubnet(24),
+			Session: sess("2001:db8::1", "", "", ""),
+			Request: req("[2001:db8:0:1::1]", ""),
+		},
+		"IPSameSubnet with invalid mask": {
+			Check:   IPSameSubnet(33),
+			Session: sess("192.168.1.10", "", "", ""),
+			Request: req("192.168.1.10", ""),
+		},
+		"AgentExact with equal agents": {
+			Check:   AgentExact(),
+			Session: sess("", "Windows", "Chrome", "120"),
+			Request: req("127.0.0.1", chrome121),
+			Match:   true,
+		},
+		"AgentExact with different agents": {
+			Check:   AgentExact(),
+			Session: sess("", "Windows", "Chrome", ""),
+			Request: req("127.0.0.1", safari17),
+		},
+		"AgentExact without request agent": {
+			Check:   AgentExact(),
+			Session: sess("", "Windows", "Chrome", ""),
+			Request: req("127.0.0.1", ""),
+		},
+		"AgentMajorVersion with equal versions": {
+			Check:   AgentMajorVersion(),
+			Session: sess("", "Windows", "Chrome", "120"),
+			Request: req("127.0.0.1", chrome120),
+			Match:   true,
+		},
+		"AgentMajorVersion with different versions": {
+			Check:   AgentMajorVersion(),
+			Session: sess("", "Windows", "Chrome", "120"),
+			Request: req("127.0.0.1", chrome121),
+		},
+		"AgentMajorVersion without session version": {
+			Check:   AgentMajorVersion(),
+			Session: sess("", "Windows", "Chrome", ""),
+			Request: req("127.0.0.1", chrome121),
+			Match:   true,
+		},
+		"AgentMajorVersion with different agents": {
+			Check:   AgentMajorVersion(),
+			Session: sess("", "Windows", "Chrome", "17"),
+			Request: req("127.0.0.1", safari17),
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			if res := c.Check(c.Session, c.Request); res != c.Match {
+				t.Errorf("want %t, got %t", c.Match, res)
+			}
+		})
+	}
+}
+
+func TestAgentVersion(t *testing.T) {
+	cc := map[string]struct {
+		UA   string
+		Name string
+		Exp  string
+	}{
+		"Chrome":       {UA: chrome120, Name: "Chrome", Exp: "120"},
+		"Safari":       {UA: safari17, Name: "Safari", Exp: "17"},
+		"No name":      {UA: chrome120},
+		"Missing name": {UA: chrome120, Name: "Firefox"},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			if res := agentVersion(c.UA, c.Name); res != c.Exp {
+				t.Errorf("want %q, got %q", c.Exp, res)
+			}
+		})
+	}
+}
+
+func TestFingerprintPolicy(t *testing.T) {
+	match := func(Session, *http.Request) bool { return true }
+	mismatch := func(Session, *http.Request) bool { return false }
+
+	base := NewFingerprintPolicy().IP(mismatch, ActionChallenge)
+	p := base.Agent(mismatch, ActionRotate).Dimension("custom", match, ActionReject)
+
+	if len(base.rules) != 1 {
+		t.Errorf("want %d, got %d", 1, len(base.rules))
+	}
+
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	exp := FingerprintResult{Action: ActionChallenge, Mismatched: []string{"ip", "agent"}}
+	if res := p.Evaluate(Session{}, r); !reflect.DeepEqual(exp, res) {
+		t.Errorf("want %v, got %v", exp, res)
+	}
+
+	p = p.IP(match, ActionReject)
+	exp = FingerprintResult{Action: ActionRotate, Mismatched: []string{"agent"}}
+	if res := p.Evaluate(Session{}, r); !reflect.DeepEqual(exp, res) {
+		t.Errorf("want %v, got %v", exp, res)
+	}
+
+	if res := NewFingerprintPolicy().Evaluate(Session{}, r); !reflect.DeepEqual(FingerprintResult{}, res) {
+		t.Errorf("want %v, got %v", FingerprintResult{}, res)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	m := Manager{}
+	Fingerprint(NewFingerprintPolicy().IP(IPExact(), ActionReject))(&m)
+	if m.fingerprint == nil || len(m.fingerprint.rules) != 1 {
+		t.Errorf("want policy, got %v", m.fingerprint)
+	}
+}
+
+func TestManagerFingerprint(t *testing.T) {
+	init := func(m *Manager) *http.Cookie {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = "192.168.1.10:3000"
+		req.Header.Set("User-Agent", chrome120)
+		if err := m.Init(rec, req, "key"); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return rec.Result().Cookies()[0]
+	}
+
+	auth := func(m *Manager, c *http.Cookie, ip, ua string) (*httptest.ResponseRecorder, Session, bool) {
+		var (
+			s  Session
+			ok bool
+		)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = ip + ":3000"
+		req.Header.Set("User-Agent", ua)
+		req.AddCookie(c)
+		m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			s, ok = FromContext(r.Context())
+		})).ServeHTTP(rec, req)
+
+		return rec, s, ok
+	}
+
+	p := NewFingerprintPolicy().
+		IP(IPSameSubnet(24), ActionChallenge).
+		Agent(AgentMajorVersion(), ActionRotate)
+
+	t.Run("Allowed request", func(t *testing.T) {
+		t.Parallel()
+
+		m := NewManager(mapStore(), Fingerprint(p))
+		c := init(m)
+
+		rec, s, ok := auth(m, c, "192.168.1.20", chrome120)
+		if !ok {
+			t.Fatalf("want %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		if !reflect.DeepEqual(FingerprintResult{}, s.Fingerprint) {
+			t.Errorf("want %v, got %v", FingerprintResult{}, s.Fingerprint)
+		}
+
+		if s.Agent.Version != "120" {
+			t.Errorf("want %q, got %q", "120", s.Agent.Version)
+		}
+	})
+
+	t.Run("Challenged request", func(t *testing.T) {
+		t.Parallel()
+
+		m := NewManager(mapStore(), Fingerprint(p), Reject(func(err error) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if err != ErrChallenge {
+					t.Errorf("want %v, got %v", ErrChallenge, err)
+				}
+
+				w.WriteHeader(http.StatusForbidden)
+			})
+		}))
+		c := init(m)
+
+		rec, _, ok := auth(m, c, "10.0.0.1", chrome120)
+		if ok || rec.Code != http.StatusForbidden {
+			t.Errorf("want %d, got %d", http.StatusForbidden, rec.Code)
+		}
+
+		if _, ok, _ = m.store.FetchByID(context.Background(), c.Value); !ok {
+			t.Error("want true, got false")
+		}
+	})
+
+	t.Run("Rejected request", func(t *testing.T) {
+		t.Parallel()
+
+		m := NewManager(mapStore(), Fingerprint(p.IP(IPExact(), ActionReject)))
+		c := init(m)
+
+		rec, _, ok := auth(m, c, "192.168.1.20", chrome120)
+		if ok || rec.Code != http.StatusUnauthorized {
+			t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+		}
+	})
+
+	t.Run("Rotated session", func(t *testing.T) {
+		t.Parallel()
+
+		m := NewManager(newCASMapStore(), Fingerprint(p), ExpiresIn(time.Hour), CookieTTL(time.Minute))
+		c := init(m)
+
+		ctx := context.Background()
+		m.store.Create(ctx, Session{ID: "ticket", UserKey: "key", ParentID: c.Value, Kind: KindTicket})
+
+		rec, s, ok := auth(m, c, "192.168.1.10", chrome121)
+		if !ok {
+			t.Fatalf("want %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		if ts, _, _ := m.store.FetchByID(ctx, "ticket"); ts.ParentID != s.ID {
+			t.Errorf("want %q, got %q", s.ID, ts.ParentID)
+		}
+
+		// the new session matches the request.
+		if !reflect.DeepEqual(FingerprintResult{}, s.Fingerprint) {
+			t.Errorf("want %v, got %v", FingerprintResult{}, s.Fingerprint)
+		}
+
+		if s.ID == c.Value || s.Agent.Version != "121" {
+			t.Errorf("want new session, got %v", s)
+		}
+
+		cc := rec.Result().Cookies()
+		if len(cc) == 0 || cc[0].Value != s.ID {
+			t.Errorf("want %q, got %v", s.ID, cc)
+		}
+
+		if _, ok, _ = m.store.FetchByID(context.Background(), c.Value); ok {
+			t.Error("want false, got true")
+		}
+
+		ns, ok, _ := m.store.FetchByID(context.Background(), s.ID)
+		if !ok {
+			t.Fatal("want true, got false")
+		}
+
+		if !reflect.DeepEqual(FingerprintResult{}, ns.Fingerprint) || ns.CookieExpiresAt.IsZero() || !ns.CookieExpiresAt.Equal(s.CookieExpiresAt) {
+			t.Errorf("want new cookie expiry and no fingerprint, got %v and %v", ns.CookieExpiresAt, ns.Fingerprint)
+		}
+
+		if len(cc) > 0 && !cc[0].Expires.Equal(s.CookieExpiresAt.Truncate(time.Second)) {
+			t.Errorf("want %v, got %v", s.CookieExpiresAt, cc[0].Expires)
+		}
+	})
+
+	t.Run("Rotation without CAS support", func(t *testing.T) {
+		t.Parallel()
+
+		m := NewManager(mapStore(), Fingerprint(p))
+		c := init(m)
+
+		if rec, _, ok := auth(m, c, "192.168.1.10", chrome121); ok {
+			t.Errorf("want rejection, got %d", rec.Code)
+		}
+
+		if _, ok, _ := m.store.FetchByID(context.Background(), c.Value); !ok {
+			t.Error("want true, got false")
+		}
+	})
+
+	t.Run("Concurrent rotations", func(t *testing.T) {
+		t.Parallel()
+
+		ms := newCASMapStore()
+		m := NewManager(ms, Fingerprint(p))
+		c := init(m)
+
+		// fetched sessions are returned with a delay, so that the
+		// requests see the old session at the same time.
+		fetch := ms.FetchByIDFunc
+		ms.FetchByIDFunc = func(ctx context.Context, id string) (Session, bool, error) {
+			s, ok, err := fetch(ctx, id)
+			time.Sleep(time.Millisecond * 10)
+			return s, ok, err
+		}
+
+		var (
+			wg      sync.WaitGroup
+			rotated int32
+		)
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, ok := auth(m, c, "192.168.1.10", chrome121); ok {
+					atomic.AddInt32(&rotated, 1)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		if rotated != 1 {
+			t.Errorf("want %d, got %d", 1, rotated)
+		}
+
+		ss, _ := ms.FetchByUserKey(context.Background(), "key")
+		if len(ss) != 1 {
+			t.Fatalf("want %d, got %d", 1, len(ss))
+		}
+
+		if ss[0].ID == c.Value || ss[0].Kind != "" {
+			t.Errorf("want new session, got %v", ss[0])
+		}
+	})
+}
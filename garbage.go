@@ -0,0 +1,191 @@
+package sessionup
+
+import (
+	"context"
+	"expvar"
+	"time"
+)
+
+const (
+	defaultGarbageInterval  = time.Hour
+	defaultOutlierThreshold = 1000
+
+	statGarbageTotal     = "garbage_total"
+	statGarbageExpired   = "garbage_expired"
+	statGarbageNoUserKey = "garbage_no_user_key"
+	statGarbageOutliers  = "garbage_outliers"
+)
+
+// IterStore is an optional Store capability used to iterate over all
+// stored sessions (see AnalyzeGarbage).
+type IterStore interface {
+	// Iterate should call the provided function for each session in
+	// the store, including expired sessions that were not deleted yet,
+	// until the function returns false.
+	// Error should be returned on system errors only.
+	Iterate(ctx context.Context, fn func(Session) bool) error
+}
+
+// GarbageConfig holds the data needed to analyze the store for leaked
+// sessions.
+type GarbageConfig struct {
+	// Interval specifies how often the store is analyzed by
+	// WatchGarbage.
+	// Defaults to the value stored in defaultGarbageInterval.
+	Interval time.Duration
+
+	// OutlierThreshold specifies the number of sessions a single user
+	// key must exceed to be reported as an outlier.
+	// Defaults to the value stored in defaultOutlierThreshold.
+	OutlierThreshold int
+
+	// OnReport specifies the function that is called with each
+	// report produced by WatchGarbage.
+	// By default it is not set.
+	OnReport func(GarbageReport)
+
+	// OnError specifies the function that is called when WatchGarbage
+	// fails to analyze the store.
+	// By default it is not set.
+	OnError func(error)
+}
+
+// GarbageReport holds the results of the store's analysis.
+type GarbageReport struct {
+	// Total specifies the number of analyzed sessions.
+	Total int
+
+	// Expired specifies the number of sessions that are expired, but
+	// still present in the store (e.g. because the store's cleanup is
+	// not configured or lags behind).
+	Expired int
+
+	// NoUserKey specifies the number of sessions without a user key.
+	NoUserKey int
+
+	// Outliers holds the number of sessions of each user key that
+	// exceeds the outlier threshold.
+	Outliers map[string]int
+}
+
+// AnalyzeGarbage iterates over all sessions of the store and reports
+// the ones that indicate integration bugs silently leaking sessions:
+// expired sessions that are still present, sessions without user keys
+// and user keys with more sessions than the provided threshold (values
+// <= 0 default to the value stored in defaultOutlierThreshold).
+// If the Expvar option is set, report values are published as gauges.
+// The store must implement IterStore interface, otherwise
+// ErrNotSupported is returned.
+func (m *Manager) AnalyzeGarbage(ctx context.Context, threshold int) (GarbageReport, error) {
+	if ctx == nil {
+		return GarbageReport{}, ErrNilContext
+	}
+
+	is, ok := m.store.(IterStore)
+	if !ok {
+		return GarbageReport{}, ErrNotSupported
+	}
+
+	if threshold <= 0 {
+		threshold = defaultOutlierThreshold
+	}
+
+	var (
+		now    = time.Now()
+		rep    GarbageReport
+		perKey = make(map[string]int)
+	)
+
+	err := is.Iterate(ctx, func(s Session) bool {
+		rep.Total++
+
+		if IsExpired(s, now) {
+			rep.Expired++
+		}
+
+		if s.UserKey == "" {
+			rep.NoUserKey++
+		} else {
+			perKey[s.UserKey]++
+		}
+
+		return ctx.Err() == nil
+	})
+	if err = m.storeErr(err); err != nil {
+		return GarbageReport{}, err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return GarbageReport{}, err
+	}
+
+	for key, n := range perKey {
+		if n <= threshold {
+			continue
+		}
+
+		if rep.Outliers == nil {
+			rep.Outliers = make(map[string]int)
+		}
+
+		rep.Outliers[key] = n
+	}
+
+	m.gauge(statGarbageTotal, rep.Total)
+	m.gauge(statGarbageExpired, rep.Expired)
+	m.gauge(statGarbageNoUserKey, rep.NoUserKey)
+	m.gauge(statGarbageOutliers, len(rep.Outliers))
+
+	return rep, nil
+}
+
+// WatchGarbage analyzes the store (see AnalyzeGarbage) immediately and
+// then periodically, passing the reports and errors to the configured
+// functions. It blocks until the context is done.
+func (m *Manager) WatchGarbage(ctx context.Context, cfg GarbageConfig) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if _, ok := m.store.(IterStore); !ok {
+		return ErrNotSupported
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultGarbageInterval
+	}
+
+	t := time.NewTicker(cfg.Interval)
+	defer t.Stop()
+
+	for {
+		rep, err := m.AnalyzeGarbage(ctx, cfg.OutlierThreshold)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			if cfg.OnError != nil {
+				cfg.OnError(err)
+			}
+		case cfg.OnReport != nil:
+			cfg.OnReport(rep)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// gauge sets the counter of the provided name to the provided value.
+func (m *Manager) gauge(name string, v int) {
+	if m.stats == nil {
+		return
+	}
+
+	g := new(expvar.Int)
+	g.Set(int64(v))
+	m.stats.Set(name, g)
+}
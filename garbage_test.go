@@ -0,0 +1,233 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type iterStoreMock struct {
+	*StoreMock
+	IterateFunc func(ctx context.Context, fn func(Session) bool) error
+}
+
+func (i *iterStoreMock) Iterate(ctx context.Context, fn func(Session) bool) error {
+	return i.IterateFunc(ctx, fn)
+}
+
+func garbageStore(ss []Session, err error) *iterStoreMock {
+	return &iterStoreMock{
+		StoreMock: &StoreMock{},
+		IterateFunc: func(_ context.Context, fn func(Session) bool) error {
+			for _, s := range ss {
+				if !fn(s) {
+					break
+				}
+			}
+
+			return err
+		},
+	}
+}
+
+func TestAnalyzeGarbage(t *testing.T) {
+	valid := time.Now().Add(time.Hour)
+	expired := time.Now().Add(-time.Hour)
+	ss := []Session{
+		{ID: "1", UserKey: "a", ExpiresAt: valid},
+		{ID: "2", UserKey: "a", ExpiresAt: valid},
+		{ID: "3", UserKey: "a", ExpiresAt: expired},
+		{ID: "4", UserKey: "b", ExpiresAt: valid},
+		{ID: "5", ExpiresAt: valid},
+		{ID: "6", ExpiresAt: expired},
+	}
+
+	cc := map[string]struct {
+		Store     Store
+		Ctx       context.Context
+		Threshold int
+		Report    GarbageReport
+		Err       error
+	}{
+		"Nil context": {
+			Store: garbageStore(ss, nil),
+			Err:   ErrNilContext,
+		},
+		"Not supported": {
+			Store: &StoreMock{},
+			Ctx:   context.Background(),
+			Err:   ErrNotSupported,
+		},
+		"Store error": {
+			Store: garbageStore(ss, errors.New("error")),
+			Ctx:   context.Background(),
+			Err:   errors.New("error"),
+		},
+		"Default threshold": {
+			Store: garbageStore(ss, nil),
+			Ctx:   context.Background(),
+			Report: GarbageReport{
+				Total:     6,
+				Expired:   2,
+				NoUserKey: 2,
+			},
+		},
+		"Outliers": {
+			Store:     garbageStore(ss, nil),
+			Ctx:       context.Background(),
+			Threshold: 1,
+			Report: GarbageReport{
+				Total:     6,
+				Expired:   2,
+				NoUserKey: 2,
+				Outliers:  map[string]int{"a": 3},
+			},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m := NewManager(c.Store)
+			rep, err := m.AnalyzeGarbage(c.Ctx, c.Threshold)
+			if !reflect.DeepEqual(err, c.Err) {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if !reflect.DeepEqual(rep, c.Report) {
+				t.Errorf("want %v, got %v", c.Report, rep)
+			}
+		})
+	}
+}
+
+func TestAnalyzeGarbageCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var n int
+	store := &iterStoreMock{
+		StoreMock: &StoreMock{},
+		IterateFunc: func(_ context.Context, fn func(Session) bool) error {
+			for fn(Session{}) {
+				n++
+				cancel()
+			}
+
+			return nil
+		},
+	}
+
+	if _, err := NewManager(store).AnalyzeGarbage(ctx, 0); err != context.Canceled {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+
+	if n != 1 {
+		t.Errorf("want %d, got %d", 1, n)
+	}
+}
+
+func TestAnalyzeGarbageExpvar(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	ss := []Session{{ID: "1", ExpiresAt: exp}, {ID: "2", UserKey: "a", ExpiresAt: exp}}
+
+	m := NewManager(garbageStore(ss, nil), Expvar("garbage_test"))
+	if _, err := m.AnalyzeGarbage(context.Background(), 0); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	stats := expvar.Get("garbage_test").(*expvar.Map)
+	for name, want := range map[string]string{
+		statGarbageTotal:     "2",
+		statGarbageExpired:   "0",
+		statGarbageNoUserKey: "1",
+		statGarbageOutliers:  "0",
+	} {
+		if v := stats.Get(name); v == nil || v.String() != want {
+			t.Errorf("want %s=%s, got %v", name, want, v)
+		}
+	}
+}
+
+func TestAnalyzeGarbagePrefix(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	store := garbageStore([]Session{
+		{ID: "app:1", UserKey: "app:a", ExpiresAt: exp},
+		{ID: "app:2", UserKey: "app:a", ExpiresAt: exp},
+		{ID: "other:3", UserKey: "other:a", ExpiresAt: exp},
+	}, nil)
+
+	rep, err := NewManager(store, KeyPrefix("app:")).AnalyzeGarbage(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	want := GarbageReport{Total: 2, Outliers: map[string]int{"a": 2}}
+	if !reflect.DeepEqual(rep, want) {
+		t.Errorf("want %v, got %v", want, rep)
+	}
+}
+
+func TestWatchGarbage(t *testing.T) {
+	m := NewManager(&StoreMock{})
+	if err := m.WatchGarbage(context.Background(), GarbageConfig{}); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+
+	var (
+		fail    error
+		reports = make(chan GarbageReport, 10)
+		errs    = make(chan error, 10)
+	)
+
+	store := &iterStoreMock{
+		StoreMock: &StoreMock{},
+		IterateFunc: func(_ context.Context, fn func(Session) bool) error {
+			if fail != nil {
+				return fail
+			}
+
+			fn(Session{ID: "1"})
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewManager(store).WatchGarbage(ctx, GarbageConfig{
+			Interval: time.Millisecond * 10,
+			OnReport: func(r GarbageReport) { reports <- r },
+			OnError:  func(err error) { errs <- err },
+		})
+	}()
+
+	if r := <-reports; r.Total != 1 || r.NoUserKey != 1 {
+		t.Errorf("want 1 session without user key, got %v", r)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+
+	fail = errors.New("error")
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		done <- NewManager(store).WatchGarbage(ctx, GarbageConfig{
+			OnReport: func(r GarbageReport) { reports <- r },
+			OnError:  func(err error) { errs <- err },
+		})
+	}()
+
+	if err := <-errs; err != fail {
+		t.Errorf("want %v, got %v", fail, err)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,155 @@
+package sessionup
+
+import (
+	"net/http"
+	"strings"
+)
+
+const defaultGatewayPrefix = "X-Auth-"
+
+// GatewayConfig is used to configure the Gateway middleware.
+type GatewayConfig struct {
+	// Prefix specifies the prefix of all identity headers that are
+	// injected into requests toward the backend. Inbound headers
+	// with this prefix are always removed, so that clients cannot
+	// spoof them.
+	// Defaults to "X-Auth-".
+	Prefix string
+
+	// Headers, if set, is used to produce additional identity
+	// headers from the session (e.g. roles stored in Meta). The
+	// returned names are prefixed with Prefix.
+	Headers func(s Session) map[string]string
+
+	// AllowAnonymous specifies whether requests without a valid
+	// session should be passed to the backend (with all identity
+	// headers removed) instead of being rejected.
+	AllowAnonymous bool
+
+	// KeepCookie specifies whether the session cookie should be
+	// forwarded to the backend. By default it is removed, so that
+	// backends never see session IDs.
+	KeepCookie bool
+}
+
+// Gateway wraps the provided handler (usually *httputil.ReverseProxy)
+// and turns it into an authentication gateway: inbound identity headers
+// are stripped, the session is checked the same way Auth does it (or
+// Public, if AllowAnonymous is set) and, on success, the user key,
+// session ID and org key are injected into the request as
+// <Prefix>User, <Prefix>Session and <Prefix>Org headers.
+// Rejections are handled by the manager's rejection function.
+func (m *Manager) Gateway(cfg GatewayConfig, next http.Handler) http.Handler {
+	if cfg.Prefix == "" {
+		cfg.Prefix = defaultGatewayPrefix
+	}
+
+	cfg.Prefix = http.CanonicalHeaderKey(cfg.Prefix)
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s, ok := FromContext(r.Context()); ok {
+			set := func(name, val string) {
+				if val != "" {
+					r.Header.Set(cfg.Prefix+name, val)
+				}
+			}
+
+			set("User", s.UserKey)
+			set("Session", s.ID)
+			set("Org", s.OrgKey)
+
+			if cfg.Headers != nil {
+				for name, val := range cfg.Headers(s) {
+					set(name, val)
+				}
+			}
+		}
+
+		if !cfg.KeepCookie {
+			m.stripCookies(r)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	var h http.Handler
+	if cfg.AllowAnonymous {
+		h = m.Public(inner)
+	} else {
+		h = m.Auth(inner)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+		stripHeaders(r.Header, cfg.Prefix)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// stripHeaders removes all headers with the provided prefix, as well
+// as their mentions in the Connection header, which would otherwise
+// allow clients to make proxies drop the injected headers. Underscores
+// in header names are treated as dashes, since many backends (e.g. CGI
+// and WSGI based ones) do not tell them apart.
+func stripHeaders(h http.Header, prefix string) {
+	for name := range h {
+		if strings.HasPrefix(normalHeader(name), prefix) {
+			delete(h, name)
+		}
+	}
+
+	cc := h["Connection"]
+	if len(cc) == 0 {
+		return
+	}
+
+	var res []string
+	for _, c := range cc {
+		for _, tok := range strings.Split(c, ",") {
+			tok = strings.TrimSpace(tok)
+			if tok == "" || strings.HasPrefix(normalHeader(tok), prefix) {
+				continue
+			}
+
+			res = append(res, tok)
+		}
+	}
+
+	if len(res) == 0 {
+		delete(h, "Connection")
+		return
+	}
+
+	h["Connection"] = []string{strings.Join(res, ", ")}
+}
+
+// stripCookies removes the session cookie (including the ones with old
+// names) from the request.
+func (m *Manager) stripCookies(r *http.Request) {
+	cc := r.Cookies()
+	r.Header.Del("Cookie")
+
+	for _, c := range cc {
+		if c.Name == m.cookie.name {
+			continue
+		}
+
+		var old bool
+		for _, n := range m.cookie.oldNames {
+			if c.Name == n {
+				old = true
+				break
+			}
+		}
+
+		if !old {
+			r.AddCookie(c)
+		}
+	}
+}
+
+// normalHeader returns the canonical form of the header name with its
+// underscores replaced by dashes.
+func normalHeader(name string) string {
+	return http.CanonicalHeaderKey(strings.ReplaceAll(name, "_", "-"))
+}
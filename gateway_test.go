@@ -0,0 +1,116 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGateway(t *testing.T) {
+	store := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			if id != "id" {
+				return Session{}, false, nil
+			}
+
+			return Session{ID: "id", UserKey: "key", OrgKey: "org"}, true, nil
+		},
+	}
+
+	cc := map[string]struct {
+		Cookie    string
+		Anonymous bool
+		Code      int
+		Headers   map[string]string
+	}{
+		"Invalid session": {
+			Cookie: "unknown",
+			Code:   http.StatusUnauthorized,
+		},
+		"Invalid session with anonymous access": {
+			Cookie:    "unknown",
+			Anonymous: true,
+			Code:      http.StatusOK,
+			Headers: map[string]string{
+				"X-Auth-User":    "",
+				"X-Auth-Session": "",
+				"X-Auth-Role":    "",
+				"Connection":     "keep-alive",
+				"Cookie":         "other=1",
+			},
+		},
+		"Valid session": {
+			Cookie: "id",
+			Code:   http.StatusOK,
+			Headers: map[string]string{
+				"X-Auth-User":    "key",
+				"X-Auth-Session": "id",
+				"X-Auth-Org":     "org",
+				"X-Auth-Role":    "admin",
+				"Connection":     "keep-alive",
+				"Cookie":         "other=1",
+			},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			var hdr http.Header
+			next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				hdr = r.Header
+			})
+
+			m := NewManager(store)
+			h := m.Gateway(GatewayConfig{
+				AllowAnonymous: c.Anonymous,
+				Headers: func(_ Session) map[string]string {
+					return map[string]string{"Role": "admin"}
+				},
+			}, next)
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set("X-Auth-User", "spoofed")
+			req.Header.Set("X-Auth-Role", "spoofed")
+			req.Header.Set("Connection", "keep-alive, x-auth-user")
+			req.AddCookie(&http.Cookie{Name: defaultName, Value: c.Cookie})
+			req.AddCookie(&http.Cookie{Name: "other", Value: "1"})
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != c.Code {
+				t.Errorf("want %d, got %d", c.Code, rec.Code)
+			}
+
+			if req.Header.Get("X-Auth-User") != "spoofed" {
+				t.Error("want original request to be unmodified")
+			}
+
+			for name, val := range c.Headers {
+				if hdr.Get(name) != val {
+					t.Errorf("%s: want %q, got %q", name, val, hdr.Get(name))
+				}
+			}
+		})
+	}
+}
+
+func TestStripHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Auth-User", "1")
+	h.Set("Connection", "X-Auth-User, x_auth_org")
+	h.Set("Accept", "*/*")
+
+	// names with underscores bypass canonicalization.
+	h["X_Auth_User"] = []string{"1"}
+	h["x_auth_session"] = []string{"1"}
+
+	stripHeaders(h, "X-Auth-")
+	if len(h) != 1 || h.Get("Accept") != "*/*" {
+		t.Errorf("want only Accept header, got %v", h)
+	}
+}
@@ -1,9 +1,8 @@
 module github.com/swithek/sessionup
 
-go 1.12
+go 1.13
 
 require (
 	github.com/blang/semver v3.5.1+incompatible // indirect
-	github.com/dchest/uniuri v0.0.0-20160212164326-8902c56451e9
 	xojoc.pw/useragent v0.0.0-20170215185434-52903803fc66
 )
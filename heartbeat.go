@@ -0,0 +1,95 @@
+package sessionup
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// defaultHeartbeat is the default heartbeat interval.
+const defaultHeartbeat = time.Minute
+
+// heartbeat is the response of the heartbeat handler.
+type heartbeat struct {
+	// ExpiresAt specifies the expiration time of the session.
+	// It is omitted for temporary sessions.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// NextPing specifies the number of seconds after which the
+	// client should ping the handler again.
+	NextPing int64 `json:"next_ping"`
+}
+
+// HeartbeatHandler produces a handler that SPAs can ping to keep the
+// session stored in the request's context alive during active use: the
+// session's expiration time, as well as that of its blobs (see
+// BlobThreshold), is moved to now + TTL (see AdaptiveTTL) and its
+// cookie is re-issued. To limit the number of store writes, the session is
+// extended at most once per provided interval (values <= 0 default to
+// a minute). Temporary sessions are never extended.
+// The handler responds with JSON object holding the session's
+// expiration time ('expires_at') and the number of seconds after which
+// the next ping is expected ('next_ping').
+// The store must implement either CASStore or UpdateStore interface.
+// The handler must be used inside Auth middleware.
+func (m *Manager) HeartbeatHandler(interval time.Duration) http.Handler {
+	if interval <= 0 {
+		interval = defaultHeartbeat
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := FromContext(r.Context())
+		if !ok {
+			m.reject(ErrUnauthorized).ServeHTTP(w, r)
+			return
+		}
+
+		var res heartbeat
+		res.NextPing = int64(interval / time.Second)
+
+		now := time.Now()
+		if ttl, meta := m.renewal(s, now); ttl != 0 {
+			exp := now.Add(ttl)
+			if exp.Sub(s.ExpiresAt) >= interval {
+				s.ExpiresAt = exp
+				s.Meta = meta
+				s.CookieExpiresAt = m.sessionCookieExpiry(s.UserKey, exp)
+
+				// blobs are extended first, so that they never
+				// expire before their session.
+				if err := m.extendBlobs(r.Context(), s); err != nil {
+					m.reject(m.storeErr(err)).ServeHTTP(w, r)
+					return
+				}
+
+				us, err := m.update(r.Context(), s)
+				switch {
+				case err == nil:
+					s = us
+					if err = m.setSessionCookie(w, s.CookieExpiresAt, s); err != nil {
+						m.reject(err).ServeHTTP(w, r)
+						return
+					}
+
+					if m.csrfActive() {
+						m.setCSRFCookie(w, s.CookieExpiresAt, s.CSRFToken)
+					}
+				case errors.Is(err, ErrConflict):
+					// the session was modified concurrently,
+					// most likely by another heartbeat.
+				default:
+					m.reject(m.storeErr(err)).ServeHTTP(w, r)
+					return
+				}
+			}
+
+			res.ExpiresAt = &s.ExpiresAt
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(res)
+	})
+}
@@ -0,0 +1,158 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatHandler(t *testing.T) {
+	cc := map[string]struct {
+		Ctx       bool
+		ExpiresIn time.Duration
+		Remaining time.Duration
+		UpdateErr error
+		Code      int
+		Updated   int
+		Cookie    bool
+	}{
+		"No context session": {
+			ExpiresIn: time.Hour,
+			Code:      http.StatusUnauthorized,
+		},
+		"Temporary session": {
+			Ctx:  true,
+			Code: http.StatusOK,
+		},
+		"Recently extended session": {
+			Ctx:       true,
+			ExpiresIn: time.Hour,
+			Remaining: time.Hour - time.Second*10,
+			Code:      http.StatusOK,
+		},
+		"Update conflict": {
+			Ctx:       true,
+			ExpiresIn: time.Hour,
+			Remaining: time.Minute * 30,
+			UpdateErr: ErrConflict,
+			Code:      http.StatusOK,
+			Updated:   1,
+		},
+		"Update error": {
+			Ctx:       true,
+			ExpiresIn: time.Hour,
+			Remaining: time.Minute * 30,
+			UpdateErr: errors.New("error"),
+			Code:      http.StatusUnauthorized,
+			Updated:   1,
+		},
+		"Successful extension": {
+			Ctx:       true,
+			ExpiresIn: time.Hour,
+			Remaining: time.Minute * 30,
+			Code:      http.StatusOK,
+			Updated:   1,
+			Cookie:    true,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			store := &updateStoreMock{
+				StoreMock: &StoreMock{},
+				UpdateFunc: func(_ context.Context, _ Session) error {
+					return c.UpdateErr
+				},
+			}
+
+			m := NewManager(store, ExpiresIn(c.ExpiresIn))
+			req := httptest.NewRequest("POST", "/", nil)
+			if c.Ctx {
+				s := Session{ID: "id", UserKey: "key", ExpiresAt: time.Now().Add(c.Remaining)}
+				req = req.WithContext(NewContext(req.Context(), s))
+			}
+
+			rec := httptest.NewRecorder()
+			m.HeartbeatHandler(time.Minute).ServeHTTP(rec, req)
+
+			if rec.Code != c.Code {
+				t.Fatalf("want %d, got %d", c.Code, rec.Code)
+			}
+
+			if len(store.updated) != c.Updated {
+				t.Errorf("want %d, got %d", c.Updated, len(store.updated))
+			}
+
+			if cookies := rec.Result().Cookies(); (len(cookies) == 1) != c.Cookie {
+				t.Errorf("want cookie %t, got %v", c.Cookie, cookies)
+			}
+
+			if c.Code != http.StatusOK {
+				return
+			}
+
+			var res heartbeat
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if res.NextPing != 60 {
+				t.Errorf("want %d, got %d", 60, res.NextPing)
+			}
+
+			if (res.ExpiresAt != nil) != (c.ExpiresIn != 0) {
+				t.Errorf("want expiration time %t, got %v", c.ExpiresIn != 0, res.ExpiresAt)
+			}
+
+			if c.Cookie && res.ExpiresAt.Before(time.Now().Add(c.ExpiresIn-time.Second)) {
+				t.Errorf("want extended expiration time, got %v", res.ExpiresAt)
+			}
+		})
+	}
+}
+
+// blobUpdateStoreMock is a blobStoreMock with UpdateStore capability.
+type blobUpdateStoreMock struct {
+	*blobStoreMock
+}
+
+func (b blobUpdateStoreMock) Update(_ context.Context, _ Session) error {
+	return nil
+}
+
+func TestHeartbeatHandlerBlobs(t *testing.T) {
+	store := blobUpdateStoreMock{&blobStoreMock{
+		StoreMock: &StoreMock{},
+		blobs:     make(map[string][]byte),
+		exps:      make(map[string]time.Time),
+	}}
+
+	m := NewManager(store, ExpiresIn(time.Hour))
+	s := Session{ID: "id", UserKey: "key", ExpiresAt: time.Now().Add(time.Millisecond * 20)}
+	store.CreateBlob(context.Background(), "id:cart", []byte("cart"), s.ExpiresAt)
+	s.Blobs = map[string]string{"cart": "id:cart"}
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req = req.WithContext(NewContext(req.Context(), s))
+	rec := httptest.NewRecorder()
+	m.HeartbeatHandler(time.Minute).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	// the session's original TTL passes.
+	time.Sleep(time.Millisecond * 40)
+
+	v, ok, err := m.FetchMeta(req.Context(), "cart")
+	if err != nil || !ok || v != "cart" {
+		t.Errorf("want %q/%t/nil, got %q/%t/%v", "cart", true, v, ok, err)
+	}
+}
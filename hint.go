@@ -0,0 +1,120 @@
+package sessionup
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultHintWindow = time.Second * 5
+
+	// hintSweep is the minimum number of remembered sessions after
+	// which the expired ones are removed.
+	hintSweep = 1024
+)
+
+// ReadYourWrites makes Init (as well as Derive) remember each new session
+// in memory for the provided duration, so that requests handled by the
+// same instance right after the login are authenticated even if a
+// replicated, eventually consistent store has not caught up yet. The
+// remembered session is used only when the store reports that the
+// session does not exist. Revocations performed by the same instance
+// forget the remembered sessions immediately, however sessions revoked
+// by other instances remain valid here until the window passes, so it
+// should be kept as short as the store's replication lag allows.
+// Zero or negative duration defaults to 5 seconds.
+// By default it is not set.
+func ReadYourWrites(window time.Duration) Option {
+	if window <= 0 {
+		window = defaultHintWindow
+	}
+
+	return func(m *Manager) {
+		m.hints = &hints{window: window, ss: make(map[string]hint)}
+	}
+}
+
+// hints holds the recently created sessions of the manager and its
+// clones.
+type hints struct {
+	window  time.Duration
+	mu      sync.Mutex
+	ss      map[string]hint
+	sweepAt int
+}
+
+// hint holds a recently created session.
+type hint struct {
+	s   Session
+	exp time.Time
+}
+
+// remember stores the new session. Expired sessions are removed when
+// they are looked up or, in bulk, once the number of remembered
+// sessions doubles since the last removal, so that their cost is
+// spread across the calls.
+func (h *hints) remember(s Session) {
+	if h == nil {
+		return
+	}
+
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.ss) >= h.sweepAt {
+		for id, ht := range h.ss {
+			if now.After(ht.exp) {
+				delete(h.ss, id)
+			}
+		}
+
+		h.sweepAt = len(h.ss)*2 + hintSweep
+	}
+
+	h.ss[s.ID] = hint{s: s, exp: now.Add(h.window)}
+}
+
+// lookup retrieves the recently created session by its ID.
+func (h *hints) lookup(id string) (Session, bool) {
+	if h == nil {
+		return Session{}, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ht, ok := h.ss[id]
+	if !ok {
+		return Session{}, false
+	}
+
+	if now := time.Now(); now.After(ht.exp) || !ht.s.ExpiresAt.After(now) {
+		delete(h.ss, id)
+		return Session{}, false
+	}
+
+	return ht.s, true
+}
+
+// forget removes the recently created sessions matched by the
+// revocation.
+func (h *hints) forget(r Revocation) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, ht := range h.ss {
+		switch {
+		case id == r.ExceptID:
+		case r.ID != "" && id == r.ID,
+			r.UserKey != "" && ht.s.UserKey == r.UserKey,
+			r.OrgKey != "" && ht.s.OrgKey == r.OrgKey:
+			delete(h.ss, id)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	m := Manager{}
+	ReadYourWrites(0)(&m)
+	if m.hints == nil || m.hints.window != defaultHintWindow {
+		t.Errorf("want %v, got %v", defaultHintWindow, m.hints)
+	}
+
+	ReadYourWrites(time.Minute)(&m)
+	if m.hints.window != time.Minute {
+		t.Errorf("want %v, got %v", time.Minute, m.hints.window)
+	}
+}
+
+func TestReadYourWritesAuth(t *testing.T) {
+	lagging := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+			return Session{}, false, nil
+		},
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return nil
+		},
+	}
+
+	login := func(m *Manager) *http.Cookie {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if err := m.Init(rec, req, "key"); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return rec.Result().Cookies()[0]
+	}
+
+	auth := func(m *Manager, c *http.Cookie) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.AddCookie(c)
+		m.Auth(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	m := NewManager(lagging)
+	if code := auth(m, login(m)); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	m = NewManager(lagging, ReadYourWrites(time.Millisecond*50))
+	c := login(m)
+	if code := auth(m, c); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	if code := auth(m, c); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	m = NewManager(lagging, ReadYourWrites(time.Minute))
+	c = login(m)
+	if err := m.RevokeByID(context.Background(), c.Value); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if code := auth(m, c); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	// sessions found in the store are not shadowed by the hints.
+	ms := mapStore()
+	m = NewManager(ms, ReadYourWrites(time.Minute))
+	c = login(m)
+	if err := ms.DeleteByID(context.Background(), c.Value); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if code := auth(m, c); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestHintsForget(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	ss := []Session{
+		{ID: "1", UserKey: "u1", OrgKey: "o1", ExpiresAt: exp},
+		{ID: "2", UserKey: "u1", OrgKey: "o2", ExpiresAt: exp},
+		{ID: "3", UserKey: "u2", OrgKey: "o1", ExpiresAt: exp},
+	}
+
+	cc := map[string]struct {
+		Revocation Revocation
+		Left       []string
+	}{
+		"By ID": {
+			Revocation: Revocation{ID: "2"},
+			Left:       []string{"1", "3"},
+		},
+		"By user key": {
+			Revocation: Revocation{UserKey: "u1"},
+			Left:       []string{"3"},
+		},
+		"By user key except ID": {
+			Revocation: Revocation{UserKey: "u1", ExceptID: "1"},
+			Left:       []string{"1", "3"},
+		},
+		"By org key": {
+			Revocation: Revocation{OrgKey: "o1"},
+			Left:       []string{"2"},
+		},
+		"No match": {
+			Revocation: Revocation{UserKey: "u3"},
+			Left:       []string{"1", "2", "3"},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m := Manager{}
+			ReadYourWrites(time.Minute)(&m)
+			for _, s := range ss {
+				m.hints.remember(s)
+			}
+
+			m.hints.forget(c.Revocation)
+
+			if len(m.hints.ss) != len(c.Left) {
+				t.Errorf("want %d, got %d", len(c.Left), len(m.hints.ss))
+			}
+
+			for _, id := range c.Left {
+				if _, ok := m.hints.lookup(id); !ok {
+					t.Errorf("want %q remembered", id)
+				}
+			}
+		})
+	}
+
+	var h *hints
+	h.remember(ss[0])
+	h.forget(Revocation{ID: "1"})
+	if _, ok := h.lookup("1"); ok {
+		t.Error("want false, got true")
+	}
+}
+
+func TestHintsExpiry(t *testing.T) {
+	h := &hints{window: time.Minute, ss: make(map[string]hint)}
+	live := Session{ID: "live", ExpiresAt: time.Now().Add(time.Hour)}
+
+	h.ss["expired"] = hint{s: live, exp: time.Now().Add(-time.Second)}
+	h.remember(live)
+
+	// expired sessions are not swept on every call.
+	h.ss["stale"] = hint{s: live, exp: time.Now().Add(-time.Second)}
+	h.remember(Session{ID: "other", ExpiresAt: time.Now().Add(time.Hour)})
+	if _, ok := h.ss["stale"]; !ok || h.sweepAt != hintSweep {
+		t.Errorf("want stale hint kept until %d, got %v and %d", hintSweep, h.ss, h.sweepAt)
+	}
+
+	if _, ok := h.ss["expired"]; ok {
+		t.Error("want expired hint removed, got kept")
+	}
+
+	if _, ok := h.lookup("stale"); ok {
+		t.Error("want false, got true")
+	}
+
+	if _, ok := h.ss["stale"]; ok {
+		t.Error("want stale hint removed on lookup, got kept")
+	}
+
+	if s, ok := h.lookup("live"); !ok || s.ID != "live" {
+		t.Errorf("want %q, got %v", "live", s)
+	}
+}
@@ -0,0 +1,63 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// idempotencyRefPrefix is prepended to the BlobStore references of
+// idempotency keys, so that they can't collide with the references of
+// metadata values.
+const idempotencyRefPrefix = "idempotency:"
+
+// ErrInvalidIdempotencyKey is returned when the idempotency key is
+// empty.
+var ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+
+// Idempotency records the provided idempotency key (e.g. the value of
+// the Idempotency-Key header) under the session stored in the context
+// and reports whether it was seen for the first time, so that retried
+// mutations (double-click submits, retries on flaky networks) can be
+// detected without a separate deduplication system. Keys are kept for
+// the provided ttl (or, if it is not positive, as long as the session),
+// but no longer than the session itself; the same key sent with another
+// session is considered to be seen for the first time.
+// Keys are kept as records of the BlobStore, which must return
+// ErrDuplicateID on reference collisions; if the store does not
+// implement BlobStore interface, ErrNotSupported is returned.
+// ErrUnauthorized is returned if the context session is not set.
+func (m *Manager) Idempotency(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ctx == nil {
+		return false, ErrNilContext
+	}
+
+	if key == "" {
+		return false, ErrInvalidIdempotencyKey
+	}
+
+	s, ok := FromContext(ctx)
+	if !ok {
+		return false, ErrUnauthorized
+	}
+
+	bs, ok := m.store.(BlobStore)
+	if !ok {
+		return false, ErrNotSupported
+	}
+
+	exp := time.Now().Add(ttl)
+	if ttl <= 0 || !s.ExpiresAt.IsZero() && s.ExpiresAt.Before(exp) {
+		exp = s.ExpiresAt
+	}
+
+	err := m.storeErr(bs.CreateBlob(ctx, idempotencyRefPrefix+s.ID+":"+key, nil, exp))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrDuplicateID):
+		return false, nil
+	default:
+		return false, err
+	}
+}
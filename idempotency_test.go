@@ -0,0 +1,105 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// uniqueBlobStoreMock is a Store with BlobStore capability that rejects
+// reference collisions.
+type uniqueBlobStoreMock struct {
+	*StoreMock
+	exps map[string]time.Time
+	err  error
+}
+
+func (u *uniqueBlobStoreMock) CreateBlob(_ context.Context, ref string, _ []byte, exp time.Time) error {
+	if u.err != nil {
+		return u.err
+	}
+
+	if _, ok := u.exps[ref]; ok {
+		return ErrDuplicateID
+	}
+
+	u.exps[ref] = exp
+	return nil
+}
+
+func (u *uniqueBlobStoreMock) FetchBlob(_ context.Context, _ string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (u *uniqueBlobStoreMock) DeleteBlob(_ context.Context, _ string) error {
+	return nil
+}
+
+func (u *uniqueBlobStoreMock) ExtendBlob(_ context.Context, _ string, _ time.Time) error {
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	now := time.Now()
+	s1 := Session{ID: "id1", ExpiresAt: now.Add(time.Hour)}
+	s2 := Session{ID: "id2", ExpiresAt: now.Add(time.Hour)}
+	ctx1 := NewContext(context.Background(), s1)
+	ctx2 := NewContext(context.Background(), s2)
+
+	store := &uniqueBlobStoreMock{StoreMock: &StoreMock{}, exps: make(map[string]time.Time)}
+	m := NewManager(store)
+
+	var nilCtx context.Context
+	if _, err := m.Idempotency(nilCtx, "key", time.Minute); err != ErrNilContext {
+		t.Errorf("want %v, got %v", ErrNilContext, err)
+	}
+
+	if _, err := m.Idempotency(ctx1, "", time.Minute); err != ErrInvalidIdempotencyKey {
+		t.Errorf("want %v, got %v", ErrInvalidIdempotencyKey, err)
+	}
+
+	if _, err := m.Idempotency(context.Background(), "key", time.Minute); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	cc := []struct {
+		Ctx   context.Context
+		Key   string
+		TTL   time.Duration
+		First bool
+		Exp   time.Time
+	}{
+		{Ctx: ctx1, Key: "a", TTL: time.Minute, First: true, Exp: now.Add(time.Minute)},
+		{Ctx: ctx1, Key: "a", TTL: time.Minute},
+		{Ctx: ctx2, Key: "a", TTL: time.Hour * 2, First: true, Exp: s2.ExpiresAt},
+		{Ctx: ctx1, Key: "b", First: true, Exp: s1.ExpiresAt},
+	}
+
+	for i, c := range cc {
+		first, err := m.Idempotency(c.Ctx, c.Key, c.TTL)
+		if err != nil {
+			t.Fatalf("%d: want nil, got %v", i, err)
+		}
+
+		if first != c.First {
+			t.Errorf("%d: want %v, got %v", i, c.First, first)
+		}
+
+		s, _ := FromContext(c.Ctx)
+		exp := store.exps[idempotencyRefPrefix+s.ID+":"+c.Key]
+		if c.First && (exp.Sub(c.Exp) > time.Second || c.Exp.Sub(exp) > time.Second) {
+			t.Errorf("%d: want %v, got %v", i, c.Exp, exp)
+		}
+	}
+
+	store.err = errors.New("error")
+	if _, err := m.Idempotency(ctx1, "c", time.Minute); err != store.err {
+		t.Errorf("want %v, got %v", store.err, err)
+	}
+
+	m = NewManager(&StoreMock{})
+	if _, err := m.Idempotency(ctx1, "key", time.Minute); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+}
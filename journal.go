@@ -0,0 +1,97 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrCompacted is returned by Journal implementations when revocations
+// following the requested sequence number were removed by compaction.
+// Readers should resynchronize their state from the store and continue
+// from the latest sequence number.
+var ErrCompacted = errors.New("revocation journal was compacted")
+
+// Revocation describes a single revocation recorded in the revocation
+// journal. Exactly one of ID, UserKey and OrgKey is set.
+type Revocation struct {
+	// Seq specifies the sequence number of the revocation, assigned
+	// by the journal. Sequence numbers start at 1 and have no gaps.
+	Seq uint64 `json:"seq"`
+
+	// At specifies a point in time when the revocation happened.
+	At time.Time `json:"at"`
+
+	// ID specifies the ID of the revoked session.
+	ID string `json:"id,omitempty"`
+
+	// UserKey specifies the user key whose sessions, created before
+	// At, were revoked.
+	UserKey string `json:"user_key,omitempty"`
+
+	// OrgKey specifies the organization key whose sessions, created
+	// before At, were revoked.
+	OrgKey string `json:"org_key,omitempty"`
+
+	// ExceptID specifies the ID of the session that was excluded
+	// from a revocation by user key.
+	ExceptID string `json:"except_id,omitempty"`
+}
+
+// Journal is an append-only log of revocations that edge validators can
+// tail to keep their caches in sync without a pub/sub system. It may be
+// implemented by stores as an optional capability or provided
+// separately with the RevocationJournal option.
+type Journal interface {
+	// AppendRevocation should assign the next sequence number to the
+	// provided revocation and append it to the journal.
+	// Error should be returned on system errors only.
+	AppendRevocation(ctx context.Context, r Revocation) error
+
+	// ReadRevocations should retrieve at most limit revocations
+	// (all of them, if limit <= 0), whose sequence numbers are
+	// greater than the provided one, in order. ErrCompacted should
+	// be returned if some of them were removed by compaction.
+	// Error should be returned on system errors as well.
+	ReadRevocations(ctx context.Context, after uint64, limit int) ([]Revocation, error)
+}
+
+// RevocationJournal sets the journal to which all revocations performed
+// by the manager will be appended. If it is not set, but the store
+// implements Journal interface, the store is used instead.
+// By default it is not set.
+func RevocationJournal(j Journal) Option {
+	return func(m *Manager) {
+		m.journal = j
+	}
+}
+
+// record appends the revocation to the journal, if it is available.
+func (m *Manager) record(ctx context.Context, r Revocation) error {
+	m.hints.forget(r)
+	m.flight.forget(r)
+
+	j := m.revocationJournal()
+	if j == nil {
+		return nil
+	}
+
+	r.At = time.Now()
+	if err := j.AppendRevocation(ctx, r); err != nil && !errors.Is(err, ErrNotSupported) {
+		return err
+	}
+
+	return nil
+}
+
+// revocationJournal returns the journal set with the RevocationJournal
+// option or, if it is not set, the store, if it implements Journal
+// interface.
+func (m *Manager) revocationJournal() Journal {
+	if m.journal != nil {
+		return m.journal
+	}
+
+	j, _ := m.store.(Journal)
+	return j
+}
@@ -0,0 +1,259 @@
+// Package journal provides a file-based sessionup.Journal implementation
+// and helpers for tailing revocation journals.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+// File is a sessionup.Journal implementation that keeps revocations in
+// an append-only file, one JSON object per line.
+type File struct {
+	mu    sync.Mutex
+	path  string
+	f     *os.File
+	first uint64
+	last  uint64
+}
+
+// Open opens the journal file at the provided path, creating it if it
+// does not exist. A partially written last line (e.g. after a crash) is
+// discarded.
+func Open(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &File{path: path, f: f}
+
+	var off int64
+	err = scan(f, func(r sessionup.Revocation, end int64) bool {
+		if j.first == 0 {
+			j.first = r.Seq
+		}
+
+		j.last = r.Seq
+		off = end
+		return true
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err = f.Truncate(off); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err = f.Seek(off, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// AppendRevocation implements sessionup.Journal interface's
+// AppendRevocation method. The file is synced after each write.
+func (j *File) AppendRevocation(_ context.Context, r sessionup.Revocation) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	r.Seq = j.last + 1
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err = j.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	if err = j.f.Sync(); err != nil {
+		return err
+	}
+
+	if j.first == 0 {
+		j.first = r.Seq
+	}
+
+	j.last = r.Seq
+	return nil
+}
+
+// ReadRevocations implements sessionup.Journal interface's
+// ReadRevocations method.
+func (j *File) ReadRevocations(_ context.Context, after uint64, limit int) ([]sessionup.Revocation, error) {
+	j.mu.Lock()
+	first, last := j.first, j.last
+	j.mu.Unlock()
+
+	if after >= last {
+		return nil, nil
+	}
+
+	if after+1 < first {
+		return nil, sessionup.ErrCompacted
+	}
+
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rr []sessionup.Revocation
+	err = scan(f, func(r sessionup.Revocation, _ int64) bool {
+		if r.Seq > last {
+			return false
+		}
+
+		if r.Seq > after {
+			rr = append(rr, r)
+		}
+
+		return limit <= 0 || len(rr) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// compaction could have happened after the bounds were checked.
+	if len(rr) > 0 && rr[0].Seq != after+1 {
+		return nil, sessionup.ErrCompacted
+	}
+
+	return rr, nil
+}
+
+// Compact removes revocations that happened before the provided time
+// (e.g. older than the maximum session TTL, since their sessions have
+// expired anyway). The last revocation is always kept, so that sequence
+// numbers continue after restarts.
+// Readers whose checkpoints fall into the removed range receive
+// sessionup.ErrCompacted.
+func (j *File) Compact(before time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.last == 0 {
+		return nil
+	}
+
+	rf, err := os.Open(j.path)
+	if err != nil {
+		return err
+	}
+	defer rf.Close()
+
+	var (
+		buf   bytes.Buffer
+		first uint64
+	)
+
+	enc := json.NewEncoder(&buf)
+	err = scan(rf, func(r sessionup.Revocation, _ int64) bool {
+		if r.At.Before(before) && r.Seq != j.last {
+			return true
+		}
+
+		if first == 0 {
+			first = r.Seq
+		}
+
+		enc.Encode(r)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(j.path), filepath.Base(j.path)+".compact")
+	if err != nil {
+		return err
+	}
+
+	if err = writeSync(tmp, buf.Bytes()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), j.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	f, err := os.OpenFile(j.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	j.f.Close()
+	j.f = f
+	j.first = first
+	return nil
+}
+
+// Close closes the journal file.
+func (j *File) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+// scan reads revocations from the reader and passes them, together with
+// the offset of their line's end, to the provided function until it
+// returns false. Reading stops at the first malformed line.
+func scan(r io.Reader, fn func(r sessionup.Revocation, end int64) bool) error {
+	br := bufio.NewReader(r)
+
+	var off int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var rev sessionup.Revocation
+		if json.Unmarshal(line, &rev) != nil || rev.Seq == 0 {
+			return nil
+		}
+
+		off += int64(len(line))
+		if !fn(rev, off) {
+			return nil
+		}
+	}
+}
+
+// writeSync writes the data to the file, syncs and closes it.
+func writeSync(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
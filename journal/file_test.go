@@ -0,0 +1,159 @@
+package journal
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+var _ sessionup.Journal = &File{}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	return dir
+}
+
+func mustAppend(t *testing.T, j *File, rr ...sessionup.Revocation) {
+	t.Helper()
+	for _, r := range rr {
+		if err := j.AppendRevocation(context.Background(), r); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+	}
+}
+
+func seqs(rr []sessionup.Revocation) []uint64 {
+	ss := make([]uint64, len(rr))
+	for i, r := range rr {
+		ss[i] = r.Seq
+	}
+	return ss
+}
+
+func TestFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	path := filepath.Join(dir, "journal")
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	rr, err := j.ReadRevocations(ctx, 0, 0)
+	if err != nil || len(rr) != 0 {
+		t.Errorf("want nil/0, got %v/%d", err, len(rr))
+	}
+
+	mustAppend(t, j,
+		sessionup.Revocation{ID: "1"},
+		sessionup.Revocation{UserKey: "key"},
+		sessionup.Revocation{OrgKey: "org"},
+	)
+
+	rr, err = j.ReadRevocations(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(rr) != 1 || rr[0].Seq != 2 || rr[0].UserKey != "key" {
+		t.Errorf("want revocation %d, got %v", 2, rr)
+	}
+
+	if err = j.Close(); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	// simulate a crash during a write.
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"seq":4,"id":`)
+	f.Close()
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	defer j.Close()
+
+	mustAppend(t, j, sessionup.Revocation{ID: "4"})
+
+	rr, err = j.ReadRevocations(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(rr) != 4 || rr[3].Seq != 4 || rr[3].ID != "4" {
+		t.Errorf("want %d revocations, got %v", 4, rr)
+	}
+}
+
+func TestFileCompact(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	path := filepath.Join(dir, "journal")
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	defer j.Close()
+
+	if err = j.Compact(time.Now()); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	mustAppend(t, j,
+		sessionup.Revocation{ID: "1", At: old},
+		sessionup.Revocation{ID: "2", At: old},
+		sessionup.Revocation{ID: "3", At: time.Now()},
+	)
+
+	if err = j.Compact(time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, err = j.ReadRevocations(ctx, 1, 0); err != sessionup.ErrCompacted {
+		t.Errorf("want %v, got %v", sessionup.ErrCompacted, err)
+	}
+
+	rr, err := j.ReadRevocations(ctx, 2, 0)
+	if err != nil || len(rr) != 1 || rr[0].ID != "3" {
+		t.Errorf("want revocation %d, got %v/%v", 3, rr, err)
+	}
+
+	if err = j.Compact(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	mustAppend(t, j, sessionup.Revocation{ID: "4"})
+	j.Close()
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	rr, err = j.ReadRevocations(ctx, 2, 0)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if ss := seqs(rr); len(ss) != 2 || ss[0] != 3 || ss[1] != 4 {
+		t.Errorf("want %v, got %v", []uint64{3, 4}, ss)
+	}
+}
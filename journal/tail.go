@@ -0,0 +1,124 @@
+package journal
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+// batchSize is the maximum number of revocations read at once by Tail.
+const batchSize = 100
+
+// Checkpoint stores the sequence number of the last revocation
+// processed by a journal reader.
+type Checkpoint interface {
+	// Load should return the stored sequence number or 0, if it is
+	// not stored yet.
+	Load() (uint64, error)
+
+	// Save should store the provided sequence number.
+	Save(seq uint64) error
+}
+
+// FileCheckpoint is a Checkpoint implementation that stores the
+// sequence number in the file at the specified path.
+type FileCheckpoint string
+
+// Load implements Checkpoint interface's Load method.
+func (c FileCheckpoint) Load() (uint64, error) {
+	data, err := ioutil.ReadFile(string(c))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// Save implements Checkpoint interface's Save method. The file is
+// replaced atomically.
+func (c FileCheckpoint) Save(seq uint64) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(string(c)), filepath.Base(string(c))+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if err = writeSync(tmp, []byte(strconv.FormatUint(seq, 10)+"\n")); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), string(c)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Tail reads the revocations that follow the checkpoint from the
+// journal and passes them to the provided function, saving the
+// checkpoint after each batch. Once all revocations are processed, the
+// journal is polled again after the provided interval.
+// Tail returns when the context is done, the function returns an error
+// or the journal fails. sessionup.ErrCompacted is returned when the
+// revocations following the checkpoint were compacted; readers should
+// then resynchronize their state and save a new checkpoint.
+func Tail(ctx context.Context, j sessionup.Journal, cp Checkpoint, interval time.Duration, fn func(r sessionup.Revocation) error) error {
+	seq, err := cp.Load()
+	if err != nil {
+		return err
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rr, err := j.ReadRevocations(ctx, seq, batchSize)
+		if err != nil {
+			return err
+		}
+
+		var ferr error
+		for _, r := range rr {
+			if ferr = fn(r); ferr != nil {
+				break
+			}
+
+			seq = r.Seq
+		}
+
+		if len(rr) > 0 {
+			if err = cp.Save(seq); err != nil {
+				return err
+			}
+		}
+
+		if ferr != nil {
+			return ferr
+		}
+
+		if len(rr) == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
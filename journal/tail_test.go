@@ -0,0 +1,112 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+func TestFileCheckpoint(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	cp := FileCheckpoint(filepath.Join(dir, "checkpoint"))
+	seq, err := cp.Load()
+	if err != nil || seq != 0 {
+		t.Errorf("want nil/0, got %v/%d", err, seq)
+	}
+
+	if err = cp.Save(42); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	seq, err = cp.Load()
+	if err != nil || seq != 42 {
+		t.Errorf("want nil/42, got %v/%d", err, seq)
+	}
+}
+
+func TestTail(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	j, err := Open(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	defer j.Close()
+
+	for i := 0; i < batchSize+5; i++ {
+		mustAppend(t, j, sessionup.Revocation{ID: "id"})
+	}
+
+	cp := FileCheckpoint(filepath.Join(dir, "checkpoint"))
+	cp.Save(2)
+
+	errStop := errors.New("stop")
+	var got []uint64
+	err = Tail(context.Background(), j, cp, time.Millisecond, func(r sessionup.Revocation) error {
+		if r.Seq == batchSize+4 {
+			return errStop
+		}
+
+		got = append(got, r.Seq)
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("want %v, got %v", errStop, err)
+	}
+
+	if len(got) != batchSize+1 || got[0] != 3 {
+		t.Errorf("want %d revocations starting at %d, got %v", batchSize+1, 3, got)
+	}
+
+	if seq, _ := cp.Load(); seq != batchSize+3 {
+		t.Errorf("want %d, got %d", batchSize+3, seq)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got = nil
+	err = Tail(ctx, j, cp, time.Millisecond, func(r sessionup.Revocation) error {
+		got = append(got, r.Seq)
+		cancel()
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+
+	if len(got) != 2 {
+		t.Errorf("want %d, got %d", 2, len(got))
+	}
+
+	if seq, _ := cp.Load(); seq != batchSize+5 {
+		t.Errorf("want %d, got %d", batchSize+5, seq)
+	}
+}
+
+func TestTailCompacted(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	j, err := Open(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	defer j.Close()
+
+	mustAppend(t, j, sessionup.Revocation{ID: "1"}, sessionup.Revocation{ID: "2"})
+	j.Compact(time.Now().Add(time.Minute))
+
+	err = Tail(context.Background(), j, FileCheckpoint(filepath.Join(dir, "checkpoint")), time.Millisecond, func(_ sessionup.Revocation) error {
+		return nil
+	})
+	if err != sessionup.ErrCompacted {
+		t.Errorf("want %v, got %v", sessionup.ErrCompacted, err)
+	}
+}
@@ -0,0 +1,157 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// journalMock is a Journal implementation that keeps revocations in
+// memory.
+type journalMock struct {
+	rr  []Revocation
+	err error
+}
+
+func (j *journalMock) AppendRevocation(_ context.Context, r Revocation) error {
+	if j.err != nil {
+		return j.err
+	}
+
+	r.Seq = uint64(len(j.rr) + 1)
+	j.rr = append(j.rr, r)
+	return nil
+}
+
+func (j *journalMock) ReadRevocations(_ context.Context, after uint64, _ int) ([]Revocation, error) {
+	if after >= uint64(len(j.rr)) {
+		return nil, nil
+	}
+
+	return append([]Revocation(nil), j.rr[after:]...), nil
+}
+
+// journalStoreMock is a Store with Journal capability, backed by
+// StoreMock.
+type journalStoreMock struct {
+	*StoreMock
+	journalMock
+}
+
+func TestRevocationJournal(t *testing.T) {
+	m := Manager{}
+	j := &journalMock{}
+	RevocationJournal(j)(&m)
+	if m.journal != j {
+		t.Errorf("want %v, got %v", j, m.journal)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	store := &StoreMock{
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}
+
+	j := &journalMock{}
+	m := NewManager(store, RevocationJournal(j))
+	ctx := NewContext(context.Background(), Session{ID: "id", UserKey: "key"})
+
+	if err := m.RevokeByID(ctx, "1"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err := m.RevokeOther(ctx); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err := m.RevokeByUserKey(ctx, "key2"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	exp := []Revocation{
+		{Seq: 1, ID: "1"},
+		{Seq: 2, UserKey: "key", ExceptID: "id"},
+		{Seq: 3, UserKey: "key2"},
+	}
+
+	for i := range j.rr {
+		if j.rr[i].At.IsZero() {
+			t.Errorf("want non-zero time, got %v", j.rr[i].At)
+		}
+		j.rr[i].At = exp[i].At
+	}
+
+	if !reflect.DeepEqual(exp, j.rr) {
+		t.Errorf("want %v, got %v", exp, j.rr)
+	}
+
+	j.err = errors.New("error")
+	if err := m.RevokeByID(ctx, "1"); err != j.err {
+		t.Errorf("want %v, got %v", j.err, err)
+	}
+}
+
+func TestRecordStore(t *testing.T) {
+	store := &journalStoreMock{
+		StoreMock: &StoreMock{
+			DeleteByIDFunc: func(_ context.Context, _ string) error {
+				return nil
+			},
+		},
+	}
+
+	m := NewManager(store)
+	if err := m.RevokeByID(context.Background(), "1"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(store.rr) != 1 || store.rr[0].ID != "1" {
+		t.Errorf("want revocation of %q, got %v", "1", store.rr)
+	}
+
+	// journal capability of the wrapped store is not required.
+	m = NewManager(store.StoreMock, KeyPrefix("a:"))
+	if err := m.RevokeByID(context.Background(), "1"); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
+
+func TestPrefixStoreJournal(t *testing.T) {
+	ctx := context.Background()
+	store := &journalStoreMock{StoreMock: &StoreMock{}}
+	store.AppendRevocation(ctx, Revocation{UserKey: "b:key"})
+
+	ps := prefixStore{Store: store, prefix: "a:"}
+	if err := ps.AppendRevocation(ctx, Revocation{UserKey: "key", ExceptID: "id"}); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if store.rr[1].UserKey != "a:key" || store.rr[1].ExceptID != "a:id" {
+		t.Errorf("want prefixed revocation, got %v", store.rr[1])
+	}
+
+	rr, err := ps.ReadRevocations(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	exp := []Revocation{{Seq: 1}, {Seq: 2, UserKey: "key", ExceptID: "id"}}
+	if !reflect.DeepEqual(exp, rr) {
+		t.Errorf("want %v, got %v", exp, rr)
+	}
+
+	ps = prefixStore{Store: &StoreMock{}, prefix: "a:"}
+	if err = ps.AppendRevocation(ctx, Revocation{}); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+
+	if _, err = ps.ReadRevocations(ctx, 0, 0); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+}
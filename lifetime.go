@@ -0,0 +1,56 @@
+package sessionup
+
+import "time"
+
+// CookieTTL sets the lifetime of the session cookies independently of
+// the lifetime of the sessions in the store (see ExpiresIn and
+// StoreTTL). Zero produces cookies without the 'Expires' attribute,
+// which are deleted when the browser is closed, while the session
+// itself is kept in the store for its full lifetime. Cookies never
+// outlive their sessions and, since the cookie expiration time no
+// longer follows the session's, RepairSkew has no effect on sessions
+// with positive cookie lifetimes.
+// By default it is not set and the cookies expire together with their
+// sessions.
+func CookieTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		m.lifetime.cookie = d
+		m.lifetime.cookieSet = true
+	}
+}
+
+// StoreTTL sets the lifetime of the sessions in the store, overriding
+// the value of ExpiresIn and TTLResolver, which then only determine
+// whether the cookies are persistent (unless CookieTTL is set).
+// By default it is not set.
+func StoreTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		m.lifetime.store = d
+	}
+}
+
+// storeTTL determines the lifetime of the user's new sessions in the
+// store. Zero produces temporary sessions.
+func (m *Manager) storeTTL(key string) time.Duration {
+	if m.lifetime.store > 0 {
+		return m.lifetime.store
+	}
+
+	return m.ttl(key)
+}
+
+// sessionCookieExpiry determines the cookie expiration time of the
+// user's session that expires at the provided time. Zero time is
+// returned for cookies that should be deleted when the browser is
+// closed.
+func (m *Manager) sessionCookieExpiry(key string, exp time.Time) time.Time {
+	if m.lifetime.cookieSet && m.lifetime.cookie <= 0 || !m.lifetime.cookieSet && m.ttl(key) == 0 {
+		return time.Time{}
+	}
+
+	if c := time.Now().Add(m.lifetime.cookie); m.lifetime.cookie > 0 && c.Before(exp) {
+		exp = c
+	}
+
+	return m.cookieExpiry(exp)
+}
@@ -0,0 +1,87 @@
+package sessionup
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLifetime(t *testing.T) {
+	around := func(t1, t2 time.Time) bool {
+		d := t1.Sub(t2)
+		return d < time.Second*2 && d > -time.Second*2
+	}
+
+	cc := map[string]struct {
+		Opts   []Option
+		Store  time.Duration
+		Cookie time.Duration
+	}{
+		"Default temporary session": {
+			Store: time.Hour * 24,
+		},
+		"Default persistent session": {
+			Opts:   []Option{ExpiresIn(time.Hour)},
+			Store:  time.Hour,
+			Cookie: time.Hour,
+		},
+		"Browser session cookie": {
+			Opts:  []Option{StoreTTL(time.Hour * 24), CookieTTL(0), ExpiresIn(time.Hour)},
+			Store: time.Hour * 24,
+		},
+		"Store TTL only": {
+			Opts:  []Option{StoreTTL(time.Hour * 2)},
+			Store: time.Hour * 2,
+		},
+		"Shorter cookie": {
+			Opts:   []Option{ExpiresIn(time.Hour), CookieTTL(time.Minute)},
+			Store:  time.Hour,
+			Cookie: time.Minute,
+		},
+		"Longer cookie": {
+			Opts:   []Option{StoreTTL(time.Hour), CookieTTL(time.Hour * 2)},
+			Store:  time.Hour,
+			Cookie: time.Hour,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			store := mapStore()
+			m := NewManager(store, c.Opts...)
+			rec := httptest.NewRecorder()
+			if err := m.Init(rec, httptest.NewRequest("GET", "/", nil), "key"); err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			ck := rec.Result().Cookies()[0]
+			s, _, _ := store.FetchByID(context.Background(), ck.Value)
+			now := time.Now()
+
+			if !around(s.ExpiresAt, now.Add(c.Store)) {
+				t.Errorf("want %v, got %v", now.Add(c.Store), s.ExpiresAt)
+			}
+
+			if c.Cookie == 0 {
+				if !ck.Expires.IsZero() || !s.CookieExpiresAt.IsZero() {
+					t.Errorf("want session cookie, got %v and %v", ck.Expires, s.CookieExpiresAt)
+				}
+
+				return
+			}
+
+			if !around(ck.Expires, now.Add(c.Cookie)) || !around(s.CookieExpiresAt, now.Add(c.Cookie)) {
+				t.Errorf("want %v, got %v and %v", now.Add(c.Cookie), ck.Expires, s.CookieExpiresAt)
+			}
+
+			if m.hasSkew(s) {
+				t.Error("want false, got true")
+			}
+		})
+	}
+}
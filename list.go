@@ -0,0 +1,96 @@
+package sessionup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ListSessionsHandler produces a handler that responds with the JSON
+// list of all sessions of the same user key as session stored in the
+// request's context currently has (see FetchAll).
+// Responses carry an ETag and conditional requests with a matching
+// 'If-None-Match' header are answered with 304 Not Modified. If the
+// store implements ChangeCounterStore interface, such requests are
+// answered without fetching the sessions.
+// The handler must be used inside Auth middleware.
+func (m *Manager) ListSessionsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		s, ok := FromContext(ctx)
+		if !ok {
+			m.reject(ErrUnauthorized).ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private, no-cache")
+
+		var etag string
+		if cs, ok := m.store.(ChangeCounterStore); ok {
+			n, err := cs.ChangeCounter(ctx, s.UserKey)
+			switch {
+			case err == nil:
+				// the current session's ID affects the response, so it
+				// must affect the tag as well.
+				etag = fmt.Sprintf(`W/"%x-%x"`, n, sha256.Sum256([]byte(s.ID)))
+			case !errors.Is(err, ErrNotSupported):
+				m.reject(err).ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if etag != "" && etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		ss, err := m.FetchAll(ctx)
+		if err != nil {
+			m.reject(err).ServeHTTP(w, r)
+			return
+		}
+
+		if ss == nil {
+			ss = []Session{}
+		}
+
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(ss); err != nil {
+			m.reject(err).ServeHTTP(w, r)
+			return
+		}
+
+		if etag == "" {
+			etag = fmt.Sprintf(`"%x"`, sha256.Sum256(buf.Bytes()))
+		}
+
+		w.Header().Set("ETag", etag)
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	})
+}
+
+// etagMatch checks whether the 'If-None-Match' header value matches
+// the provided entity tag or not, using the weak comparison.
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
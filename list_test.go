@@ -0,0 +1,170 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// counterStoreMock is a Store with ChangeCounterStore capability,
+// backed by StoreMock.
+type counterStoreMock struct {
+	*StoreMock
+	counter uint64
+	err     error
+}
+
+func (c *counterStoreMock) ChangeCounter(_ context.Context, _ string) (uint64, error) {
+	return c.counter, c.err
+}
+
+func TestListSessionsHandler(t *testing.T) {
+	errTest := errors.New("error")
+	ms := func() *StoreMock {
+		return &StoreMock{
+			FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+				return []Session{{ID: "id"}, {ID: "id1"}}, nil
+			},
+		}
+	}
+
+	fetchErr := ms()
+	fetchErr.FetchByUserKeyFunc = func(_ context.Context, _ string) ([]Session, error) {
+		return nil, errTest
+	}
+
+	cc := map[string]struct {
+		Store       Store
+		NoSession   bool
+		IfNoneMatch func(etag string) string
+		Code        int
+		Fetched     int
+	}{
+		"No context session": {
+			Store:     ms(),
+			NoSession: true,
+			Code:      http.StatusUnauthorized,
+		},
+		"Error returned by store.FetchByUserKey": {
+			Store:   fetchErr,
+			Code:    http.StatusUnauthorized,
+			Fetched: 1,
+		},
+		"Error returned by store.ChangeCounter": {
+			Store: &counterStoreMock{StoreMock: ms(), err: errTest},
+			Code:  http.StatusUnauthorized,
+		},
+		"Full response": {
+			Store:   ms(),
+			Code:    http.StatusOK,
+			Fetched: 1,
+		},
+		"Not modified": {
+			Store:       ms(),
+			IfNoneMatch: func(etag string) string { return `"other", ` + etag },
+			Code:        http.StatusNotModified,
+			Fetched:     1,
+		},
+		"Modified": {
+			Store:       ms(),
+			IfNoneMatch: func(_ string) string { return `"other"` },
+			Code:        http.StatusOK,
+			Fetched:     1,
+		},
+		"Counter not supported by wrapped store": {
+			Store:       &counterStoreMock{StoreMock: ms(), err: ErrNotSupported},
+			IfNoneMatch: func(etag string) string { return etag },
+			Code:        http.StatusNotModified,
+			Fetched:     1,
+		},
+		"Not modified according to counter": {
+			Store:       &counterStoreMock{StoreMock: ms(), counter: 5},
+			IfNoneMatch: func(etag string) string { return etag },
+			Code:        http.StatusNotModified,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := NewManager(c.Store)
+			serve := func(inm string) *httptest.ResponseRecorder {
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "http://example.com/sessions", nil)
+				if !c.NoSession {
+					req = req.WithContext(NewContext(req.Context(), Session{ID: "id", UserKey: "key"}))
+				}
+
+				if inm != "" {
+					req.Header.Set("If-None-Match", inm)
+				}
+
+				m.ListSessionsHandler().ServeHTTP(rec, req)
+				return rec
+			}
+
+			var fetched int
+			rec := serve("")
+			if c.IfNoneMatch != nil {
+				fetched = fetchCalls(c.Store)
+				rec = serve(c.IfNoneMatch(rec.Header().Get("ETag")))
+			}
+
+			if rec.Code != c.Code {
+				t.Errorf("want %d, got %d", c.Code, rec.Code)
+			}
+
+			if n := fetchCalls(c.Store) - fetched; n != c.Fetched {
+				t.Errorf("want %d, got %d", c.Fetched, n)
+			}
+
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var ss []Session
+			if err := json.NewDecoder(rec.Body).Decode(&ss); err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if len(ss) != 2 || !ss[0].Current || ss[1].Current {
+				t.Errorf("want 2 sessions with the first one current, got %v", ss)
+			}
+		})
+	}
+}
+
+// fetchCalls counts the FetchByUserKey calls of the mocked store.
+func fetchCalls(s Store) int {
+	switch st := s.(type) {
+	case *StoreMock:
+		return len(st.FetchByUserKeyCalls())
+	case *counterStoreMock:
+		return len(st.FetchByUserKeyCalls())
+	}
+
+	return 0
+}
+
+func TestETagMatch(t *testing.T) {
+	cc := map[string]bool{
+		``:                false,
+		`*`:               true,
+		`"a"`:             true,
+		`W/"a"`:           true,
+		`"b", W/"a"`:      true,
+		`"b","c"`:         false,
+		`"a-suffix"`:      false,
+		`W/"b" , "c" , *`: true,
+	}
+
+	for h, exp := range cc {
+		if res := etagMatch(h, `W/"a"`); res != exp {
+			t.Errorf("%q: want %t, got %t", h, exp, res)
+		}
+	}
+}
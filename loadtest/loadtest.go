@@ -0,0 +1,391 @@
+// Package loadtest provides helpers that mint realistic sessions in bulk
+// and drive synthetic authenticated traffic through a sessionup.Manager,
+// so that the capacity of the session layer can be planned without
+// running the full application.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+const (
+	defaultClients  = 10
+	defaultRequests = 1000
+	defaultTarget   = "http://example.com/"
+
+	idLen = 40
+
+	// maxAge specifies how long ago the generated sessions may have
+	// been created.
+	maxAge = time.Hour * 24
+
+	// minTTL and maxTTL specify the bounds of the generated sessions'
+	// remaining lifetime.
+	minTTL = time.Hour
+	maxTTL = time.Hour * 24 * 7
+)
+
+// agent holds a User-Agent header and the data sessionup extracts
+// from it.
+type agent struct {
+	header  string
+	os      string
+	browser string
+	version string
+	device  string
+}
+
+// agents holds a sample of widespread browsers and devices.
+var agents = []agent{
+	{
+		header:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36",
+		os:      "Windows",
+		browser: "Chrome",
+		version: "118",
+		device:  sessionup.DeviceDesktop,
+	},
+	{
+		header:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:119.0) Gecko/20100101 Firefox/119.0",
+		os:      "Windows",
+		browser: "Firefox",
+		version: "119",
+		device:  sessionup.DeviceDesktop,
+	},
+	{
+		header:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36 Edg/118.0.2088.76",
+		os:      "Windows",
+		browser: "Edge",
+		version: "118",
+		device:  sessionup.DeviceDesktop,
+	},
+	{
+		header:  "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+		os:      "macOS",
+		browser: "Safari",
+		version: "17",
+		device:  sessionup.DeviceDesktop,
+	},
+	{
+		header:  "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36",
+		os:      "Linux",
+		browser: "Chrome",
+		version: "117",
+		device:  sessionup.DeviceDesktop,
+	},
+	{
+		header:  "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+		os:      "iOS",
+		browser: "Safari",
+		version: "17",
+		device:  sessionup.DeviceMobile,
+	},
+	{
+		header:  "Mozilla/5.0 (Linux; Android 13; SM-S908B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Mobile Safari/537.36",
+		os:      "Android",
+		browser: "Chrome",
+		version: "118",
+		device:  sessionup.DeviceMobile,
+	},
+	{
+		header:  "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		browser: "Googlebot",
+		version: "2",
+		device:  sessionup.DeviceBot,
+	},
+}
+
+// Generator produces random, but realistic session and request data.
+// It is safe for concurrent use.
+type Generator struct {
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	genID func() string
+}
+
+// NewGenerator returns a fresh instance of Generator. The same seed
+// produces the same sequence of data.
+func NewGenerator(seed int64) *Generator {
+	rnd := rand.New(rand.NewSource(seed))
+
+	return &Generator{
+		rnd:   rnd,
+		genID: sessionup.NewGenID(rnd, sessionup.IDCharset, idLen),
+	}
+}
+
+// IP produces a random public IP address; roughly a fifth of them are
+// IPv6 addresses.
+func (g *Generator) IP() net.IP {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.ip()
+}
+
+// UserAgent produces a random User-Agent header of a widespread browser
+// or device.
+func (g *Generator) UserAgent() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.agent().header
+}
+
+// Session produces a session of the provided user key with a random ID,
+// IP address, agent data and timestamps: it is created some time during
+// the last day and expires some time during the next week.
+func (g *Generator) Session(key string) sessionup.Session {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	a := g.agent()
+
+	s := sessionup.Session{
+		CreatedAt: now.Add(-time.Duration(g.rnd.Int63n(int64(maxAge)))),
+		ExpiresAt: now.Add(minTTL + time.Duration(g.rnd.Int63n(int64(maxTTL-minTTL)))),
+		ID:        g.genID(),
+		UserKey:   key,
+		IP:        g.ip(),
+	}
+
+	s.CookieExpiresAt = s.ExpiresAt
+	s.Agent.OS = a.os
+	s.Agent.Browser = a.browser
+	s.Agent.Version = a.version
+	s.Agent.Device = a.device
+
+	return s
+}
+
+// Request produces a GET request to the provided target that originates
+// from a random IP address and carries a random User-Agent header.
+func (g *Generator) Request(target string) *http.Request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r := httptest.NewRequest("GET", target, nil)
+	r.RemoteAddr = net.JoinHostPort(g.ip().String(), strconv.Itoa(1024+g.rnd.Intn(64512)))
+	r.Header.Set("User-Agent", g.agent().header)
+
+	return r
+}
+
+// Populate creates the provided number of sessions for each of the
+// provided number of users (with keys "user-0", "user-1", etc.) directly
+// in the store. It stops at the first failure.
+func (g *Generator) Populate(ctx context.Context, s sessionup.Store, users, perUser int) error {
+	for u := 0; u < users; u++ {
+		key := "user-" + strconv.Itoa(u)
+		for i := 0; i < perUser; i++ {
+			if err := s.Create(ctx, g.Session(key)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ip produces a random public IP address. The mutex must be held.
+func (g *Generator) ip() net.IP {
+	if g.rnd.Intn(5) == 0 {
+		ip := make(net.IP, net.IPv6len)
+		g.rnd.Read(ip)
+		ip[0], ip[1] = 0x20, 0x01 // 2001::/16, global unicast.
+		return ip
+	}
+
+	for {
+		ip := net.IPv4(byte(1+g.rnd.Intn(223)), byte(g.rnd.Intn(256)), byte(g.rnd.Intn(256)), byte(1+g.rnd.Intn(254)))
+		if !ip.IsLoopback() && !isPrivate(ip) {
+			return ip
+		}
+	}
+}
+
+// agent picks a random agent. The mutex must be held.
+func (g *Generator) agent() agent {
+	return agents[g.rnd.Intn(len(agents))]
+}
+
+// isPrivate checks whether the IPv4 address belongs to a private range
+// or not.
+func isPrivate(ip net.IP) bool {
+	ip = ip.To4()
+	return ip[0] == 10 ||
+		ip[0] == 172 && ip[1]&0xf0 == 16 ||
+		ip[0] == 192 && ip[1] == 168
+}
+
+// Config holds the parameters of synthetic traffic.
+type Config struct {
+	// Clients specifies the number of concurrent synthetic browsers.
+	// Each of them logs in once (with user keys "client-0",
+	// "client-1", etc.) and keeps its IP address and User-Agent header
+	// for all of its requests.
+	// Defaults to the value stored in defaultClients.
+	Clients int
+
+	// Requests specifies the total number of authenticated requests
+	// sent by all clients.
+	// Defaults to the value stored in defaultRequests.
+	Requests int
+
+	// Target specifies the URL of the requests.
+	// Defaults to the value stored in defaultTarget.
+	Target string
+
+	// Handler specifies the handler wrapped by the manager's Auth
+	// middleware.
+	// Defaults to a handler that responds with 200 OK.
+	Handler http.Handler
+}
+
+// Report holds the results of synthetic traffic.
+type Report struct {
+	// Requests specifies the number of sent authenticated requests.
+	Requests int
+
+	// Failures specifies the number of requests that did not succeed
+	// (e.g. were rejected by the Auth middleware).
+	Failures int
+
+	// Duration specifies the total time spent sending the requests.
+	Duration time.Duration
+
+	// P50, P95, P99 and Max specify the percentiles of the time spent
+	// in the Auth middleware and the wrapped handler.
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// Throughput returns the number of requests handled per second.
+func (r Report) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// Drive logs in the synthetic clients with the manager's Init method and
+// then sends authenticated requests through its Auth middleware until
+// the configured number of requests is reached or the context is
+// cancelled. Responses with status codes other than 2xx are counted as
+// failures. An error is returned if any of the clients fails to log in.
+func Drive(ctx context.Context, m *sessionup.Manager, g *Generator, cfg Config) (Report, error) {
+	if cfg.Clients <= 0 {
+		cfg.Clients = defaultClients
+	}
+
+	if cfg.Requests <= 0 {
+		cfg.Requests = defaultRequests
+	}
+
+	if cfg.Target == "" {
+		cfg.Target = defaultTarget
+	}
+
+	if cfg.Handler == nil {
+		cfg.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	}
+
+	clients := make([]*http.Request, cfg.Clients)
+	for i := range clients {
+		r := g.Request(cfg.Target).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		if err := m.Init(rec, r, "client-"+strconv.Itoa(i)); err != nil {
+			return Report{}, err
+		}
+
+		cc := rec.Result().Cookies()
+		if len(cc) == 0 {
+			return Report{}, errors.New("loadtest: no cookies set by Init")
+		}
+
+		for _, c := range cc {
+			r.AddCookie(c)
+		}
+
+		clients[i] = r
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sent     int64
+		failures int64
+		lats     = make([]time.Duration, 0, cfg.Requests)
+	)
+
+	h := m.Auth(cfg.Handler)
+	start := time.Now()
+
+	for _, r := range clients {
+		wg.Add(1)
+		go func(r *http.Request) {
+			defer wg.Done()
+
+			var ll []time.Duration
+			for ctx.Err() == nil && atomic.AddInt64(&sent, 1) <= int64(cfg.Requests) {
+				rec := httptest.NewRecorder()
+				t := time.Now()
+				h.ServeHTTP(rec, r.Clone(ctx))
+				ll = append(ll, time.Since(t))
+
+				if rec.Code < 200 || rec.Code > 299 {
+					atomic.AddInt64(&failures, 1)
+				}
+			}
+
+			mu.Lock()
+			lats = append(lats, ll...)
+			mu.Unlock()
+		}(r)
+	}
+
+	wg.Wait()
+
+	rep := Report{
+		Requests: len(lats),
+		Failures: int(failures),
+		Duration: time.Since(start),
+	}
+
+	if len(lats) == 0 {
+		return rep, nil
+	}
+
+	sort.Slice(lats, func(i, j int) bool { return lats[i] < lats[j] })
+	rep.P50 = percentile(lats, 50)
+	rep.P95 = percentile(lats, 95)
+	rep.P99 = percentile(lats, 99)
+	rep.Max = lats[len(lats)-1]
+
+	return rep, nil
+}
+
+// percentile returns the provided percentile of the sorted durations.
+func percentile(dd []time.Duration, p int) time.Duration {
+	i := (len(dd)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return dd[i]
+}
@@ -0,0 +1,186 @@
+package loadtest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/memstore"
+)
+
+func TestGenerator(t *testing.T) {
+	g1, g2 := NewGenerator(1), NewGenerator(1)
+	s1, s2 := g1.Session("key"), g2.Session("key")
+	if s1.ID != s2.ID || !s1.IP.Equal(s2.IP) || s1.Agent != s2.Agent {
+		t.Errorf("want equal sessions, got %v and %v", s1, s2)
+	}
+
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		s := g1.Session("key")
+		if err := sessionup.ValidateSession(s); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		if len(s.ID) != idLen {
+			t.Errorf("want %d, got %d", idLen, len(s.ID))
+		}
+
+		if s.CreatedAt.After(now) || s.CreatedAt.Before(now.Add(-maxAge)) {
+			t.Errorf("want within last %v, got %v", maxAge, s.CreatedAt)
+		}
+
+		if s.ExpiresAt.Before(now.Add(minTTL)) || s.ExpiresAt.After(time.Now().Add(maxTTL)) {
+			t.Errorf("want between %v and %v, got %v", minTTL, maxTTL, s.ExpiresAt)
+		}
+
+		if s.Agent.Browser == "" || s.Agent.Device == "" {
+			t.Errorf("want agent data, got %v", s.Agent)
+		}
+
+		if ip := g1.IP(); ip.IsLoopback() || ip.To4() != nil && isPrivate(ip) {
+			t.Errorf("want public IP, got %v", ip)
+		}
+
+		if g1.UserAgent() == "" {
+			t.Error("want non-empty User-Agent")
+		}
+	}
+
+	r := g1.Request("http://example.com/a")
+	if r.URL.Path != "/a" || r.Header.Get("User-Agent") == "" {
+		t.Errorf("want request data, got %v", r)
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err != nil || net.ParseIP(host) == nil {
+		t.Errorf("want valid remote address, got %q", r.RemoteAddr)
+	}
+}
+
+func TestIsPrivate(t *testing.T) {
+	cc := map[string]struct {
+		IP      string
+		Private bool
+	}{
+		"10.0.0.0/8": {
+			IP:      "10.1.2.3",
+			Private: true,
+		},
+		"172.16.0.0/12": {
+			IP:      "172.31.2.3",
+			Private: true,
+		},
+		"192.168.0.0/16": {
+			IP:      "192.168.2.3",
+			Private: true,
+		},
+		"Public 172": {
+			IP: "172.32.2.3",
+		},
+		"Public": {
+			IP: "8.8.8.8",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			if p := isPrivate(net.ParseIP(c.IP)); p != c.Private {
+				t.Errorf("want %t, got %t", c.Private, p)
+			}
+		})
+	}
+}
+
+func TestPopulate(t *testing.T) {
+	ctx := context.Background()
+	st := memstore.New(0)
+	if err := NewGenerator(1).Populate(ctx, st, 3, 4); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	for _, key := range []string{"user-0", "user-1", "user-2"} {
+		ss, err := st.FetchByUserKey(ctx, key)
+		if err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		if len(ss) != 4 {
+			t.Errorf("want %d, got %d", 4, len(ss))
+		}
+	}
+}
+
+func TestDrive(t *testing.T) {
+	ctx := context.Background()
+	m := sessionup.NewManager(memstore.New(0), sessionup.Validate(true))
+
+	rep, err := Drive(ctx, m, NewGenerator(1), Config{Clients: 4, Requests: 200})
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if rep.Requests != 200 || rep.Failures != 0 {
+		t.Errorf("want %d and %d, got %d and %d", 200, 0, rep.Requests, rep.Failures)
+	}
+
+	if rep.P50 > rep.P95 || rep.P95 > rep.P99 || rep.P99 > rep.Max || rep.Max == 0 {
+		t.Errorf("want ordered percentiles, got %v", rep)
+	}
+
+	if rep.Throughput() <= 0 {
+		t.Errorf("want positive throughput, got %v", rep.Throughput())
+	}
+
+	rep, err = Drive(ctx, m, NewGenerator(1), Config{
+		Requests: 10,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}),
+	})
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if rep.Requests != 10 || rep.Failures != 10 {
+		t.Errorf("want %d and %d, got %d and %d", 10, 10, rep.Requests, rep.Failures)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	rep, err = Drive(cctx, m, NewGenerator(1), Config{})
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if !reflect.DeepEqual(rep, Report{Duration: rep.Duration}) {
+		t.Errorf("want empty report, got %v", rep)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	dd := make([]time.Duration, 100)
+	for i := range dd {
+		dd[i] = time.Duration(i + 1)
+	}
+
+	if p := percentile(dd, 50); p != 50 {
+		t.Errorf("want %d, got %d", 50, p)
+	}
+
+	if p := percentile(dd, 99); p != 99 {
+		t.Errorf("want %d, got %d", 99, p)
+	}
+
+	if p := percentile(dd[:1], 50); p != 1 {
+		t.Errorf("want %d, got %d", 1, p)
+	}
+}
@@ -0,0 +1,126 @@
+package sessionup
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Translator translates client-safe error messages (see PublicError)
+// into other languages.
+type Translator interface {
+	// Translate should return the message translated into the
+	// provided language (a BCP 47 tag, e.g. "de" or "pt-BR"). The
+	// second returned value indicates whether the translation was
+	// found or not.
+	Translate(lang, msg string) (string, bool)
+}
+
+// Catalog is a Translator backed by a message catalog: it maps
+// language tags to translations of the original (English) messages.
+// Tags are matched case-insensitively.
+type Catalog map[string]map[string]string
+
+// Translate implements Translator interface's Translate method.
+func (c Catalog) Translate(lang, msg string) (string, bool) {
+	for l, mm := range c {
+		if strings.EqualFold(l, lang) {
+			t, ok := mm[msg]
+			return t, ok
+		}
+	}
+
+	return "", false
+}
+
+// localize translates the message into the most preferred language of
+// the request that the translator supports. Preferences are taken from
+// the session's metadata under the provided key (if set) first and
+// then from the 'Accept-Language' header; each tag is also tried
+// without its region subtag. The original message and empty language
+// are returned if no translation is found.
+func localize(r *http.Request, tr Translator, metaKey, msg string) (string, string) {
+	if tr == nil {
+		return msg, ""
+	}
+
+	for _, lang := range languages(r, metaKey) {
+		if t, ok := tr.Translate(lang, msg); ok {
+			return t, lang
+		}
+
+		if i := strings.IndexByte(lang, '-'); i > 0 {
+			if t, ok := tr.Translate(lang[:i], msg); ok {
+				return t, lang[:i]
+			}
+		}
+	}
+
+	return msg, ""
+}
+
+// languages returns the language tags preferred by the request, most
+// preferred first.
+func languages(r *http.Request, metaKey string) []string {
+	var ll []string
+	if metaKey != "" {
+		if l := requestSession(r).Meta[metaKey]; l != "" {
+			ll = append(ll, l)
+		}
+	}
+
+	type pref struct {
+		lang string
+		q    float64
+	}
+
+	var pp []pref
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		ss := strings.Split(part, ";")
+		lang := strings.TrimSpace(ss[0])
+		if lang == "" || lang == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, s := range ss[1:] {
+			s = strings.TrimSpace(s)
+			if strings.HasPrefix(s, "q=") {
+				if v, err := strconv.ParseFloat(s[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > 0 {
+			pp = append(pp, pref{lang: lang, q: q})
+		}
+	}
+
+	sort.SliceStable(pp, func(i, j int) bool {
+		return pp[i].q > pp[j].q
+	})
+
+	for _, p := range pp {
+		ll = append(ll, p.lang)
+	}
+
+	return ll
+}
+
+// requestSession returns the session attached to the request's
+// context, either as a valid session or as the result of the
+// authentication performed by a requirement middleware (e.g. when the
+// session is valid, but not fresh enough).
+func requestSession(r *http.Request) Session {
+	if s, ok := FromContext(r.Context()); ok {
+		return s
+	}
+
+	if res, ok := r.Context().Value(authResultKey).(*authResult); ok {
+		return res.s
+	}
+
+	return Session{}
+}
@@ -0,0 +1,176 @@
+package sessionup
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCatalogTranslate(t *testing.T) {
+	c := Catalog{
+		"de":    {"unauthorized": "nicht autorisiert"},
+		"pt-BR": {"unauthorized": "não autorizado"},
+	}
+
+	if msg, ok := c.Translate("DE", "unauthorized"); !ok || msg != "nicht autorisiert" {
+		t.Errorf("want %q, got %q", "nicht autorisiert", msg)
+	}
+
+	if msg, ok := c.Translate("pt-br", "unauthorized"); !ok || msg != "não autorizado" {
+		t.Errorf("want %q, got %q", "não autorizado", msg)
+	}
+
+	if _, ok := c.Translate("de", "unknown"); ok {
+		t.Error("want false, got true")
+	}
+
+	if _, ok := c.Translate("fr", "unauthorized"); ok {
+		t.Error("want false, got true")
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	c := Catalog{
+		"de": {"unauthorized": "nicht autorisiert"},
+		"lt": {"unauthorized": "neautorizuota"},
+	}
+
+	cc := map[string]struct {
+		Translator Translator
+		Accept     string
+		Locale     string
+		Msg        string
+		Lang       string
+	}{
+		"No translator": {
+			Accept: "de",
+			Msg:    "unauthorized",
+		},
+		"No preferences": {
+			Translator: c,
+			Msg:        "unauthorized",
+		},
+		"Unsupported language": {
+			Translator: c,
+			Accept:     "fr, *;q=0.5",
+			Msg:        "unauthorized",
+		},
+		"Accept-Language order": {
+			Translator: c,
+			Accept:     "fr;q=0.9, lt;q=0.5, de;q=0.8",
+			Msg:        "nicht autorisiert",
+			Lang:       "de",
+		},
+		"Rejected language": {
+			Translator: c,
+			Accept:     "de;q=0, lt;q=0.1",
+			Msg:        "neautorizuota",
+			Lang:       "lt",
+		},
+		"Region fallback": {
+			Translator: c,
+			Accept:     "de-AT",
+			Msg:        "nicht autorisiert",
+			Lang:       "de",
+		},
+		"Session locale": {
+			Translator: c,
+			Accept:     "de",
+			Locale:     "lt",
+			Msg:        "neautorizuota",
+			Lang:       "lt",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set("Accept-Language", c.Accept)
+			if c.Locale != "" {
+				s := Session{Meta: map[string]string{"locale": c.Locale}}
+				req = req.WithContext(NewContext(req.Context(), s))
+			}
+
+			msg, lang := localize(req, c.Translator, "locale", "unauthorized")
+			if msg != c.Msg {
+				t.Errorf("want %q, got %q", c.Msg, msg)
+			}
+
+			if lang != c.Lang {
+				t.Errorf("want %q, got %q", c.Lang, lang)
+			}
+		})
+	}
+}
+
+func TestRequestSession(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if s := requestSession(req); !reflect.DeepEqual(s, Session{}) {
+		t.Errorf("want empty session, got %v", s)
+	}
+
+	ctx := context.WithValue(req.Context(), authResultKey, &authResult{s: Session{ID: "1"}, err: ErrNotFresh})
+	if s := requestSession(req.WithContext(ctx)); s.ID != "1" {
+		t.Errorf("want %q, got %q", "1", s.ID)
+	}
+
+	ctx = NewContext(ctx, Session{ID: "2"})
+	if s := requestSession(req.WithContext(ctx)); s.ID != "2" {
+		t.Errorf("want %q, got %q", "2", s.ID)
+	}
+}
+
+func TestNegotiatedRejectLocalized(t *testing.T) {
+	rej := NegotiatedReject(RejectConfig{
+		Template:   template.Must(template.New("").Parse(`<p lang="{{ .Lang }}">{{ .Error }}</p>`)),
+		CacheSize:  10,
+		Translator: Catalog{"de": {"unauthorized": "nicht autorisiert"}},
+	})
+
+	serve := func(accept, lang string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("Accept-Language", lang)
+		rej(ErrUnauthorized).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("application/json", "de")
+	if body := rec.Body.String(); body != `{"error":"nicht autorisiert"}`+"\n" {
+		t.Errorf("want %q, got %q", `{"error":"nicht autorisiert"}`+"\n", body)
+	}
+
+	if l := rec.Header().Get("Content-Language"); l != "de" {
+		t.Errorf("want %q, got %q", "de", l)
+	}
+
+	if v := rec.Header()["Vary"]; len(v) != 3 || v[2] != "Accept-Language" {
+		t.Errorf("want Accept-Language in Vary, got %v", v)
+	}
+
+	// cached pages are not shared between languages.
+	if body := serve("text/html", "de").Body.String(); body != `<p lang="de">nicht autorisiert</p>` {
+		t.Errorf("want %q, got %q", `<p lang="de">nicht autorisiert</p>`, body)
+	}
+
+	rec = serve("text/html", "en")
+	if body := rec.Body.String(); body != `<p lang="">unauthorized</p>` {
+		t.Errorf("want %q, got %q", `<p lang="">unauthorized</p>`, body)
+	}
+
+	if l := rec.Header().Get("Content-Language"); l != "" {
+		t.Errorf("want empty, got %q", l)
+	}
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
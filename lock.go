@@ -0,0 +1,204 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLockTTL = time.Second * 30
+
+	// lockPollInterval specifies how often a held lock is retried while
+	// waiting for it.
+	lockPollInterval = time.Millisecond * 10
+)
+
+// ErrLocked is returned when another request of the same session is
+// already being handled by a route protected with RequireExclusive.
+var ErrLocked = errors.New("another request of the session is in progress")
+
+// LockStore is an optional Store capability used to hold short-lived
+// per-session locks shared by all instances of the application (see
+// RequireExclusive).
+type LockStore interface {
+	// AcquireLock should set a lock under the provided key, held by the
+	// provided owner, if it is not held already, and ensure that it is
+	// released automatically when the ttl passes.
+	// The returned value indicates whether the lock was acquired or not.
+	// Error should be returned on system errors only.
+	AcquireLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+
+	// ReleaseLock should release the lock under the provided key if it
+	// is still held by the provided owner.
+	// If the lock is not found or held by another owner, this function
+	// should be no-op and return nil.
+	// Error should be returned on system errors only.
+	ReleaseLock(ctx context.Context, key, owner string) error
+}
+
+// LockConfig holds the data needed to guard routes with per-session
+// locks.
+type LockConfig struct {
+	// TTL specifies the maximum time the lock is held, so that crashed
+	// or stuck requests do not block the session forever.
+	// Defaults to the value stored in defaultLockTTL.
+	TTL time.Duration
+
+	// Wait specifies how long the request waits for the lock held by
+	// another request before being rejected with ErrLocked.
+	// Zero rejects the request immediately.
+	Wait time.Duration
+
+	// AllMethods specifies whether requests with safe methods (GET,
+	// HEAD, OPTIONS and TRACE) should be guarded as well.
+	// By default only state-mutating requests are guarded.
+	AllMethods bool
+}
+
+// RequireExclusive produces a middleware that activates the wrapped
+// handler only if the request has a valid session (same as
+// RequireValid) and no other guarded request of the same session is
+// being handled, preventing double-submit races on checkout-style
+// endpoints. Otherwise the manager's rejection function is called with
+// ErrLocked or the authentication error.
+// Locks are held in the store if it implements the LockStore
+// interface (and does not return ErrNotSupported), otherwise they are
+// held in memory and only guard requests
+// handled by the same instance (including the manager's clones).
+func (m *Manager) RequireExclusive(cfg LockConfig) func(http.Handler) http.Handler {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultLockTTL
+	}
+
+	valid := m.RequireValid()
+
+	return func(next http.Handler) http.Handler {
+		return valid(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.noop || !cfg.AllMethods && safeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			s, _ := FromContext(r.Context())
+			release, err := m.lock(r.Context(), s.ID, cfg)
+			if err != nil {
+				m.reject(err).ServeHTTP(w, r)
+				return
+			}
+
+			defer release()
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// lock acquires the lock of the provided session ID, waiting for it if
+// needed, and returns the function that releases it.
+func (m *Manager) lock(ctx context.Context, id string, cfg LockConfig) (func(), error) {
+	owner := m.genID()
+	if owner == "" {
+		return nil, ErrGenID
+	}
+
+	memAcquire := m.locks.acquire
+	memRelease := func() {
+		m.locks.release(id, owner)
+	}
+
+	acquire, release := memAcquire, memRelease
+	if ls, ok := m.store.(LockStore); ok {
+		acquire = ls.AcquireLock
+		release = func() {
+			// the request's context may already be cancelled.
+			ls.ReleaseLock(context.Background(), id, owner)
+		}
+	}
+
+	deadline := time.Now().Add(cfg.Wait)
+	for {
+		ok, err := acquire(ctx, id, owner, cfg.TTL)
+		if errors.Is(err, ErrNotSupported) {
+			// wrapping stores (e.g. KeyPrefix) implement LockStore
+			// even if the underlying store does not.
+			acquire, release = memAcquire, memRelease
+			continue
+		}
+
+		if err = m.storeErr(err); err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return release, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, ErrLocked
+		}
+
+		t := time.NewTimer(lockPollInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ErrLocked
+		case <-t.C:
+		}
+	}
+}
+
+// locks holds the in-memory per-session locks of the manager and its
+// clones.
+type locks struct {
+	mu   sync.Mutex
+	held map[string]heldLock
+}
+
+// heldLock holds the owner and expiration time of an in-memory lock.
+type heldLock struct {
+	owner string
+	exp   time.Time
+}
+
+// acquire sets the in-memory lock if it is not held or has expired.
+func (l *locks) acquire(_ context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if h, ok := l.held[key]; ok && now.Before(h.exp) {
+		return false, nil
+	}
+
+	if l.held == nil {
+		l.held = make(map[string]heldLock)
+	}
+
+	l.held[key] = heldLock{owner: owner, exp: now.Add(ttl)}
+	return true, nil
+}
+
+// release removes the in-memory lock if it is still held by the
+// provided owner.
+func (l *locks) release(key, owner string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if h, ok := l.held[key]; ok && h.owner == owner {
+		delete(l.held, key)
+	}
+}
+
+// safeMethod checks whether the HTTP method is safe (i.e. does not
+// mutate state) or not.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
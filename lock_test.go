@@ -0,0 +1,261 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lockStoreMock struct {
+	*StoreMock
+	AcquireLockFunc func(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	ReleaseLockFunc func(ctx context.Context, key, owner string) error
+}
+
+func (l *lockStoreMock) AcquireLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	return l.AcquireLockFunc(ctx, key, owner, ttl)
+}
+
+func (l *lockStoreMock) ReleaseLock(ctx context.Context, key, owner string) error {
+	return l.ReleaseLockFunc(ctx, key, owner)
+}
+
+func TestRequireExclusive(t *testing.T) {
+	m := NewManager(mapStore())
+
+	rec := httptest.NewRecorder()
+	if err := m.Init(rec, httptest.NewRequest("GET", "http://example.com/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	c := rec.Result().Cookies()[0]
+	serve := func(h http.Handler, method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "http://example.com/", nil)
+		req.AddCookie(c)
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	blocking := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+	})
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	h := m.RequireExclusive(LockConfig{})(blocking)
+	other := m.Clone().RequireExclusive(LockConfig{})(noop)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve(h, "POST")
+	}()
+	<-entered
+
+	// requests of the same session are rejected, including the ones of
+	// other routes and clones.
+	if rec := serve(other, "POST"); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), ErrLocked.Error()) {
+		t.Errorf("want %d and %q, got %d and %q", http.StatusUnauthorized, ErrLocked, rec.Code, rec.Body.String())
+	}
+
+	// safe methods are not guarded by default.
+	if rec := serve(other, "GET"); rec.Code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if rec := serve(m.RequireExclusive(LockConfig{AllMethods: true})(noop), "GET"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	// waiting requests proceed once the lock is released.
+	done := make(chan int)
+	go func() {
+		done <- serve(m.RequireExclusive(LockConfig{Wait: time.Second})(noop), "POST").Code
+	}()
+
+	time.Sleep(lockPollInterval * 3)
+	close(unblock)
+	wg.Wait()
+
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+
+	if rec := serve(other, "POST"); rec.Code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	// invalid sessions are rejected before locking.
+	req := httptest.NewRequest("POST", "http://example.com/", nil)
+	rec = httptest.NewRecorder()
+	other.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || len(m.locks.held) != 0 {
+		t.Errorf("want %d and no locks, got %d and %v", http.StatusUnauthorized, rec.Code, m.locks.held)
+	}
+}
+
+func TestRequireExclusiveLockStore(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		acquired = make(map[string]string)
+		released []string
+		fail     error
+	)
+
+	store := &lockStoreMock{
+		StoreMock: mapStore(),
+		AcquireLockFunc: func(_ context.Context, key, owner string, ttl time.Duration) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if ttl != defaultLockTTL {
+				t.Errorf("want %v, got %v", defaultLockTTL, ttl)
+			}
+
+			if fail != nil {
+				return false, fail
+			}
+
+			if _, ok := acquired[key]; ok {
+				return false, nil
+			}
+
+			acquired[key] = owner
+			return true, nil
+		},
+		ReleaseLockFunc: func(_ context.Context, key, owner string) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if acquired[key] == owner {
+				delete(acquired, key)
+				released = append(released, key)
+			}
+
+			return nil
+		},
+	}
+
+	m := NewManager(store)
+	rec := httptest.NewRecorder()
+	if err := m.Init(rec, httptest.NewRequest("GET", "http://example.com/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	c := rec.Result().Cookies()[0]
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "http://example.com/", nil)
+		req.AddCookie(c)
+		m.RequireExclusive(LockConfig{})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if _, ok := acquired[c.Value]; !ok {
+				t.Error("want lock held in the store")
+			}
+		})).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+
+	if len(released) != 1 || released[0] != c.Value || len(m.locks.held) != 0 {
+		t.Errorf("want %q released in the store, got %v", c.Value, released)
+	}
+
+	fail = errors.New("error")
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+}
+
+func TestRequireExclusiveKeyPrefix(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		acquired []string
+		released []string
+	)
+
+	store := &lockStoreMock{
+		StoreMock: mapStore(),
+		AcquireLockFunc: func(_ context.Context, key, _ string, _ time.Duration) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			acquired = append(acquired, key)
+			return true, nil
+		},
+		ReleaseLockFunc: func(_ context.Context, key, _ string) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			released = append(released, key)
+			return nil
+		},
+	}
+
+	serve := func(m *Manager) int {
+		rec := httptest.NewRecorder()
+		if err := m.Init(rec, httptest.NewRequest("GET", "http://example.com/", nil), "key"); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		req := httptest.NewRequest("POST", "http://example.com/", nil)
+		req.AddCookie(rec.Result().Cookies()[0])
+		rec = httptest.NewRecorder()
+		m.RequireExclusive(LockConfig{})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	m := NewManager(store, KeyPrefix("app:"))
+	if code := serve(m); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+
+	if len(acquired) != 1 || !strings.HasPrefix(acquired[0], "app:") || len(released) != 1 || released[0] != acquired[0] {
+		t.Errorf("want prefixed lock held in the store, got %v and %v", acquired, released)
+	}
+
+	// stores without locks fall back to in-memory ones.
+	if code := serve(NewManager(mapStore(), KeyPrefix("app:"))); code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestLocks(t *testing.T) {
+	l := &locks{}
+	if ok, _ := l.acquire(context.Background(), "id", "a", time.Hour); !ok {
+		t.Error("want true, got false")
+	}
+
+	if ok, _ := l.acquire(context.Background(), "id", "b", time.Hour); ok {
+		t.Error("want false, got true")
+	}
+
+	// only the owner can release the lock.
+	l.release("id", "b")
+	if ok, _ := l.acquire(context.Background(), "id", "b", time.Hour); ok {
+		t.Error("want false, got true")
+	}
+
+	l.release("id", "a")
+	if ok, _ := l.acquire(context.Background(), "id", "b", -time.Second); !ok {
+		t.Error("want true, got false")
+	}
+
+	// expired locks can be acquired by others.
+	if ok, _ := l.acquire(context.Background(), "id", "c", time.Hour); !ok {
+		t.Error("want true, got false")
+	}
+}
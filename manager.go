@@ -2,17 +2,23 @@ package sessionup
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/ed25519"
+	"crypto/rand"
 	"errors"
+	"expvar"
+	"io"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/dchest/uniuri"
 )
 
 const (
-	defaultName = "sessionup"
-	idLen       = 40
+	defaultName      = "sessionup"
+	defaultChallenge = `Cookie realm="` + defaultName + `"`
+	idLen            = 40
+	idChars          = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
 )
 
 var (
@@ -22,6 +28,14 @@ var (
 	// ErrNotOwner is returned when session's status is being modified
 	// not by its owner.
 	ErrNotOwner = errors.New("session can be managed only by its owner")
+
+	// ErrGenID is returned when the ID generation function fails to
+	// produce a new session ID.
+	ErrGenID = errors.New("session ID could not be generated")
+
+	// publicErrors holds errors whose messages can be safely
+	// sent to clients.
+	publicErrors = []error{ErrUnauthorized, ErrNotOwner, ErrCSRF, ErrNotVerified, ErrConflict, ErrInvalidSignedURL, ErrNotFresh, ErrChallenge, ErrInvalidCursor, ErrLocked, ErrInvalidTicket, ErrAmbiguousCookie, ErrAccessDenied}
 )
 
 // Manager holds the data needed to properly create sessions
@@ -36,23 +50,94 @@ type Manager struct {
 		secure   bool
 		httpOnly bool
 		sameSite http.SameSite
+		oldNames []string
+		mutator  func(*http.Cookie)
+	}
+	csrf struct {
+		origin  bool
+		token   bool
+		origins []string
+		proxies []*net.IPNet
+		header  string
+	}
+	affinity struct {
+		value  string
+		header string
+	}
+	anomaly struct {
+		cfg AnomalyConfig
+		fn  func(*http.Request, Session, Anomaly) error
+	}
+	stateless struct {
+		key     []byte
+		signer  ed25519.PrivateKey
+		refresh time.Duration
+	}
+	drain struct {
+		state *drainState
+		err   error
 	}
-	expiresIn time.Duration
-	withIP    bool
-	withAgent bool
-	validate  bool
+	trace struct {
+		on     bool
+		header bool
+	}
+	failure struct {
+		revoke bool
+		fn     func(*http.Request, Session, error)
+	}
+	lifetime struct {
+		cookie    time.Duration
+		cookieSet bool
+		store     time.Duration
+	}
+	expiresIn     time.Duration
+	blobThreshold int
+	orgSeparator  string
+	changeHeader  string
+	notifier      *Notifier
+	hints         *hints
+	flight        *flight
+	locks         *locks
+	signOuts      *signOuts
+	noop          bool
+	duplicates    DuplicatePolicy
+	adaptive      *AdaptiveConfig
+	dedupe        *DedupeConfig
+	access        func(Session) []AccessRule
+	fingerprint   *FingerprintPolicy
+	journal       Journal
+	reloaded      *atomic.Value
+	scope         string
+	signingKey    []byte
+	withIP        bool
+	withAgent     bool
+	validate      bool
+	repairSkew    bool
+	clockSkew     time.Duration
 
-	genID  func() string
-	reject func(error) http.Handler
+	stats       *expvar.Map
+	redactor    Redactor
+	risk        RiskScorer
+	genID       func() string
+	reject      func(error) http.Handler
+	onCanary    func(*http.Request, Session, error)
+	ttlResolver func(string) time.Duration
+	purge       func(context.Context, string) error
+	optErrs     []error
 }
 
-// setter is used to set Manager configuration options.
-type setter func(*Manager)
+// Option is used to set Manager configuration options. Options that
+// receive invalid values record errors, which are returned by New.
+type Option func(*Manager)
 
 // CookieName sets the name of the cookie.
 // Defaults to the value stored in defaultName.
-func CookieName(n string) setter {
+func CookieName(n string) Option {
 	return func(m *Manager) {
+		if !validCookieName(n) {
+			m.invalid("CookieName", ErrInvalidCookieName)
+		}
+
 		m.cookie.name = n
 	}
 }
@@ -60,7 +145,7 @@ func CookieName(n string) setter {
 // Domain sets the 'Domain' attribute on the session cookie.
 // Defaults to empty string.
 // More at: https://developer.mozilla.org/en-US/docs/Web/HTTP/Cookies#Scope_of_cookies
-func Domain(d string) setter {
+func Domain(d string) Option {
 	return func(m *Manager) {
 		m.cookie.domain = d
 	}
@@ -69,7 +154,7 @@ func Domain(d string) setter {
 // Path sets the 'Path' attribute on the session cookie.
 // Defaults to "/".
 // More at: https://developer.mozilla.org/en-US/docs/Web/HTTP/Cookies#Scope_of_cookies
-func Path(p string) setter {
+func Path(p string) Option {
 	return func(m *Manager) {
 		m.cookie.path = p
 	}
@@ -78,7 +163,7 @@ func Path(p string) setter {
 // Secure sets the 'Secure' attribute on the session cookie.
 // Defaults to true.
 // More at: https://developer.mozilla.org/en-US/docs/Web/HTTP/Cookies#Secure_and_HttpOnly_cookies
-func Secure(s bool) setter {
+func Secure(s bool) Option {
 	return func(m *Manager) {
 		m.cookie.secure = s
 	}
@@ -87,7 +172,7 @@ func Secure(s bool) setter {
 // HttpOnly sets the 'HttpOnly' attribute on the session cookie.
 // Defaults to true.
 // More at: https://developer.mozilla.org/en-US/docs/Web/HTTP/Cookies#Secure_and_HttpOnly_cookies
-func HttpOnly(h bool) setter {
+func HttpOnly(h bool) Option {
 	return func(m *Manager) {
 		m.cookie.httpOnly = h
 	}
@@ -95,8 +180,10 @@ func HttpOnly(h bool) setter {
 
 // SameSite sets the 'SameSite' attribute on the session cookie.
 // Defaults to http.SameSiteStrictMode.
+// Setting it to http.SameSiteNoneMode automatically activates CSRF
+// checks in Auth and Public middlewares (see CheckOrigin and CheckToken).
 // More at: https://developer.mozilla.org/en-US/docs/Web/HTTP/Cookies#SameSite_cookies
-func SameSite(s http.SameSite) setter {
+func SameSite(s http.SameSite) Option {
 	return func(m *Manager) {
 		m.cookie.sameSite = s
 	}
@@ -107,16 +194,33 @@ func SameSite(s http.SameSite) setter {
 // If unset, 'Expires' attribute will be omitted during cookie creation.
 // By default it is not set.
 // More about Expires at: https://developer.mozilla.org/en-US/docs/Web/HTTP/Cookies#Session_cookies
-func ExpiresIn(e time.Duration) setter {
+func ExpiresIn(e time.Duration) Option {
 	return func(m *Manager) {
+		if e < 0 {
+			m.invalid("ExpiresIn", ErrNegative)
+		}
+
 		m.expiresIn = e
 	}
 }
 
+// TTLResolver sets the function which will be called during session
+// creation to determine the session's lifetime based on its user key,
+// allowing different lifetimes for e.g. service accounts, admins and
+// regular users. Returned duration is used the same way as the value
+// of ExpiresIn (zero produces a temporary session).
+// By default it is not set and the value of ExpiresIn is used for
+// all sessions.
+func TTLResolver(fn func(key string) time.Duration) Option {
+	return func(m *Manager) {
+		m.ttlResolver = fn
+	}
+}
+
 // WithIP determines whether IP should be extracted
 // from the request or not.
 // Defaults to true.
-func WithIP(w bool) setter {
+func WithIP(w bool) Option {
 	return func(m *Manager) {
 		m.withIP = w
 	}
@@ -125,7 +229,7 @@ func WithIP(w bool) setter {
 // WithAgent determines whether User-Agent data should
 // be extracted from the request or not.
 // Defaults to true.
-func WithAgent(w bool) setter {
+func WithAgent(w bool) Option {
 	return func(m *Manager) {
 		m.withAgent = w
 	}
@@ -134,7 +238,8 @@ func WithAgent(w bool) setter {
 // Validate determines whether IP and User-Agent data
 // should be checked on each request to authenticated
 // routes or not.
-func Validate(v bool) setter {
+// It is ignored when the Fingerprint option is set.
+func Validate(v bool) Option {
 	return func(m *Manager) {
 		m.validate = v
 	}
@@ -143,24 +248,36 @@ func Validate(v bool) setter {
 // GenID sets the function which will be called when a new session
 // is created and ID is being generated.
 // Defaults to DefaultGenID function.
-func GenID(g func() string) setter {
+func GenID(g func() string) Option {
 	return func(m *Manager) {
 		m.genID = g
 	}
 }
 
+// Entropy sets the source of randomness used to generate session IDs
+// with the default alphanumeric charset and length.
+// Useful for hardware-backed random sources or deterministic
+// simulation testing.
+// Defaults to crypto/rand.Reader.
+func Entropy(r io.Reader) Option {
+	return func(m *Manager) {
+		m.genID = NewGenID(r, idChars, idLen)
+	}
+}
+
 // Reject sets the function which will be called on error in Auth
 // middleware.
 // Defaults to DefaultReject function.
-func Reject(r func(error) http.Handler) setter {
+func Reject(r func(error) http.Handler) Option {
 	return func(m *Manager) {
 		m.reject = r
 	}
 }
 
 // NewManager creates a new Manager with the provided store
-// and options applied to it.
-func NewManager(s Store, opts ...setter) *Manager {
+// and options applied to it. Invalid option values are not reported;
+// use New to check them.
+func NewManager(s Store, opts ...Option) *Manager {
 	m := &Manager{store: s}
 	m.Defaults()
 
@@ -179,46 +296,157 @@ func (m *Manager) Defaults() {
 	m.cookie.secure = true
 	m.cookie.httpOnly = true
 	m.cookie.sameSite = http.SameSiteStrictMode
+	m.csrf.origin = true
+	m.csrf.token = true
+	m.csrf.header = defaultCSRFHeader
 	m.withIP = true
 	m.withAgent = true
 	m.genID = DefaultGenID
 	m.reject = DefaultReject
+	m.drain.state = &drainState{}
+	m.locks = &locks{}
+	m.signOuts = &signOuts{ttl: defaultSignOutCache}
+	m.reloaded = &atomic.Value{}
 }
 
 // DefaultGenID is the default ID generation function called during
-// session creation.
+// session creation. It produces 40 alphanumeric characters long IDs
+// from crypto/rand.Reader.
 func DefaultGenID() string {
-	return uniuri.NewLen(idLen)
+	return defaultGenID()
+}
+
+// defaultGenID holds the ID generation function used by DefaultGenID.
+var defaultGenID = NewGenID(rand.Reader, idChars, idLen)
+
+// NewGenID produces an ID generation function that builds n characters
+// long IDs from the provided charset, using the provided reader as the
+// source of randomness. Each character of the charset is equally
+// likely to be picked.
+// Generated function returns an empty string if the reader fails or
+// the charset is empty or longer than 256 characters; Init treats
+// such IDs as errors.
+func NewGenID(r io.Reader, chars string, n int) func() string {
+	var mu sync.Mutex
+
+	return func() string {
+		if len(chars) == 0 || len(chars) > 256 || n <= 0 {
+			return ""
+		}
+
+		// bytes above max are discarded to avoid modulo bias.
+		max := 256 - (256 % len(chars))
+		id := make([]byte, 0, n)
+		buf := make([]byte, n+n/4)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		for len(id) < n {
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return ""
+			}
+
+			for _, b := range buf {
+				if int(b) >= max {
+					continue
+				}
+
+				id = append(id, chars[int(b)%len(chars)])
+				if len(id) == n {
+					break
+				}
+			}
+		}
+
+		return string(id)
+	}
 }
 
 // DefaultReject is the default rejection function called on error.
-// It produces a response consisting of 401 status code and a JSON
-// body with 'error' field.
+// It produces a response consisting of 401 status code, 'WWW-Authenticate'
+// header set to the value stored in defaultChallenge, 'Cache-Control'
+// header set to 'no-store' and a JSON body with 'error' field.
+// Only errors defined in this package are reflected in the body, all
+// other errors (e.g. returned by the store) are replaced with a generic
+// message; the original error is still available to custom rejection
+// functions for logging.
 func DefaultReject(err error) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(struct {
-			Error string `json:"error"`
-		}{Error: err.Error()})
-	})
+	return ChallengeReject(defaultChallenge)(err)
+}
+
+// ChallengeReject produces a rejection function that behaves the same
+// way as DefaultReject, but sets the 'WWW-Authenticate' header to the
+// provided challenge. Empty challenge omits the header.
+func ChallengeReject(ch string) func(error) http.Handler {
+	return func(err error) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ch != "" {
+				w.Header().Set("WWW-Authenticate", ch)
+			}
+
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(errorBody(PublicError(err)))
+		})
+	}
+}
+
+// PublicError returns a message, describing the error, that is safe to
+// be sent to clients. Errors defined in this package are returned as
+// they are, all other errors are replaced with ErrUnauthorized.
+func PublicError(err error) string {
+	for _, pe := range publicErrors {
+		if errors.Is(err, pe) {
+			return pe.Error()
+		}
+	}
+
+	return ErrUnauthorized.Error()
 }
 
 // Clone copies the manager to its fresh copy and applies provided
 // options.
-func (m *Manager) Clone(opts ...setter) *Manager {
+func (m *Manager) Clone(opts ...Option) *Manager {
 	cm := &Manager{}
 	*cm = *m
+	cm.optErrs = nil
+	if cm.flight != nil {
+		cm.flight = newFlight()
+	}
+
+	before := cm.options()
 	for _, o := range opts {
 		o(cm)
 	}
 
+	// policies changed by the options take precedence over the
+	// reloaded ones, so the clone stops following the reloads.
+	if after := cm.options(); after != before {
+		if c, ok := cm.loadReloaded(); ok {
+			cm.reloaded = &atomic.Value{}
+			cm.reloaded.Store(mergeConfig(c, before, after))
+		}
+	}
+
 	return cm
 }
 
 // Init creates a fresh session with the provided user key, inserts it in
 // the store and sets the proper values of the cookie.
+// If DedupeDevices is set, sessions of the same device are reused or
+// replaced; errors that occur while revoking the replaced sessions are
+// returned after the new session's cookie is set.
 func (m *Manager) Init(w http.ResponseWriter, r *http.Request, key string, mm ...Meta) error {
+	if w == nil {
+		return ErrNilWriter
+	}
+
+	if r == nil {
+		return ErrNilRequest
+	}
+
 	var meta map[string]string
 
 	if len(mm) > 0 {
@@ -228,18 +456,109 @@ func (m *Manager) Init(w http.ResponseWriter, r *http.Request, key string, mm ..
 		}
 	}
 
+	if m.dedupe != nil && m.dedupe.Action == DedupeReuse {
+		ok, err := m.reuse(w, r, key, meta)
+		if err != nil || ok {
+			return err
+		}
+	}
+
+	ns, err := m.create(r, key, meta)
+	if err != nil {
+		return err
+	}
+
+	m.activate(w, ns)
+
+	if m.dedupe != nil && m.dedupe.Action == DedupeReplace {
+		return m.replace(r.Context(), ns.s)
+	}
+
+	return nil
+}
+
+// recreate inserts the session, which received a new ID, into the
+// store and revokes the session of the old ID. Blobs referenced by the
+// session are kept.
+func (m *Manager) recreate(ctx context.Context, s Session, old string) error {
+	if err := m.storeErr(m.store.Create(ctx, s)); err != nil {
+		return err
+	}
+
+	m.hints.remember(s)
+	return m.deleteSession(ctx, old)
+}
+
+// newSess holds a session inserted in the store, whose cookie is not
+// set yet.
+type newSess struct {
+	s       Session
+	val     string
+	exp     time.Time
+	anomaly Anomaly
+}
+
+// create creates a fresh session with the provided user key and
+// metadata and inserts it in the store.
+func (m *Manager) create(r *http.Request, key string, meta map[string]string) (newSess, error) {
+	if err := m.drainErr(); err != nil {
+		return newSess{}, err
+	}
+
 	s := m.newSession(r, key, meta)
-	exp := s.ExpiresAt
+	if s.ID == "" {
+		return newSess{}, ErrGenID
+	}
+
+	if err := ValidateSession(s); err != nil {
+		return newSess{}, err
+	}
+
+	if m.csrfActive() {
+		s.CSRFToken = m.genID()
+		if s.CSRFToken == "" {
+			return newSess{}, ErrGenID
+		}
+	}
+
+	a, err := m.checkAnomaly(r, s)
+	if err != nil {
+		return newSess{}, err
+	}
+
 	if s.ExpiresAt.IsZero() {
 		s.ExpiresAt = time.Now().Add(time.Hour * 24) // for temporary sessions
 	}
 
-	if err := m.store.Create(r.Context(), s); err != nil {
-		return err
+	exp := m.sessionCookieExpiry(key, s.ExpiresAt)
+	s.CookieExpiresAt = exp
+
+	if err := m.createBlobs(r.Context(), &s); err != nil {
+		return newSess{}, err
 	}
 
-	m.setCookie(w, exp, s.ID)
-	return nil
+	val, err := m.cookieValue(s)
+	if err != nil {
+		return newSess{}, err
+	}
+
+	if err := m.storeErr(m.store.Create(r.Context(), s)); err != nil {
+		return newSess{}, err
+	}
+
+	m.hints.remember(s)
+	m.stat(statCreated)
+
+	return newSess{s: s, val: val, exp: exp, anomaly: a}, nil
+}
+
+// activate notifies about the created session and sets its cookies.
+func (m *Manager) activate(w http.ResponseWriter, ns newSess) {
+	m.notify(ns.s.UserKey, Event{Type: EventCreated, Session: ns.s, Anomaly: ns.anomaly})
+	m.setCookie(w, ns.exp, ns.val)
+	if m.csrfActive() {
+		m.setCSRFCookie(w, ns.exp, ns.s.CSRFToken)
+	}
 }
 
 // Public wraps the provided handler, checks whether the session, associated to
@@ -267,48 +586,157 @@ func (m *Manager) Auth(next http.Handler) http.Handler {
 	return m.wrap(m.reject, next)
 }
 
-// wrap extracts cookie data from the incoming request and checks session existence in
-// the store. If no errors occur, response/request data will be passed to the wrapped
-// handler, otherwise, provided rejection function will be used.
+// wrap authenticates the incoming request. If no errors occur, response/request
+// data will be passed to the wrapped handler, otherwise, provided rejection
+// function will be used.
 func (m *Manager) wrap(rej func(error) http.Handler, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		c, err := r.Cookie(m.cookie.name)
-		if err != nil {
-			rej(err).ServeHTTP(w, r)
-			return
+	if m.stats != nil {
+		orig := rej
+		rej = func(err error) http.Handler {
+			m.stat(statAuthFailures)
+			return orig(err)
 		}
+	}
 
-		ctx := r.Context()
-		s, ok, err := m.store.FetchByID(ctx, c.Value)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tr := m.tracer()
+		s, err := m.authenticate(w, r, tr)
 		if err != nil {
-			rej(err).ServeHTTP(w, r)
+			rej(err).ServeHTTP(w, tr.finish(w, r))
 			return
 		}
 
-		if !ok {
-			rej(ErrUnauthorized).ServeHTTP(w, r)
-			return
-		}
+		m.stat(statAuthSuccesses)
+		r = r.WithContext(NewContext(r.Context(), s))
+		tr.step(TraceContext)
+		next.ServeHTTP(w, tr.finish(w, r))
+	})
+}
 
-		if m.validate && !s.IsValid(r) {
-			rej(ErrUnauthorized).ServeHTTP(w, r)
-			return
-		}
+// authenticate extracts cookie data from the incoming request, checks
+// session existence in the store and validates it. Cookies of the
+// session are maintained as well. The steps are recorded by the
+// provided tracer.
+func (m *Manager) authenticate(w http.ResponseWriter, r *http.Request, tr *tracer) (Session, error) {
+	if m.noop {
+		return anonymousSession(), nil
+	}
 
-		next.ServeHTTP(w, r.WithContext(NewContext(ctx, s)))
-	})
+	c, old, err := m.sessionCookie(r)
+	tr.step(TraceCookie)
+	if err != nil {
+		return Session{}, err
+	}
+
+	ctx := r.Context()
+	s, ok, err := m.loadSession(ctx, w, c.Value)
+	if m.stateless.key != nil {
+		tr.step(TraceToken)
+	} else {
+		tr.step(TraceStore)
+	}
+
+	if err != nil {
+		return Session{}, err
+	}
+
+	if !ok {
+		return Session{}, ErrUnauthorized
+	}
+
+	err = m.checkSession(r, c, s)
+	tr.step(TraceValidate)
+	if err != nil {
+		return Session{}, err
+	}
+
+	s, err = m.checkFingerprint(w, r, s)
+	tr.step(TraceFingerprint)
+	if err != nil {
+		return Session{}, err
+	}
+
+	if m.affinity.header != "" {
+		SetAffinityHeader(w, s, m.affinity.header)
+	}
+
+	if m.repairSkew {
+		s = m.fixSkew(w, r, s)
+	}
+
+	if old != "" {
+		m.migrateCookie(w, s, old)
+	}
+
+	s = m.reissue(ctx, w, s)
+
+	if m.changeHeader != "" {
+		m.notifyChange(w, r, s)
+	}
+
+	m.trackActive(s)
+	tr.step(TraceMaintain)
+	return s, nil
+}
+
+// checkSession checks whether the session, loaded from the provided
+// cookie, can be used for the request.
+func (m *Manager) checkSession(r *http.Request, c *http.Cookie, s Session) error {
+	if s.Kind == KindCanary {
+		m.triggerCanary(r, s)
+		return ErrUnauthorized
+	}
+
+	if s.Kind != "" {
+		return ErrUnauthorized
+	}
+
+	ctx := r.Context()
+	if err := m.checkSignOut(ctx, s); err != nil {
+		return err
+	}
+
+	if err := m.checkDuplicates(ctx, r, c, s); err != nil {
+		return err
+	}
+
+	if err := m.checkScope(ctx, s); err != nil {
+		return err
+	}
+
+	if err := m.checkAccess(r, s); err != nil {
+		return err
+	}
+
+	if m.csrfActive() && !m.checkCSRF(r, s) {
+		return ErrCSRF
+	}
+
+	return nil
 }
 
 // Revoke deletes the current session, stored in the context, from the store
 // and ensures cookie deletion.
 // Function will be no-op and return nil, if context session is not set.
+// Revoke is idempotent: revoking an already revoked session returns nil.
+// Requests that passed Auth before Revoke returned may still be handled
+// with the revoked session, but all requests authenticated after it
+// returns will be rejected with ErrUnauthorized.
 func (m *Manager) Revoke(ctx context.Context, w http.ResponseWriter) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if w == nil {
+		return ErrNilWriter
+	}
+
 	s, ok := FromContext(ctx)
 	if !ok {
 		return nil
 	}
 
-	if err := m.RevokeByID(ctx, s.ID); err != nil {
+	if err := m.revokeSession(ctx, s); err != nil {
 		return err
 	}
 
@@ -316,23 +744,67 @@ func (m *Manager) Revoke(ctx context.Context, w http.ResponseWriter) error {
 	return nil
 }
 
-// RevokeByID deletes session by its ID.
+// RevokeByID deletes session by its ID, as well as its blobs (see
+// BlobThreshold).
 // Function will be no-op and return nil, if no session is found.
 func (m *Manager) RevokeByID(ctx context.Context, id string) error {
-	return m.store.DeleteByID(ctx, id)
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	s := Session{ID: id}
+	if m.blobsEnabled() {
+		// the session's blob references are needed to delete them.
+		fs, ok, err := m.store.FetchByID(ctx, id)
+		if err = m.storeErr(err); err != nil {
+			return err
+		}
+
+		if ok {
+			s = fs
+		}
+	}
+
+	return m.revokeSession(ctx, s)
+}
+
+// revokeSession deletes the session's blobs and then the session
+// itself, so that failed calls can be retried without leaking the
+// blobs, and records the revocation.
+func (m *Manager) revokeSession(ctx context.Context, s Session) error {
+	if err := m.deleteBlobs(ctx, s); err != nil {
+		return err
+	}
+
+	return m.deleteSession(ctx, s.ID)
+}
+
+// deleteSession deletes the session of the provided ID (but not its
+// blobs) and records the revocation.
+func (m *Manager) deleteSession(ctx context.Context, id string) error {
+	if err := ignoreNotFound(m.storeErr(m.store.DeleteByID(ctx, id))); err != nil {
+		return err
+	}
+
+	m.forgetActive(id)
+	return m.record(ctx, Revocation{ID: id})
 }
 
 // RevokeByIDExt deletes session by its ID after checking if it
 // belongs to the same user as the one in the context.
 // Function will be no-op and return nil, if no session is found.
 func (m *Manager) RevokeByIDExt(ctx context.Context, id string) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
 	s1, ok := FromContext(ctx)
 	if !ok {
 		return nil
 	}
 
 	s2, ok, err := m.store.FetchByID(ctx, id)
-	if err != nil {
+	if err = m.storeErr(err); err != nil {
 		return err
 	}
 
@@ -344,25 +816,44 @@ func (m *Manager) RevokeByIDExt(ctx context.Context, id string) error {
 		return ErrNotOwner
 	}
 
-	return m.store.DeleteByID(ctx, id)
+	if err := m.revokeSession(ctx, s2); err != nil {
+		return err
+	}
+
+	m.notify(s2.UserKey, Event{Type: EventRevoked, Session: s2, Revocation: Revocation{ID: id}})
+	return nil
 }
 
 // RevokeOther deletes all sessions of the same user key as session stored in the
 // context currently has. Context session will be excluded.
 // Function will be no-op and return nil, if context session is not set.
 func (m *Manager) RevokeOther(ctx context.Context) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
 	s, ok := FromContext(ctx)
 	if !ok {
 		return nil
 	}
 
-	return m.store.DeleteByUserKey(ctx, s.UserKey, s.ID)
+	return m.revokeByUserKey(ctx, Revocation{UserKey: s.UserKey, ExceptID: s.ID})
 }
 
 // RevokeAll deletes all sessions of the same user key as session stored in the
 // context currently has. This includes context session as well.
 // Function will be no-op and return nil, if context session is not set.
+// Sessions that are initialized concurrently with RevokeAll may or may not
+// survive it, but each of them will be either fully valid or fully revoked.
 func (m *Manager) RevokeAll(ctx context.Context, w http.ResponseWriter) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if w == nil {
+		return ErrNilWriter
+	}
+
 	s, ok := FromContext(ctx)
 	if !ok {
 		return nil
@@ -380,37 +871,130 @@ func (m *Manager) RevokeAll(ctx context.Context, w http.ResponseWriter) error {
 // This includes context session as well.
 // Function will be no-op and return nil, if no sessions are found.
 func (m *Manager) RevokeByUserKey(ctx context.Context, key string) error {
-	return m.store.DeleteByUserKey(ctx, key)
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	return m.revokeByUserKey(ctx, Revocation{UserKey: key})
+}
+
+// revokeByUserKey deletes the sessions matched by the user key
+// revocation (except the one with its ExceptID), notifies about it and
+// records it. The blobs of the sessions are deleted first, so that
+// failed calls can be retried without leaking the blobs.
+func (m *Manager) revokeByUserKey(ctx context.Context, r Revocation) error {
+	if m.blobsEnabled() {
+		ss, err := m.store.FetchByUserKey(ctx, r.UserKey)
+		if err = m.storeErr(err); err != nil {
+			return err
+		}
+
+		for _, s := range ss {
+			if s.ID == r.ExceptID {
+				continue
+			}
+
+			if err = m.deleteBlobs(ctx, s); err != nil {
+				return err
+			}
+		}
+	}
+
+	var except []string
+	if r.ExceptID != "" {
+		except = append(except, r.ExceptID)
+	}
+
+	if err := ignoreNotFound(m.storeErr(m.store.DeleteByUserKey(ctx, r.UserKey, except...))); err != nil {
+		return err
+	}
+
+	m.notify(r.UserKey, Event{Type: EventRevoked, Revocation: r})
+	return m.record(ctx, r)
 }
 
 // FetchAll retrieves all sessions of the same user key as session stored in the
 // context currently has. Session with the same ID as the one stored in the context
 // will have its 'Current' field set to true. If no sessions are found or the context
 // session is not set, both return values will be nil.
+// The slice returned by the store is never modified, so stores may safely
+// share it between requests.
 func (m *Manager) FetchAll(ctx context.Context) ([]Session, error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+
 	cs, ok := FromContext(ctx)
 	if !ok {
 		return nil, nil
 	}
 
 	ss, err := m.store.FetchByUserKey(ctx, cs.UserKey)
-	if err != nil {
+	if err = m.storeErr(err); err != nil {
 		return nil, err
 	}
 
-	if ss == nil {
+	// the slice may be shared with other requests (e.g. by caching
+	// stores), so it must never be modified.
+	var res []Session
+	for _, s := range ss {
+		if s.Kind != "" {
+			// canaries and other special records are never listed
+			continue
+		}
+
+		res = append(res, s)
+	}
+
+	if len(res) == 0 {
 		return nil, nil
 	}
 
-	for i, s := range ss {
-		// ensure that only the real current session is marked as such
-		s.Current = false
-		if s.ID == cs.ID {
-			s.Current = true
+	markCurrent(res, cs.ID)
+
+	if m.risk != nil {
+		for i, s := range res {
+			res[i].Risk = clampRisk(m.risk.Score(s, res))
 		}
-		ss[i] = s
 	}
-	return ss, nil
+
+	return res, nil
+}
+
+// ttl determines the lifetime of a new session that belongs to the
+// provided user key.
+func (m *Manager) ttl(key string) time.Duration {
+	if m.ttlResolver != nil {
+		return m.ttlResolver(key)
+	}
+
+	return m.Config().ExpiresIn
+}
+
+// update increments the session's version and saves it in the store.
+// If the store implements CASStore interface, ErrConflict is returned
+// when the stored session was modified since it was retrieved.
+func (m *Manager) update(ctx context.Context, s Session) (Session, error) {
+	old := s.Version
+	s.Version++
+
+	if cs, ok := m.store.(CASStore); ok {
+		swapped, err := cs.CompareAndSwap(ctx, old, s)
+		if err == nil && !swapped {
+			err = ErrConflict
+		}
+
+		if !errors.Is(err, ErrNotSupported) {
+			return s, err
+		}
+	}
+
+	us, ok := m.store.(UpdateStore)
+	if !ok {
+		return s, ErrNotSupported
+	}
+
+	return s, us.Update(ctx, s)
 }
 
 // setCookie creates a cookie and sets its values to the options set in the manager
@@ -427,11 +1011,14 @@ func (m *Manager) setCookie(w http.ResponseWriter, exp time.Time, tok string) {
 		SameSite: m.cookie.sameSite,
 	}
 
-	http.SetCookie(w, c)
+	m.writeCookie(w, c)
 }
 
 // deleteCookie creates a cookie and overrides the existing one with values that
 // would require the client to delete it immediately.
 func (m *Manager) deleteCookie(w http.ResponseWriter) {
 	m.setCookie(w, time.Unix(1, 0), "")
+	if m.csrfActive() {
+		m.setCSRFCookie(w, time.Unix(1, 0), "")
+	}
 }
@@ -1,14 +1,18 @@
 package sessionup
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -78,6 +82,15 @@ func TestExpiresIn(t *testing.T) {
 	}
 }
 
+func TestTTLResolver(t *testing.T) {
+	m := Manager{}
+	val := func(_ string) time.Duration { return time.Hour }
+	TTLResolver(val)(&m)
+	if m.ttlResolver == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
 func TestWithIP(t *testing.T) {
 	m := Manager{}
 	val := true
@@ -148,8 +161,15 @@ func TestDefaults(t *testing.T) {
 	cm.cookie.secure = true
 	cm.cookie.httpOnly = true
 	cm.cookie.sameSite = http.SameSiteStrictMode
+	cm.csrf.origin = true
+	cm.csrf.token = true
+	cm.csrf.header = defaultCSRFHeader
 	cm.withIP = true
 	cm.withAgent = true
+	cm.drain.state = &drainState{}
+	cm.locks = &locks{}
+	cm.signOuts = &signOuts{ttl: defaultSignOutCache}
+	cm.reloaded = &atomic.Value{}
 
 	m := Manager{}
 	m.Defaults()
@@ -171,22 +191,82 @@ func TestDefaults(t *testing.T) {
 func TestDefaultReject(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "http://example.com", nil)
-	res, _ := json.Marshal(struct {
-		Error string `json:"error"`
-	}{Error: "major problem"})
-
 	DefaultReject(errors.New("major problem")).ServeHTTP(rec, req)
-	if rec.Code != http.StatusUnauthorized {
-		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	if rec.Header().Get("WWW-Authenticate") != defaultChallenge {
+		t.Errorf("want %q, got %q", defaultChallenge, rec.Header().Get("WWW-Authenticate"))
 	}
+}
 
-	if rec.Header().Get("Content-Type") != "application/json" {
-		t.Errorf("want %q, got %q", "application/json", rec.Header().Get("Content-Type"))
+func TestChallengeReject(t *testing.T) {
+	cc := map[string]struct {
+		Challenge string
+		Err       error
+		Message   string
+	}{
+		"Internal error with no challenge": {
+			Err:     errors.New("major problem"),
+			Message: ErrUnauthorized.Error(),
+		},
+		"Internal error": {
+			Challenge: "Cookie",
+			Err:       errors.New("major problem"),
+			Message:   ErrUnauthorized.Error(),
+		},
+		"Public error": {
+			Challenge: "Cookie",
+			Err:       ErrCSRF,
+			Message:   ErrCSRF.Error(),
+		},
+		"Wrapped public error": {
+			Challenge: "Cookie",
+			Err:       fmt.Errorf("wrapped: %w", ErrNotOwner),
+			Message:   ErrNotOwner.Error(),
+		},
 	}
 
-	bd := append(res, '\n')
-	if !reflect.DeepEqual(rec.Body.Bytes(), bd) {
-		t.Errorf("want %q, got %q", string(rec.Body.Bytes()), string(bd))
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://example.com", nil)
+			res, _ := json.Marshal(struct {
+				Error string `json:"error"`
+			}{Error: c.Message})
+
+			ChallengeReject(c.Challenge)(c.Err).ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+			}
+
+			if rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("want %q, got %q", "application/json", rec.Header().Get("Content-Type"))
+			}
+
+			if rec.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("want %q, got %q", "no-store", rec.Header().Get("Cache-Control"))
+			}
+
+			_, ok := rec.Header()["Www-Authenticate"]
+			if ok != (c.Challenge != "") || rec.Header().Get("WWW-Authenticate") != c.Challenge {
+				t.Errorf("want %q, got %q", c.Challenge, rec.Header().Get("WWW-Authenticate"))
+			}
+
+			bd := append(res, '\n')
+			if !reflect.DeepEqual(rec.Body.Bytes(), bd) {
+				t.Errorf("want %q, got %q", string(bd), string(rec.Body.Bytes()))
+			}
+		})
+	}
+}
+
+func TestPublicError(t *testing.T) {
+	if msg := PublicError(errors.New("db is down")); msg != ErrUnauthorized.Error() {
+		t.Errorf("want %q, got %q", ErrUnauthorized.Error(), msg)
+	}
+
+	if msg := PublicError(ErrNotOwner); msg != ErrNotOwner.Error() {
+		t.Errorf("want %q, got %q", ErrNotOwner.Error(), msg)
 	}
 }
 
@@ -195,6 +275,84 @@ func TestDefaultGenID(t *testing.T) {
 	if len(id) != idLen {
 		t.Errorf("want %d, got %d", idLen, len(id))
 	}
+
+	if id == DefaultGenID() {
+		t.Errorf("want unique IDs, got %q twice", id)
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	m := Manager{}
+	Entropy(bytes.NewReader(make([]byte, 100)))(&m)
+	if m.genID == nil {
+		t.Fatal("want non-nil, got nil")
+	}
+
+	if id := m.genID(); id != strings.Repeat("A", idLen) {
+		t.Errorf("want %q, got %q", strings.Repeat("A", idLen), id)
+	}
+}
+
+func TestNewGenID(t *testing.T) {
+	cc := map[string]struct {
+		Reader io.Reader
+		Chars  string
+		Len    int
+		ID     string
+	}{
+		"Empty charset": {
+			Reader: bytes.NewReader([]byte{0, 1, 2}),
+			Len:    3,
+		},
+		"Invalid length": {
+			Reader: bytes.NewReader([]byte{0, 1, 2}),
+			Chars:  "abc",
+		},
+		"Reader error": {
+			Reader: bytes.NewReader([]byte{0}),
+			Chars:  "abc",
+			Len:    3,
+		},
+		"Biased bytes discarded": {
+			Reader: bytes.NewReader([]byte{255, 0, 1, 2, 3, 4, 5, 6, 7, 8}),
+			Chars:  "abc",
+			Len:    3,
+			ID:     "abc",
+		},
+		"Successful generation": {
+			Reader: bytes.NewReader([]byte{5, 4, 3, 2, 1, 0, 0, 0, 0}),
+			Chars:  "abc",
+			Len:    3,
+			ID:     "cba",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			id := NewGenID(c.Reader, c.Chars, c.Len)()
+			if id != c.ID {
+				t.Errorf("want %q, got %q", c.ID, id)
+			}
+		})
+	}
+}
+
+func TestInitGenIDError(t *testing.T) {
+	m := Manager{store: &StoreMock{}}
+	m.Defaults()
+	m.genID = func() string { return "" }
+
+	rec := httptest.NewRecorder()
+	err := m.Init(rec, httptest.NewRequest("GET", "http://example.com/", nil), "key")
+	if err != ErrGenID {
+		t.Errorf("want %v, got %v", ErrGenID, err)
+	}
+
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("want %d, got %d", 0, len(rec.Result().Cookies()))
+	}
 }
 
 func TestClone(t *testing.T) {
@@ -305,6 +463,17 @@ func TestInit(t *testing.T) {
 				),
 			),
 		},
+		"Invalid metadata key": {
+			Store: storeStub(nil),
+			Meta: []Meta{
+				MetaEntry("", "10"),
+			},
+			Checks: checks(
+				hasErr(true),
+				hasCookie(false),
+				wasCreateCalled(0, key, time.Time{}, time.Time{}, nil),
+			),
+		},
 		"Successful temporary session init": {
 			Store: storeStub(nil),
 			Meta: []Meta{
@@ -841,6 +1010,37 @@ func TestRevokeByID(t *testing.T) {
 	}
 }
 
+func TestRevokeIdempotency(t *testing.T) {
+	s := &StoreMock{
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return ErrNotFound
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return ErrNotFound
+		},
+	}
+
+	m := Manager{store: s}
+	m.Defaults()
+	ctx := NewContext(context.Background(), Session{ID: "id", UserKey: "key"})
+
+	if err := m.Revoke(ctx, httptest.NewRecorder()); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if err := m.RevokeByID(ctx, "id"); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if err := m.RevokeOther(ctx); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if err := m.RevokeAll(ctx, httptest.NewRecorder()); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
+
 func TestRevokeByIDExt(t *testing.T) {
 	type check func(*testing.T, *StoreMock, error)
 
@@ -1376,6 +1576,153 @@ func TestFetchAll(t *testing.T) {
 	}
 }
 
+func TestFetchAllShared(t *testing.T) {
+	shared := []Session{
+		{ID: "id0", Current: true},
+		{ID: "id1"},
+		{ID: "canary", Kind: KindCanary},
+		{ID: "id2"},
+	}
+
+	store := &StoreMock{
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return shared, nil
+		},
+	}
+
+	m := Manager{store: store}
+	for _, id := range []string{"id1", "id2"} {
+		ss, err := m.FetchAll(NewContext(context.Background(), Session{ID: id}))
+		if err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		exp := []Session{{ID: "id0"}, {ID: "id1"}, {ID: "id2"}}
+		for i := range exp {
+			exp[i].Current = exp[i].ID == id
+		}
+
+		if !reflect.DeepEqual(exp, ss) {
+			t.Errorf("want %v, got %v", exp, ss)
+		}
+	}
+
+	exp := []Session{
+		{ID: "id0", Current: true},
+		{ID: "id1"},
+		{ID: "canary", Kind: KindCanary},
+		{ID: "id2"},
+	}
+
+	if !reflect.DeepEqual(exp, shared) {
+		t.Errorf("want %v, got %v", exp, shared)
+	}
+}
+
+// casStoreMock is a Store with CASStore capability, backed by
+// updateStoreMock.
+type casStoreMock struct {
+	*updateStoreMock
+	swapped bool
+	err     error
+}
+
+func (c *casStoreMock) CompareAndSwap(_ context.Context, _ uint64, _ Session) (bool, error) {
+	return c.swapped, c.err
+}
+
+func TestUpdate(t *testing.T) {
+	us := func(err error) *updateStoreMock {
+		return &updateStoreMock{
+			StoreMock: &StoreMock{},
+			UpdateFunc: func(_ context.Context, _ Session) error {
+				return err
+			},
+		}
+	}
+
+	cc := map[string]struct {
+		Store   Store
+		Err     error
+		Updated int
+	}{
+		"Store without update support": {
+			Store: &StoreMock{},
+			Err:   ErrNotSupported,
+		},
+		"Error returned by store.Update": {
+			Store:   us(ErrNotFound),
+			Err:     ErrNotFound,
+			Updated: 1,
+		},
+		"Successful update": {
+			Store:   us(nil),
+			Updated: 1,
+		},
+		"Version conflict": {
+			Store: &casStoreMock{updateStoreMock: us(nil)},
+			Err:   ErrConflict,
+		},
+		"Swap not supported by wrapped store": {
+			Store:   &casStoreMock{updateStoreMock: us(nil), err: ErrNotSupported},
+			Updated: 1,
+		},
+		"Successful swap": {
+			Store: &casStoreMock{updateStoreMock: us(nil), swapped: true},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := Manager{store: c.Store}
+			s, err := m.update(context.Background(), Session{ID: "id", Version: 4})
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if s.Version != 5 {
+				t.Errorf("want %d, got %d", 5, s.Version)
+			}
+
+			var updated int
+			switch st := c.Store.(type) {
+			case *updateStoreMock:
+				updated = len(st.updated)
+			case *casStoreMock:
+				updated = len(st.updated)
+			}
+
+			if updated != c.Updated {
+				t.Errorf("want %d, got %d", c.Updated, updated)
+			}
+		})
+	}
+}
+
+func TestTTL(t *testing.T) {
+	m := Manager{expiresIn: time.Hour}
+	if d := m.ttl("admin"); d != time.Hour {
+		t.Errorf("want %v, got %v", time.Hour, d)
+	}
+
+	m.ttlResolver = func(key string) time.Duration {
+		if key == "admin" {
+			return time.Minute
+		}
+		return m.expiresIn
+	}
+
+	if d := m.ttl("admin"); d != time.Minute {
+		t.Errorf("want %v, got %v", time.Minute, d)
+	}
+
+	if d := m.ttl("user"); d != time.Hour {
+		t.Errorf("want %v, got %v", time.Hour, d)
+	}
+}
+
 func TestSetCookie(t *testing.T) {
 	exp := http.Cookie{
 		Name:     defaultName,
@@ -2,6 +2,7 @@ package memstore
 
 import (
 	"context"
+	"net"
 	"sync"
 	"time"
 
@@ -15,6 +16,11 @@ type MemStore struct {
 	dataMu   sync.RWMutex
 	sessions map[string]sessionup.Session
 	users    map[string][]string
+	orgs     map[string][]string
+	blobs    map[string]blob
+	signOuts map[string]sessionup.SignOutMarker
+	revs     map[string]uint64
+	rev      uint64
 
 	stopMu   sync.RWMutex
 	stopChan chan struct{}
@@ -28,6 +34,10 @@ func New(d time.Duration) *MemStore {
 	m := &MemStore{
 		sessions: make(map[string]sessionup.Session),
 		users:    make(map[string][]string),
+		orgs:     make(map[string][]string),
+		blobs:    make(map[string]blob),
+		signOuts: make(map[string]sessionup.SignOutMarker),
+		revs:     make(map[string]uint64),
 	}
 
 	if d > 0 {
@@ -47,7 +57,9 @@ func (m *MemStore) Create(_ context.Context, s sessionup.Session) error {
 	}
 
 	m.users[s.UserKey] = append(m.users[s.UserKey], s.ID)
+	m.addOrg(s)
 	m.sessions[s.ID] = s
+	m.touch(s.UserKey)
 	m.dataMu.Unlock()
 	return nil
 }
@@ -57,7 +69,7 @@ func (m *MemStore) FetchByID(_ context.Context, id string) (sessionup.Session, b
 	m.dataMu.RLock()
 	s, ok := m.sessions[id]
 	m.dataMu.RUnlock()
-	if ok && !s.ExpiresAt.After(time.Now()) {
+	if ok && sessionup.IsExpired(s, time.Now()) {
 		return sessionup.Session{}, false, nil
 	}
 	return s, ok, nil
@@ -69,13 +81,116 @@ func (m *MemStore) FetchByUserKey(_ context.Context, key string) ([]sessionup.Se
 	ids := m.users[key]
 	var ss []sessionup.Session
 	for _, id := range ids {
-		s, ok := m.sessions[id]
-		if ok && s.ExpiresAt.After(time.Now()) {
+		if s, ok := m.sessions[id]; ok {
 			ss = append(ss, s)
 		}
 	}
 	m.dataMu.RUnlock()
-	return ss, nil
+	return sessionup.ExpiredFilter(ss, time.Now()), nil
+}
+
+// Iterate implements sessionup.IterStore interface's Iterate method.
+// Sessions are collected before the provided function is called, so it
+// may safely call other methods of the store.
+func (m *MemStore) Iterate(_ context.Context, fn func(sessionup.Session) bool) error {
+	m.dataMu.RLock()
+	ss := make([]sessionup.Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		ss = append(ss, s)
+	}
+	m.dataMu.RUnlock()
+
+	for _, s := range ss {
+		if !fn(s) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Update implements sessionup.UpdateStore interface's Update method.
+func (m *MemStore) Update(_ context.Context, s sessionup.Session) error {
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+
+	if _, ok := m.fetch(s.ID); !ok {
+		return sessionup.ErrNotFound
+	}
+
+	m.replace(s)
+	return nil
+}
+
+// CompareAndSwap implements sessionup.CASStore interface's CompareAndSwap method.
+func (m *MemStore) CompareAndSwap(_ context.Context, old uint64, s sessionup.Session) (bool, error) {
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+
+	cur, ok := m.fetch(s.ID)
+	if !ok {
+		return false, sessionup.ErrNotFound
+	}
+
+	if cur.Version != old {
+		return false, nil
+	}
+
+	m.replace(s)
+	return true, nil
+}
+
+// fetch retrieves a non-expired session by its ID.
+// NOTE: should be enclosed with mutex locks when called.
+func (m *MemStore) fetch(id string) (sessionup.Session, bool) {
+	s, ok := m.sessions[id]
+	if !ok || sessionup.IsExpired(s, time.Now()) {
+		return sessionup.Session{}, false
+	}
+
+	return s, true
+}
+
+// replace replaces the existing session with the provided one,
+// updating the user key index if needed.
+// NOTE: should be enclosed with mutex locks when called.
+func (m *MemStore) replace(s sessionup.Session) {
+	if old := m.sessions[s.ID]; old.UserKey != s.UserKey || old.OrgKey != s.OrgKey {
+		m.del(old.ID, old.UserKey)
+		m.users[s.UserKey] = append(m.users[s.UserKey], s.ID)
+		m.addOrg(s)
+	}
+
+	m.sessions[s.ID] = s
+	m.touch(s.UserKey)
+}
+
+// ChangeCounter implements sessionup.ChangeCounterStore interface's
+// ChangeCounter method. Expired sessions of the user are deleted
+// before the counter is returned.
+func (m *MemStore) ChangeCounter(_ context.Context, key string) (uint64, error) {
+	t := time.Now()
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+
+	for _, id := range append([]string(nil), m.users[key]...) {
+		if !m.sessions[id].ExpiresAt.After(t) {
+			m.del(id, key)
+		}
+	}
+
+	return m.revs[key], nil
+}
+
+// touch records a change of the user's sessions.
+// NOTE: should be enclosed with mutex locks when called.
+func (m *MemStore) touch(key string) {
+	if m.revs == nil {
+		m.revs = make(map[string]uint64)
+	}
+
+	m.rev++
+	m.revs[key] = m.rev
 }
 
 // DeleteByID implements sessionup.Store interface's DeleteByID method.
@@ -94,6 +209,8 @@ func (m *MemStore) DeleteByID(_ context.Context, id string) error {
 
 // DeleteByUserKey implements sessionup.Store interface's DeleteByUserKey method.
 func (m *MemStore) DeleteByUserKey(_ context.Context, key string, expID ...string) error {
+	expID = append([]string(nil), expID...) // caller's slice must not be modified
+
 	m.dataMu.Lock()
 	ids := m.users[key]
 	var bin []string
@@ -116,9 +233,146 @@ outer:
 	return nil
 }
 
-// del deletes id from both sessions and users maps.
+// DeleteCreatedBetween implements sessionup.RangeStore interface's
+// DeleteCreatedBetween method.
+func (m *MemStore) DeleteCreatedBetween(_ context.Context, key string, from, to time.Time) ([]sessionup.Session, error) {
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+
+	var ss []sessionup.Session
+	for _, id := range append([]string(nil), m.users[key]...) {
+		s := m.sessions[id]
+		if s.CreatedAt.Before(from) || !s.CreatedAt.Before(to) {
+			continue
+		}
+
+		m.del(id, key)
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// DeleteByOrgKey implements sessionup.OrgStore interface's DeleteByOrgKey method.
+func (m *MemStore) DeleteByOrgKey(_ context.Context, org string) error {
+	m.dataMu.Lock()
+	for _, id := range append([]string(nil), m.orgs[org]...) {
+		m.del(id, m.sessions[id].UserKey)
+	}
+	m.dataMu.Unlock()
+	return nil
+}
+
+// addOrg adds the session's ID to the organization index.
+// NOTE: should be enclosed with mutex locks when called.
+func (m *MemStore) addOrg(s sessionup.Session) {
+	if s.OrgKey == "" {
+		return
+	}
+
+	if m.orgs == nil {
+		m.orgs = make(map[string][]string)
+	}
+
+	m.orgs[s.OrgKey] = append(m.orgs[s.OrgKey], s.ID)
+}
+
+// blob holds data of a single BlobStore record.
+type blob struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// CreateBlob implements sessionup.BlobStore interface's CreateBlob method.
+func (m *MemStore) CreateBlob(_ context.Context, ref string, data []byte, exp time.Time) error {
+	m.dataMu.Lock()
+	if m.blobs == nil {
+		m.blobs = make(map[string]blob)
+	}
+
+	if _, ok := m.blobs[ref]; ok {
+		m.dataMu.Unlock()
+		return sessionup.ErrDuplicateID
+	}
+
+	m.blobs[ref] = blob{data: append([]byte(nil), data...), expiresAt: exp}
+	m.dataMu.Unlock()
+	return nil
+}
+
+// FetchBlob implements sessionup.BlobStore interface's FetchBlob method.
+func (m *MemStore) FetchBlob(_ context.Context, ref string) ([]byte, bool, error) {
+	m.dataMu.RLock()
+	b, ok := m.blobs[ref]
+	m.dataMu.RUnlock()
+	if !ok || !b.expiresAt.After(time.Now()) {
+		return nil, false, nil
+	}
+
+	return append([]byte(nil), b.data...), true, nil
+}
+
+// DeleteBlob implements sessionup.BlobStore interface's DeleteBlob method.
+func (m *MemStore) DeleteBlob(_ context.Context, ref string) error {
+	m.dataMu.Lock()
+	delete(m.blobs, ref)
+	m.dataMu.Unlock()
+	return nil
+}
+
+// ExtendBlob implements sessionup.BlobStore interface's ExtendBlob method.
+func (m *MemStore) ExtendBlob(_ context.Context, ref string, exp time.Time) error {
+	m.dataMu.Lock()
+	if b, ok := m.blobs[ref]; ok && b.expiresAt.After(time.Now()) {
+		b.expiresAt = exp
+		m.blobs[ref] = b
+	}
+
+	m.dataMu.Unlock()
+	return nil
+}
+
+// SetSignOut implements sessionup.SignOutStore interface's SetSignOut method.
+func (m *MemStore) SetSignOut(_ context.Context, mk sessionup.SignOutMarker) error {
+	m.dataMu.Lock()
+	if m.signOuts == nil {
+		m.signOuts = make(map[string]sessionup.SignOutMarker)
+	}
+
+	m.signOuts[mk.UserKey] = mk
+	m.dataMu.Unlock()
+	return nil
+}
+
+// FetchSignOut implements sessionup.SignOutStore interface's FetchSignOut method.
+func (m *MemStore) FetchSignOut(_ context.Context, key string) (sessionup.SignOutMarker, bool, error) {
+	m.dataMu.RLock()
+	mk, ok := m.signOuts[key]
+	m.dataMu.RUnlock()
+	if !ok || !mk.Pending(time.Now()) {
+		return sessionup.SignOutMarker{}, false, nil
+	}
+
+	return mk, true, nil
+}
+
+// del deletes id from sessions, users and organizations maps.
 // NOTE: should be enclosed with mutex locks when called.
 func (m *MemStore) del(id, key string) {
+	if org := m.sessions[id].OrgKey; org != "" {
+		ids := m.orgs[org]
+		for i, v := range ids {
+			if v == id {
+				m.orgs[org] = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+
+		if len(m.orgs[org]) == 0 {
+			delete(m.orgs, org)
+		}
+	}
+
 	ids := m.users[key]
 	c := len(ids)
 	for i, v := range ids {
@@ -128,10 +382,15 @@ func (m *MemStore) del(id, key string) {
 			break
 		}
 	}
+	delete(m.sessions, id)
 	if c == 0 {
+		// an empty set of sessions is always represented by 0.
 		delete(m.users, key)
+		delete(m.revs, key)
+		return
 	}
-	delete(m.sessions, id)
+
+	m.touch(key)
 }
 
 // deleteExpired deletes all expired sessions.
@@ -143,6 +402,18 @@ func (m *MemStore) deleteExpired() {
 			m.del(s.ID, s.UserKey)
 		}
 	}
+
+	for ref, b := range m.blobs {
+		if !b.expiresAt.After(t) {
+			delete(m.blobs, ref)
+		}
+	}
+
+	for key, mk := range m.signOuts {
+		if !mk.Pending(t) {
+			delete(m.signOuts, key)
+		}
+	}
 	m.dataMu.Unlock()
 }
 
@@ -177,3 +448,117 @@ func (m *MemStore) StopCleanup() {
 	}
 	m.stopMu.RUnlock()
 }
+
+// Usage returns the number of bytes that the non-expired sessions of
+// each user key would take once encoded with the provided codec, e.g.
+// to estimate the memory that a serializing store (such as Redis)
+// would need, or to find users whose metadata grows unexpectedly.
+func (m *MemStore) Usage(_ context.Context, c sessionup.Codec) (map[string]int, error) {
+	m.dataMu.RLock()
+	ss := make([]sessionup.Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		ss = append(ss, s)
+	}
+	m.dataMu.RUnlock()
+
+	usage := make(map[string]int)
+	for _, s := range sessionup.ExpiredFilter(ss, time.Now()) {
+		n, err := c.Size(s)
+		if err != nil {
+			return nil, err
+		}
+
+		usage[s.UserKey] += n
+	}
+
+	return usage, nil
+}
+
+// Snapshot holds a copy of all sessions and blobs of the store, taken
+// by MemStore's Snapshot method.
+type Snapshot struct {
+	sessions []sessionup.Session
+	blobs    map[string]blob
+}
+
+// Snapshot copies all sessions and blobs of the store (including the
+// expired ones that were not cleaned up yet), so that they can be put
+// back with Restore, e.g. to reset test fixtures between cases.
+func (m *MemStore) Snapshot(_ context.Context) (Snapshot, error) {
+	m.dataMu.RLock()
+	defer m.dataMu.RUnlock()
+
+	snap := Snapshot{
+		sessions: make([]sessionup.Session, 0, len(m.sessions)),
+		blobs:    make(map[string]blob, len(m.blobs)),
+	}
+
+	for _, s := range m.sessions {
+		snap.sessions = append(snap.sessions, copySession(s))
+	}
+
+	for ref, b := range m.blobs {
+		snap.blobs[ref] = blob{data: append([]byte(nil), b.data...), expiresAt: b.expiresAt}
+	}
+
+	return snap, nil
+}
+
+// Restore replaces all sessions and blobs of the store with the ones
+// held by the snapshot. The snapshot can be restored multiple times.
+func (m *MemStore) Restore(_ context.Context, snap Snapshot) error {
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+
+	keys := make([]string, 0, len(m.users))
+	for key := range m.users {
+		keys = append(keys, key)
+	}
+
+	m.sessions = make(map[string]sessionup.Session, len(snap.sessions))
+	m.users = make(map[string][]string)
+	m.orgs = make(map[string][]string)
+	m.blobs = make(map[string]blob, len(snap.blobs))
+
+	for _, key := range keys {
+		delete(m.revs, key)
+	}
+
+	for _, s := range snap.sessions {
+		m.users[s.UserKey] = append(m.users[s.UserKey], s.ID)
+		m.addOrg(s)
+		m.sessions[s.ID] = copySession(s)
+		m.touch(s.UserKey)
+	}
+
+	for ref, b := range snap.blobs {
+		m.blobs[ref] = blob{data: append([]byte(nil), b.data...), expiresAt: b.expiresAt}
+	}
+
+	return nil
+}
+
+// copySession copies the session with its metadata, so that the copy
+// can be modified independently.
+func copySession(s sessionup.Session) sessionup.Session {
+	if s.Meta != nil {
+		meta := make(map[string]string, len(s.Meta))
+		for k, v := range s.Meta {
+			meta[k] = v
+		}
+
+		s.Meta = meta
+	}
+
+	if s.Blobs != nil {
+		blobs := make(map[string]string, len(s.Blobs))
+		for k, v := range s.Blobs {
+			blobs[k] = v
+		}
+
+		s.Blobs = blobs
+	}
+
+	s.IP = append(net.IP(nil), s.IP...)
+	return s
+}
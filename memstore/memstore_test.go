@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/storetest"
 )
 
 func TestType(t *testing.T) {
@@ -16,6 +17,26 @@ func TestType(t *testing.T) {
 		}
 	}()
 	var _ sessionup.Store = &MemStore{}
+	var _ sessionup.BlobStore = &MemStore{}
+	var _ sessionup.UpdateStore = &MemStore{}
+	var _ sessionup.CASStore = &MemStore{}
+	var _ sessionup.ChangeCounterStore = &MemStore{}
+	var _ sessionup.OrgStore = &MemStore{}
+	var _ sessionup.IterStore = &MemStore{}
+	var _ sessionup.RangeStore = &MemStore{}
+	var _ sessionup.SignOutStore = &MemStore{}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunStoreTests(t, func() sessionup.Store {
+		return New(0)
+	})
+}
+
+func BenchmarkConformance(b *testing.B) {
+	storetest.RunStoreBenchmarks(b, func() sessionup.Store {
+		return New(0)
+	})
 }
 
 func TestNew(t *testing.T) {
@@ -154,6 +175,114 @@ func TestFetchByUserKey(t *testing.T) {
 	}
 }
 
+func TestUpdate(t *testing.T) {
+	m := MemStore{
+		sessions: make(map[string]sessionup.Session),
+		users:    make(map[string][]string),
+	}
+	m.users["key"] = []string{"id1", "id2"}
+	m.sessions["id1"] = sessionup.Session{ID: "id1", UserKey: "key", ExpiresAt: time.Now().Add(time.Hour)}
+	m.sessions["id2"] = sessionup.Session{ID: "id2", UserKey: "key"}
+
+	err := m.Update(context.Background(), sessionup.Session{ID: "id3", UserKey: "key"})
+	if err != sessionup.ErrNotFound {
+		t.Errorf("want %v, got %v", sessionup.ErrNotFound, err)
+	}
+
+	err = m.Update(context.Background(), sessionup.Session{ID: "id2", UserKey: "key"})
+	if err != sessionup.ErrNotFound {
+		t.Errorf("want %v, got %v", sessionup.ErrNotFound, err)
+	}
+
+	exp := sessionup.Session{
+		ID:        "id1",
+		UserKey:   "key",
+		ExpiresAt: m.sessions["id1"].ExpiresAt,
+		Meta:      map[string]string{"test": "1"},
+	}
+	if err = m.Update(context.Background(), exp); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if !reflect.DeepEqual(exp, m.sessions["id1"]) {
+		t.Errorf("want %v, got %v", exp, m.sessions["id1"])
+	}
+
+	exp.UserKey = "key1"
+	if err = m.Update(context.Background(), exp); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if !reflect.DeepEqual([]string{"id2"}, m.users["key"]) {
+		t.Errorf("want %v, got %v", []string{"id2"}, m.users["key"])
+	}
+
+	if !reflect.DeepEqual([]string{"id1"}, m.users["key1"]) {
+		t.Errorf("want %v, got %v", []string{"id1"}, m.users["key1"])
+	}
+}
+
+func TestIterate(t *testing.T) {
+	m := MemStore{
+		sessions: make(map[string]sessionup.Session),
+	}
+	m.sessions["id1"] = sessionup.Session{ID: "id1", ExpiresAt: time.Now().Add(time.Hour)}
+	m.sessions["id2"] = sessionup.Session{ID: "id2", ExpiresAt: time.Now().Add(-time.Hour)}
+
+	ids := make(map[string]bool)
+	err := m.Iterate(context.Background(), func(s sessionup.Session) bool {
+		ids[s.ID] = true
+		return true
+	})
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(ids) != 2 || !ids["id1"] || !ids["id2"] {
+		t.Errorf("want both sessions, got %v", ids)
+	}
+
+	var n int
+	m.Iterate(context.Background(), func(s sessionup.Session) bool {
+		n++
+		return false
+	})
+
+	if n != 1 {
+		t.Errorf("want %d, got %d", 1, n)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	m := MemStore{
+		sessions: make(map[string]sessionup.Session),
+		users:    make(map[string][]string),
+	}
+	m.users["key"] = []string{"id1"}
+	m.sessions["id1"] = sessionup.Session{ID: "id1", UserKey: "key", Version: 2, ExpiresAt: time.Now().Add(time.Hour)}
+
+	_, err := m.CompareAndSwap(context.Background(), 0, sessionup.Session{ID: "id2", UserKey: "key"})
+	if err != sessionup.ErrNotFound {
+		t.Errorf("want %v, got %v", sessionup.ErrNotFound, err)
+	}
+
+	exp := m.sessions["id1"]
+	exp.Version = 3
+	ok, err := m.CompareAndSwap(context.Background(), 1, exp)
+	if ok || err != nil {
+		t.Errorf("want false/nil, got %t/%v", ok, err)
+	}
+
+	ok, err = m.CompareAndSwap(context.Background(), 2, exp)
+	if !ok || err != nil {
+		t.Errorf("want true/nil, got %t/%v", ok, err)
+	}
+
+	if m.sessions["id1"].Version != 3 {
+		t.Errorf("want %d, got %d", 3, m.sessions["id1"].Version)
+	}
+}
+
 func TestDeleteByID(t *testing.T) {
 	m := MemStore{
 		sessions: make(map[string]sessionup.Session),
@@ -239,6 +368,140 @@ func TestDeleteByUserKey(t *testing.T) {
 	}
 }
 
+func TestDeleteCreatedBetween(t *testing.T) {
+	now := time.Now()
+	m := New(0)
+	for i, id := range []string{"id1", "id2", "id3"} {
+		s := sessionup.Session{ID: id, UserKey: "key", CreatedAt: now.Add(time.Hour * time.Duration(i))}
+		if err := m.Create(context.Background(), s); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+	}
+
+	ss, err := m.DeleteCreatedBetween(context.Background(), "key", now.Add(time.Hour), now.Add(time.Hour*2))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(ss) != 1 || ss[0].ID != "id2" {
+		t.Errorf("want %q, got %v", "id2", ss)
+	}
+
+	if _, ok := m.sessions["id2"]; ok {
+		t.Error("want id2 deleted")
+	}
+
+	if len(m.sessions) != 2 || len(m.users["key"]) != 2 {
+		t.Errorf("want %d, got %d", 2, len(m.sessions))
+	}
+
+	if ss, _ = m.DeleteCreatedBetween(context.Background(), "other", now, now.Add(time.Hour*3)); ss != nil {
+		t.Errorf("want nil, got %v", ss)
+	}
+}
+
+func TestBlob(t *testing.T) {
+	m := MemStore{}
+	ctx := context.Background()
+	exp := []byte("data")
+
+	err := m.CreateBlob(ctx, "ref", exp, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	err = m.CreateBlob(ctx, "ref", exp, time.Now().Add(time.Hour))
+	if err != sessionup.ErrDuplicateID {
+		t.Errorf("want %v, got %v", sessionup.ErrDuplicateID, err)
+	}
+
+	err = m.CreateBlob(ctx, "ref1", exp, time.Now())
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	data, ok, err := m.FetchBlob(ctx, "ref")
+	if err != nil || !ok {
+		t.Fatalf("want nil/true, got %v/%t", err, ok)
+	}
+
+	if !reflect.DeepEqual(exp, data) {
+		t.Errorf("want %q, got %q", exp, data)
+	}
+
+	_, ok, err = m.FetchBlob(ctx, "ref1")
+	if err != nil || ok {
+		t.Errorf("want nil/false, got %v/%t", err, ok)
+	}
+
+	// expired blobs are not brought back.
+	if err = m.ExtendBlob(ctx, "ref1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, _ = m.FetchBlob(ctx, "ref1"); ok {
+		t.Errorf("want %t, got %t", false, ok)
+	}
+
+	if err = m.CreateBlob(ctx, "ref2", exp, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err = m.ExtendBlob(ctx, "ref2", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, _ = m.FetchBlob(ctx, "ref2"); ok {
+		t.Errorf("want %t, got %t", false, ok)
+	}
+
+	if err = m.ExtendBlob(ctx, "missing", time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if err = m.DeleteBlob(ctx, "ref"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	_, ok, _ = m.FetchBlob(ctx, "ref")
+	if ok {
+		t.Errorf("want %t, got %t", false, ok)
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	m := MemStore{}
+	ctx := context.Background()
+	now := time.Now()
+	exp := sessionup.SignOutMarker{UserKey: "key", At: now, ExpiresAt: now.Add(time.Hour)}
+
+	if err := m.SetSignOut(ctx, exp); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err := m.SetSignOut(ctx, sessionup.SignOutMarker{UserKey: "key1", At: now, ExpiresAt: now}); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	mk, ok, err := m.FetchSignOut(ctx, "key")
+	if err != nil || !ok {
+		t.Fatalf("want nil/true, got %v/%t", err, ok)
+	}
+
+	if !reflect.DeepEqual(exp, mk) {
+		t.Errorf("want %v, got %v", exp, mk)
+	}
+
+	if _, ok, err = m.FetchSignOut(ctx, "key1"); err != nil || ok {
+		t.Errorf("want nil/false, got %v/%t", err, ok)
+	}
+
+	m.deleteExpired()
+	if len(m.signOuts) != 1 {
+		t.Errorf("want %d, got %d", 1, len(m.signOuts))
+	}
+}
+
 func TestDel(t *testing.T) {
 	m := MemStore{
 		sessions: make(map[string]sessionup.Session),
@@ -279,11 +542,19 @@ func TestDeleteExpired(t *testing.T) {
 	m.sessions["id1"] = sessionup.Session{ID: "id1", UserKey: "key"}
 	m.sessions["id2"] = sessionup.Session{ID: "id2", UserKey: "key"}
 	m.sessions["id3"] = sessionup.Session{ID: "id2", UserKey: "key", ExpiresAt: time.Now().Add(time.Hour)}
+	m.blobs = map[string]blob{
+		"ref1": {},
+		"ref2": {expiresAt: time.Now().Add(time.Hour)},
+	}
 	m.deleteExpired()
 	if len(m.sessions) != 1 {
 		t.Errorf("want %d, got %d", 1, len(m.sessions))
 	}
 
+	if len(m.blobs) != 1 {
+		t.Errorf("want %d, got %d", 1, len(m.blobs))
+	}
+
 	if len(m.users["key"]) != 1 {
 		t.Errorf("want %d, got %d", 1, len(m.users["key"]))
 	}
@@ -308,3 +579,120 @@ func TestStartCleanup(t *testing.T) {
 		t.Errorf("want %d, got %d", 0, len(m.users))
 	}
 }
+
+func TestUsage(t *testing.T) {
+	ctx := context.Background()
+	m := New(0)
+	m.Create(ctx, sessionup.Session{ID: "id1", UserKey: "key1", ExpiresAt: time.Now().Add(time.Hour)})
+	m.Create(ctx, sessionup.Session{ID: "id2", UserKey: "key1", ExpiresAt: time.Now().Add(time.Hour), Meta: map[string]string{"k": "v"}})
+	m.Create(ctx, sessionup.Session{ID: "id3", UserKey: "key2", ExpiresAt: time.Now().Add(time.Hour)})
+	m.Create(ctx, sessionup.Session{ID: "id4", UserKey: "key3", ExpiresAt: time.Now()})
+
+	c := sessionup.Codec{}
+	usage, err := m.Usage(ctx, c)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	want := make(map[string]int)
+	for _, id := range []string{"id1", "id2", "id3"} {
+		s, _, _ := m.FetchByID(ctx, id)
+		n, _ := c.Size(s)
+		want[s.UserKey] += n
+	}
+
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("want %v, got %v", want, usage)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	m := New(0)
+	m.Create(ctx, sessionup.Session{ID: "id1", UserKey: "key1", OrgKey: "org", ExpiresAt: time.Now().Add(time.Hour), Meta: map[string]string{"k": "v"}})
+	m.Create(ctx, sessionup.Session{ID: "id2", UserKey: "key1", ExpiresAt: time.Now().Add(time.Hour)})
+	m.CreateBlob(ctx, "ref", []byte("data"), time.Now().Add(time.Hour))
+
+	snap, err := m.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	rev, _ := m.ChangeCounter(ctx, "key1")
+
+	m.DeleteByOrgKey(ctx, "org")
+	m.DeleteBlob(ctx, "ref")
+	m.Create(ctx, sessionup.Session{ID: "id3", UserKey: "key2", ExpiresAt: time.Now().Add(time.Hour)})
+	s, _, _ := m.FetchByID(ctx, "id2")
+	s.Meta = map[string]string{"changed": "true"}
+	m.Update(ctx, s)
+
+	for i := 0; i < 2; i++ {
+		if err = m.Restore(ctx, snap); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		ss, _ := m.FetchByUserKey(ctx, "key1")
+		if len(ss) != 2 {
+			t.Errorf("want %d, got %d", 2, len(ss))
+		}
+
+		if s, _, _ := m.FetchByID(ctx, "id1"); s.Meta["k"] != "v" {
+			t.Errorf("want %q, got %q", "v", s.Meta["k"])
+		}
+
+		if s, _, _ := m.FetchByID(ctx, "id2"); s.Meta != nil {
+			t.Errorf("want nil, got %v", s.Meta)
+		}
+
+		if _, ok, _ := m.FetchByID(ctx, "id3"); ok {
+			t.Error("want id3 deleted")
+		}
+
+		if data, ok, _ := m.FetchBlob(ctx, "ref"); !ok || string(data) != "data" {
+			t.Errorf("want %q, got %q", "data", data)
+		}
+
+		if n, _ := m.ChangeCounter(ctx, "key1"); n == rev {
+			t.Error("want changed counter")
+		}
+
+		if n, _ := m.ChangeCounter(ctx, "key2"); n != 0 {
+			t.Errorf("want %d, got %d", 0, n)
+		}
+
+		// restored sessions must not share metadata with the snapshot.
+		s, _, _ := m.FetchByID(ctx, "id1")
+		s.Meta["k"] = "changed"
+		m.DeleteByOrgKey(ctx, "org")
+	}
+}
+
+func TestStoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	m := New(0)
+	m.Create(ctx, storetest.NewSession("id1", "key"))
+	m.Create(ctx, storetest.NewSession("id2", "key"))
+
+	snap, err := storetest.Snapshot(ctx, m)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	m.DeleteByID(ctx, "id1")
+	m.Create(ctx, storetest.NewSession("id3", "key"))
+
+	if err = storetest.Restore(ctx, m, snap); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	ss, _ := m.FetchByUserKey(ctx, "key")
+	sessionup.SortSessions(ss)
+	if len(ss) != 2 || ss[0].ID != "id1" || ss[1].ID != "id2" {
+		t.Errorf("want id1 and id2, got %v", ss)
+	}
+
+	if _, err = storetest.Snapshot(ctx, struct{ sessionup.Store }{m}); err != sessionup.ErrNotSupported {
+		t.Errorf("want %v, got %v", sessionup.ErrNotSupported, err)
+	}
+}
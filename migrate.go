@@ -0,0 +1,74 @@
+package sessionup
+
+import (
+	"net/http"
+	"time"
+)
+
+// OldCookieNames sets the names of cookies that were previously used to
+// hold session IDs. During the migration window, Auth and Public
+// middlewares accept sessions from cookies with old names (when the
+// cookie with the current name is missing) and, once the session is
+// validated, transparently re-issue it under the current name and clear
+// the old cookie, so that renaming the cookie does not log users out.
+// By default it is not set.
+func OldCookieNames(nn ...string) Option {
+	return func(m *Manager) {
+		for _, n := range nn {
+			if !validCookieName(n) {
+				m.invalid("OldCookieNames", ErrInvalidCookieName)
+			}
+		}
+
+		m.cookie.oldNames = nn
+	}
+}
+
+// sessionCookie retrieves the session cookie from the request, falling
+// back to the cookies with old names. The name of the old cookie is
+// returned if it was used.
+func (m *Manager) sessionCookie(r *http.Request) (*http.Cookie, string, error) {
+	c, err := r.Cookie(m.cookie.name)
+	if err == nil {
+		return c, "", nil
+	}
+
+	for _, n := range m.cookie.oldNames {
+		if oc, oerr := r.Cookie(n); oerr == nil {
+			return oc, n, nil
+		}
+	}
+
+	return nil, "", err
+}
+
+// migrateCookie re-issues the session cookie under the current name and
+// clears the cookie with the old name.
+func (m *Manager) migrateCookie(w http.ResponseWriter, s Session, old string) {
+	exp := m.sessionCookieExpiry(s.UserKey, s.ExpiresAt)
+
+	if err := m.setSessionCookie(w, exp, s); err != nil {
+		return
+	}
+
+	m.expireCookie(w, old)
+
+	if m.csrfActive() {
+		m.setCSRFCookie(w, exp, s.CSRFToken)
+		m.expireCookie(w, old+csrfCookieSuffix)
+	}
+}
+
+// expireCookie overrides the cookie with the provided name with values
+// that would require the client to delete it immediately.
+func (m *Manager) expireCookie(w http.ResponseWriter, name string) {
+	m.writeCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     m.cookie.path,
+		Domain:   m.cookie.domain,
+		Expires:  time.Unix(1, 0),
+		Secure:   m.cookie.secure,
+		HttpOnly: m.cookie.httpOnly,
+		SameSite: m.cookie.sameSite,
+	})
+}
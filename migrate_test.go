@@ -0,0 +1,113 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOldCookieNames(t *testing.T) {
+	m := Manager{}
+	val := []string{"old", "older"}
+	OldCookieNames(val...)(&m)
+	if !reflect.DeepEqual(val, m.cookie.oldNames) {
+		t.Errorf("want %v, got %v", val, m.cookie.oldNames)
+	}
+}
+
+func TestCookieMigration(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	s := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id, UserKey: "key", ExpiresAt: exp, CSRFToken: "token"}, id == "id", nil
+		},
+	}
+
+	cc := map[string]struct {
+		Cookies   []*http.Cookie
+		ExpiresIn time.Duration
+		SameSite  http.SameSite
+		Code      int
+		Set       map[string]string
+		Expires   time.Time
+	}{
+		"No cookies": {
+			Code: http.StatusUnauthorized,
+		},
+		"Current cookie": {
+			Cookies: []*http.Cookie{{Name: "new", Value: "id"}},
+			Code:    http.StatusNoContent,
+		},
+		"Current cookie preferred over old one": {
+			Cookies: []*http.Cookie{{Name: "old", Value: "id1"}, {Name: "new", Value: "id"}},
+			Code:    http.StatusNoContent,
+		},
+		"Invalid session in old cookie": {
+			Cookies: []*http.Cookie{{Name: "old", Value: "id1"}},
+			Code:    http.StatusUnauthorized,
+		},
+		"Temporary session migrated": {
+			Cookies: []*http.Cookie{{Name: "older", Value: "id"}},
+			Code:    http.StatusNoContent,
+			Set:     map[string]string{"new": "id", "older": ""},
+		},
+		"Persistent session migrated": {
+			Cookies:   []*http.Cookie{{Name: "old", Value: "id"}},
+			ExpiresIn: time.Hour,
+			Code:      http.StatusNoContent,
+			Set:       map[string]string{"new": "id", "old": ""},
+			Expires:   exp,
+		},
+		"Session with CSRF token migrated": {
+			Cookies:  []*http.Cookie{{Name: "old", Value: "id"}},
+			SameSite: http.SameSiteNoneMode,
+			Code:     http.StatusNoContent,
+			Set:      map[string]string{"new": "id", "old": "", "new_csrf": "token", "old_csrf": ""},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := NewManager(s, CookieName("new"), OldCookieNames("old", "older"), ExpiresIn(c.ExpiresIn),
+				CheckOrigin(false))
+			if c.SameSite != 0 {
+				SameSite(c.SameSite)(m)
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			for _, ck := range c.Cookies {
+				req.AddCookie(ck)
+			}
+
+			m.Auth(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})).ServeHTTP(rec, req)
+
+			if rec.Code != c.Code {
+				t.Errorf("want %d, got %d", c.Code, rec.Code)
+			}
+
+			set := make(map[string]string)
+			for _, ck := range rec.Result().Cookies() {
+				set[ck.Name] = ck.Value
+				if ck.Name == "new" && !ck.Expires.Equal(c.Expires) {
+					t.Errorf("want %v, got %v", c.Expires, ck.Expires)
+				}
+			}
+
+			if len(c.Set) == 0 {
+				c.Set = map[string]string{}
+			}
+
+			if !reflect.DeepEqual(c.Set, set) {
+				t.Errorf("want %v, got %v", c.Set, set)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package mirrorstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+const defaultMaxDetails = 100
+
+// DifferenceKind determines how a session differs between the stores.
+type DifferenceKind int
+
+const (
+	// DifferenceMissing means that the session exists only in the
+	// primary store.
+	DifferenceMissing DifferenceKind = iota
+
+	// DifferenceExtra means that the session exists only in the
+	// secondary store.
+	DifferenceExtra
+
+	// DifferenceMismatch means that the session exists in both stores,
+	// but its fields differ.
+	DifferenceMismatch
+)
+
+// String returns the name of the difference kind.
+func (k DifferenceKind) String() string {
+	switch k {
+	case DifferenceMissing:
+		return "missing"
+	case DifferenceExtra:
+		return "extra"
+	case DifferenceMismatch:
+		return "mismatch"
+	}
+
+	return "unknown"
+}
+
+// CompareConfig holds the data needed to compare stores.
+type CompareConfig struct {
+	// MaxDetails specifies the maximum number of differences whose
+	// details are included in the report; the rest are only counted,
+	// so that large divergences do not flood the output.
+	// Defaults to the value stored in defaultMaxDetails.
+	MaxDetails int
+
+	// Equal specifies the function used to compare sessions retrieved
+	// from both stores. Defaults to the Equal function.
+	Equal func(a, b sessionup.Session) bool
+}
+
+// Difference holds the data of a session that differs between the
+// stores.
+type Difference struct {
+	// Kind specifies how the session differs.
+	Kind DifferenceKind
+
+	// ID specifies the session's ID.
+	ID string
+
+	// Primary specifies the session retrieved from the primary store.
+	// It is empty if the kind is DifferenceExtra.
+	Primary sessionup.Session
+
+	// Secondary specifies the session retrieved from the secondary
+	// store. It is empty if the kind is DifferenceMissing.
+	Secondary sessionup.Session
+}
+
+// Report holds the result of a comparison of two stores.
+type Report struct {
+	// Checked specifies the number of distinct sessions found in
+	// either of the stores.
+	Checked int
+
+	// Missing specifies the number of sessions that exist only in the
+	// primary store.
+	Missing int
+
+	// Extra specifies the number of sessions that exist only in the
+	// secondary store.
+	Extra int
+
+	// Mismatched specifies the number of sessions whose fields differ.
+	Mismatched int
+
+	// Details holds the differences of the sessions with the lowest
+	// IDs (at most CompareConfig's MaxDetails), ordered by session ID.
+	Details []Difference
+}
+
+// Converged checks whether the stores hold the same sessions.
+func (r Report) Converged() bool {
+	return r.Missing == 0 && r.Extra == 0 && r.Mismatched == 0
+}
+
+// Compare iterates over all sessions of both stores and reports the
+// ones that are missing from the secondary store, exist only in it or
+// differ, so that operators can confirm that a dual-write migration or
+// mirroring converged before switching to the secondary store.
+// Sessions that are expired at the time of the comparison are skipped,
+// since stores clean them up at different times. The sessions of the
+// secondary store are held in memory during the comparison.
+// Both stores must implement sessionup.IterStore interface, otherwise
+// sessionup.ErrNotSupported is returned.
+func Compare(ctx context.Context, primary, secondary sessionup.Store, cfg CompareConfig) (Report, error) {
+	if cfg.MaxDetails <= 0 {
+		cfg.MaxDetails = defaultMaxDetails
+	}
+
+	if cfg.Equal == nil {
+		cfg.Equal = Equal
+	}
+
+	pi, ok := primary.(sessionup.IterStore)
+	if !ok {
+		return Report{}, sessionup.ErrNotSupported
+	}
+
+	si, ok := secondary.(sessionup.IterStore)
+	if !ok {
+		return Report{}, sessionup.ErrNotSupported
+	}
+
+	now := time.Now()
+	others := make(map[string]sessionup.Session)
+
+	err := si.Iterate(ctx, func(s sessionup.Session) bool {
+		if !sessionup.IsExpired(s, now) {
+			others[s.ID] = s
+		}
+
+		return true
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	var (
+		rep Report
+		dd  []Difference
+	)
+
+	// add keeps the differences with the lowest IDs, so that the
+	// details do not depend on the stores' iteration order.
+	add := func(d Difference) {
+		i := sort.Search(len(dd), func(i int) bool { return dd[i].ID > d.ID })
+		if i >= cfg.MaxDetails {
+			return
+		}
+
+		if len(dd) < cfg.MaxDetails {
+			dd = append(dd, Difference{})
+		}
+
+		copy(dd[i+1:], dd[i:])
+		dd[i] = d
+	}
+
+	err = pi.Iterate(ctx, func(s sessionup.Session) bool {
+		if sessionup.IsExpired(s, now) {
+			return true
+		}
+
+		rep.Checked++
+
+		o, ok := others[s.ID]
+		delete(others, s.ID)
+
+		switch {
+		case !ok:
+			rep.Missing++
+			add(Difference{Kind: DifferenceMissing, ID: s.ID, Primary: s})
+		case !cfg.Equal(s, o):
+			rep.Mismatched++
+			add(Difference{Kind: DifferenceMismatch, ID: s.ID, Primary: s, Secondary: o})
+		}
+
+		return true
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	for id, o := range others {
+		rep.Checked++
+		rep.Extra++
+		add(Difference{Kind: DifferenceExtra, ID: id, Secondary: o})
+	}
+
+	rep.Details = dd
+	return rep, nil
+}
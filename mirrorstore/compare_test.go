@@ -0,0 +1,84 @@
+package mirrorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/memstore"
+)
+
+// failingIterStore is a sessionup.IterStore whose iteration fails.
+type failingIterStore struct {
+	sessionup.Store
+}
+
+func (failingIterStore) Iterate(_ context.Context, _ func(sessionup.Session) bool) error {
+	return errors.New("error")
+}
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour)
+	primary, secondary := memstore.New(0), memstore.New(0)
+
+	for _, id := range []string{"same", "missing1", "missing2", "changed"} {
+		primary.Create(ctx, sessionup.Session{ID: id, UserKey: "key", ExpiresAt: exp})
+	}
+
+	for _, id := range []string{"same", "extra"} {
+		secondary.Create(ctx, sessionup.Session{ID: id, UserKey: "key", ExpiresAt: exp})
+	}
+
+	secondary.Create(ctx, sessionup.Session{ID: "changed", UserKey: "other", ExpiresAt: exp})
+	primary.Create(ctx, sessionup.Session{ID: "expired", UserKey: "key", ExpiresAt: time.Now()})
+
+	rep, err := Compare(ctx, primary, secondary, CompareConfig{MaxDetails: 3})
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if rep.Converged() {
+		t.Error("want false, got true")
+	}
+
+	if rep.Checked != 5 || rep.Missing != 2 || rep.Extra != 1 || rep.Mismatched != 1 {
+		t.Errorf("want 5/2/1/1, got %d/%d/%d/%d", rep.Checked, rep.Missing, rep.Extra, rep.Mismatched)
+	}
+
+	var details []string
+	for _, d := range rep.Details {
+		details = append(details, fmt.Sprintf("%s %s", d.Kind, d.ID))
+	}
+
+	if want := []string{"mismatch changed", "extra extra", "missing missing1"}; !reflect.DeepEqual(details, want) {
+		t.Errorf("want %v, got %v", want, details)
+	}
+
+	if rep.Details[0].Primary.UserKey != "key" || rep.Details[0].Secondary.UserKey != "other" {
+		t.Errorf("want both sessions, got %v", rep.Details[0])
+	}
+
+	rep, err = Compare(ctx, primary, primary, CompareConfig{})
+	if err != nil || !rep.Converged() || rep.Checked != 4 {
+		t.Errorf("want converged report, got %v and %v", rep, err)
+	}
+
+	if _, err = Compare(ctx, failingStore{}, secondary, CompareConfig{}); err != sessionup.ErrNotSupported {
+		t.Errorf("want %v, got %v", sessionup.ErrNotSupported, err)
+	}
+
+	if _, err = Compare(ctx, primary, failingStore{}, CompareConfig{}); err != sessionup.ErrNotSupported {
+		t.Errorf("want %v, got %v", sessionup.ErrNotSupported, err)
+	}
+
+	for _, pair := range [][2]sessionup.Store{{failingIterStore{}, secondary}, {primary, failingIterStore{}}} {
+		if _, err = Compare(ctx, pair[0], pair[1], CompareConfig{}); err == nil {
+			t.Error("want non-nil, got nil")
+		}
+	}
+}
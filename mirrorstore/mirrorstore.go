@@ -0,0 +1,535 @@
+// Package mirrorstore provides a sessionup.Store decorator that mirrors
+// operations to a secondary store, so that a new backend can be
+// evaluated against production traffic before switching to it.
+package mirrorstore
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+const (
+	defaultQueueSize = 1024
+	defaultTimeout   = time.Second
+)
+
+// Config holds the data needed to mirror operations.
+type Config struct {
+	// Percent specifies the share (0-100) of sessions whose
+	// operations are mirrored. Sessions are selected by hashing their
+	// IDs (and lookups by user key by hashing the key), so each
+	// selected session is mirrored during its whole lifetime.
+	// Deletions by user or organization key are always mirrored, so
+	// that the secondary store never keeps revoked sessions.
+	Percent float64
+
+	// QueueSize specifies the maximum number of mirrored operations
+	// waiting to be sent to the secondary store. Operations that do not
+	// fit are dropped, so the primary store is never slowed down.
+	// Defaults to the value stored in defaultQueueSize.
+	QueueSize int
+
+	// Timeout specifies the time limit of each mirrored operation.
+	// Defaults to the value stored in defaultTimeout.
+	Timeout time.Duration
+
+	// Equal specifies the function used to compare sessions retrieved
+	// from both stores. Defaults to the Equal function.
+	Equal func(a, b sessionup.Session) bool
+
+	// OnDivergence specifies the function that is called when the
+	// secondary store fails or returns a result different from the
+	// primary's. It is called from the mirroring goroutine.
+	// By default it is not set.
+	OnDivergence func(d Divergence)
+}
+
+// Divergence holds the data of a mirrored operation whose outcome
+// differs between the stores.
+type Divergence struct {
+	// Op specifies the name of the operation, e.g. "FetchByID".
+	Op string
+
+	// Key specifies the session ID, user key or other key the operation
+	// was called with.
+	Key string
+
+	// Err specifies the error returned by the secondary store, if any.
+	Err error
+}
+
+// Stats holds the counters of mirrored operations.
+type Stats struct {
+	// Mirrored specifies the number of operations sent to the
+	// secondary store.
+	Mirrored uint64
+
+	// Failed specifies the number of mirrored operations that returned
+	// an error.
+	Failed uint64
+
+	// Diverged specifies the number of mirrored reads whose results
+	// differ from the primary's.
+	Diverged uint64
+
+	// Dropped specifies the number of operations that were not
+	// mirrored because the queue was full.
+	Dropped uint64
+}
+
+// MirrorStore is a sessionup.Store implementation that serves all
+// operations from the primary store and asynchronously repeats a share
+// of them on the secondary store, comparing the results of reads.
+// Optional capabilities are served by the primary store; their writes
+// are mirrored only if the secondary store supports them as well.
+type MirrorStore struct {
+	// counters are kept first to be 64-bit aligned for atomic
+	// operations on 32-bit platforms.
+	mirrored uint64
+	failed   uint64
+	diverged uint64
+	dropped  uint64
+
+	primary   sessionup.Store
+	secondary sessionup.Store
+	cfg       Config
+
+	queue   chan func(context.Context)
+	done    chan struct{}
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// New returns a fresh instance of MirrorStore and starts the goroutine
+// that mirrors operations to the secondary store. Close must be called
+// to stop it.
+func New(primary, secondary sessionup.Store, cfg Config) *MirrorStore {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	if cfg.Equal == nil {
+		cfg.Equal = Equal
+	}
+
+	m := &MirrorStore{
+		primary:   primary,
+		secondary: secondary,
+		cfg:       cfg,
+		queue:     make(chan func(context.Context), cfg.QueueSize),
+		done:      make(chan struct{}),
+	}
+
+	go m.run()
+
+	return m
+}
+
+// Create implements sessionup.Store interface's Create method.
+func (m *MirrorStore) Create(ctx context.Context, s sessionup.Session) error {
+	if err := m.primary.Create(ctx, s); err != nil {
+		return err
+	}
+
+	m.mirrorWrite("Create", s.ID, true, func(ctx context.Context) error {
+		return m.secondary.Create(ctx, s)
+	})
+
+	return nil
+}
+
+// FetchByID implements sessionup.Store interface's FetchByID method.
+func (m *MirrorStore) FetchByID(ctx context.Context, id string) (sessionup.Session, bool, error) {
+	s, ok, err := m.primary.FetchByID(ctx, id)
+	if err != nil || !m.sampled(id) {
+		return s, ok, err
+	}
+
+	m.enqueue(func(ctx context.Context) {
+		ms, mok, err := m.secondary.FetchByID(ctx, id)
+		m.compare("FetchByID", id, err, ok == mok && (!ok || m.cfg.Equal(s, ms)))
+	})
+
+	return s, ok, nil
+}
+
+// FetchByUserKey implements sessionup.Store interface's FetchByUserKey
+// method. Only the sessions selected for mirroring are compared.
+func (m *MirrorStore) FetchByUserKey(ctx context.Context, key string) ([]sessionup.Session, error) {
+	ss, err := m.primary.FetchByUserKey(ctx, key)
+	if err != nil || !m.sampled(key) {
+		return ss, err
+	}
+
+	var exp []sessionup.Session
+	for _, s := range ss {
+		if m.sampled(s.ID) {
+			exp = append(exp, s)
+		}
+	}
+
+	m.enqueue(func(ctx context.Context) {
+		ms, err := m.secondary.FetchByUserKey(ctx, key)
+		m.compare("FetchByUserKey", key, err, m.equalAll(exp, ms))
+	})
+
+	return ss, nil
+}
+
+// DeleteByID implements sessionup.Store interface's DeleteByID method.
+func (m *MirrorStore) DeleteByID(ctx context.Context, id string) error {
+	if err := m.primary.DeleteByID(ctx, id); err != nil {
+		return err
+	}
+
+	m.mirrorWrite("DeleteByID", id, true, func(ctx context.Context) error {
+		return ignoreNotFound(m.secondary.DeleteByID(ctx, id))
+	})
+
+	return nil
+}
+
+// DeleteByUserKey implements sessionup.Store interface's DeleteByUserKey method.
+func (m *MirrorStore) DeleteByUserKey(ctx context.Context, key string, expID ...string) error {
+	if err := m.primary.DeleteByUserKey(ctx, key, expID...); err != nil {
+		return err
+	}
+
+	expID = append([]string(nil), expID...)
+	m.mirrorWrite("DeleteByUserKey", key, false, func(ctx context.Context) error {
+		return ignoreNotFound(m.secondary.DeleteByUserKey(ctx, key, expID...))
+	})
+
+	return nil
+}
+
+// DeleteByOrgKey implements sessionup.OrgStore interface's DeleteByOrgKey method.
+func (m *MirrorStore) DeleteByOrgKey(ctx context.Context, org string) error {
+	ps, ok := m.primary.(sessionup.OrgStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	if err := ps.DeleteByOrgKey(ctx, org); err != nil {
+		return err
+	}
+
+	if ss, ok := m.secondary.(sessionup.OrgStore); ok {
+		m.mirrorWrite("DeleteByOrgKey", org, false, func(ctx context.Context) error {
+			return ignoreNotFound(ss.DeleteByOrgKey(ctx, org))
+		})
+	}
+
+	return nil
+}
+
+// Update implements sessionup.UpdateStore interface's Update method.
+func (m *MirrorStore) Update(ctx context.Context, s sessionup.Session) error {
+	ps, ok := m.primary.(sessionup.UpdateStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	if err := ps.Update(ctx, s); err != nil {
+		return err
+	}
+
+	m.mirrorUpdate(s)
+	return nil
+}
+
+// CompareAndSwap implements sessionup.CASStore interface's
+// CompareAndSwap method. Successful swaps are mirrored as updates.
+func (m *MirrorStore) CompareAndSwap(ctx context.Context, old uint64, s sessionup.Session) (bool, error) {
+	ps, ok := m.primary.(sessionup.CASStore)
+	if !ok {
+		return false, sessionup.ErrNotSupported
+	}
+
+	swapped, err := ps.CompareAndSwap(ctx, old, s)
+	if err == nil && swapped {
+		m.mirrorUpdate(s)
+	}
+
+	return swapped, err
+}
+
+// CreateBlob implements sessionup.BlobStore interface's CreateBlob method.
+func (m *MirrorStore) CreateBlob(ctx context.Context, ref string, data []byte, exp time.Time) error {
+	ps, ok := m.primary.(sessionup.BlobStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	if err := ps.CreateBlob(ctx, ref, data, exp); err != nil {
+		return err
+	}
+
+	if ss, ok := m.secondary.(sessionup.BlobStore); ok {
+		m.mirrorWrite("CreateBlob", ref, true, func(ctx context.Context) error {
+			return ss.CreateBlob(ctx, ref, data, exp)
+		})
+	}
+
+	return nil
+}
+
+// FetchBlob implements sessionup.BlobStore interface's FetchBlob method.
+func (m *MirrorStore) FetchBlob(ctx context.Context, ref string) ([]byte, bool, error) {
+	ps, ok := m.primary.(sessionup.BlobStore)
+	if !ok {
+		return nil, false, sessionup.ErrNotSupported
+	}
+
+	return ps.FetchBlob(ctx, ref)
+}
+
+// DeleteBlob implements sessionup.BlobStore interface's DeleteBlob method.
+func (m *MirrorStore) DeleteBlob(ctx context.Context, ref string) error {
+	ps, ok := m.primary.(sessionup.BlobStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	if err := ps.DeleteBlob(ctx, ref); err != nil {
+		return err
+	}
+
+	if ss, ok := m.secondary.(sessionup.BlobStore); ok {
+		m.mirrorWrite("DeleteBlob", ref, true, func(ctx context.Context) error {
+			return ignoreNotFound(ss.DeleteBlob(ctx, ref))
+		})
+	}
+
+	return nil
+}
+
+// ExtendBlob implements sessionup.BlobStore interface's ExtendBlob method.
+func (m *MirrorStore) ExtendBlob(ctx context.Context, ref string, exp time.Time) error {
+	ps, ok := m.primary.(sessionup.BlobStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	if err := ps.ExtendBlob(ctx, ref, exp); err != nil {
+		return err
+	}
+
+	if ss, ok := m.secondary.(sessionup.BlobStore); ok {
+		m.mirrorWrite("ExtendBlob", ref, true, func(ctx context.Context) error {
+			return ignoreNotFound(ss.ExtendBlob(ctx, ref, exp))
+		})
+	}
+
+	return nil
+}
+
+// ChangeCounter implements sessionup.ChangeCounterStore interface's
+// ChangeCounter method. It is never mirrored, since counters of
+// different stores are not comparable.
+func (m *MirrorStore) ChangeCounter(ctx context.Context, key string) (uint64, error) {
+	ps, ok := m.primary.(sessionup.ChangeCounterStore)
+	if !ok {
+		return 0, sessionup.ErrNotSupported
+	}
+
+	return ps.ChangeCounter(ctx, key)
+}
+
+// Stats returns the current values of the mirroring counters.
+func (m *MirrorStore) Stats() Stats {
+	return Stats{
+		Mirrored: atomic.LoadUint64(&m.mirrored),
+		Failed:   atomic.LoadUint64(&m.failed),
+		Diverged: atomic.LoadUint64(&m.diverged),
+		Dropped:  atomic.LoadUint64(&m.dropped),
+	}
+}
+
+// Close stops accepting new operations for mirroring and waits until
+// the queued ones are sent to the secondary store. The primary store
+// keeps serving operations after Close.
+func (m *MirrorStore) Close() {
+	m.closeMu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.closeMu.Unlock()
+
+	<-m.done
+}
+
+// Equal is the default session comparison function. It compares all
+// stored fields of the sessions, tolerating differences of time fields
+// below a millisecond (as many databases truncate timestamps).
+func Equal(a, b sessionup.Session) bool {
+	a.CreatedAt, b.CreatedAt = truncate(a.CreatedAt, b.CreatedAt)
+	a.ExpiresAt, b.ExpiresAt = truncate(a.ExpiresAt, b.ExpiresAt)
+	a.CookieExpiresAt, b.CookieExpiresAt = truncate(a.CookieExpiresAt, b.CookieExpiresAt)
+	a.Current, b.Current = false, false
+	a.Risk, b.Risk = 0, 0
+	a.Fingerprint, b.Fingerprint = sessionup.FingerprintResult{}, sessionup.FingerprintResult{}
+
+	if !a.IP.Equal(b.IP) {
+		return false
+	}
+
+	a.IP, b.IP = nil, nil
+
+	if len(a.Meta) == 0 && len(b.Meta) == 0 {
+		a.Meta, b.Meta = nil, nil
+	}
+
+	if len(a.Blobs) == 0 && len(b.Blobs) == 0 {
+		a.Blobs, b.Blobs = nil, nil
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// truncate converts both points in time to the same value (in UTC) if
+// they differ by less than a millisecond.
+func truncate(a, b time.Time) (time.Time, time.Time) {
+	d := a.Sub(b)
+	if d > -time.Millisecond && d < time.Millisecond {
+		return b.UTC(), b.UTC()
+	}
+
+	return a.UTC(), b.UTC()
+}
+
+// sampled checks whether the operations of the provided key should be
+// mirrored or not.
+func (m *MirrorStore) sampled(key string) bool {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return float64(h.Sum32()%10000) < m.cfg.Percent*100
+}
+
+// mirrorWrite queues the write operation, if its key is selected for
+// mirroring or sampling is disabled.
+func (m *MirrorStore) mirrorWrite(op, key string, sample bool, fn func(context.Context) error) {
+	if sample && !m.sampled(key) {
+		return
+	}
+
+	m.enqueue(func(ctx context.Context) {
+		if err := fn(ctx); err != nil {
+			m.compare(op, key, err, true)
+		}
+	})
+}
+
+// mirrorUpdate queues the update of the session, if the secondary store
+// supports updates.
+func (m *MirrorStore) mirrorUpdate(s sessionup.Session) {
+	ss, ok := m.secondary.(sessionup.UpdateStore)
+	if !ok {
+		return
+	}
+
+	m.mirrorWrite("Update", s.ID, true, func(ctx context.Context) error {
+		return ss.Update(ctx, s)
+	})
+}
+
+// enqueue adds the operation to the queue or drops it, if the queue is
+// full or closed.
+func (m *MirrorStore) enqueue(fn func(context.Context)) {
+	m.closeMu.RLock()
+	defer m.closeMu.RUnlock()
+
+	if m.closed {
+		atomic.AddUint64(&m.dropped, 1)
+		return
+	}
+
+	select {
+	case m.queue <- fn:
+	default:
+		atomic.AddUint64(&m.dropped, 1)
+	}
+}
+
+// run executes the queued operations in order, so that mirrored reads
+// observe the mirrored writes that preceded them.
+func (m *MirrorStore) run() {
+	defer close(m.done)
+
+	for fn := range m.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+		fn(ctx)
+		cancel()
+		atomic.AddUint64(&m.mirrored, 1)
+	}
+}
+
+// compare counts the outcome of the mirrored operation and notifies the
+// divergence function, if needed.
+func (m *MirrorStore) compare(op, key string, err error, equal bool) {
+	switch {
+	case err != nil:
+		atomic.AddUint64(&m.failed, 1)
+	case !equal:
+		atomic.AddUint64(&m.diverged, 1)
+	default:
+		return
+	}
+
+	if m.cfg.OnDivergence != nil {
+		m.cfg.OnDivergence(Divergence{Op: op, Key: key, Err: err})
+	}
+}
+
+// equalAll checks whether both lists hold equal sessions, regardless
+// of their order.
+func (m *MirrorStore) equalAll(a, b []sessionup.Session) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	// the slices may be shared with other callers.
+	a = sortByID(a)
+	b = sortByID(b)
+
+	for i := range a {
+		if !m.cfg.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ignoreNotFound converts sessionup.ErrNotFound into nil, since
+// deletions are idempotent.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, sessionup.ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// sortByID returns a copy of the sessions sorted by their IDs.
+func sortByID(ss []sessionup.Session) []sessionup.Session {
+	res := append([]sessionup.Session(nil), ss...)
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res
+}
@@ -0,0 +1,280 @@
+package mirrorstore
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/memstore"
+	"github.com/swithek/sessionup/storetest"
+)
+
+// failingStore is a sessionup.Store that fails all operations.
+type failingStore struct {
+	sessionup.Store
+}
+
+func (failingStore) Create(_ context.Context, _ sessionup.Session) error {
+	return errors.New("error")
+}
+
+func (failingStore) FetchByID(_ context.Context, _ string) (sessionup.Session, bool, error) {
+	return sessionup.Session{}, false, errors.New("error")
+}
+
+// blockingStore is a sessionup.Store whose reads block until the
+// channel is closed.
+type blockingStore struct {
+	sessionup.Store
+	wait chan struct{}
+}
+
+func (b blockingStore) FetchByID(ctx context.Context, id string) (sessionup.Session, bool, error) {
+	<-b.wait
+	return b.Store.FetchByID(ctx, id)
+}
+
+func TestType(t *testing.T) {
+	var _ sessionup.Store = &MirrorStore{}
+	var _ sessionup.UpdateStore = &MirrorStore{}
+	var _ sessionup.BlobStore = &MirrorStore{}
+	var _ sessionup.CASStore = &MirrorStore{}
+	var _ sessionup.ChangeCounterStore = &MirrorStore{}
+	var _ sessionup.OrgStore = &MirrorStore{}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunStoreTests(t, func() sessionup.Store {
+		return New(memstore.New(0), memstore.New(0), Config{Percent: 100})
+	})
+}
+
+func TestNew(t *testing.T) {
+	m := New(memstore.New(0), memstore.New(0), Config{})
+	defer m.Close()
+
+	if m.cfg.QueueSize != defaultQueueSize {
+		t.Errorf("want %d, got %d", defaultQueueSize, m.cfg.QueueSize)
+	}
+
+	if m.cfg.Timeout != defaultTimeout {
+		t.Errorf("want %v, got %v", defaultTimeout, m.cfg.Timeout)
+	}
+
+	if m.cfg.Equal == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestMirror(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := memstore.New(0), memstore.New(0)
+
+	var (
+		mu  sync.Mutex
+		dd  []Divergence
+		cfg = Config{
+			Percent: 100,
+			OnDivergence: func(d Divergence) {
+				mu.Lock()
+				dd = append(dd, d)
+				mu.Unlock()
+			},
+		}
+	)
+
+	m := New(primary, secondary, cfg)
+	for _, s := range []sessionup.Session{
+		storetest.NewSession("id1", "key"),
+		storetest.NewSession("id2", "key"),
+	} {
+		if err := m.Create(ctx, s); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+	}
+
+	m.FetchByID(ctx, "id1")
+	m.FetchByUserKey(ctx, "key")
+
+	s := storetest.NewSession("id2", "key")
+	s.Meta = map[string]string{"a": "b"}
+	if err := m.Update(ctx, s); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	// the secondary store loses a session.
+	m.enqueue(func(ctx context.Context) {
+		secondary.DeleteByID(ctx, "id1")
+	})
+
+	m.FetchByID(ctx, "id1")
+	m.FetchByID(ctx, "id2")
+	m.FetchByUserKey(ctx, "key")
+
+	if err := m.DeleteByUserKey(ctx, "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	m.FetchByUserKey(ctx, "key")
+	m.Close()
+
+	exp := Stats{Mirrored: 11, Diverged: 2}
+	if st := m.Stats(); st != exp {
+		t.Errorf("want %v, got %v", exp, st)
+	}
+
+	expd := []Divergence{{Op: "FetchByID", Key: "id1"}, {Op: "FetchByUserKey", Key: "key"}}
+	if len(dd) != len(expd) || dd[0] != expd[0] || dd[1] != expd[1] {
+		t.Errorf("want %v, got %v", expd, dd)
+	}
+
+	if ss, _ := secondary.FetchByUserKey(ctx, "key"); ss != nil {
+		t.Errorf("want nil, got %v", ss)
+	}
+
+	// closed stores keep serving operations.
+	if err := m.Create(ctx, storetest.NewSession("id3", "key")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if st := m.Stats(); st.Dropped != 1 {
+		t.Errorf("want %d, got %d", 1, st.Dropped)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := memstore.New(0), memstore.New(0)
+
+	m := New(primary, secondary, Config{Percent: 50})
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		if err := m.Create(ctx, storetest.NewSession(id, "key")); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+	}
+
+	m.FetchByUserKey(ctx, "key")
+	m.Close()
+
+	ss, _ := secondary.FetchByUserKey(ctx, "key")
+	if len(ss) == 0 || len(ss) == 10 {
+		t.Errorf("want partial mirroring, got %d", len(ss))
+	}
+
+	for _, s := range ss {
+		if !m.sampled(s.ID) {
+			t.Errorf("want sampled, got %q", s.ID)
+		}
+	}
+
+	if st := m.Stats(); st.Diverged != 0 || st.Failed != 0 {
+		t.Errorf("want no divergence, got %v", st)
+	}
+
+	m = New(primary, secondary, Config{})
+	m.Create(ctx, storetest.NewSession("k", "key"))
+	m.FetchByID(ctx, "k")
+	m.DeleteByUserKey(ctx, "key")
+	m.Close()
+
+	if st := m.Stats(); st.Mirrored != 1 {
+		t.Errorf("want %d, got %d", 1, st.Mirrored)
+	}
+
+	if ss, _ = secondary.FetchByUserKey(ctx, "key"); ss != nil {
+		t.Errorf("want nil, got %v", ss)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	ctx := context.Background()
+
+	var dd []Divergence
+	m := New(memstore.New(0), failingStore{Store: memstore.New(0)}, Config{
+		Percent:      100,
+		OnDivergence: func(d Divergence) { dd = append(dd, d) },
+	})
+
+	if err := m.Create(ctx, storetest.NewSession("id", "key")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, err := m.FetchByID(ctx, "id"); err != nil || !ok {
+		t.Fatalf("want nil/true, got %v/%t", err, ok)
+	}
+
+	m.Close()
+
+	if st := m.Stats(); st.Failed != 2 {
+		t.Errorf("want %d, got %d", 2, st.Failed)
+	}
+
+	if len(dd) != 2 || dd[0].Op != "Create" || dd[1].Op != "FetchByID" || dd[1].Err == nil {
+		t.Errorf("want failures, got %v", dd)
+	}
+
+	if err := New(failingStore{}, memstore.New(0), Config{}).Create(ctx, sessionup.Session{}); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestDropped(t *testing.T) {
+	ctx := context.Background()
+	wait := make(chan struct{})
+
+	m := New(memstore.New(0), blockingStore{Store: memstore.New(0), wait: wait}, Config{
+		Percent:   100,
+		QueueSize: 1,
+	})
+
+	for i := 0; i < 5; i++ {
+		m.FetchByID(ctx, "id")
+	}
+
+	close(wait)
+	m.Close()
+
+	st := m.Stats()
+	if st.Mirrored+st.Dropped != 5 || st.Dropped < 3 {
+		t.Errorf("want dropped operations, got %v", st)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	now := time.Now()
+	a := storetest.NewSession("id", "key")
+	a.CreatedAt = now
+	a.IP = net.ParseIP("127.0.0.1")
+	a.Meta = map[string]string{}
+
+	b := a
+	b.CreatedAt = now.Add(time.Microsecond * 300).In(time.FixedZone("test", 3600))
+	b.IP = net.ParseIP("127.0.0.1").To4()
+	b.Meta = nil
+	b.Current = true
+
+	if !Equal(a, b) {
+		t.Errorf("want %t, got %t", true, false)
+	}
+
+	b.CreatedAt = now.Add(time.Millisecond * 2)
+	if Equal(a, b) {
+		t.Errorf("want %t, got %t", false, true)
+	}
+
+	b = a
+	b.UserKey = "key1"
+	if Equal(a, b) {
+		t.Errorf("want %t, got %t", false, true)
+	}
+
+	b = a
+	b.IP = net.ParseIP("127.0.0.2")
+	if Equal(a, b) {
+		t.Errorf("want %t, got %t", false, true)
+	}
+}
@@ -0,0 +1,45 @@
+package sessionup
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CookieMutator sets the function that will be applied to every cookie
+// (including the deleted ones) just before it is written to the
+// response. It can be used to set attributes the package doesn't model
+// yet: attributes not supported by http.Cookie (e.g. vendor-specific
+// ones) can be appended to its Unparsed field in their raw form
+// (e.g. "Partitioned").
+// By default it is not set.
+func CookieMutator(fn func(c *http.Cookie)) Option {
+	return func(m *Manager) {
+		m.cookie.mutator = fn
+	}
+}
+
+// writeCookie applies the cookie mutator to the cookie and adds it to
+// the response headers.
+func (m *Manager) writeCookie(w http.ResponseWriter, c *http.Cookie) {
+	if m.noop {
+		return
+	}
+
+	if m.cookie.mutator == nil {
+		http.SetCookie(w, c)
+		return
+	}
+
+	m.cookie.mutator(c)
+
+	v := c.String()
+	if v == "" {
+		return
+	}
+
+	if len(c.Unparsed) > 0 {
+		v += "; " + strings.Join(c.Unparsed, "; ")
+	}
+
+	w.Header().Add("Set-Cookie", v)
+}
@@ -0,0 +1,77 @@
+package sessionup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCookieMutator(t *testing.T) {
+	m := Manager{}
+	CookieMutator(func(_ *http.Cookie) {})(&m)
+	if m.cookie.mutator == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestWriteCookie(t *testing.T) {
+	cc := map[string]struct {
+		Mutator func(*http.Cookie)
+		Header  string
+	}{
+		"No mutator": {
+			Header: "sessionup=id; Path=/",
+		},
+		"Modelled attribute": {
+			Mutator: func(c *http.Cookie) {
+				c.MaxAge = 60
+			},
+			Header: "sessionup=id; Path=/; Max-Age=60",
+		},
+		"Unmodelled attribute": {
+			Mutator: func(c *http.Cookie) {
+				c.Unparsed = append(c.Unparsed, "Partitioned")
+			},
+			Header: "sessionup=id; Path=/; Partitioned",
+		},
+		"Invalid cookie": {
+			Mutator: func(c *http.Cookie) {
+				c.Name = ""
+			},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m := Manager{}
+			CookieMutator(c.Mutator)(&m)
+
+			rec := httptest.NewRecorder()
+			m.writeCookie(rec, &http.Cookie{Name: "sessionup", Value: "id", Path: "/"})
+
+			if h := rec.Header().Get("Set-Cookie"); h != c.Header {
+				t.Errorf("want %q, got %q", c.Header, h)
+			}
+		})
+	}
+}
+
+func TestCookieMutatorDelete(t *testing.T) {
+	var names []string
+	m := NewManager(&StoreMock{}, CookieMutator(func(c *http.Cookie) {
+		names = append(names, c.Name)
+	}))
+
+	rec := httptest.NewRecorder()
+	m.deleteCookie(rec)
+	m.expireCookie(rec, "old")
+	m.setCookie(rec, time.Now(), "id")
+
+	if len(names) != 3 || names[0] != defaultName || names[1] != "old" || names[2] != defaultName {
+		t.Errorf("want mutator to be applied to all cookies, got %v", names)
+	}
+}
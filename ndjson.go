@@ -0,0 +1,126 @@
+package sessionup
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+)
+
+// sessionRecord is the NDJSON representation of a stored session. Unlike
+// Session's own JSON representation, it holds all fields that stores
+// persist and none of the per-request ones.
+type sessionRecord struct {
+	ID              string            `json:"id"`
+	UserKey         string            `json:"user_key"`
+	OrgKey          string            `json:"org_key,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	ExpiresAt       time.Time         `json:"expires_at"`
+	CookieExpiresAt time.Time         `json:"cookie_expires_at"`
+	IP              net.IP            `json:"ip,omitempty"`
+	OS              string            `json:"os,omitempty"`
+	Browser         string            `json:"browser,omitempty"`
+	Device          string            `json:"device,omitempty"`
+	AgentVersion    string            `json:"agent_version,omitempty"`
+	ParentID        string            `json:"parent_id,omitempty"`
+	Scope           string            `json:"scope,omitempty"`
+	Kind            string            `json:"kind,omitempty"`
+	Version         uint64            `json:"version,omitempty"`
+	Affinity        string            `json:"affinity,omitempty"`
+	Meta            map[string]string `json:"meta,omitempty"`
+	Blobs           map[string]string `json:"blobs,omitempty"`
+	CSRFToken       string            `json:"csrf_token,omitempty"`
+}
+
+// WriteNDJSON writes the sessions to the writer as newline-delimited
+// JSON, one session per line, with all the fields that stores persist
+// (Current, Risk and Fingerprint are omitted). The output can be read
+// back with ReadNDJSON, e.g. to export sessions from one store and
+// import them into another or to keep test fixtures.
+func WriteNDJSON(w io.Writer, ss []Session) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	for _, s := range ss {
+		if err := enc.Encode(newSessionRecord(s)); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadNDJSON reads the sessions written by WriteNDJSON from the reader.
+// Empty lines are skipped.
+func ReadNDJSON(r io.Reader) ([]Session, error) {
+	var ss []Session
+	dec := json.NewDecoder(r)
+
+	for {
+		var rec sessionRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return ss, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		ss = append(ss, rec.session())
+	}
+}
+
+// newSessionRecord returns the record of the session.
+func newSessionRecord(s Session) sessionRecord {
+	return sessionRecord{
+		ID:              s.ID,
+		UserKey:         s.UserKey,
+		OrgKey:          s.OrgKey,
+		CreatedAt:       s.CreatedAt,
+		ExpiresAt:       s.ExpiresAt,
+		CookieExpiresAt: s.CookieExpiresAt,
+		IP:              s.IP,
+		OS:              s.Agent.OS,
+		Browser:         s.Agent.Browser,
+		Device:          s.Agent.Device,
+		AgentVersion:    s.Agent.Version,
+		ParentID:        s.ParentID,
+		Scope:           s.Scope,
+		Kind:            s.Kind,
+		Version:         s.Version,
+		Affinity:        s.Affinity,
+		Meta:            s.Meta,
+		Blobs:           s.Blobs,
+		CSRFToken:       s.CSRFToken,
+	}
+}
+
+// session returns the session held by the record.
+func (rec sessionRecord) session() Session {
+	s := Session{
+		CreatedAt:       rec.CreatedAt,
+		ExpiresAt:       rec.ExpiresAt,
+		CookieExpiresAt: rec.CookieExpiresAt,
+		ID:              rec.ID,
+		UserKey:         rec.UserKey,
+		OrgKey:          rec.OrgKey,
+		IP:              rec.IP,
+		ParentID:        rec.ParentID,
+		Scope:           rec.Scope,
+		Kind:            rec.Kind,
+		Version:         rec.Version,
+		Affinity:        rec.Affinity,
+		Meta:            rec.Meta,
+		Blobs:           rec.Blobs,
+		CSRFToken:       rec.CSRFToken,
+	}
+
+	s.Agent.OS = rec.OS
+	s.Agent.Browser = rec.Browser
+	s.Agent.Device = rec.Device
+	s.Agent.Version = rec.AgentVersion
+
+	return s
+}
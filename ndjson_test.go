@@ -0,0 +1,103 @@
+package sessionup
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(_ []byte) (int, error) {
+	return 0, errors.New("error")
+}
+
+func TestNDJSON(t *testing.T) {
+	now := time.Now().UTC().Round(0)
+	s1 := Session{
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(time.Hour),
+		CookieExpiresAt: now.Add(time.Minute * 59),
+		ID:              "id1",
+		UserKey:         "key",
+		OrgKey:          "org",
+		IP:              net.ParseIP("127.0.0.1"),
+		ParentID:        "parent",
+		Scope:           "chat",
+		Kind:            KindTrustedDevice,
+		Version:         3,
+		Affinity:        "eu",
+		Meta:            map[string]string{"k": "v"},
+		Blobs:           map[string]string{"b": "ref"},
+		CSRFToken:       "token",
+	}
+	s1.Agent.OS = "Linux"
+	s1.Agent.Browser = "Firefox"
+	s1.Agent.Device = "desktop"
+	s1.Agent.Version = "70"
+
+	s2 := Session{ID: "id2", UserKey: "key", Current: true, Risk: 0.5, CreatedAt: now, ExpiresAt: now}
+
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, []Session{s1, s2}); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("want %d lines, got %d", 2, n)
+	}
+
+	ss, err := ReadNDJSON(strings.NewReader(buf.String() + "\n"))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	s2.Current, s2.Risk = false, 0
+	if !reflect.DeepEqual(ss, []Session{s1, s2}) {
+		t.Errorf("want %v, got %v", []Session{s1, s2}, ss)
+	}
+
+	if _, err = ReadNDJSON(strings.NewReader("{}\n{")); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+
+	if ss, err = ReadNDJSON(strings.NewReader("")); err != nil || ss != nil {
+		t.Errorf("want nil values, got %v and %v", ss, err)
+	}
+
+	if err = WriteNDJSON(failingWriter{}, []Session{s1}); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestNDJSONVectors(t *testing.T) {
+	// the vectors are generated by ./cmd/vectors; changes to the
+	// output of this test indicate a breaking change of the format.
+	data, err := ioutil.ReadFile("testdata/sessions.ndjson")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	ss, err := ReadNDJSON(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(ss) == 0 {
+		t.Fatal("want vectors, got none")
+	}
+
+	var buf bytes.Buffer
+	if err = WriteNDJSON(&buf, ss); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if buf.String() != string(data) {
+		t.Errorf("want %q, got %q", data, buf.String())
+	}
+}
@@ -0,0 +1,110 @@
+package sessionup
+
+import (
+	"context"
+	"time"
+)
+
+// KindAnonymous marks the sessions that no-op managers (see
+// NewNoopManager) attach to requests.
+const KindAnonymous = "anonymous"
+
+// NewNoopManager creates a manager that does not manage sessions at
+// all, so that applications rolling out authentication behind a
+// feature flag can keep a single code path and pick the manager per
+// environment:
+// - Auth, Public and all requirement middlewares activate the wrapped
+// handler with an anonymous session (of KindAnonymous, without an ID)
+// in the request's context;
+// - Init, Revoke and other methods succeed without storing or
+// retrieving anything and no cookies are ever set.
+// Provided options are applied as usual, but cannot change the store.
+func NewNoopManager(opts ...Option) *Manager {
+	m := NewManager(noopStore{}, opts...)
+	m.store = noopStore{}
+	m.noop = true
+
+	return m
+}
+
+// IsAnonymous checks whether the session stored in the context was
+// attached by a no-op manager (see NewNoopManager) or not.
+func IsAnonymous(ctx context.Context) bool {
+	s, ok := FromContext(ctx)
+	return ok && s.Kind == KindAnonymous
+}
+
+// anonymousSession produces the session attached to requests by no-op
+// managers.
+func anonymousSession() Session {
+	return Session{CreatedAt: time.Now(), Kind: KindAnonymous}
+}
+
+// noopStore is a Store (as well as OrgStore, UpdateStore, BlobStore,
+// IterStore and RangeStore) that stores nothing and never fails.
+type noopStore struct{}
+
+// Create implements Store interface's Create method.
+func (noopStore) Create(_ context.Context, _ Session) error {
+	return nil
+}
+
+// FetchByID implements Store interface's FetchByID method.
+func (noopStore) FetchByID(_ context.Context, _ string) (Session, bool, error) {
+	return Session{}, false, nil
+}
+
+// FetchByUserKey implements Store interface's FetchByUserKey method.
+func (noopStore) FetchByUserKey(_ context.Context, _ string) ([]Session, error) {
+	return nil, nil
+}
+
+// DeleteByID implements Store interface's DeleteByID method.
+func (noopStore) DeleteByID(_ context.Context, _ string) error {
+	return nil
+}
+
+// DeleteByUserKey implements Store interface's DeleteByUserKey method.
+func (noopStore) DeleteByUserKey(_ context.Context, _ string, _ ...string) error {
+	return nil
+}
+
+// DeleteByOrgKey implements OrgStore interface's DeleteByOrgKey method.
+func (noopStore) DeleteByOrgKey(_ context.Context, _ string) error {
+	return nil
+}
+
+// Update implements UpdateStore interface's Update method.
+func (noopStore) Update(_ context.Context, _ Session) error {
+	return nil
+}
+
+// CreateBlob implements BlobStore interface's CreateBlob method.
+func (noopStore) CreateBlob(_ context.Context, _ string, _ []byte, _ time.Time) error {
+	return nil
+}
+
+// FetchBlob implements BlobStore interface's FetchBlob method.
+func (noopStore) FetchBlob(_ context.Context, _ string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+// DeleteBlob implements BlobStore interface's DeleteBlob method.
+func (noopStore) DeleteBlob(_ context.Context, _ string) error {
+	return nil
+}
+
+// ExtendBlob implements BlobStore interface's ExtendBlob method.
+func (noopStore) ExtendBlob(_ context.Context, _ string, _ time.Time) error {
+	return nil
+}
+
+// Iterate implements IterStore interface's Iterate method.
+func (noopStore) Iterate(_ context.Context, _ func(Session) bool) error {
+	return nil
+}
+
+// DeleteCreatedBetween implements RangeStore interface's DeleteCreatedBetween method.
+func (noopStore) DeleteCreatedBetween(_ context.Context, _ string, _, _ time.Time) ([]Session, error) {
+	return nil, nil
+}
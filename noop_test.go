@@ -0,0 +1,84 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNoopManager(t *testing.T) {
+	m := NewNoopManager(CookieName("custom"))
+	if m.cookie.name != "custom" {
+		t.Errorf("want %q, got %q", "custom", m.cookie.name)
+	}
+
+	var anonymous bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		anonymous = IsAnonymous(r.Context())
+	})
+
+	cc := map[string]http.Handler{
+		"Auth":             m.Auth(h),
+		"Public":           m.Public(h),
+		"RequireValid":     m.RequireValid()(h),
+		"RequireNone":      m.RequireNone()(h),
+		"RequireFresh":     m.RequireFresh(time.Minute)(h),
+		"RequireStepUp":    m.RequireStepUp("mfa", time.Minute)(h),
+		"RequireVerified":  m.Auth(m.RequireVerified("email", time.Minute, nil)(h)),
+		"RequireExclusive": m.RequireExclusive(LockConfig{AllMethods: true})(h),
+	}
+
+	for cn, c := range cc {
+		anonymous = false
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest("POST", "http://example.com/", nil))
+
+		if !anonymous {
+			t.Errorf("%s: want anonymous session", cn)
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: want %d, got %d", cn, http.StatusOK, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if err := m.Init(rec, req, "key"); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("want %d, got %d", 0, len(rec.Result().Cookies()))
+	}
+
+	ctx := NewContext(context.Background(), anonymousSession())
+	if err := m.Revoke(ctx, httptest.NewRecorder()); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if err := m.RevokeAll(ctx, httptest.NewRecorder()); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	ss, err := m.FetchAll(ctx)
+	if err != nil || ss != nil {
+		t.Errorf("want nil values, got %v and %v", ss, err)
+	}
+}
+
+func TestIsAnonymous(t *testing.T) {
+	if IsAnonymous(context.Background()) {
+		t.Error("want false, got true")
+	}
+
+	if IsAnonymous(NewContext(context.Background(), Session{ID: "id"})) {
+		t.Error("want false, got true")
+	}
+
+	if !IsAnonymous(NewContext(context.Background(), anonymousSession())) {
+		t.Error("want true, got false")
+	}
+}
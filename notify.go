@@ -0,0 +1,146 @@
+package sessionup
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types delivered in notification digests.
+const (
+	// EventCreated is emitted when a new session is created. Its
+	// Anomaly field is set if anomaly detection is active (see
+	// OnAnomaly).
+	EventCreated = "created"
+
+	// EventAnomaly is emitted when session creation is refused by
+	// the function set with OnAnomaly.
+	EventAnomaly = "anomaly"
+
+	// EventRevoked is emitted when sessions of the user are revoked.
+	EventRevoked = "revoked"
+)
+
+// Event describes a single session event of a user.
+type Event struct {
+	// Type specifies the type of the event.
+	Type string
+
+	// At specifies a point in time when the event happened.
+	At time.Time
+
+	// Session specifies the (redacted) session the event is about.
+	// It is not set for revocations of multiple sessions.
+	Session Session
+
+	// Anomaly specifies the anomalies detected during session
+	// creation.
+	Anomaly Anomaly
+
+	// Revocation describes the revoked sessions.
+	Revocation Revocation
+}
+
+// Digest holds the events of a single user that happened during the
+// notifier's window.
+type Digest struct {
+	UserKey string
+	Events  []Event
+}
+
+// Notifier batches session events per user and delivers them as
+// digests, so that users are not notified separately about events that
+// fire in quick succession.
+type Notifier struct {
+	window time.Duration
+	fn     func(Digest)
+
+	mu      sync.Mutex
+	pending map[string]*pendingDigest
+}
+
+// pendingDigest holds the events waiting for the delivery.
+type pendingDigest struct {
+	events []Event
+	timer  *time.Timer
+}
+
+// NewNotifier creates a new notifier that delivers a digest to the
+// provided function once the window passes after the first event of
+// the user. The function is called from a separate goroutine.
+func NewNotifier(window time.Duration, fn func(d Digest)) *Notifier {
+	return &Notifier{
+		window:  window,
+		fn:      fn,
+		pending: make(map[string]*pendingDigest),
+	}
+}
+
+// Notifications sets the notifier to which session events (creations,
+// anomalies, revocations) will be passed.
+// By default it is not set.
+func Notifications(n *Notifier) Option {
+	return func(m *Manager) {
+		m.notifier = n
+	}
+}
+
+// Notify adds the event to the pending digest of the user.
+func (n *Notifier) Notify(key string, e Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	pd, ok := n.pending[key]
+	if !ok {
+		pd = &pendingDigest{}
+		pd.timer = time.AfterFunc(n.window, func() {
+			n.deliver(key, pd)
+		})
+		n.pending[key] = pd
+	}
+
+	pd.events = append(pd.events, e)
+}
+
+// Flush immediately delivers all pending digests (e.g. during the
+// shutdown).
+func (n *Notifier) Flush() {
+	n.mu.Lock()
+	pp := n.pending
+	n.pending = make(map[string]*pendingDigest)
+	n.mu.Unlock()
+
+	for key, pd := range pp {
+		pd.timer.Stop()
+		n.fn(Digest{UserKey: key, Events: pd.events})
+	}
+}
+
+// deliver removes the pending digest of the user and passes it to the
+// notifier's function.
+func (n *Notifier) deliver(key string, pd *pendingDigest) {
+	n.mu.Lock()
+	if n.pending[key] != pd {
+		// already flushed.
+		n.mu.Unlock()
+		return
+	}
+
+	delete(n.pending, key)
+	n.mu.Unlock()
+
+	n.fn(Digest{UserKey: key, Events: pd.events})
+}
+
+// notify passes the event to the notifier, if it is set.
+func (m *Manager) notify(key string, e Event) {
+	if m.notifier == nil {
+		return
+	}
+
+	e.At = time.Now()
+	if e.Session.ID != "" {
+		e.Session = m.Redacted(e.Session)
+	}
+
+	m.notifier.Notify(key, e)
+}
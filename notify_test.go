@@ -0,0 +1,156 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotifications(t *testing.T) {
+	m := Manager{}
+	n := NewNotifier(time.Minute, func(_ Digest) {})
+	Notifications(n)(&m)
+	if m.notifier != n {
+		t.Errorf("want %v, got %v", n, m.notifier)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	var (
+		mu sync.Mutex
+		dd []Digest
+	)
+
+	done := make(chan struct{}, 2)
+	n := NewNotifier(time.Millisecond*20, func(d Digest) {
+		mu.Lock()
+		dd = append(dd, d)
+		mu.Unlock()
+		done <- struct{}{}
+	})
+
+	n.Notify("key1", Event{Type: EventCreated})
+	n.Notify("key1", Event{Type: EventRevoked})
+	n.Notify("key2", Event{Type: EventCreated})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("want digest, got timeout")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(dd) != 2 {
+		t.Fatalf("want %d, got %d", 2, len(dd))
+	}
+
+	for _, d := range dd {
+		exp := 1
+		if d.UserKey == "key1" {
+			exp = 2
+		}
+
+		if len(d.Events) != exp {
+			t.Errorf("%s: want %d, got %d", d.UserKey, exp, len(d.Events))
+		}
+	}
+}
+
+func TestNotifierFlush(t *testing.T) {
+	var dd []Digest
+	n := NewNotifier(time.Hour, func(d Digest) {
+		dd = append(dd, d)
+	})
+
+	n.Notify("key", Event{Type: EventCreated})
+	n.Notify("key", Event{Type: EventCreated})
+	n.Flush()
+
+	if len(dd) != 1 || len(dd[0].Events) != 2 {
+		t.Errorf("want %d digest with %d events, got %v", 1, 2, dd)
+	}
+
+	n.Flush()
+	if len(dd) != 1 {
+		t.Errorf("want %d, got %d", 1, len(dd))
+	}
+}
+
+func TestManagerNotify(t *testing.T) {
+	store := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			s := Session{ID: "old", CreatedAt: time.Now().Add(-time.Hour)}
+			s.Agent.OS = "Other"
+			return []Session{s}, nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}
+
+	var dd []Digest
+	n := NewNotifier(time.Hour, func(d Digest) {
+		dd = append(dd, d)
+	})
+
+	errBlocked := errors.New("blocked")
+	var block bool
+	m := NewManager(store, Notifications(n), Redaction(DropAgent()),
+		OnAnomaly(AnomalyConfig{}, func(_ *http.Request, _ Session, _ Anomaly) error {
+			if block {
+				return errBlocked
+			}
+			return nil
+		}),
+	)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "agent")
+
+	if err := m.Init(httptest.NewRecorder(), req, "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	block = true
+	if err := m.Init(httptest.NewRecorder(), req, "key"); err != errBlocked {
+		t.Fatalf("want %v, got %v", errBlocked, err)
+	}
+
+	if err := m.RevokeByUserKey(context.Background(), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	n.Flush()
+
+	if len(dd) != 1 || dd[0].UserKey != "key" {
+		t.Fatalf("want %d digest of %q, got %v", 1, "key", dd)
+	}
+
+	ee := dd[0].Events
+	if len(ee) != 3 || ee[0].Type != EventCreated || ee[1].Type != EventAnomaly || ee[2].Type != EventRevoked {
+		t.Fatalf("want created, anomaly and revoked events, got %v", ee)
+	}
+
+	if !ee[0].Anomaly.NewAgent || ee[0].At.IsZero() {
+		t.Errorf("want new agent anomaly and time, got %v", ee[0])
+	}
+
+	if ee[0].Session.ID == "" || ee[0].Session.Agent.OS != "" {
+		t.Errorf("want redacted session, got %v", ee[0].Session)
+	}
+
+	if ee[2].Revocation.UserKey != "key" {
+		t.Errorf("want %q, got %q", "key", ee[2].Revocation.UserKey)
+	}
+}
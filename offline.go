@@ -0,0 +1,213 @@
+package sessionup
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/swithek/sessionup/token"
+)
+
+// bundleVersion is the version of the validation bundle format
+// produced by ExportBundle.
+const bundleVersion = 2
+
+var (
+	// ErrNotStateless is returned when a validation bundle is being
+	// exported by a manager that is not in stateless mode.
+	ErrNotStateless = errors.New("stateless mode is not active")
+
+	// ErrNotSigned is returned when a validation bundle is being
+	// exported by a manager whose stateless tokens are not signed.
+	ErrNotSigned = errors.New("stateless tokens are not signed")
+
+	// ErrStaleBundle is returned when a validation bundle is loaded or
+	// used after its NotAfter time, or its format is not supported.
+	ErrStaleBundle = errors.New("validation bundle is stale or unsupported")
+)
+
+// Bundle holds everything that a disconnected service needs to validate
+// stateless session cookies (see Stateless) without access to the
+// store: the public key of stateless session tokens (see
+// SignStateless), the policies and the revocations recorded in the
+// journal up to the checkpoint. It can be marshalled to JSON.
+// The public key can only verify tokens, so services that hold the
+// bundle cannot issue sessions.
+type Bundle struct {
+	// Version specifies the format of the bundle.
+	Version int `json:"version"`
+
+	// CreatedAt specifies a point in time when the bundle was
+	// exported.
+	CreatedAt time.Time `json:"created_at"`
+
+	// NotAfter specifies a point in time after which the bundle must
+	// no longer be used, as the revocations it holds are too old.
+	NotAfter time.Time `json:"not_after"`
+
+	// PublicKey specifies the public key that verifies stateless
+	// session tokens.
+	PublicKey ed25519.PublicKey `json:"public_key"`
+
+	// CookieName specifies the name of the session cookie.
+	CookieName string `json:"cookie_name"`
+
+	// ClockSkew specifies the tolerated clock drift (see ClockSkew
+	// option).
+	ClockSkew time.Duration `json:"clock_skew"`
+
+	// Checkpoint specifies the sequence number of the last revocation
+	// included in the bundle.
+	Checkpoint uint64 `json:"checkpoint"`
+
+	// Revocations holds all revocations of the journal up to the
+	// checkpoint.
+	Revocations []Revocation `json:"revocations,omitempty"`
+}
+
+// ExportBundle produces a validation bundle that can be loaded with
+// LoadBundle by services that cannot reach the store. The bundle stays
+// usable for the provided duration (the stateless refresh interval if
+// maxAge <= 0), after which a new one must be exported; revocations
+// performed after the export are not visible to offline validators
+// until then.
+// Revocations are read from the journal (see RevocationJournal); if it
+// is not available, the bundle holds none. ErrCompacted is returned if
+// the journal was compacted, ExtendBundle can then be used with a
+// previously exported bundle.
+// ErrNotStateless is returned if the stateless mode is not active,
+// ErrNotSigned is returned if its tokens are not signed.
+func (m *Manager) ExportBundle(ctx context.Context, maxAge time.Duration) (Bundle, error) {
+	return m.ExtendBundle(ctx, Bundle{}, maxAge)
+}
+
+// ExtendBundle produces a new validation bundle (see ExportBundle) that
+// holds the revocations of the provided bundle and the ones that
+// followed its checkpoint, so that bundles can be exported
+// incrementally even after the journal's compaction.
+func (m *Manager) ExtendBundle(ctx context.Context, prev Bundle, maxAge time.Duration) (Bundle, error) {
+	if ctx == nil {
+		return Bundle{}, ErrNilContext
+	}
+
+	if m.stateless.key == nil {
+		return Bundle{}, ErrNotStateless
+	}
+
+	if m.stateless.signer == nil {
+		return Bundle{}, ErrNotSigned
+	}
+
+	if maxAge <= 0 {
+		maxAge = m.stateless.refresh
+	}
+
+	now := time.Now()
+	b := Bundle{
+		Version:    bundleVersion,
+		CreatedAt:  now,
+		NotAfter:   now.Add(maxAge),
+		PublicKey:  m.stateless.signer.Public().(ed25519.PublicKey),
+		CookieName: m.cookie.name,
+		ClockSkew:  m.Config().ClockSkew,
+		Checkpoint: prev.Checkpoint,
+	}
+
+	b.Revocations = append(b.Revocations, prev.Revocations...)
+
+	if j := m.revocationJournal(); j != nil {
+		rr, err := j.ReadRevocations(ctx, prev.Checkpoint, 0)
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			return Bundle{}, err
+		}
+
+		b.Revocations = append(b.Revocations, rr...)
+		if len(rr) > 0 {
+			b.Checkpoint = rr[len(rr)-1].Seq
+		}
+	}
+
+	return b, nil
+}
+
+// OfflineValidator validates stateless session cookies with a
+// validation bundle, without access to the store.
+type OfflineValidator struct {
+	b Bundle
+}
+
+// LoadBundle checks the bundle and creates an offline validator from it.
+// ErrStaleBundle is returned if the bundle's NotAfter time has passed
+// or its version is not supported.
+func LoadBundle(b Bundle) (*OfflineValidator, error) {
+	if b.Version != bundleVersion || !time.Now().Before(b.NotAfter) {
+		return nil, ErrStaleBundle
+	}
+
+	if len(b.PublicKey) != ed25519.PublicKeySize {
+		return nil, token.ErrKeySize
+	}
+
+	return &OfflineValidator{b: b}, nil
+}
+
+// Validate extracts the session from the request's stateless session
+// cookie and checks that it is neither expired (tolerating the bundle's
+// clock skew) nor revoked by any of the bundle's revocations.
+// ErrStaleBundle is returned once the bundle's NotAfter time passes,
+// ErrUnauthorized is returned if the session is not valid.
+func (v *OfflineValidator) Validate(r *http.Request) (Session, error) {
+	if r == nil {
+		return Session{}, ErrNilRequest
+	}
+
+	now := time.Now()
+	if !now.Before(v.b.NotAfter) {
+		return Session{}, ErrStaleBundle
+	}
+
+	c, err := r.Cookie(v.b.CookieName)
+	if err != nil {
+		return Session{}, ErrUnauthorized
+	}
+
+	msg, _, err := token.Verify(v.b.PublicKey, c.Value, []byte(statelessAssertion))
+	if err != nil {
+		return Session{}, ErrUnauthorized
+	}
+
+	var p statelessPayload
+	if err = json.Unmarshal(msg, &p); err != nil {
+		return Session{}, ErrUnauthorized
+	}
+
+	s := p.session()
+	if s.Kind != "" || IsExpired(s, now.Add(-v.b.ClockSkew)) {
+		return Session{}, ErrUnauthorized
+	}
+
+	for _, rv := range v.b.Revocations {
+		if revokes(rv, s) {
+			return Session{}, ErrUnauthorized
+		}
+	}
+
+	return s, nil
+}
+
+// revokes checks whether the revocation applies to the session.
+func revokes(r Revocation, s Session) bool {
+	switch {
+	case r.ID != "":
+		return r.ID == s.ID
+	case r.UserKey != "":
+		return r.UserKey == s.UserKey && r.ExceptID != s.ID && s.CreatedAt.Before(r.At)
+	case r.OrgKey != "":
+		return r.OrgKey == s.OrgKey && s.CreatedAt.Before(r.At)
+	}
+
+	return false
+}
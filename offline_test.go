@@ -0,0 +1,218 @@
+package sessionup
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup/token"
+)
+
+func TestExportBundle(t *testing.T) {
+	var ctx context.Context
+	if _, err := NewManager(mapStore()).ExportBundle(ctx, 0); err != ErrNilContext {
+		t.Errorf("want %v, got %v", ErrNilContext, err)
+	}
+
+	if _, err := NewManager(mapStore()).ExportBundle(context.Background(), 0); err != ErrNotStateless {
+		t.Errorf("want %v, got %v", ErrNotStateless, err)
+	}
+
+	key := make([]byte, token.KeySize)
+	if _, err := NewManager(mapStore(), Stateless(key, time.Minute)).ExportBundle(context.Background(), 0); err != ErrNotSigned {
+		t.Errorf("want %v, got %v", ErrNotSigned, err)
+	}
+
+	signer := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	j := &journalMock{}
+	m := NewManager(mapStore(), Stateless(key, time.Minute), SignStateless(signer), RevocationJournal(j))
+
+	b, err := m.ExportBundle(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if b.NotAfter.Sub(b.CreatedAt) != time.Minute {
+		t.Errorf("want %v, got %v", time.Minute, b.NotAfter.Sub(b.CreatedAt))
+	}
+
+	if !bytes.Equal(b.PublicKey, signer.Public().(ed25519.PublicKey)) {
+		t.Errorf("want public key, got %x", b.PublicKey)
+	}
+
+	if b.Checkpoint != 0 || len(b.Revocations) != 0 {
+		t.Errorf("want empty checkpoint, got %d and %v", b.Checkpoint, b.Revocations)
+	}
+
+	j.AppendRevocation(context.Background(), Revocation{ID: "1"})
+	j.AppendRevocation(context.Background(), Revocation{ID: "2"})
+	b, _ = m.ExportBundle(context.Background(), time.Hour)
+
+	j.AppendRevocation(context.Background(), Revocation{ID: "3"})
+	b, err = m.ExtendBundle(context.Background(), b, time.Hour)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if b.Checkpoint != 3 || len(b.Revocations) != 3 || b.Revocations[2].ID != "3" {
+		t.Errorf("want all revocations, got %d and %v", b.Checkpoint, b.Revocations)
+	}
+}
+
+func TestOfflineValidator(t *testing.T) {
+	key := make([]byte, token.KeySize)
+	j := &journalMock{}
+	store := mapStore()
+	store.DeleteByIDFunc = func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	signer := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	m := NewManager(store, Stateless(key, time.Minute), SignStateless(signer), RevocationJournal(j))
+
+	login := func() string {
+		rec := httptest.NewRecorder()
+		if err := m.Init(rec, httptest.NewRequest("GET", "/", nil), "key"); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return rec.Result().Cookies()[0].Value
+	}
+
+	validate := func(v *OfflineValidator, val string) (Session, error) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: defaultName, Value: val})
+		return v.Validate(req)
+	}
+
+	kept, revoked := login(), login()
+	rs, _, _ := m.loadSession(context.Background(), httptest.NewRecorder(), revoked)
+	if err := m.RevokeByID(context.Background(), rs.ID); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	b, err := m.ExportBundle(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	// bundles are transferred as JSON.
+	data, _ := json.Marshal(b)
+	b = Bundle{}
+	json.Unmarshal(data, &b)
+
+	v, err := LoadBundle(b)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if s, err := validate(v, kept); err != nil || s.UserKey != "key" {
+		t.Errorf("want valid session, got %v and %v", s, err)
+	}
+
+	if _, err = validate(v, revoked); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	if _, err = validate(v, kept+"x"); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	if _, err = v.Validate(nil); err != ErrNilRequest {
+		t.Errorf("want %v, got %v", ErrNilRequest, err)
+	}
+
+	v.b.NotAfter = time.Now()
+	if _, err = validate(v, kept); err != ErrStaleBundle {
+		t.Errorf("want %v, got %v", ErrStaleBundle, err)
+	}
+
+	if _, err = LoadBundle(v.b); err != ErrStaleBundle {
+		t.Errorf("want %v, got %v", ErrStaleBundle, err)
+	}
+
+	b.PublicKey = b.PublicKey[1:]
+	if _, err = LoadBundle(b); err != token.ErrKeySize {
+		t.Errorf("want %v, got %v", token.ErrKeySize, err)
+	}
+}
+
+func TestOfflineValidatorClockSkew(t *testing.T) {
+	signer := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	m := NewManager(mapStore(), Stateless(make([]byte, token.KeySize), time.Minute), SignStateless(signer), ClockSkew(time.Minute))
+
+	b, err := m.ExportBundle(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	v, err := LoadBundle(b)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	cc := map[string]struct {
+		ExpiresIn time.Duration
+		Err       error
+	}{
+		"Not expired":          {ExpiresIn: time.Minute},
+		"Expired within skew":  {ExpiresIn: -time.Second * 30},
+		"Expired beyond skew":  {ExpiresIn: -time.Minute * 2, Err: ErrUnauthorized},
+		"Expiring within skew": {ExpiresIn: time.Second * 30},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := m.cookieValue(Session{ID: "id", ExpiresAt: time.Now().Add(c.ExpiresIn)})
+			if err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(&http.Cookie{Name: defaultName, Value: val})
+			if _, err = v.Validate(req); err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+		})
+	}
+}
+
+func TestRevokes(t *testing.T) {
+	now := time.Now()
+	s := Session{ID: "id", UserKey: "key", OrgKey: "org", CreatedAt: now}
+
+	cc := map[string]struct {
+		Revocation Revocation
+		Result     bool
+	}{
+		"ID":                {Revocation: Revocation{ID: "id"}, Result: true},
+		"Other ID":          {Revocation: Revocation{ID: "other"}},
+		"User key":          {Revocation: Revocation{UserKey: "key", At: now.Add(time.Second)}, Result: true},
+		"User key excepted": {Revocation: Revocation{UserKey: "key", ExceptID: "id", At: now.Add(time.Second)}},
+		"User key before":   {Revocation: Revocation{UserKey: "key", At: now.Add(-time.Second)}},
+		"Org key":           {Revocation: Revocation{OrgKey: "org", At: now.Add(time.Second)}, Result: true},
+		"Other org key":     {Revocation: Revocation{OrgKey: "other", At: now.Add(time.Second)}},
+		"Empty revocation":  {},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			if res := revokes(c.Revocation, s); res != c.Result {
+				t.Errorf("want %v, got %v", c.Result, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package sessionup
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrInvalidCookieName is returned by New when the cookie name
+	// is empty or contains characters not allowed in cookie names.
+	ErrInvalidCookieName = errors.New("invalid cookie name")
+
+	// ErrInvalidOrigin is returned by New when a trusted origin is
+	// not an absolute URL consisting of a scheme and a host only.
+	ErrInvalidOrigin = errors.New("invalid origin")
+
+	// ErrInvalidNetwork is returned by New when a trusted proxy's
+	// address range is not in the CIDR notation.
+	ErrInvalidNetwork = errors.New("invalid network")
+
+	// ErrInvalidURL is returned by New when the base URL is not an
+	// absolute URL.
+	ErrInvalidURL = errors.New("invalid URL")
+
+	// ErrNegative is returned by New when a duration or a size is
+	// negative.
+	ErrNegative = errors.New("negative value")
+)
+
+// OptionError is returned by New when an option receives an invalid
+// value.
+type OptionError struct {
+	// Option specifies the name of the option, e.g. "Stateless".
+	Option string
+
+	// Err specifies the reason why the value is invalid.
+	Err error
+}
+
+// Error implements error interface.
+func (e *OptionError) Error() string {
+	return "sessionup: invalid " + e.Option + " option: " + e.Err.Error()
+}
+
+// Unwrap returns the reason why the value is invalid.
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// New creates a new Manager with the provided store and options applied
+// to it, same as NewManager, but also checks the values received by the
+// options, so that misconfigurations (e.g. keys of invalid size) fail
+// loudly at startup instead of misbehaving at runtime. The error of the
+// first invalid option is returned as *OptionError.
+func New(s Store, opts ...Option) (*Manager, error) {
+	m := NewManager(s, opts...)
+	if len(m.optErrs) > 0 {
+		return nil, m.optErrs[0]
+	}
+
+	return m, nil
+}
+
+// invalid records that the option received an invalid value. The
+// value is applied nonetheless, so that NewManager keeps its behaviour.
+func (m *Manager) invalid(opt string, err error) {
+	m.optErrs = append(m.optErrs, &OptionError{Option: opt, Err: err})
+}
+
+// validCookieName checks whether the name is a valid cookie name (an
+// HTTP token) or not.
+func validCookieName(n string) bool {
+	return n != "" && strings.IndexFunc(n, func(r rune) bool {
+		return isNotCookieChar(r) || strings.ContainsRune("()<>@:/[]?={}", r)
+	}) < 0
+}
+
+// validOrigin checks whether the origin consists of a scheme and a host
+// only (a trailing slash is allowed) or not.
+func validOrigin(o string) bool {
+	u, err := url.Parse(o)
+	return err == nil && u.Scheme != "" && u.Host != "" && u.User == nil &&
+		(u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.Fragment == ""
+}
@@ -0,0 +1,132 @@
+package sessionup
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup/token"
+)
+
+func TestNew(t *testing.T) {
+	key := make([]byte, token.KeySize)
+
+	cc := map[string]struct {
+		Opts   []Option
+		Option string
+		Err    error
+	}{
+		"Valid options": {
+			Opts: []Option{
+				CookieName("session"),
+				OldCookieNames("old"),
+				TrustedOrigins("https://example.com", "http://localhost:8080/"),
+				SigningKey(key),
+				Stateless(key, 0),
+				BaseURL("https://example.com/app"),
+				ExpiresIn(0),
+				ClockSkew(time.Second),
+				BlobThreshold(0),
+			},
+		},
+		"Empty cookie name": {
+			Opts:   []Option{CookieName("")},
+			Option: "CookieName",
+			Err:    ErrInvalidCookieName,
+		},
+		"Invalid cookie name": {
+			Opts:   []Option{CookieName("my session")},
+			Option: "CookieName",
+			Err:    ErrInvalidCookieName,
+		},
+		"Invalid old cookie name": {
+			Opts:   []Option{OldCookieNames("old", "a=b")},
+			Option: "OldCookieNames",
+			Err:    ErrInvalidCookieName,
+		},
+		"Origin with path": {
+			Opts:   []Option{TrustedOrigins("https://example.com/app")},
+			Option: "TrustedOrigins",
+			Err:    ErrInvalidOrigin,
+		},
+		"Origin without scheme": {
+			Opts:   []Option{TrustedOrigins("example.com")},
+			Option: "TrustedOrigins",
+			Err:    ErrInvalidOrigin,
+		},
+		"Short signing key": {
+			Opts:   []Option{SigningKey([]byte("short"))},
+			Option: "SigningKey",
+			Err:    token.ErrKeySize,
+		},
+		"Relative base URL": {
+			Opts:   []Option{BaseURL("/app")},
+			Option: "BaseURL",
+			Err:    ErrInvalidURL,
+		},
+		"Invalid base URL": {
+			Opts:   []Option{BaseURL("://")},
+			Option: "BaseURL",
+			Err:    ErrInvalidURL,
+		},
+		"Negative lifetime": {
+			Opts:   []Option{ExpiresIn(-time.Hour)},
+			Option: "ExpiresIn",
+			Err:    ErrNegative,
+		},
+		"Negative clock skew": {
+			Opts:   []Option{ClockSkew(-time.Second)},
+			Option: "ClockSkew",
+			Err:    ErrNegative,
+		},
+		"Negative blob threshold": {
+			Opts:   []Option{BlobThreshold(-1)},
+			Option: "BlobThreshold",
+			Err:    ErrNegative,
+		},
+		"Short stateless key": {
+			Opts:   []Option{CookieName("session"), Stateless([]byte("short"), 0), SigningKey(nil)},
+			Option: "Stateless",
+			Err:    token.ErrKeySize,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := New(&StoreMock{}, c.Opts...)
+			if c.Err == nil {
+				if err != nil || m == nil {
+					t.Errorf("want manager and nil, got %v and %v", m, err)
+				}
+
+				return
+			}
+
+			if m != nil {
+				t.Errorf("want nil, got %v", m)
+			}
+
+			var oerr *OptionError
+			if !errors.As(err, &oerr) {
+				t.Fatalf("want %T, got %v", oerr, err)
+			}
+
+			if oerr.Option != c.Option {
+				t.Errorf("want %q, got %q", c.Option, oerr.Option)
+			}
+
+			if !errors.Is(err, c.Err) {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+		})
+	}
+
+	m := NewManager(&StoreMock{}, CookieName(""))
+	if cm := m.Clone(); len(cm.optErrs) != 0 {
+		t.Errorf("want no errors, got %v", cm.optErrs)
+	}
+}
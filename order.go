@@ -0,0 +1,202 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// crockford holds the Crockford's base32 alphabet, whose order
+	// matches the byte order of its characters.
+	crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+	sortableTimeLen = 10
+	sortableSeqLen  = 4
+	sortableSeqMax  = 1 << (5 * sortableSeqLen)
+)
+
+// ErrInvalidCursor is returned by FetchAllPaged when the provided
+// cursor is malformed.
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// clock holds the last point in time returned by monotonicNow.
+var clock struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// monotonicNow returns the current time, ensuring that every call
+// returns a point in time later than the previous one, so that sessions
+// created by the same process never share their creation time.
+func monotonicNow() time.Time {
+	t := time.Now()
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	if !t.After(clock.last) {
+		t = clock.last.Add(time.Nanosecond)
+	}
+
+	clock.last = t
+	return t
+}
+
+// SortSessions sorts the sessions by their creation time and, if it is
+// equal (e.g. sessions created on different nodes or stored with a
+// truncated precision), by their IDs, which produces a stable total
+// order. Store implementations that support ordered listings should use
+// the same order.
+func SortSessions(ss []Session) {
+	sort.Slice(ss, func(i, j int) bool {
+		return sessionLess(ss[i], ss[j])
+	})
+}
+
+// sessionLess checks whether the first session precedes the second one
+// in the total order or not.
+func sessionLess(a, b Session) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+
+	return a.ID < b.ID
+}
+
+// NewSortableGenID produces an ID generation function (to be used with
+// GenID) whose IDs sort in the order of their generation: each ID
+// starts with the 10 characters long millisecond timestamp and 4
+// characters long sequence number (both encoded with Crockford's
+// base32), followed by 40 random alphanumeric characters read from the
+// provided reader. Within the same process, IDs are strictly increasing
+// even if many of them are generated in the same millisecond.
+// Generated function returns an empty string if the reader fails.
+func NewSortableGenID(r io.Reader) func() string {
+	var (
+		mu   sync.Mutex
+		last int64
+		seq  int64
+	)
+
+	random := NewGenID(r, idChars, idLen)
+
+	return func() string {
+		rnd := random()
+		if rnd == "" {
+			return ""
+		}
+
+		mu.Lock()
+		ms := time.Now().UnixNano() / int64(time.Millisecond)
+		if ms <= last {
+			ms = last
+			seq++
+			if seq == sortableSeqMax {
+				// sequence exhausted, borrow the next millisecond.
+				ms++
+				seq = 0
+			}
+		} else {
+			seq = 0
+		}
+		last = ms
+		id := encodeCrockford(ms, sortableTimeLen) + encodeCrockford(seq, sortableSeqLen)
+		mu.Unlock()
+
+		return id + rnd
+	}
+}
+
+// encodeCrockford encodes the number with Crockford's base32 alphabet
+// into a string of the provided length, padded with zeros.
+func encodeCrockford(n int64, l int) string {
+	b := make([]byte, l)
+	for i := l - 1; i >= 0; i-- {
+		b[i] = crockford[n&31]
+		n >>= 5
+	}
+
+	return string(b)
+}
+
+// FetchAllPaged retrieves a page of the sessions returned by FetchAll,
+// sorted by SortSessions order. Empty cursor retrieves the first page,
+// the returned cursor retrieves the next one and is empty once there
+// are no more sessions. Limit <= 0 retrieves all remaining sessions.
+// Since the order is total, sessions created or revoked between the
+// calls never cause other sessions to be skipped or repeated.
+// ErrInvalidCursor is returned if the cursor is malformed.
+func (m *Manager) FetchAllPaged(ctx context.Context, cursor string, limit int) ([]Session, string, error) {
+	if ctx == nil {
+		return nil, "", ErrNilContext
+	}
+
+	var after Session
+	if cursor != "" {
+		var err error
+		if after, err = decodeCursor(cursor); err != nil {
+			return nil, "", err
+		}
+	}
+
+	ss, err := m.FetchAll(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	SortSessions(ss)
+
+	if cursor != "" {
+		i := sort.Search(len(ss), func(i int) bool {
+			return sessionLess(after, ss[i])
+		})
+		ss = ss[i:]
+	}
+
+	if limit <= 0 || len(ss) <= limit {
+		if len(ss) == 0 {
+			return nil, "", nil
+		}
+
+		return ss, "", nil
+	}
+
+	ss = ss[:limit]
+	return ss, encodeCursor(ss[limit-1]), nil
+}
+
+// encodeCursor produces the pagination cursor pointing after the
+// provided session.
+func encodeCursor(s Session) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(strconv.FormatInt(s.CreatedAt.UnixNano(), 10) + ":" + s.ID),
+	)
+}
+
+// decodeCursor extracts the creation time and ID of the session the
+// cursor points after.
+func decodeCursor(cursor string) (Session, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Session{}, ErrInvalidCursor
+	}
+
+	i := strings.IndexByte(string(b), ':')
+	if i < 0 {
+		return Session{}, ErrInvalidCursor
+	}
+
+	ns, err := strconv.ParseInt(string(b[:i]), 10, 64)
+	if err != nil {
+		return Session{}, ErrInvalidCursor
+	}
+
+	return Session{CreatedAt: time.Unix(0, ns), ID: string(b[i+1:])}, nil
+}
@@ -0,0 +1,180 @@
+package sessionup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonotonicNow(t *testing.T) {
+	prev := monotonicNow()
+	for i := 0; i < 1000; i++ {
+		now := monotonicNow()
+		if !now.After(prev) {
+			t.Fatalf("want after %v, got %v", prev, now)
+		}
+		prev = now
+	}
+}
+
+func TestSortSessions(t *testing.T) {
+	now := time.Now()
+	ss := []Session{
+		{ID: "c", CreatedAt: now},
+		{ID: "a", CreatedAt: now.Add(time.Second)},
+		{ID: "b", CreatedAt: now},
+		{ID: "a", CreatedAt: now},
+	}
+
+	SortSessions(ss)
+
+	exp := []Session{
+		{ID: "a", CreatedAt: now},
+		{ID: "b", CreatedAt: now},
+		{ID: "c", CreatedAt: now},
+		{ID: "a", CreatedAt: now.Add(time.Second)},
+	}
+
+	if !reflect.DeepEqual(exp, ss) {
+		t.Errorf("want %v, got %v", exp, ss)
+	}
+}
+
+func TestNewSortableGenID(t *testing.T) {
+	gen := NewSortableGenID(rand.Reader)
+
+	prev := gen()
+	if len(prev) != sortableTimeLen+sortableSeqLen+idLen {
+		t.Errorf("want %d, got %d", sortableTimeLen+sortableSeqLen+idLen, len(prev))
+	}
+
+	if err := ValidateSession(Session{ID: prev}); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		id := gen()
+		if id[:sortableTimeLen+sortableSeqLen] <= prev[:sortableTimeLen+sortableSeqLen] {
+			t.Fatalf("want after %q, got %q", prev, id)
+		}
+		prev = id
+	}
+
+	if id := NewSortableGenID(bytes.NewReader(nil))(); id != "" {
+		t.Errorf("want %q, got %q", "", id)
+	}
+}
+
+func TestEncodeCrockford(t *testing.T) {
+	cc := map[int64]string{
+		0:       "0000",
+		31:      "000Z",
+		32:      "0010",
+		1048575: "ZZZZ",
+	}
+
+	for n, exp := range cc {
+		if res := encodeCrockford(n, 4); res != exp {
+			t.Errorf("want %q, got %q", exp, res)
+		}
+	}
+}
+
+func TestFetchAllPaged(t *testing.T) {
+	now := time.Now()
+
+	var ss []Session
+	for _, id := range []string{"e", "d", "c", "b", "a"} {
+		ss = append(ss, Session{ID: id, CreatedAt: now})
+	}
+	ss = append(ss, Session{ID: "z", CreatedAt: now.Add(-time.Second)}, Session{ID: "x", Kind: KindCanary})
+
+	m := Manager{store: &StoreMock{
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return ss, nil
+		},
+	}}
+
+	ctx := NewContext(context.Background(), Session{ID: "c"})
+
+	var (
+		ids    []string
+		cursor string
+		pages  int
+	)
+
+	for {
+		page, next, err := m.FetchAllPaged(ctx, cursor, 2)
+		if err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		for _, s := range page {
+			ids = append(ids, s.ID)
+			if s.Current != (s.ID == "c") {
+				t.Errorf("want %t, got %t", s.ID == "c", s.Current)
+			}
+		}
+
+		pages++
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if exp := "z,a,b,c,d,e"; strings.Join(ids, ",") != exp {
+		t.Errorf("want %q, got %q", exp, strings.Join(ids, ","))
+	}
+
+	if pages != 3 {
+		t.Errorf("want %d, got %d", 3, pages)
+	}
+
+	page, next, err := m.FetchAllPaged(ctx, "", 0)
+	if err != nil || next != "" || len(page) != 6 {
+		t.Errorf("want nil/\"\"/6, got %v/%q/%d", err, next, len(page))
+	}
+
+	_, cursor, _ = m.FetchAllPaged(ctx, "", 6)
+	if cursor != "" {
+		t.Errorf("want %q, got %q", "", cursor)
+	}
+
+	// sessions created after the cursor was issued are not skipped.
+	_, cursor, _ = m.FetchAllPaged(ctx, "", 3)
+	ss = append(ss, Session{ID: "bb", CreatedAt: now})
+	page, _, _ = m.FetchAllPaged(ctx, cursor, 0)
+	if len(page) != 4 || page[0].ID != "bb" {
+		t.Errorf("want bb first, got %v", page)
+	}
+
+	for _, cur := range []string{"!", "YWJj", encodeCursor(Session{ID: "a"})[:3]} {
+		if _, _, err = m.FetchAllPaged(ctx, cur, 2); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("want %v, got %v", ErrInvalidCursor, err)
+		}
+	}
+
+	page, next, err = m.FetchAllPaged(context.Background(), "", 2)
+	if err != nil || page != nil || next != "" {
+		t.Errorf("want nil/nil/\"\", got %v/%v/%q", err, page, next)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	s := Session{ID: "a:b", CreatedAt: time.Unix(0, 1234567890123456789)}
+
+	res, err := decodeCursor(encodeCursor(s))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if !res.CreatedAt.Equal(s.CreatedAt) || res.ID != s.ID {
+		t.Errorf("want %v, got %v", s, res)
+	}
+}
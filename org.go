@@ -0,0 +1,68 @@
+package sessionup
+
+import (
+	"context"
+	"strings"
+)
+
+const defaultOrgSeparator = "/"
+
+// OrgSeparator sets the separator of composite user keys (e.g.
+// "org/user") which are used to group sessions by organization. When
+// it is set, the part of the user key before the first separator is
+// stored as the session's organization key and can later be used to
+// revoke the sessions of all organization's members with RevokeByOrg.
+// Keys without the separator produce sessions that do not belong to
+// any organization.
+// By default it is not set and organization keys are not extracted.
+func OrgSeparator(sep string) Option {
+	return func(m *Manager) {
+		m.orgSeparator = sep
+	}
+}
+
+// CompositeKey joins the organization and user keys with the default
+// separator ("/"), to be used with OrgSeparator("/").
+func CompositeKey(org, user string) string {
+	return org + defaultOrgSeparator + user
+}
+
+// orgKey extracts the organization key from the composite user key.
+func (m *Manager) orgKey(key string) string {
+	if m.orgSeparator == "" {
+		return ""
+	}
+
+	i := strings.Index(key, m.orgSeparator)
+	if i <= 0 {
+		return ""
+	}
+
+	return key[:i]
+}
+
+// RevokeByOrg deletes the sessions of all members of the provided
+// organization (e.g. when it is offboarded or its SSO configuration is
+// rotated). The store must implement OrgStore interface, otherwise
+// ErrNotSupported is returned.
+// Function will be no-op and return nil, if no sessions are found.
+func (m *Manager) RevokeByOrg(ctx context.Context, org string) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if org == "" {
+		return nil
+	}
+
+	os, ok := m.store.(OrgStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	if err := ignoreNotFound(os.DeleteByOrgKey(ctx, org)); err != nil {
+		return err
+	}
+
+	return m.record(ctx, Revocation{OrgKey: org})
+}
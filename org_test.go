@@ -0,0 +1,146 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// orgStoreMock is a Store with OrgStore capability, backed by
+// StoreMock.
+type orgStoreMock struct {
+	*StoreMock
+	orgs []string
+	err  error
+}
+
+func (o *orgStoreMock) DeleteByOrgKey(_ context.Context, org string) error {
+	o.orgs = append(o.orgs, org)
+	return o.err
+}
+
+func TestOrgSeparator(t *testing.T) {
+	m := Manager{}
+	val := ":"
+	OrgSeparator(val)(&m)
+	if m.orgSeparator != val {
+		t.Errorf("want %q, got %q", val, m.orgSeparator)
+	}
+}
+
+func TestOrgKey(t *testing.T) {
+	cc := map[string]struct {
+		Separator string
+		Key       string
+		Org       string
+	}{
+		"Separator not set": {
+			Key: CompositeKey("org", "user"),
+		},
+		"Key without organization": {
+			Separator: defaultOrgSeparator,
+			Key:       "user",
+		},
+		"Key with empty organization": {
+			Separator: defaultOrgSeparator,
+			Key:       "/user",
+		},
+		"Composite key": {
+			Separator: defaultOrgSeparator,
+			Key:       CompositeKey("org", "user/1"),
+			Org:       "org",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := Manager{orgSeparator: c.Separator}
+			if org := m.orgKey(c.Key); org != c.Org {
+				t.Errorf("want %q, got %q", c.Org, org)
+			}
+		})
+	}
+}
+
+func TestRevokeByOrg(t *testing.T) {
+	errTest := errors.New("error")
+
+	cc := map[string]struct {
+		Store Store
+		Org   string
+		Err   error
+		Orgs  []string
+	}{
+		"Empty organization key": {
+			Store: &orgStoreMock{StoreMock: &StoreMock{}},
+		},
+		"Store without organization support": {
+			Store: &StoreMock{},
+			Org:   "org",
+			Err:   ErrNotSupported,
+		},
+		"Organization support missing in wrapped store": {
+			Store: prefixStore{Store: &StoreMock{}, prefix: "p:"},
+			Org:   "org",
+			Err:   ErrNotSupported,
+		},
+		"Error returned by store.DeleteByOrgKey": {
+			Store: &orgStoreMock{StoreMock: &StoreMock{}, err: errTest},
+			Org:   "org",
+			Err:   errTest,
+			Orgs:  []string{"org"},
+		},
+		"Successful revocation": {
+			Store: &orgStoreMock{StoreMock: &StoreMock{}, err: ErrNotFound},
+			Org:   "org",
+			Orgs:  []string{"org"},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := Manager{store: c.Store}
+			if err := m.RevokeByOrg(context.Background(), c.Org); err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if os, ok := c.Store.(*orgStoreMock); ok && len(os.orgs) != len(c.Orgs) {
+				t.Errorf("want %v, got %v", c.Orgs, os.orgs)
+			}
+		})
+	}
+}
+
+func TestPrefixStoreOrg(t *testing.T) {
+	os := &orgStoreMock{StoreMock: &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+	}}
+
+	ps := prefixStore{Store: os, prefix: "p:"}
+	if err := ps.Create(context.Background(), Session{ID: "id", UserKey: "key"}); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err := ps.Create(context.Background(), Session{ID: "id", UserKey: "org/key", OrgKey: "org"}); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	cc := os.CreateCalls()
+	if cc[0].S.OrgKey != "" || cc[1].S.OrgKey != "p:org" {
+		t.Errorf("want %q/%q, got %q/%q", "", "p:org", cc[0].S.OrgKey, cc[1].S.OrgKey)
+	}
+
+	if err := ps.DeleteByOrgKey(context.Background(), "org"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(os.orgs) != 1 || os.orgs[0] != "p:org" {
+		t.Errorf("want %v, got %v", []string{"p:org"}, os.orgs)
+	}
+}
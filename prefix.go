@@ -0,0 +1,308 @@
+package sessionup
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// KeyPrefix sets the prefix that will be prepended to session IDs and
+// user keys before they are passed to the store, and stripped from them
+// when sessions are retrieved.
+// Useful when multiple applications or deployments share the same
+// underlying data store and their sessions must not collide.
+// Setting it more than once (e.g. via Clone) replaces the previous
+// prefix. Empty string disables prefixing.
+// By default it is not set.
+func KeyPrefix(p string) Option {
+	return func(m *Manager) {
+		if ps, ok := m.store.(prefixStore); ok {
+			m.store = ps.Store
+		}
+
+		if p == "" {
+			return
+		}
+
+		m.store = prefixStore{Store: m.store, prefix: p}
+	}
+}
+
+// prefixStore wraps the Store and namespaces all session IDs and user
+// keys that pass through it.
+type prefixStore struct {
+	Store
+	prefix string
+}
+
+// Create implements Store interface's Create method.
+func (p prefixStore) Create(ctx context.Context, s Session) error {
+	return p.Store.Create(ctx, p.wrap(s))
+}
+
+// FetchByID implements Store interface's FetchByID method.
+func (p prefixStore) FetchByID(ctx context.Context, id string) (Session, bool, error) {
+	s, ok, err := p.Store.FetchByID(ctx, p.prefix+id)
+	if err != nil || !ok {
+		return s, ok, err
+	}
+
+	return p.unwrap(s), true, nil
+}
+
+// FetchByUserKey implements Store interface's FetchByUserKey method.
+func (p prefixStore) FetchByUserKey(ctx context.Context, key string) ([]Session, error) {
+	ss, err := p.Store.FetchByUserKey(ctx, p.prefix+key)
+	if err != nil || ss == nil {
+		return ss, err
+	}
+
+	res := make([]Session, len(ss))
+	for i, s := range ss {
+		res[i] = p.unwrap(s)
+	}
+
+	return res, nil
+}
+
+// DeleteByID implements Store interface's DeleteByID method.
+func (p prefixStore) DeleteByID(ctx context.Context, id string) error {
+	return p.Store.DeleteByID(ctx, p.prefix+id)
+}
+
+// DeleteByUserKey implements Store interface's DeleteByUserKey method.
+func (p prefixStore) DeleteByUserKey(ctx context.Context, key string, expID ...string) error {
+	ids := make([]string, len(expID))
+	for i, id := range expID {
+		ids[i] = p.prefix + id
+	}
+
+	return p.Store.DeleteByUserKey(ctx, p.prefix+key, ids...)
+}
+
+// Update implements UpdateStore interface's Update method.
+func (p prefixStore) Update(ctx context.Context, s Session) error {
+	us, ok := p.Store.(UpdateStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return us.Update(ctx, p.wrap(s))
+}
+
+// CompareAndSwap implements CASStore interface's CompareAndSwap method.
+func (p prefixStore) CompareAndSwap(ctx context.Context, old uint64, s Session) (bool, error) {
+	cs, ok := p.Store.(CASStore)
+	if !ok {
+		return false, ErrNotSupported
+	}
+
+	return cs.CompareAndSwap(ctx, old, p.wrap(s))
+}
+
+// CreateBlob implements BlobStore interface's CreateBlob method.
+func (p prefixStore) CreateBlob(ctx context.Context, ref string, data []byte, exp time.Time) error {
+	bs, ok := p.Store.(BlobStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return bs.CreateBlob(ctx, p.prefix+ref, data, exp)
+}
+
+// FetchBlob implements BlobStore interface's FetchBlob method.
+func (p prefixStore) FetchBlob(ctx context.Context, ref string) ([]byte, bool, error) {
+	bs, ok := p.Store.(BlobStore)
+	if !ok {
+		return nil, false, ErrNotSupported
+	}
+
+	return bs.FetchBlob(ctx, p.prefix+ref)
+}
+
+// DeleteBlob implements BlobStore interface's DeleteBlob method.
+func (p prefixStore) DeleteBlob(ctx context.Context, ref string) error {
+	bs, ok := p.Store.(BlobStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return bs.DeleteBlob(ctx, p.prefix+ref)
+}
+
+// ExtendBlob implements BlobStore interface's ExtendBlob method.
+func (p prefixStore) ExtendBlob(ctx context.Context, ref string, exp time.Time) error {
+	bs, ok := p.Store.(BlobStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return bs.ExtendBlob(ctx, p.prefix+ref, exp)
+}
+
+// ChangeCounter implements ChangeCounterStore interface's ChangeCounter method.
+func (p prefixStore) ChangeCounter(ctx context.Context, key string) (uint64, error) {
+	cs, ok := p.Store.(ChangeCounterStore)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	return cs.ChangeCounter(ctx, p.prefix+key)
+}
+
+// DeleteByOrgKey implements OrgStore interface's DeleteByOrgKey method.
+func (p prefixStore) DeleteByOrgKey(ctx context.Context, org string) error {
+	os, ok := p.Store.(OrgStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return os.DeleteByOrgKey(ctx, p.prefix+org)
+}
+
+// SetSignOut implements SignOutStore interface's SetSignOut method.
+func (p prefixStore) SetSignOut(ctx context.Context, mk SignOutMarker) error {
+	ss, ok := p.Store.(SignOutStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	mk.UserKey = p.prefix + mk.UserKey
+	return ss.SetSignOut(ctx, mk)
+}
+
+// FetchSignOut implements SignOutStore interface's FetchSignOut method.
+func (p prefixStore) FetchSignOut(ctx context.Context, key string) (SignOutMarker, bool, error) {
+	ss, ok := p.Store.(SignOutStore)
+	if !ok {
+		return SignOutMarker{}, false, ErrNotSupported
+	}
+
+	mk, ok, err := ss.FetchSignOut(ctx, p.prefix+key)
+	if err != nil || !ok {
+		return mk, ok, err
+	}
+
+	mk.UserKey = strings.TrimPrefix(mk.UserKey, p.prefix)
+	return mk, true, nil
+}
+
+// AcquireLock implements LockStore interface's AcquireLock method.
+func (p prefixStore) AcquireLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	ls, ok := p.Store.(LockStore)
+	if !ok {
+		return false, ErrNotSupported
+	}
+
+	return ls.AcquireLock(ctx, p.prefix+key, owner, ttl)
+}
+
+// ReleaseLock implements LockStore interface's ReleaseLock method.
+func (p prefixStore) ReleaseLock(ctx context.Context, key, owner string) error {
+	ls, ok := p.Store.(LockStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return ls.ReleaseLock(ctx, p.prefix+key, owner)
+}
+
+// DeleteCreatedBetween implements RangeStore interface's DeleteCreatedBetween method.
+func (p prefixStore) DeleteCreatedBetween(ctx context.Context, key string, from, to time.Time) ([]Session, error) {
+	rs, ok := p.Store.(RangeStore)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	ss, err := rs.DeleteCreatedBetween(ctx, p.prefix+key, from, to)
+	if err != nil || ss == nil {
+		return ss, err
+	}
+
+	for i, s := range ss {
+		ss[i] = p.unwrap(s)
+	}
+
+	return ss, nil
+}
+
+// Iterate implements IterStore interface's Iterate method.
+// Sessions of other namespaces are skipped.
+func (p prefixStore) Iterate(ctx context.Context, fn func(Session) bool) error {
+	is, ok := p.Store.(IterStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return is.Iterate(ctx, func(s Session) bool {
+		if !strings.HasPrefix(s.ID, p.prefix) {
+			return true
+		}
+
+		return fn(p.unwrap(s))
+	})
+}
+
+// AppendRevocation implements Journal interface's AppendRevocation method.
+func (p prefixStore) AppendRevocation(ctx context.Context, r Revocation) error {
+	j, ok := p.Store.(Journal)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	for _, k := range []*string{&r.ID, &r.UserKey, &r.OrgKey, &r.ExceptID} {
+		if *k != "" {
+			*k = p.prefix + *k
+		}
+	}
+
+	return j.AppendRevocation(ctx, r)
+}
+
+// ReadRevocations implements Journal interface's ReadRevocations method.
+// Revocations of other namespaces are returned with all keys cleared,
+// so that their sequence numbers can still be used as checkpoints.
+func (p prefixStore) ReadRevocations(ctx context.Context, after uint64, limit int) ([]Revocation, error) {
+	j, ok := p.Store.(Journal)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	rr, err := j.ReadRevocations(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range rr {
+		if !strings.HasPrefix(r.ID+r.UserKey+r.OrgKey, p.prefix) {
+			rr[i] = Revocation{Seq: r.Seq, At: r.At}
+			continue
+		}
+
+		for _, k := range []*string{&rr[i].ID, &rr[i].UserKey, &rr[i].OrgKey, &rr[i].ExceptID} {
+			*k = strings.TrimPrefix(*k, p.prefix)
+		}
+	}
+
+	return rr, nil
+}
+
+// wrap prepends the prefix to session's ID, user key and organization
+// key (if it is set).
+func (p prefixStore) wrap(s Session) Session {
+	s.ID = p.prefix + s.ID
+	s.UserKey = p.prefix + s.UserKey
+	if s.OrgKey != "" {
+		s.OrgKey = p.prefix + s.OrgKey
+	}
+	return s
+}
+
+// unwrap strips the prefix from session's ID, user key and
+// organization key.
+func (p prefixStore) unwrap(s Session) Session {
+	s.ID = strings.TrimPrefix(s.ID, p.prefix)
+	s.UserKey = strings.TrimPrefix(s.UserKey, p.prefix)
+	s.OrgKey = strings.TrimPrefix(s.OrgKey, p.prefix)
+	return s
+}
@@ -0,0 +1,104 @@
+package sessionup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	s := &StoreMock{}
+	m := Manager{store: s}
+
+	KeyPrefix("a:")(&m)
+	ps, ok := m.store.(prefixStore)
+	if !ok {
+		t.Fatalf("want %T, got %T", prefixStore{}, m.store)
+	}
+
+	if ps.prefix != "a:" || ps.Store != s {
+		t.Errorf("want %q prefix over %v, got %q prefix over %v", "a:", s, ps.prefix, ps.Store)
+	}
+
+	KeyPrefix("b:")(&m)
+	ps = m.store.(prefixStore)
+	if ps.prefix != "b:" || ps.Store != s {
+		t.Errorf("want %q prefix over %v, got %q prefix over %v", "b:", s, ps.prefix, ps.Store)
+	}
+
+	KeyPrefix("")(&m)
+	if m.store != s {
+		t.Errorf("want %v, got %v", s, m.store)
+	}
+}
+
+func TestPrefixStore(t *testing.T) {
+	ctx := context.Background()
+	s := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id, UserKey: "p:key"}, true, nil
+		},
+		FetchByUserKeyFunc: func(_ context.Context, key string) ([]Session, error) {
+			return []Session{{ID: "p:id", UserKey: key}}, nil
+		},
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}
+
+	ps := prefixStore{Store: s, prefix: "p:"}
+	exp := Session{ID: "id", UserKey: "key"}
+
+	if err := ps.Create(ctx, exp); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if c := s.CreateCalls()[0].S; c.ID != "p:id" || c.UserKey != "p:key" {
+		t.Errorf("want %q/%q, got %q/%q", "p:id", "p:key", c.ID, c.UserKey)
+	}
+
+	res, ok, err := ps.FetchByID(ctx, "id")
+	if err != nil || !ok {
+		t.Fatalf("want nil/true, got %v/%t", err, ok)
+	}
+
+	if s.FetchByIDCalls()[0].ID != "p:id" {
+		t.Errorf("want %q, got %q", "p:id", s.FetchByIDCalls()[0].ID)
+	}
+
+	if !reflect.DeepEqual(exp, res) {
+		t.Errorf("want %v, got %v", exp, res)
+	}
+
+	ss, err := ps.FetchByUserKey(ctx, "key")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if !reflect.DeepEqual([]Session{exp}, ss) {
+		t.Errorf("want %v, got %v", []Session{exp}, ss)
+	}
+
+	if err = ps.DeleteByID(ctx, "id"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if s.DeleteByIDCalls()[0].ID != "p:id" {
+		t.Errorf("want %q, got %q", "p:id", s.DeleteByIDCalls()[0].ID)
+	}
+
+	if err = ps.DeleteByUserKey(ctx, "key", "id1", "id2"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	c := s.DeleteByUserKeyCalls()[0]
+	if c.Key != "p:key" || !reflect.DeepEqual([]string{"p:id1", "p:id2"}, c.ExpID) {
+		t.Errorf("want %q/%v, got %q/%v", "p:key", []string{"p:id1", "p:id2"}, c.Key, c.ExpID)
+	}
+}
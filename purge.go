@@ -0,0 +1,62 @@
+package sessionup
+
+import (
+	"context"
+	"fmt"
+)
+
+// Purger sets the function which will be called by OnUserDeleted to
+// remove application-specific data (e.g. audit records) associated
+// with the deleted user's key.
+// By default it is not set.
+func Purger(fn func(ctx context.Context, key string) error) Option {
+	return func(m *Manager) {
+		m.purge = fn
+	}
+}
+
+// OnUserDeleted revokes all sessions (including canaries) under the
+// provided user key, together with their blobs, and calls the function
+// set with Purger. It is meant to be called from account deletion
+// handlers or queue consumers.
+// OnUserDeleted is idempotent, so failed calls can be safely retried:
+// sessions are revoked only after their blobs are deleted, so a retry
+// still finds the blobs that a failed call did not delete.
+func (m *Manager) OnUserDeleted(ctx context.Context, key string) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if err := m.RevokeByUserKey(ctx, key); err != nil {
+		return err
+	}
+
+	if m.purge != nil {
+		return m.purge(ctx, key)
+	}
+
+	return nil
+}
+
+// OnUsersDeleted calls OnUserDeleted for each of the provided user
+// keys, e.g. while processing an account deletion backlog. It stops on
+// the first error and returns it together with the failed key; keys
+// processed before it are not affected, so the whole batch can be
+// safely retried.
+func (m *Manager) OnUsersDeleted(ctx context.Context, keys ...string) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := m.OnUserDeleted(ctx, key); err != nil {
+			return fmt.Errorf("purging user %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPurger(t *testing.T) {
+	m := Manager{}
+	Purger(func(_ context.Context, _ string) error { return nil })(&m)
+	if m.purge == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestOnUserDeleted(t *testing.T) {
+	errTest := errors.New("error")
+
+	cc := map[string]struct {
+		FetchErr  error
+		DeleteErr error
+		PurgeErr  error
+		Err       error
+		Blobs     int
+		Purged    bool
+	}{
+		"Error returned by store.FetchByUserKey": {
+			FetchErr: errTest,
+			Err:      errTest,
+			Blobs:    2,
+		},
+		"Error returned by store.DeleteByUserKey": {
+			DeleteErr: errTest,
+			Err:       errTest,
+		},
+		"Error returned by purge function": {
+			PurgeErr: errTest,
+			Err:      errTest,
+			Purged:   true,
+		},
+		"Successful cleanup": {
+			Purged: true,
+		},
+		"Sessions already revoked": {
+			DeleteErr: ErrNotFound,
+			Purged:    true,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			s := &blobStoreMock{
+				StoreMock: &StoreMock{
+					FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+						return []Session{
+							{ID: "id1", Blobs: map[string]string{"a": "id1:a"}},
+							{ID: "id2", Blobs: map[string]string{"b": "id2:b"}},
+						}, c.FetchErr
+					},
+					DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+						return c.DeleteErr
+					},
+				},
+				blobs: map[string][]byte{"id1:a": nil, "id2:b": nil},
+			}
+
+			var purged bool
+			m := Manager{store: s, blobThreshold: 1, purge: func(_ context.Context, key string) error {
+				purged = true
+				if key != "key" {
+					t.Errorf("want %q, got %q", "key", key)
+				}
+				return c.PurgeErr
+			}}
+
+			err := m.OnUserDeleted(context.Background(), "key")
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if len(s.blobs) != c.Blobs {
+				t.Errorf("want %d, got %d", c.Blobs, len(s.blobs))
+			}
+
+			if purged != c.Purged {
+				t.Errorf("want %t, got %t", c.Purged, purged)
+			}
+		})
+	}
+}
+
+func TestOnUserDeletedRetry(t *testing.T) {
+	errTest := errors.New("error")
+	ss := []Session{
+		{ID: "id1", Blobs: map[string]string{"a": "id1:a"}},
+		{ID: "id2", Blobs: map[string]string{"b": "id2:b"}},
+	}
+
+	s := &blobStoreMock{
+		StoreMock: &StoreMock{
+			FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+				return ss, nil
+			},
+			DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+				ss = nil
+				return nil
+			},
+		},
+		blobs: map[string][]byte{"id1:a": nil, "id2:b": nil},
+		err:   errTest,
+	}
+
+	m := Manager{store: s, blobThreshold: 1}
+	if err := m.OnUserDeleted(context.Background(), "key"); err != errTest {
+		t.Errorf("want %v, got %v", errTest, err)
+	}
+
+	if len(ss) != 2 {
+		t.Errorf("want sessions kept until blobs are deleted, got %v", ss)
+	}
+
+	// the blob was not deleted after all.
+	s.blobs["id1:a"] = nil
+	s.err = nil
+
+	if err := m.OnUserDeleted(context.Background(), "key"); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if len(s.blobs) != 0 || len(ss) != 0 {
+		t.Errorf("want blobs and sessions deleted, got %v and %v", s.blobs, ss)
+	}
+}
+
+func TestOnUsersDeleted(t *testing.T) {
+	errTest := errors.New("error")
+	s := &StoreMock{
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return nil, nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, key string, _ ...string) error {
+			if key == "bad" {
+				return errTest
+			}
+			return nil
+		},
+	}
+
+	m := Manager{store: s}
+	if err := m.OnUsersDeleted(context.Background(), "a", "b"); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	err := m.OnUsersDeleted(context.Background(), "c", "bad", "d")
+	if !errors.Is(err, errTest) {
+		t.Errorf("want %v, got %v", errTest, err)
+	}
+
+	if n := len(s.DeleteByUserKeyCalls()); n != 4 {
+		t.Errorf("want %d, got %d", 4, n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.OnUsersDeleted(ctx, "e"); err != context.Canceled {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+}
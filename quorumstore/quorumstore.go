@@ -0,0 +1,308 @@
+// Package quorumstore provides a sessionup.Store implementation that
+// keeps sessions in multiple stores and accepts them only if enough of
+// those stores agree, so that a single compromised or corrupted store
+// cannot forge or resurrect a session on its own.
+package quorumstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+// Error is returned when an operation fails on too many stores for the
+// quorum to be reached.
+type Error struct {
+	// Errs holds errors keyed by the index of the store that
+	// returned them.
+	Errs map[int]error
+}
+
+// Error implements error interface.
+func (e *Error) Error() string {
+	ii := make([]int, 0, len(e.Errs))
+	for i := range e.Errs {
+		ii = append(ii, i)
+	}
+	sort.Ints(ii)
+
+	msgs := make([]string, len(ii))
+	for j, i := range ii {
+		msgs[j] = fmt.Sprintf("store %d: %v", i, e.Errs[i])
+	}
+
+	return fmt.Sprintf("%d store(s) failed: %s", len(ii), strings.Join(msgs, "; "))
+}
+
+// Is checks whether any of the stores returned the target error.
+func (e *Error) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// QuorumStore is a sessionup.Store implementation that writes sessions
+// to all underlying stores and reads them from all of them, accepting a
+// session only if at least quorum stores return equal copies of it.
+// Sessions are compared by their ID, user and organization keys,
+// expiration time, kind, scope, parent ID and CSRF token; if the
+// agreeing copies differ in other fields (e.g. after a partially
+// applied update), the one with the highest version is returned.
+// Every operation waits for all stores, so its latency is the latency
+// of the slowest store.
+type QuorumStore struct {
+	stores []sessionup.Store
+	quorum int
+}
+
+// New returns a fresh instance of QuorumStore.
+// Quorum parameter determines how many stores must agree on a session
+// for it to be accepted; values <= 0 default to the majority of the
+// stores and values above the number of stores default to all of them.
+func New(quorum int, stores ...sessionup.Store) *QuorumStore {
+	if quorum <= 0 {
+		quorum = len(stores)/2 + 1
+	}
+
+	if quorum > len(stores) {
+		quorum = len(stores)
+	}
+
+	return &QuorumStore{
+		stores: stores,
+		quorum: quorum,
+	}
+}
+
+// Create implements sessionup.Store interface's Create method.
+// The session is written to all stores and *Error is returned if fewer
+// than quorum of them succeed.
+func (q *QuorumStore) Create(ctx context.Context, s sessionup.Session) error {
+	return q.write(func(st sessionup.Store) error {
+		return st.Create(ctx, s)
+	})
+}
+
+// FetchByID implements sessionup.Store interface's FetchByID method.
+// The session is found only if at least quorum stores return equal
+// copies of it. *Error is returned if too many stores fail for the
+// quorum to be reached.
+func (q *QuorumStore) FetchByID(ctx context.Context, id string) (sessionup.Session, bool, error) {
+	res := make([][]sessionup.Session, len(q.stores))
+	errs := q.fanOut(func(i int, st sessionup.Store) error {
+		s, ok, err := st.FetchByID(ctx, id)
+		if ok {
+			res[i] = []sessionup.Session{s}
+		}
+
+		return err
+	})
+
+	if len(errs) > len(q.stores)-q.quorum {
+		return sessionup.Session{}, false, &Error{Errs: errs}
+	}
+
+	s, ok := q.agreed(res, id)
+	return s, ok, nil
+}
+
+// FetchByUserKey implements sessionup.Store interface's FetchByUserKey method.
+// Only sessions returned by at least quorum stores are included.
+// *Error is returned if too many stores fail for the quorum to be
+// reached.
+func (q *QuorumStore) FetchByUserKey(ctx context.Context, key string) ([]sessionup.Session, error) {
+	res := make([][]sessionup.Session, len(q.stores))
+	errs := q.fanOut(func(i int, st sessionup.Store) error {
+		ss, err := st.FetchByUserKey(ctx, key)
+		res[i] = ss
+		return err
+	})
+
+	if len(errs) > len(q.stores)-q.quorum {
+		return nil, &Error{Errs: errs}
+	}
+
+	ids := make(map[string]struct{})
+	for _, ss := range res {
+		for _, s := range ss {
+			ids[s.ID] = struct{}{}
+		}
+	}
+
+	var agreed []sessionup.Session
+	for id := range ids {
+		if s, ok := q.agreed(res, id); ok && s.UserKey == key {
+			agreed = append(agreed, s)
+		}
+	}
+
+	sessionup.SortSessions(agreed)
+	return agreed, nil
+}
+
+// DeleteByID implements sessionup.Store interface's DeleteByID method.
+// The session is deleted from all stores and *Error is returned if it
+// may still be present in quorum of them.
+func (q *QuorumStore) DeleteByID(ctx context.Context, id string) error {
+	return q.delete(func(st sessionup.Store) error {
+		return st.DeleteByID(ctx, id)
+	})
+}
+
+// DeleteByUserKey implements sessionup.Store interface's DeleteByUserKey method.
+// Sessions are deleted from all stores and *Error is returned if they
+// may still be present in quorum of them.
+func (q *QuorumStore) DeleteByUserKey(ctx context.Context, key string, expID ...string) error {
+	return q.delete(func(st sessionup.Store) error {
+		return st.DeleteByUserKey(ctx, key, expID...)
+	})
+}
+
+// DeleteByOrgKey implements sessionup.OrgStore interface's DeleteByOrgKey method.
+// Sessions are deleted from all stores and *Error is returned if they
+// may still be present in quorum of them.
+func (q *QuorumStore) DeleteByOrgKey(ctx context.Context, org string) error {
+	for _, st := range q.stores {
+		if _, ok := st.(sessionup.OrgStore); !ok {
+			return sessionup.ErrNotSupported
+		}
+	}
+
+	return q.delete(func(st sessionup.Store) error {
+		return st.(sessionup.OrgStore).DeleteByOrgKey(ctx, org)
+	})
+}
+
+// Update implements sessionup.UpdateStore interface's Update method.
+// The session is updated in all stores and *Error is returned if fewer
+// than quorum of them succeed.
+func (q *QuorumStore) Update(ctx context.Context, s sessionup.Session) error {
+	for _, st := range q.stores {
+		if _, ok := st.(sessionup.UpdateStore); !ok {
+			return sessionup.ErrNotSupported
+		}
+	}
+
+	return q.write(func(st sessionup.Store) error {
+		return st.(sessionup.UpdateStore).Update(ctx, s)
+	})
+}
+
+// agreed returns the session of the provided ID that is present in at
+// least quorum of the results.
+func (q *QuorumStore) agreed(res [][]sessionup.Session, id string) (sessionup.Session, bool) {
+	var cand []sessionup.Session
+	for _, ss := range res {
+		for _, s := range ss {
+			if s.ID == id {
+				cand = append(cand, s)
+				break
+			}
+		}
+	}
+
+	for _, a := range cand {
+		best, n := a, 0
+		for _, b := range cand {
+			if !equal(a, b) {
+				continue
+			}
+
+			n++
+			if b.Version > best.Version {
+				best = b
+			}
+		}
+
+		if n >= q.quorum {
+			return best, true
+		}
+	}
+
+	return sessionup.Session{}, false
+}
+
+// equal checks whether the security relevant fields of both sessions
+// are equal or not, tolerating differences of the expiration time below
+// a millisecond (as many databases truncate timestamps).
+func equal(a, b sessionup.Session) bool {
+	d := a.ExpiresAt.Sub(b.ExpiresAt)
+
+	return d > -time.Millisecond && d < time.Millisecond &&
+		a.ID == b.ID &&
+		a.UserKey == b.UserKey &&
+		a.OrgKey == b.OrgKey &&
+		a.Kind == b.Kind &&
+		a.Scope == b.Scope &&
+		a.ParentID == b.ParentID &&
+		a.CSRFToken == b.CSRFToken
+}
+
+// write calls the provided function for each store and returns *Error
+// if it fails on so many stores that fewer than quorum of them
+// succeeded.
+func (q *QuorumStore) write(fn func(sessionup.Store) error) error {
+	errs := q.fanOut(func(_ int, st sessionup.Store) error {
+		return fn(st)
+	})
+
+	if len(q.stores)-len(errs) < q.quorum {
+		return &Error{Errs: errs}
+	}
+
+	return nil
+}
+
+// delete calls the provided function for each store and returns *Error
+// if it fails on so many stores that the deleted data may still be
+// agreed on by quorum of them.
+func (q *QuorumStore) delete(fn func(sessionup.Store) error) error {
+	errs := q.fanOut(func(_ int, st sessionup.Store) error {
+		return fn(st)
+	})
+
+	if len(errs) >= q.quorum {
+		return &Error{Errs: errs}
+	}
+
+	return nil
+}
+
+// fanOut concurrently calls the provided function for each store and
+// collects the errors of the failed ones.
+func (q *QuorumStore) fanOut(fn func(int, sessionup.Store) error) map[int]error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs map[int]error
+	)
+
+	for i, st := range q.stores {
+		wg.Add(1)
+		go func(i int, st sessionup.Store) {
+			defer wg.Done()
+
+			if err := fn(i, st); err != nil {
+				mu.Lock()
+				if errs == nil {
+					errs = make(map[int]error)
+				}
+				errs[i] = err
+				mu.Unlock()
+			}
+		}(i, st)
+	}
+
+	wg.Wait()
+	return errs
+}
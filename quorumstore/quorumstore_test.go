@@ -0,0 +1,261 @@
+package quorumstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/memstore"
+	"github.com/swithek/sessionup/storetest"
+)
+
+// failingStore is a sessionup.Store that fails all operations.
+type failingStore struct {
+	sessionup.Store
+}
+
+func (failingStore) Create(_ context.Context, _ sessionup.Session) error {
+	return errors.New("error")
+}
+
+func (failingStore) FetchByID(_ context.Context, _ string) (sessionup.Session, bool, error) {
+	return sessionup.Session{}, false, errors.New("error")
+}
+
+func (failingStore) FetchByUserKey(_ context.Context, _ string) ([]sessionup.Session, error) {
+	return nil, errors.New("error")
+}
+
+func (failingStore) DeleteByID(_ context.Context, _ string) error {
+	return errors.New("error")
+}
+
+func (failingStore) DeleteByUserKey(_ context.Context, _ string, _ ...string) error {
+	return errors.New("error")
+}
+
+func TestType(t *testing.T) {
+	var _ sessionup.Store = &QuorumStore{}
+	var _ sessionup.UpdateStore = &QuorumStore{}
+	var _ sessionup.OrgStore = &QuorumStore{}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunStoreTests(t, func() sessionup.Store {
+		return New(2, memstore.New(0), memstore.New(0), memstore.New(0))
+	})
+}
+
+func BenchmarkConformance(b *testing.B) {
+	storetest.RunStoreBenchmarks(b, func() sessionup.Store {
+		return New(2, memstore.New(0), memstore.New(0), memstore.New(0))
+	})
+}
+
+func TestNew(t *testing.T) {
+	cc := map[string]struct {
+		Quorum int
+		Stores int
+		Want   int
+	}{
+		"Majority of odd": {
+			Quorum: 0,
+			Stores: 3,
+			Want:   2,
+		},
+		"Majority of even": {
+			Quorum: -1,
+			Stores: 4,
+			Want:   3,
+		},
+		"Above all": {
+			Quorum: 5,
+			Stores: 3,
+			Want:   3,
+		},
+		"Custom": {
+			Quorum: 1,
+			Stores: 3,
+			Want:   1,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			ss := make([]sessionup.Store, c.Stores)
+			for i := range ss {
+				ss[i] = memstore.New(0)
+			}
+
+			if q := New(c.Quorum, ss...); q.quorum != c.Want {
+				t.Errorf("want %d, got %d", c.Want, q.quorum)
+			}
+		})
+	}
+}
+
+func TestFetchByIDQuorum(t *testing.T) {
+	ctx := context.Background()
+	s1, s2, s3 := memstore.New(0), memstore.New(0), memstore.New(0)
+	q := New(2, s1, s2, s3)
+
+	// a session present in a single store is not accepted.
+	forged := storetest.NewSession("forged", "key")
+	if err := s1.Create(ctx, forged); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, err := q.FetchByID(ctx, "forged"); ok || err != nil {
+		t.Errorf("want false and nil, got %t and %v", ok, err)
+	}
+
+	if ss, err := q.FetchByUserKey(ctx, "key"); len(ss) != 0 || err != nil {
+		t.Errorf("want empty and nil, got %v and %v", ss, err)
+	}
+
+	// a session tampered in a single store is still accepted.
+	s := storetest.NewSession("id", "key")
+	if err := q.Create(ctx, s); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	tampered := s
+	tampered.UserKey = "admin"
+	if err := s3.DeleteByID(ctx, s.ID); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err := s3.Create(ctx, tampered); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	res, ok, err := q.FetchByID(ctx, s.ID)
+	if !ok || err != nil {
+		t.Fatalf("want true and nil, got %t and %v", ok, err)
+	}
+
+	if res.UserKey != "key" {
+		t.Errorf("want %q, got %q", "key", res.UserKey)
+	}
+
+	if ss, err := q.FetchByUserKey(ctx, "key"); len(ss) != 1 || err != nil {
+		t.Errorf("want %d and nil, got %d and %v", 1, len(ss), err)
+	}
+
+	if ss, err := q.FetchByUserKey(ctx, "admin"); len(ss) != 0 || err != nil {
+		t.Errorf("want empty and nil, got %v and %v", ss, err)
+	}
+
+	// once tampered differently in the second store, the quorum is lost.
+	if err := s2.DeleteByID(ctx, s.ID); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	tampered.UserKey = "root"
+	if err := s2.Create(ctx, tampered); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, err := q.FetchByID(ctx, s.ID); ok || err != nil {
+		t.Errorf("want false and nil, got %t and %v", ok, err)
+	}
+}
+
+func TestFetchByIDVersion(t *testing.T) {
+	ctx := context.Background()
+	s1, s2 := memstore.New(0), memstore.New(0)
+	q := New(2, s1, s2)
+
+	s := storetest.NewSession("id", "key")
+	if err := q.Create(ctx, s); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	s.Version = 1
+	s.Meta = map[string]string{"a": "b"}
+	if err := s2.Update(ctx, s); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	res, ok, err := q.FetchByID(ctx, s.ID)
+	if !ok || err != nil {
+		t.Fatalf("want true and nil, got %t and %v", ok, err)
+	}
+
+	if res.Version != 1 || res.Meta["a"] != "b" {
+		t.Errorf("want latest version, got %v", res)
+	}
+
+	s.ExpiresAt = s.ExpiresAt.Add(time.Hour)
+	if err := s2.Update(ctx, s); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, err := q.FetchByID(ctx, s.ID); ok || err != nil {
+		t.Errorf("want false and nil, got %t and %v", ok, err)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	ctx := context.Background()
+	ok1, ok2 := memstore.New(0), memstore.New(0)
+
+	q := New(2, ok1, ok2, failingStore{})
+	s := storetest.NewSession("id", "key")
+	if err := q.Create(ctx, s); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, err := q.FetchByID(ctx, s.ID); !ok || err != nil {
+		t.Errorf("want true and nil, got %t and %v", ok, err)
+	}
+
+	if ss, err := q.FetchByUserKey(ctx, "key"); len(ss) != 1 || err != nil {
+		t.Errorf("want %d and nil, got %d and %v", 1, len(ss), err)
+	}
+
+	if err := q.DeleteByID(ctx, s.ID); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	q = New(2, ok1, failingStore{}, failingStore{})
+
+	var qerr *Error
+	if err := q.Create(ctx, s); !errors.As(err, &qerr) {
+		t.Fatalf("want %T, got %v", qerr, err)
+	}
+
+	if len(qerr.Errs) != 2 {
+		t.Errorf("want %d, got %d", 2, len(qerr.Errs))
+	}
+
+	if qerr.Error() != "2 store(s) failed: store 1: error; store 2: error" {
+		t.Errorf("want %q, got %q", "2 store(s) failed: store 1: error; store 2: error", qerr.Error())
+	}
+
+	if _, _, err := q.FetchByID(ctx, s.ID); !errors.As(err, &qerr) {
+		t.Errorf("want %T, got %v", qerr, err)
+	}
+
+	if _, err := q.FetchByUserKey(ctx, "key"); !errors.As(err, &qerr) {
+		t.Errorf("want %T, got %v", qerr, err)
+	}
+
+	if err := q.DeleteByUserKey(ctx, "key"); !errors.As(err, &qerr) {
+		t.Errorf("want %T, got %v", qerr, err)
+	}
+
+	if err := q.DeleteByOrgKey(ctx, "org"); err != sessionup.ErrNotSupported {
+		t.Errorf("want %v, got %v", sessionup.ErrNotSupported, err)
+	}
+
+	if err := q.Update(ctx, s); err != sessionup.ErrNotSupported {
+		t.Errorf("want %v, got %v", sessionup.ErrNotSupported, err)
+	}
+}
@@ -0,0 +1,93 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RangeStore is an optional Store capability used to delete sessions
+// by their creation time without fetching them first (see
+// RevokeCreatedBetween).
+type RangeStore interface {
+	// DeleteCreatedBetween should delete all sessions, that have the
+	// provided user key and were created at or after the from time and
+	// before the to time, from the store and return them.
+	// If no sessions are found, this function should be no-op and
+	// return nil values.
+	// Error should be returned on system errors only.
+	DeleteCreatedBetween(ctx context.Context, key string, from, to time.Time) ([]Session, error)
+}
+
+// RevokeCreatedBetween deletes all sessions under the provided user key
+// that were created at or after the from time and before the to time
+// (e.g. when the user reports that their account was accessed on a
+// specific day), while keeping the older and newer sessions intact.
+// Device trust records (see TrustDevice) created in that window are
+// deleted as well.
+// If the store implements RangeStore interface, sessions are deleted by
+// it directly, otherwise (or if it returns ErrNotSupported) they are
+// fetched and deleted one by one.
+// Function will be no-op and return nil, if no sessions are found.
+func (m *Manager) RevokeCreatedBetween(ctx context.Context, key string, from, to time.Time) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if !from.Before(to) {
+		return nil
+	}
+
+	if rs, ok := m.store.(RangeStore); ok {
+		ss, err := rs.DeleteCreatedBetween(ctx, key, from, to)
+		if !errors.Is(err, ErrNotSupported) {
+			return m.rangeRevoked(ctx, key, ss, err)
+		}
+	}
+
+	ss, err := m.store.FetchByUserKey(ctx, key)
+	if err = m.storeErr(err); err != nil {
+		return err
+	}
+
+	for _, s := range ss {
+		if s.CreatedAt.Before(from) || !s.CreatedAt.Before(to) {
+			continue
+		}
+
+		if err := m.revokeSession(ctx, s); err != nil {
+			return err
+		}
+
+		m.notifyRevoked(key, s)
+	}
+
+	return nil
+}
+
+// rangeRevoked completes the revocation of the sessions deleted by
+// the RangeStore.
+func (m *Manager) rangeRevoked(ctx context.Context, key string, ss []Session, err error) error {
+	if err = m.storeErr(err); err != nil {
+		return err
+	}
+
+	for _, s := range ss {
+		m.forgetActive(s.ID)
+		if err := m.record(ctx, Revocation{ID: s.ID}); err != nil {
+			return err
+		}
+
+		m.notifyRevoked(key, s)
+		if err := m.deleteBlobs(ctx, s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyRevoked notifies about the revoked session.
+func (m *Manager) notifyRevoked(key string, s Session) {
+	m.notify(key, Event{Type: EventRevoked, Session: s, Revocation: Revocation{ID: s.ID}})
+}
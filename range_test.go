@@ -0,0 +1,167 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type rangeStoreMock struct {
+	*StoreMock
+	DeleteCreatedBetweenFunc func(ctx context.Context, key string, from, to time.Time) ([]Session, error)
+}
+
+func (r *rangeStoreMock) DeleteCreatedBetween(ctx context.Context, key string, from, to time.Time) ([]Session, error) {
+	return r.DeleteCreatedBetweenFunc(ctx, key, from, to)
+}
+
+func TestRevokeCreatedBetween(t *testing.T) {
+	now := time.Now()
+	ss := []Session{
+		{ID: "1", UserKey: "key", CreatedAt: now.Add(-time.Hour * 3)},
+		{ID: "2", UserKey: "key", CreatedAt: now.Add(-time.Hour * 2)},
+		{ID: "3", UserKey: "key", CreatedAt: now.Add(-time.Hour)},
+		{ID: "4", UserKey: "key", CreatedAt: now},
+	}
+
+	fetching := func(err error) (*StoreMock, *[]string) {
+		var deleted []string
+		return &StoreMock{
+			FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+				return ss, err
+			},
+			DeleteByIDFunc: func(_ context.Context, id string) error {
+				deleted = append(deleted, id)
+				return nil
+			},
+		}, &deleted
+	}
+
+	cc := map[string]struct {
+		Ctx     context.Context
+		From    time.Time
+		To      time.Time
+		Err     error
+		Deleted []string
+	}{
+		"Nil context": {
+			Err: ErrNilContext,
+		},
+		"Empty range": {
+			Ctx:  context.Background(),
+			From: now,
+			To:   now.Add(-time.Hour),
+		},
+		"Range": {
+			Ctx:     context.Background(),
+			From:    now.Add(-time.Hour * 2),
+			To:      now,
+			Deleted: []string{"2", "3"},
+		},
+		"Open-ended range": {
+			Ctx:     context.Background(),
+			From:    now.Add(-time.Minute),
+			To:      now.Add(time.Hour),
+			Deleted: []string{"4"},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			store, deleted := fetching(nil)
+			m := NewManager(store)
+			err := m.RevokeCreatedBetween(c.Ctx, "key", c.From, c.To)
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if !reflect.DeepEqual(*deleted, c.Deleted) {
+				t.Errorf("want %v, got %v", c.Deleted, *deleted)
+			}
+		})
+	}
+
+	// prefixed stores implement RangeStore regardless of the
+	// underlying store, so ErrNotSupported falls back to fetching.
+	store, deleted := fetching(nil)
+	if err := NewManager(store, KeyPrefix("app:")).RevokeCreatedBetween(context.Background(), "key", now.Add(-time.Hour*2), now); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if want := []string{"app:2", "app:3"}; !reflect.DeepEqual(*deleted, want) {
+		t.Errorf("want %v, got %v", want, *deleted)
+	}
+
+	store, _ = fetching(errors.New("error"))
+	if err := NewManager(store).RevokeCreatedBetween(context.Background(), "key", now.Add(-time.Hour), now); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestRevokeCreatedBetweenRangeStore(t *testing.T) {
+	now := time.Now()
+	var (
+		gotKey   string
+		gotRange [2]time.Time
+		fail     error
+	)
+
+	store := &rangeStoreMock{
+		StoreMock: &StoreMock{},
+		DeleteCreatedBetweenFunc: func(_ context.Context, key string, from, to time.Time) ([]Session, error) {
+			gotKey, gotRange = key, [2]time.Time{from, to}
+			if fail != nil {
+				return nil, fail
+			}
+
+			return []Session{{ID: "app:1", UserKey: key}, {ID: "app:2", UserKey: key}}, nil
+		},
+	}
+
+	var digests []Digest
+	n := NewNotifier(time.Hour, func(d Digest) { digests = append(digests, d) })
+	m := NewManager(store, KeyPrefix("app:"), Notifications(n))
+
+	if err := m.RevokeCreatedBetween(context.Background(), "key", now.Add(-time.Hour), now); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if gotKey != "app:key" || !gotRange[0].Equal(now.Add(-time.Hour)) || !gotRange[1].Equal(now) {
+		t.Errorf("want prefixed key and range, got %q and %v", gotKey, gotRange)
+	}
+
+	n.Flush()
+	if len(digests) != 1 || len(digests[0].Events) != 2 {
+		t.Fatalf("want %d events, got %v", 2, digests)
+	}
+
+	var ids []string
+	for _, e := range digests[0].Events {
+		if e.Type != EventRevoked {
+			t.Errorf("want %v, got %v", EventRevoked, e.Type)
+		}
+
+		ids = append(ids, e.Revocation.ID)
+	}
+
+	sort.Strings(ids)
+	if !reflect.DeepEqual(ids, []string{"1", "2"}) {
+		t.Errorf("want %v, got %v", []string{"1", "2"}, ids)
+	}
+
+	fail = errors.New("error")
+	if err := m.RevokeCreatedBetween(context.Background(), "key", now.Add(-time.Hour), now); err != fail {
+		t.Errorf("want %v, got %v", fail, err)
+	}
+
+	if len(store.FetchByUserKeyCalls()) != 0 {
+		t.Errorf("want %d, got %d", 0, len(store.FetchByUserKeyCalls()))
+	}
+}
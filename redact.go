@@ -0,0 +1,106 @@
+package sessionup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+)
+
+// Redactor is used to remove or mask sensitive session data before the
+// session reaches hooks (OnCanary, OnAnomaly), loggers, audit sinks and
+// other consumers (see Manager.Redacted).
+// Implementations must not modify the provided session's maps and
+// slices; they should be copied instead.
+type Redactor interface {
+	Redact(s Session) Session
+}
+
+// RedactorFunc is an adapter that allows ordinary functions to be used
+// as Redactors.
+type RedactorFunc func(s Session) Session
+
+// Redact implements Redactor interface.
+func (f RedactorFunc) Redact(s Session) Session {
+	return f(s)
+}
+
+// Redaction sets the Redactor which will be applied to sessions passed
+// to hooks and returned by Manager.Redacted.
+// By default it is not set and sessions are passed unchanged.
+func Redaction(r Redactor) Option {
+	return func(m *Manager) {
+		m.redactor = r
+	}
+}
+
+// Redacted applies the manager's Redactor to the provided session.
+// It should be used before sessions are passed to loggers, audit sinks
+// or export APIs.
+func (m *Manager) Redacted(s Session) Session {
+	if m.redactor == nil {
+		return s
+	}
+
+	return m.redactor.Redact(s)
+}
+
+// ChainRedactors produces a Redactor that applies the provided
+// Redactors in order.
+func ChainRedactors(rr ...Redactor) Redactor {
+	return RedactorFunc(func(s Session) Session {
+		for _, r := range rr {
+			s = r.Redact(s)
+		}
+
+		return s
+	})
+}
+
+// MaskIP produces a Redactor that zeroes the last octet of IPv4
+// addresses and the last 80 bits of IPv6 addresses.
+func MaskIP() Redactor {
+	return RedactorFunc(func(s Session) Session {
+		if len(s.IP) == 0 {
+			return s
+		}
+
+		if ip4 := s.IP.To4(); ip4 != nil {
+			s.IP = ip4.Mask(net.CIDRMask(24, 32))
+			return s
+		}
+
+		s.IP = s.IP.Mask(net.CIDRMask(48, 128))
+		return s
+	})
+}
+
+// DropAgent produces a Redactor that removes User-Agent data.
+func DropAgent() Redactor {
+	return RedactorFunc(func(s Session) Session {
+		s.Agent.OS = ""
+		s.Agent.Browser = ""
+		s.Agent.Device = ""
+		s.Agent.Version = ""
+		return s
+	})
+}
+
+// HashUserKey produces a Redactor that replaces user and organization
+// keys with their salted SHA-256 hashes, so that sessions of the same
+// user can still be correlated.
+func HashUserKey(salt string) Redactor {
+	hash := func(key string) string {
+		if key == "" {
+			return ""
+		}
+
+		sum := sha256.Sum256([]byte(salt + key))
+		return hex.EncodeToString(sum[:])
+	}
+
+	return RedactorFunc(func(s Session) Session {
+		s.UserKey = hash(s.UserKey)
+		s.OrgKey = hash(s.OrgKey)
+		return s
+	})
+}
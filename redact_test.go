@@ -0,0 +1,113 @@
+package sessionup
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	m := Manager{}
+	Redaction(DropAgent())(&m)
+	if m.redactor == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	s := Session{ID: "id", UserKey: "key"}
+	m := Manager{}
+	if res := m.Redacted(s); !reflect.DeepEqual(s, res) {
+		t.Errorf("want %v, got %v", s, res)
+	}
+
+	m.redactor = RedactorFunc(func(s Session) Session {
+		s.ID = ""
+		return s
+	})
+
+	if res := m.Redacted(s); res.ID != "" || s.ID != "id" {
+		t.Errorf("want %q/%q, got %q/%q", "", "id", res.ID, s.ID)
+	}
+}
+
+func TestMaskIP(t *testing.T) {
+	cc := map[string]string{
+		"":                       "<nil>",
+		"192.168.1.42":           "192.168.1.0",
+		"2001:db8:aa:bb:cc::1":   "2001:db8:aa::",
+		"::ffff:192.168.100.200": "192.168.100.0",
+	}
+
+	for ip, exp := range cc {
+		orig := net.ParseIP(ip)
+		s := MaskIP().Redact(Session{IP: orig})
+		if res := s.IP.String(); res != exp {
+			t.Errorf("%q: want %q, got %q", ip, exp, res)
+		}
+
+		if ip != "" && !orig.Equal(net.ParseIP(ip)) {
+			t.Errorf("%q: want original IP untouched, got %v", ip, orig)
+		}
+	}
+}
+
+func TestChainRedactors(t *testing.T) {
+	s := Session{UserKey: "key", OrgKey: "org", IP: net.ParseIP("10.0.0.1")}
+	s.Agent.OS = "Linux"
+	s.Agent.Browser = "Firefox"
+	s.Agent.Device = DeviceDesktop
+	s.Agent.Version = "120"
+
+	res := ChainRedactors(MaskIP(), DropAgent(), HashUserKey("salt")).Redact(s)
+	if res.Agent.OS != "" || res.Agent.Browser != "" || res.Agent.Device != "" || res.Agent.Version != "" {
+		t.Errorf("want empty agent, got %v", res.Agent)
+	}
+
+	if res.IP.String() != "10.0.0.0" {
+		t.Errorf("want %q, got %q", "10.0.0.0", res.IP.String())
+	}
+
+	if len(res.UserKey) != 64 || res.UserKey == HashUserKey("other").Redact(s).UserKey {
+		t.Errorf("want salted hash, got %q", res.UserKey)
+	}
+
+	if res.UserKey != HashUserKey("salt").Redact(s).UserKey {
+		t.Error("want stable hash, got a different one")
+	}
+
+	if res.OrgKey == "org" || HashUserKey("salt").Redact(Session{}).OrgKey != "" {
+		t.Errorf("want hashed non-empty keys only, got %q", res.OrgKey)
+	}
+}
+
+func TestRedactedCanary(t *testing.T) {
+	s := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id, UserKey: "key", Kind: KindCanary}, true, nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}
+
+	var key string
+	m := NewManager(s, Redaction(HashUserKey("")), OnCanary(func(_ *http.Request, ses Session, _ error) {
+		key = ses.UserKey
+	}))
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.AddCookie(&http.Cookie{Name: defaultName, Value: "canary"})
+	m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+	if key == "" || key == "key" {
+		t.Errorf("want hashed key, got %q", key)
+	}
+
+	if ff := s.DeleteByUserKeyCalls(); len(ff) != 1 || ff[0].Key != "key" {
+		t.Errorf("want revocation of %q sessions, got %v", "key", ff)
+	}
+}
@@ -0,0 +1,85 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// reissueMetaKey holds the metadata key of the marker set by
+	// ScheduleReissue.
+	reissueMetaKey = "sessionup_reissue"
+
+	// reissueTTL specifies how long the marker set by ScheduleReissue
+	// remains valid.
+	reissueTTL = time.Minute * 15
+)
+
+// ScheduleReissue schedules the cookie of the session stored in the
+// context to be re-issued on the next authenticated response. It should
+// be used instead of setting the cookie directly when the response's
+// headers were already sent, e.g. when the session is extended in the
+// middle of a server-sent events stream or a long-poll, so that rolling
+// expiration keeps working for streaming-heavy applications.
+// The schedule is kept as a short-lived (15 minutes) marker in the
+// session's metadata, so the store must implement either CASStore or
+// UpdateStore interface, otherwise ErrNotSupported is returned.
+// ErrUnauthorized is returned if the context session is not set.
+func (m *Manager) ScheduleReissue(ctx context.Context) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	cs, ok := FromContext(ctx)
+	if !ok {
+		return ErrUnauthorized
+	}
+
+	exp := strconv.FormatInt(time.Now().Add(reissueTTL).Unix(), 10)
+	return m.UpdateMeta(ctx, cs.ID, MetaEntry(reissueMetaKey, exp))
+}
+
+// reissue re-issues the session's cookies if ScheduleReissue was called
+// and removes the marker. Failures to remove the marker are ignored,
+// since re-issuing the cookie again is harmless.
+func (m *Manager) reissue(ctx context.Context, w http.ResponseWriter, s Session) Session {
+	v, ok := s.Meta[reissueMetaKey]
+	if !ok {
+		return s
+	}
+
+	meta := make(map[string]string, len(s.Meta))
+	for k, v := range s.Meta {
+		if k != reissueMetaKey {
+			meta[k] = v
+		}
+	}
+
+	ns := s
+	ns.Meta = meta
+	if len(meta) == 0 {
+		ns.Meta = nil
+	}
+
+	ns, err := m.update(ctx, ns)
+	if err == nil {
+		s = ns
+	}
+
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || time.Now().Unix() > sec {
+		return s
+	}
+
+	if err = m.setSessionCookie(w, s.CookieExpiresAt, s); err != nil {
+		return s
+	}
+
+	if m.csrfActive() {
+		m.setCSRFCookie(w, s.CookieExpiresAt, s.CSRFToken)
+	}
+
+	return s
+}
@@ -0,0 +1,91 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestScheduleReissue(t *testing.T) {
+	ms := mapStore()
+	store := &updateStoreMock{
+		StoreMock: ms,
+		UpdateFunc: func(ctx context.Context, s Session) error {
+			return ms.Create(ctx, s)
+		},
+	}
+
+	m := NewManager(store, ExpiresIn(time.Hour))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if err := m.Init(rec, req, "key", MetaEntry("a", "b")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	c := rec.Result().Cookies()[0]
+	auth := func() []*http.Cookie {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.AddCookie(c)
+		m.Auth(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("want %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		return rec.Result().Cookies()
+	}
+
+	ctx := context.Background()
+	if err := m.ScheduleReissue(ctx); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	ctx = NewContext(ctx, Session{ID: c.Value})
+	if err := m.ScheduleReissue(ctx); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if cc := auth(); len(cc) != 1 || cc[0].Name != defaultName || cc[0].Value != c.Value {
+		t.Errorf("want session cookie, got %v", cc)
+	} else if cc[0].Expires.Before(time.Now().Add(time.Minute * 59)) {
+		t.Errorf("want after %v, got %v", time.Now().Add(time.Minute*59), cc[0].Expires)
+	}
+
+	s, _, _ := ms.FetchByID(ctx, c.Value)
+	if _, ok := s.Meta[reissueMetaKey]; ok || s.Meta["a"] != "b" {
+		t.Errorf("want marker removed, got %v", s.Meta)
+	}
+
+	if cc := auth(); len(cc) != 0 {
+		t.Errorf("want %d, got %d", 0, len(cc))
+	}
+
+	// expired markers are removed without re-issuing the cookie.
+	err := m.UpdateMeta(ctx, c.Value, MetaEntry(reissueMetaKey, strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if cc := auth(); len(cc) != 0 {
+		t.Errorf("want %d, got %d", 0, len(cc))
+	}
+
+	s, _, _ = ms.FetchByID(ctx, c.Value)
+	if _, ok := s.Meta[reissueMetaKey]; ok {
+		t.Errorf("want marker removed, got %v", s.Meta)
+	}
+
+	m = NewManager(&StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id}, true, nil
+		},
+	})
+
+	if err = m.ScheduleReissue(ctx); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+}
@@ -0,0 +1,406 @@
+package sessionup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultReturnParam = "return_to"
+
+// RejectConfig holds the data needed to produce content negotiated
+// rejection responses.
+type RejectConfig struct {
+	// Challenge specifies the value of the 'WWW-Authenticate' header
+	// sent on 401 responses. Empty string omits the header.
+	Challenge string
+
+	// LoginURL specifies the URL browser clients are redirected to.
+	// The originally requested URI is passed to it in the query
+	// parameter specified by ReturnParam.
+	LoginURL string
+
+	// ReturnParam specifies the name of the query parameter that
+	// holds the originally requested URI.
+	// Defaults to the value stored in defaultReturnParam.
+	ReturnParam string
+
+	// Template specifies the HTML page rendered for browser clients
+	// when LoginURL is not set. It is executed with RejectData.
+	Template *template.Template
+
+	// CacheSize specifies the maximum number of rendered HTML pages
+	// kept in memory, so that high rates of unauthenticated traffic
+	// do not render the template over and over again. Pages are
+	// cached per error class, language, return URI and content
+	// encoding.
+	// JSON bodies are always cached, since their number is bounded.
+	// Zero disables HTML page caching.
+	CacheSize int
+
+	// CacheTTL specifies how long cached HTML pages are kept.
+	// Zero keeps them until they are evicted.
+	CacheTTL time.Duration
+
+	// Expvar specifies the name of the expvar map (see Expvar option)
+	// to which cache hits are counted. Empty string disables counting.
+	Expvar string
+
+	// Translator specifies the translator used to localize error
+	// messages of both JSON and HTML responses, based on the session's
+	// locale (see LocaleMeta) and the 'Accept-Language' header.
+	// By default it is not set and messages are sent in English.
+	Translator Translator
+
+	// LocaleMeta specifies the metadata key under which sessions hold
+	// the user's preferred language, which takes precedence over the
+	// 'Accept-Language' header.
+	// By default it is not set.
+	LocaleMeta string
+}
+
+// RejectData holds the data passed to rejection HTML templates.
+type RejectData struct {
+	// Error specifies the client-safe error message, localized if
+	// the Translator is set.
+	Error string
+
+	// Lang specifies the language of the error message. It is empty
+	// if the message was not translated.
+	Lang string
+
+	// Status specifies the HTTP status code of the response.
+	Status int
+
+	// ReturnTo specifies the originally requested URI.
+	ReturnTo string
+}
+
+// NegotiatedReject produces a rejection function that honors the
+// client's content preferences: browser clients (that prefer HTML over
+// JSON) are redirected to the login URL or shown the configured HTML
+// page, while the rest receive the same JSON response as produced by
+// ChallengeReject. Response bodies are gzip-compressed for clients
+// that accept it and error messages are localized if the Translator is
+// set.
+// If the request's context is already done (e.g. its deadline has
+// passed) and the HTML page is not cached, the page is not rendered and
+// the JSON response is sent instead.
+func NegotiatedReject(cfg RejectConfig) func(error) http.Handler {
+	if cfg.ReturnParam == "" {
+		cfg.ReturnParam = defaultReturnParam
+	}
+
+	pages := newRejectCache(cfg.CacheSize, cfg.CacheTTL)
+	stats := publishStats(cfg.Expvar)
+
+	return func(err error) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", "Accept-Encoding")
+			w.Header().Set("Cache-Control", "no-store")
+
+			if cfg.Translator != nil {
+				w.Header().Add("Vary", "Accept-Language")
+			}
+
+			msg, lang := localize(r, cfg.Translator, cfg.LocaleMeta, PublicError(err))
+			if lang != "" {
+				w.Header().Set("Content-Language", lang)
+			}
+
+			var (
+				gz   = acceptsGzip(r)
+				key  string
+				body []byte
+				html = prefersHTML(r) && (cfg.LoginURL != "" || cfg.Template != nil)
+			)
+
+			if html && cfg.LoginURL == "" {
+				key = fmt.Sprintf("%s\x00%s\x00%s\x00%t", msg, lang, r.URL.RequestURI(), gz)
+				body, _ = pages.get(key)
+				if body != nil && stats != nil {
+					stats.Add(statRejectCacheHits, 1)
+				}
+
+				// rendering is skipped if the request is already
+				// cancelled or past its deadline.
+				html = body != nil || r.Context().Err() == nil
+			}
+
+			if !html {
+				if cfg.Challenge != "" {
+					w.Header().Set("WWW-Authenticate", cfg.Challenge)
+				}
+
+				writeBody(w, r, http.StatusUnauthorized, "application/json", errorBody(msg))
+				return
+			}
+
+			if cfg.LoginURL != "" {
+				http.Redirect(w, r, loginURL(cfg.LoginURL, cfg.ReturnParam, r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+
+			if body == nil {
+				var buf bytes.Buffer
+				if err := cfg.Template.Execute(&buf, RejectData{
+					Error:    msg,
+					Lang:     lang,
+					Status:   http.StatusUnauthorized,
+					ReturnTo: r.URL.RequestURI(),
+				}); err != nil {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+
+				body = buf.Bytes()
+				if gz {
+					body = gzipBody(body)
+				}
+
+				pages.set(key, body)
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if gz {
+				w.Header().Set("Content-Encoding", "gzip")
+			}
+
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(body)
+		})
+	}
+}
+
+// SafeReturnTo extracts the return URI from the request's query
+// parameter and checks whether it points to the same origin (i.e. it
+// is an absolute path without scheme and host) or not, so that it can
+// be safely used in redirects after login. "/" is returned if the
+// parameter is missing or invalid.
+func SafeReturnTo(r *http.Request, param string) string {
+	if param == "" {
+		param = defaultReturnParam
+	}
+
+	rt := r.URL.Query().Get(param)
+	if !strings.HasPrefix(rt, "/") || strings.HasPrefix(rt, "//") || strings.HasPrefix(rt, "/\\") {
+		return "/"
+	}
+
+	for _, c := range rt {
+		if c < 0x20 || c == 0x7f || c == '\\' {
+			return "/"
+		}
+	}
+
+	u, err := url.Parse(rt)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "/"
+	}
+
+	return rt
+}
+
+// loginURL appends the return URI to the login URL.
+func loginURL(login, param, rt string) string {
+	u, err := url.Parse(login)
+	if err != nil {
+		return login
+	}
+
+	q := u.Query()
+	q.Set(param, rt)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// prefersHTML checks whether the client prefers HTML over JSON
+// responses or not, based on the 'Accept' header.
+func prefersHTML(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return acceptQuality(accept, "text/html") > acceptQuality(accept, "application/json")
+}
+
+// acceptQuality determines the quality value the 'Accept' header
+// assigns to the provided media type.
+func acceptQuality(accept, typ string) float64 {
+	var q float64
+	for _, part := range strings.Split(accept, ",") {
+		pp := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(pp[0]))
+		if mt != typ && mt != "*/*" && mt != typ[:strings.Index(typ, "/")]+"/*" {
+			continue
+		}
+
+		mq := 1.0
+		for _, p := range pp[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					mq = v
+				}
+			}
+		}
+
+		if mt == typ {
+			return mq
+		}
+
+		if mq > q {
+			q = mq
+		}
+	}
+
+	return q
+}
+
+// acceptsGzip checks whether the client accepts gzip-compressed
+// responses or not.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		pp := strings.Split(part, ";")
+		if strings.TrimSpace(strings.ToLower(pp[0])) != "gzip" {
+			continue
+		}
+
+		for _, p := range pp[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				v, err := strconv.ParseFloat(p[2:], 64)
+				return err == nil && v > 0
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// writeBody writes the response body, compressing it if the client
+// accepts it.
+func writeBody(w http.ResponseWriter, r *http.Request, code int, typ string, body []byte) {
+	w.Header().Set("Content-Type", typ)
+	if !acceptsGzip(r) {
+		w.WriteHeader(code)
+		w.Write(body)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(code)
+	w.Write(gzipBody(body))
+}
+
+// gzipBody compresses the provided body.
+func gzipBody(body []byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(body)
+	gw.Close()
+	return buf.Bytes()
+}
+
+// errorBodies holds encoded JSON error bodies keyed by public error
+// messages (possibly translated), whose number is bounded.
+var errorBodies sync.Map
+
+// errorBody produces the JSON body of the rejection response with the
+// provided public error message.
+func errorBody(msg string) []byte {
+	if b, ok := errorBodies.Load(msg); ok {
+		return b.([]byte)
+	}
+
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(struct {
+		Error string `json:"error"`
+	}{Error: msg})
+
+	b, _ := errorBodies.LoadOrStore(msg, buf.Bytes())
+	return b.([]byte)
+}
+
+// rejectCache is a size-bounded cache of rendered rejection bodies.
+type rejectCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]rejectEntry
+}
+
+// rejectEntry holds a single rendered rejection body.
+type rejectEntry struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+// newRejectCache creates a new cache. Nil is returned if the size
+// is not positive.
+func newRejectCache(size int, ttl time.Duration) *rejectCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &rejectCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]rejectEntry),
+	}
+}
+
+// get retrieves a non-expired body from the cache.
+func (c *rejectCache) get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !e.expiresAt.IsZero() && !e.expiresAt.After(time.Now()) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return e.body, true
+}
+
+// set inserts the body into the cache, evicting an arbitrary entry
+// if the cache is full.
+func (c *rejectCache) set(key string, body []byte) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+
+	var exp time.Time
+	if c.ttl > 0 {
+		exp = time.Now().Add(c.ttl)
+	}
+
+	c.entries[key] = rejectEntry{body: body, expiresAt: exp}
+}
@@ -0,0 +1,236 @@
+package sessionup
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiatedReject(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`<p>{{ .Error }} {{ .ReturnTo }}</p>`))
+
+	cc := map[string]struct {
+		Config   RejectConfig
+		Accept   string
+		Encoding string
+		Code     int
+		Type     string
+		Body     string
+		Location string
+	}{
+		"JSON client": {
+			Config: RejectConfig{Challenge: "Cookie", LoginURL: "/login"},
+			Accept: "application/json",
+			Code:   http.StatusUnauthorized,
+			Type:   "application/json",
+			Body:   `{"error":"unauthorized"}` + "\n",
+		},
+		"Browser client without HTML config": {
+			Accept: "text/html,*/*;q=0.8",
+			Code:   http.StatusUnauthorized,
+			Type:   "application/json",
+			Body:   `{"error":"unauthorized"}` + "\n",
+		},
+		"Compressed JSON": {
+			Accept:   "*/*",
+			Encoding: "deflate, gzip",
+			Code:     http.StatusUnauthorized,
+			Type:     "application/json",
+			Body:     `{"error":"unauthorized"}` + "\n",
+		},
+		"Browser client redirected to login": {
+			Config:   RejectConfig{LoginURL: "/login?lang=en"},
+			Accept:   "text/html,application/xhtml+xml,*/*;q=0.8",
+			Code:     http.StatusSeeOther,
+			Location: "/login?lang=en&return_to=%2Fprivate%3Fa%3D1",
+		},
+		"Browser client shown HTML page": {
+			Config:   RejectConfig{Template: tmpl},
+			Accept:   "text/html",
+			Encoding: "gzip;q=0",
+			Code:     http.StatusUnauthorized,
+			Type:     "text/html; charset=utf-8",
+			Body:     "<p>unauthorized /private?a=1</p>",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://example.com/private?a=1", nil)
+			req.Header.Set("Accept", c.Accept)
+			req.Header.Set("Accept-Encoding", c.Encoding)
+			NegotiatedReject(c.Config)(errors.New("internal")).ServeHTTP(rec, req)
+
+			if rec.Code != c.Code {
+				t.Errorf("want %d, got %d", c.Code, rec.Code)
+			}
+
+			if loc := rec.Header().Get("Location"); loc != c.Location {
+				t.Errorf("want %q, got %q", c.Location, loc)
+			}
+
+			if c.Type == "" {
+				return
+			}
+
+			if typ := rec.Header().Get("Content-Type"); typ != c.Type {
+				t.Errorf("want %q, got %q", c.Type, typ)
+			}
+
+			body := rec.Body.String()
+			if strings.Contains(c.Encoding, "gzip") && !strings.Contains(c.Encoding, "q=0") {
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("want nil, got %v", err)
+				}
+
+				bd, _ := ioutil.ReadAll(zr)
+				body = string(bd)
+			}
+
+			if body != c.Body {
+				t.Errorf("want %q, got %q", c.Body, body)
+			}
+		})
+	}
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	cc := map[string]string{
+		"":                          "/",
+		"/private?a=1":              "/private?a=1",
+		"//evil.com":                "/",
+		"/\\evil.com":               "/",
+		"https://evil.com/":         "/",
+		"private":                   "/",
+		"/a%0d%0aSet-Cookie:%20x=1": "/",
+	}
+
+	for rt, exp := range cc {
+		req := httptest.NewRequest("GET", "http://example.com/login", nil)
+		q := req.URL.Query()
+		q.Set("next", rt)
+		req.URL.RawQuery = q.Encode()
+		if strings.Contains(rt, "%") {
+			req.URL.RawQuery = "next=" + rt
+		}
+
+		if res := SafeReturnTo(req, "next"); res != exp {
+			t.Errorf("%q: want %q, got %q", rt, exp, res)
+		}
+	}
+}
+
+func TestAcceptQuality(t *testing.T) {
+	if q := acceptQuality("text/*;q=0.5, application/json", "text/html"); q != 0.5 {
+		t.Errorf("want %v, got %v", 0.5, q)
+	}
+
+	if q := acceptQuality("", "text/html"); q != 0 {
+		t.Errorf("want %v, got %v", 0, q)
+	}
+}
+
+func TestNegotiatedRejectCache(t *testing.T) {
+	var rendered int
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{
+		"count": func() string {
+			rendered++
+			return ""
+		},
+	}).Parse(`{{ count }}<p>{{ .Error }} {{ .ReturnTo }}</p>`))
+
+	rej := NegotiatedReject(RejectConfig{Template: tmpl, CacheSize: 2})
+	serve := func(ctx context.Context, target, enc string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", target, nil).WithContext(ctx)
+		req.Header.Set("Accept", "text/html")
+		req.Header.Set("Accept-Encoding", enc)
+		rej(ErrUnauthorized).ServeHTTP(rec, req)
+		return rec
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		rec := serve(ctx, "http://example.com/a", "")
+		if body := rec.Body.String(); body != "<p>unauthorized /a</p>" {
+			t.Errorf("want %q, got %q", "<p>unauthorized /a</p>", body)
+		}
+	}
+
+	if rendered != 1 {
+		t.Errorf("want %d, got %d", 1, rendered)
+	}
+
+	if rec := serve(ctx, "http://example.com/a", "gzip"); rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("want %q, got %q", "gzip", rec.Header().Get("Content-Encoding"))
+	}
+
+	if rendered != 2 {
+		t.Errorf("want %d, got %d", 2, rendered)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if rec := serve(cctx, "http://example.com/b", ""); rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("want %q, got %q", "application/json", rec.Header().Get("Content-Type"))
+	}
+
+	if rec := serve(cctx, "http://example.com/a", ""); rec.Body.String() != "<p>unauthorized /a</p>" {
+		t.Errorf("want cached page, got %q", rec.Body.String())
+	}
+
+	if rendered != 2 {
+		t.Errorf("want %d, got %d", 2, rendered)
+	}
+}
+
+func TestRejectCache(t *testing.T) {
+	var c *rejectCache
+	c.set("a", []byte("a"))
+	if _, ok := c.get("a"); ok {
+		t.Errorf("want %t, got %t", false, ok)
+	}
+
+	c = newRejectCache(2, time.Millisecond*50)
+	c.set("a", []byte("a"))
+	c.set("b", []byte("b"))
+	c.set("b", []byte("b"))
+	if len(c.entries) != 2 {
+		t.Errorf("want %d, got %d", 2, len(c.entries))
+	}
+
+	c.set("c", []byte("c"))
+	if len(c.entries) != 2 {
+		t.Errorf("want %d, got %d", 2, len(c.entries))
+	}
+
+	if b, ok := c.get("c"); !ok || string(b) != "c" {
+		t.Errorf("want %q/%t, got %q/%t", "c", true, b, ok)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	if _, ok := c.get("c"); ok {
+		t.Errorf("want %t, got %t", false, ok)
+	}
+}
+
+func TestErrorBody(t *testing.T) {
+	exp := `{"error":"unauthorized"}` + "\n"
+	for i := 0; i < 2; i++ {
+		if b := errorBody("unauthorized"); string(b) != exp {
+			t.Errorf("want %q, got %q", exp, b)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// authResultKey is the context key of the authentication result shared
+// by requirement middlewares.
+const authResultKey contextKey = 1
+
+// ErrNotFresh is returned when the session was created too long ago
+// for the route that requires a recent sign-in.
+var ErrNotFresh = errors.New("recent sign-in required")
+
+// authResult holds the result of the request's authentication.
+type authResult struct {
+	m   *Manager
+	s   Session
+	err error
+}
+
+// RequireNone produces a middleware that attaches the session to the
+// request's context if it is valid, but activates the wrapped handler
+// in any case (same as Public).
+// All requirement middlewares (RequireNone, RequireValid, RequireFresh
+// and RequireStepUp) of the same manager share a single authentication
+// pass (and store fetch) per request, so they can be freely composed
+// on nested routers.
+func (m *Manager) RequireNone() func(http.Handler) http.Handler {
+	return m.require(func(_ Session, err error, first bool) error {
+		if err != nil && first {
+			m.reject(err) // called only for potential logging and other custom, non-http logic
+		}
+
+		return nil
+	})
+}
+
+// RequireValid produces a middleware that activates the wrapped handler
+// only if the request has a valid session (same as Auth), otherwise the
+// manager's rejection function is called.
+func (m *Manager) RequireValid() func(http.Handler) http.Handler {
+	return m.require(func(_ Session, err error, _ bool) error {
+		return err
+	})
+}
+
+// RequireFresh produces a middleware that activates the wrapped handler
+// only if the request has a valid session that was created not earlier
+// than maxAge ago (e.g. for routes that change account credentials),
+// otherwise the manager's rejection function is called with
+// ErrNotFresh or the authentication error.
+func (m *Manager) RequireFresh(maxAge time.Duration) func(http.Handler) http.Handler {
+	return m.require(func(s Session, err error, _ bool) error {
+		if err != nil {
+			return err
+		}
+
+		if time.Since(s.CreatedAt) > maxAge+m.Config().ClockSkew {
+			return ErrNotFresh
+		}
+
+		return nil
+	})
+}
+
+// RequireStepUp produces a middleware that activates the wrapped handler
+// only if the request has a valid session that was verified (see
+// MetaTime and IsVerified) not earlier than maxAge ago, otherwise the
+// manager's rejection function is called with ErrNotVerified or the
+// authentication error.
+func (m *Manager) RequireStepUp(key string, maxAge time.Duration) func(http.Handler) http.Handler {
+	return m.require(func(s Session, err error, _ bool) error {
+		if err != nil {
+			return err
+		}
+
+		if !IsVerified(s, key, maxAge+m.Config().ClockSkew) {
+			return ErrNotVerified
+		}
+
+		return nil
+	})
+}
+
+// require produces a middleware that authenticates the request (or
+// reuses the result of an outer requirement middleware) and passes the
+// result to the provided check, together with a flag indicating whether
+// the authentication was performed by this middleware. The wrapped
+// handler is activated if the check returns nil.
+func (m *Manager) require(check func(s Session, err error, first bool) error) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, ok := r.Context().Value(authResultKey).(*authResult)
+			first := !ok || res.m != m
+			r, s, err := m.resolve(w, r)
+			if m.noop {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err = check(s, err, first); err != nil {
+				m.reject(err).ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// resolve returns the authentication result of the request. The request
+// is authenticated only if no outer requirement middleware of the same
+// manager did it already; the result (and the session, if it is valid)
+// is then attached to the returned request's context.
+func (m *Manager) resolve(w http.ResponseWriter, r *http.Request) (*http.Request, Session, error) {
+	if res, ok := r.Context().Value(authResultKey).(*authResult); ok && res.m == m {
+		return r, res.s, res.err
+	}
+
+	tr := m.tracer()
+	s, err := m.authenticate(w, r, tr)
+	ctx := context.WithValue(r.Context(), authResultKey, &authResult{m: m, s: s, err: err})
+	if err != nil {
+		m.stat(statAuthFailures)
+		return tr.finish(w, r.WithContext(ctx)), s, err
+	}
+
+	m.stat(statAuthSuccesses)
+	r = r.WithContext(NewContext(ctx, s))
+	tr.step(TraceContext)
+	return tr.finish(w, r), s, nil
+}
@@ -0,0 +1,138 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRequire(t *testing.T) {
+	verified := map[string]string{"2fa": strconv.FormatInt(time.Now().Unix(), 10)}
+
+	cc := map[string]struct {
+		Session    *Session
+		Middleware func(m *Manager) func(http.Handler) http.Handler
+		Code       int
+		Rejected   error
+	}{
+		"RequireNone without session": {
+			Middleware: func(m *Manager) func(http.Handler) http.Handler { return m.RequireNone() },
+			Code:       http.StatusOK,
+			Rejected:   ErrUnauthorized,
+		},
+		"RequireValid without session": {
+			Middleware: func(m *Manager) func(http.Handler) http.Handler { return m.RequireValid() },
+			Code:       http.StatusUnauthorized,
+			Rejected:   ErrUnauthorized,
+		},
+		"RequireValid with session": {
+			Session:    &Session{CreatedAt: time.Now().Add(-time.Hour)},
+			Middleware: func(m *Manager) func(http.Handler) http.Handler { return m.RequireValid() },
+			Code:       http.StatusOK,
+		},
+		"RequireFresh with old session": {
+			Session:    &Session{CreatedAt: time.Now().Add(-time.Hour)},
+			Middleware: func(m *Manager) func(http.Handler) http.Handler { return m.RequireFresh(time.Minute) },
+			Code:       http.StatusUnauthorized,
+			Rejected:   ErrNotFresh,
+		},
+		"RequireFresh with new session": {
+			Session:    &Session{CreatedAt: time.Now()},
+			Middleware: func(m *Manager) func(http.Handler) http.Handler { return m.RequireFresh(time.Minute) },
+			Code:       http.StatusOK,
+		},
+		"RequireStepUp without verification": {
+			Session:    &Session{CreatedAt: time.Now()},
+			Middleware: func(m *Manager) func(http.Handler) http.Handler { return m.RequireStepUp("2fa", time.Minute) },
+			Code:       http.StatusUnauthorized,
+			Rejected:   ErrNotVerified,
+		},
+		"RequireStepUp with verification": {
+			Session:    &Session{CreatedAt: time.Now(), Meta: verified},
+			Middleware: func(m *Manager) func(http.Handler) http.Handler { return m.RequireStepUp("2fa", time.Minute) },
+			Code:       http.StatusOK,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			store := &StoreMock{
+				FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+					if c.Session == nil {
+						return Session{}, false, nil
+					}
+
+					s := *c.Session
+					s.ID = id
+					return s, true, nil
+				},
+			}
+
+			var rejected []error
+			m := NewManager(store, Reject(func(err error) http.Handler {
+				rejected = append(rejected, err)
+				return DefaultReject(err)
+			}))
+
+			var called bool
+			h := c.Middleware(m)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				called = true
+				if _, ok := FromContext(r.Context()); ok != (c.Session != nil) {
+					t.Errorf("want session %t, got %t", c.Session != nil, ok)
+				}
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(&http.Cookie{Name: defaultName, Value: "id"})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != c.Code {
+				t.Errorf("want %d, got %d", c.Code, rec.Code)
+			}
+
+			if called != (c.Code == http.StatusOK) {
+				t.Errorf("want handler called %t, got %t", c.Code == http.StatusOK, called)
+			}
+
+			if c.Rejected == nil && len(rejected) != 0 || c.Rejected != nil && (len(rejected) != 1 || rejected[0] != c.Rejected) {
+				t.Errorf("want %v, got %v", c.Rejected, rejected)
+			}
+		})
+	}
+}
+
+func TestRequireComposed(t *testing.T) {
+	store := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id, CreatedAt: time.Now()}, true, nil
+		},
+	}
+
+	m := NewManager(store)
+	var called bool
+	h := m.RequireNone()(m.RequireValid()(m.RequireFresh(time.Minute)(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		called = true
+	}))))
+
+	// another manager must perform its own authentication.
+	h = m.Clone().RequireValid()(h)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: defaultName, Value: "id"})
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Error("want handler called, got not called")
+	}
+
+	if n := len(store.FetchByIDCalls()); n != 2 {
+		t.Errorf("want %d, got %d", 2, n)
+	}
+}
@@ -0,0 +1,150 @@
+package sessionup
+
+import (
+	"net"
+	"time"
+)
+
+// RiskScorer is used to compute the risk score of a session, based on
+// its own data and the data of all other sessions of the same user.
+type RiskScorer interface {
+	// Score should return the risk score of the session in range
+	// [0, 1] (higher == riskier). The provided slice holds all
+	// sessions of the user, including the scored one.
+	Score(s Session, ss []Session) float64
+}
+
+// RiskScorerFunc is an adapter that allows ordinary functions to be used
+// as RiskScorers.
+type RiskScorerFunc func(s Session, ss []Session) float64
+
+// Score implements RiskScorer interface.
+func (f RiskScorerFunc) Score(s Session, ss []Session) float64 {
+	return f(s, ss)
+}
+
+// RiskScoring sets the RiskScorer that will be used to compute the risk
+// scores of sessions returned by FetchAll, e.g. to power "review
+// suspicious sessions" pages.
+// By default it is not set and scores are not computed.
+func RiskScoring(rs RiskScorer) Option {
+	return func(m *Manager) {
+		m.risk = rs
+	}
+}
+
+// WeightedRisk holds a RiskScorer and its weight used by CompositeRisk.
+type WeightedRisk struct {
+	Scorer RiskScorer
+	Weight float64
+}
+
+// CompositeRisk produces a RiskScorer that computes the weighted
+// average of the provided scorers' scores.
+func CompositeRisk(ww ...WeightedRisk) RiskScorer {
+	return RiskScorerFunc(func(s Session, ss []Session) float64 {
+		var sum, total float64
+		for _, w := range ww {
+			if w.Weight <= 0 {
+				continue
+			}
+
+			sum += clampRisk(w.Scorer.Score(s, ss)) * w.Weight
+			total += w.Weight
+		}
+
+		if total == 0 {
+			return 0
+		}
+
+		return sum / total
+	})
+}
+
+// AgeRisk produces a RiskScorer whose score grows linearly with the
+// session's age and reaches 1 once the session is maxAge old.
+func AgeRisk(maxAge time.Duration) RiskScorer {
+	return RiskScorerFunc(func(s Session, _ []Session) float64 {
+		if maxAge <= 0 {
+			return 0
+		}
+
+		return float64(time.Since(s.CreatedAt)) / float64(maxAge)
+	})
+}
+
+// AgentRisk produces a RiskScorer that scores 1 if the session's
+// User-Agent data (OS, browser and device type) is not shared by any
+// other session of the user, and 0 otherwise (or if the user has only
+// one session).
+func AgentRisk() RiskScorer {
+	return RiskScorerFunc(func(s Session, ss []Session) float64 {
+		var others bool
+		for _, os := range ss {
+			if os.ID == s.ID {
+				continue
+			}
+
+			others = true
+			if os.Agent == s.Agent {
+				return 0
+			}
+		}
+
+		if !others {
+			return 0
+		}
+
+		return 1
+	})
+}
+
+// GeoVelocityRisk produces a RiskScorer that scores 1 if another
+// session of the user was created from a different country (resolved
+// with the provided function) within the provided window, which would
+// require impossible travel, and 0 otherwise.
+func GeoVelocityRisk(country func(ip net.IP) string, window time.Duration) RiskScorer {
+	return RiskScorerFunc(func(s Session, ss []Session) float64 {
+		if len(s.IP) == 0 {
+			return 0
+		}
+
+		c := country(s.IP)
+		if c == "" {
+			return 0
+		}
+
+		for _, os := range ss {
+			if os.ID == s.ID || len(os.IP) == 0 {
+				continue
+			}
+
+			d := s.CreatedAt.Sub(os.CreatedAt)
+			if d < 0 {
+				d = -d
+			}
+
+			if d > window {
+				continue
+			}
+
+			if oc := country(os.IP); oc != "" && oc != c {
+				return 1
+			}
+		}
+
+		return 0
+	})
+}
+
+// clampRisk limits the score to [0, 1] range.
+func clampRisk(r float64) float64 {
+	switch {
+	case r < 0:
+		return 0
+	case r > 1:
+		return 1
+	default:
+		return r
+	}
+}
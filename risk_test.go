@@ -0,0 +1,177 @@
+package sessionup
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRiskScoring(t *testing.T) {
+	m := Manager{}
+	RiskScoring(AgentRisk())(&m)
+	if m.risk == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
+
+func TestCompositeRisk(t *testing.T) {
+	fixed := func(r float64) RiskScorer {
+		return RiskScorerFunc(func(_ Session, _ []Session) float64 { return r })
+	}
+
+	cc := map[string]struct {
+		Weighted []WeightedRisk
+		Risk     float64
+	}{
+		"No scorers": {},
+		"Zero weights": {
+			Weighted: []WeightedRisk{{Scorer: fixed(1)}},
+		},
+		"Weighted average": {
+			Weighted: []WeightedRisk{
+				{Scorer: fixed(1), Weight: 3},
+				{Scorer: fixed(0), Weight: 1},
+			},
+			Risk: 0.75,
+		},
+		"Clamped scores": {
+			Weighted: []WeightedRisk{
+				{Scorer: fixed(5), Weight: 1},
+				{Scorer: fixed(-5), Weight: 1},
+			},
+			Risk: 0.5,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			r := CompositeRisk(c.Weighted...).Score(Session{}, nil)
+			if r != c.Risk {
+				t.Errorf("want %v, got %v", c.Risk, r)
+			}
+		})
+	}
+}
+
+func TestAgeRisk(t *testing.T) {
+	s := Session{CreatedAt: time.Now().Add(-time.Hour)}
+
+	if r := AgeRisk(0).Score(s, nil); r != 0 {
+		t.Errorf("want %v, got %v", 0, r)
+	}
+
+	if r := AgeRisk(4*time.Hour).Score(s, nil); r < 0.24 || r > 0.26 {
+		t.Errorf("want %v, got %v", 0.25, r)
+	}
+}
+
+func TestAgentRisk(t *testing.T) {
+	ses := func(id, os string) Session {
+		s := Session{ID: id}
+		s.Agent.OS = os
+		return s
+	}
+
+	cc := map[string]struct {
+		Sessions []Session
+		Risk     float64
+	}{
+		"Only session": {
+			Sessions: []Session{ses("1", "Linux")},
+		},
+		"Known agent": {
+			Sessions: []Session{ses("1", "Linux"), ses("2", "Windows"), ses("3", "Linux")},
+		},
+		"Unknown agent": {
+			Sessions: []Session{ses("1", "Linux"), ses("2", "Windows")},
+			Risk:     1,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			r := AgentRisk().Score(c.Sessions[0], c.Sessions)
+			if r != c.Risk {
+				t.Errorf("want %v, got %v", c.Risk, r)
+			}
+		})
+	}
+}
+
+func TestGeoVelocityRisk(t *testing.T) {
+	now := time.Now()
+	country := func(ip net.IP) string {
+		if ip[0] == 10 {
+			return "LT"
+		}
+		return "US"
+	}
+
+	ses := func(id, ip string, age time.Duration) Session {
+		return Session{ID: id, CreatedAt: now.Add(-age), IP: net.ParseIP(ip).To4()}
+	}
+
+	cc := map[string]struct {
+		Sessions []Session
+		Risk     float64
+	}{
+		"No IP": {
+			Sessions: []Session{{ID: "1"}, ses("2", "8.8.8.8", 0)},
+		},
+		"Same country": {
+			Sessions: []Session{ses("1", "10.0.0.1", 0), ses("2", "10.0.0.2", time.Minute)},
+		},
+		"Another country outside the window": {
+			Sessions: []Session{ses("1", "10.0.0.1", 0), ses("2", "8.8.8.8", 2*time.Hour)},
+		},
+		"Another country within the window": {
+			Sessions: []Session{ses("1", "10.0.0.1", time.Minute), ses("2", "8.8.8.8", 0)},
+			Risk:     1,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			r := GeoVelocityRisk(country, time.Hour).Score(c.Sessions[0], c.Sessions)
+			if r != c.Risk {
+				t.Errorf("want %v, got %v", c.Risk, r)
+			}
+		})
+	}
+}
+
+func TestFetchAllRisk(t *testing.T) {
+	ses := func(id, os string) Session {
+		s := Session{ID: id, UserKey: "key"}
+		s.Agent.OS = os
+		return s
+	}
+
+	store := &StoreMock{
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return []Session{ses("1", "Linux"), ses("2", "Linux"), ses("3", "Windows")}, nil
+		},
+	}
+
+	m := Manager{store: store}
+	RiskScoring(AgentRisk())(&m)
+
+	ss, err := m.FetchAll(NewContext(context.Background(), ses("1", "Linux")))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	exp := []float64{0, 0, 1}
+	for i, s := range ss {
+		if s.Risk != exp[i] {
+			t.Errorf("%s: want %v, got %v", s.ID, exp[i], s.Risk)
+		}
+	}
+}
@@ -28,6 +28,11 @@ type Session struct {
 	// from the store.
 	ExpiresAt time.Time `json:"-"`
 
+	// CookieExpiresAt specifies the expiration time of the
+	// cookie that was issued for this session. It is zero for
+	// temporary sessions, whose cookies have no expiration time.
+	CookieExpiresAt time.Time `json:"-"`
+
 	// ID specifies a unique ID used to find this session
 	// in the store.
 	ID string `json:"id"`
@@ -36,6 +41,10 @@ type Session struct {
 	// sessions of the same user.
 	UserKey string `json:"-"`
 
+	// OrgKey specifies a non-unique key used to find all
+	// sessions of the same organization's members.
+	OrgKey string `json:"-"`
+
 	// IP specifies an IP address that was used to create
 	// this session
 	IP net.IP `json:"ip"`
@@ -45,11 +54,59 @@ type Session struct {
 	Agent struct {
 		OS      string `json:"os"`
 		Browser string `json:"browser"`
+		Device  string `json:"device,omitempty"`
+		Version string `json:"version,omitempty"`
 	} `json:"agent"`
 
+	// Risk specifies the risk score of this session in range
+	// [0, 1], computed by FetchAll when RiskScoring option is set.
+	// NOTE: this field should be omitted by Store interface
+	// implementations when inserting session into the underlying
+	// data store.
+	Risk float64 `json:"risk,omitempty"`
+
+	// ParentID specifies the ID of the session from which
+	// this child session was derived. It is empty for regular
+	// sessions.
+	ParentID string `json:"-"`
+
+	// Scope specifies the scope of this child session. Only
+	// managers returned by Scoped accept child sessions.
+	Scope string `json:"scope,omitempty"`
+
+	// Fingerprint specifies the outcome of the FingerprintPolicy
+	// evaluation of the current request (see Fingerprint option).
+	// NOTE: this field should be omitted by Store interface
+	// implementations when inserting session into the underlying
+	// data store.
+	Fingerprint FingerprintResult `json:"-"`
+
+	// Kind specifies the purpose of this record. Regular
+	// sessions have it empty, other kinds (e.g. KindCanary)
+	// are never accepted by Auth and are not listed by FetchAll.
+	Kind string `json:"-"`
+
+	// Version specifies the revision of this session, incremented
+	// on each update. It is used to detect concurrent modifications.
+	Version uint64 `json:"-"`
+
+	// Affinity specifies the region, node or other routing hint
+	// of the server instance that created this session.
+	Affinity string `json:"affinity,omitempty"`
+
 	// Meta specifies a map of metadata associated with
 	// the session.
 	Meta map[string]string `json:"meta,omitempty"`
+
+	// Blobs specifies a map of metadata keys whose values
+	// are stored separately in the BlobStore, and references
+	// to those values.
+	Blobs map[string]string `json:"-"`
+
+	// CSRFToken specifies the anti-CSRF token that must
+	// accompany state-changing requests when the session
+	// cookie is sent with SameSite=None.
+	CSRFToken string `json:"-"`
 }
 
 // IsValid checks whether the incoming request's properties match
@@ -61,6 +118,9 @@ func (s Session) IsValid(r *http.Request) bool {
 	}
 
 	a := useragent.Parse(r.Header.Get("User-Agent"))
+	if a == nil {
+		a = &useragent.UserAgent{}
+	}
 
 	os := true
 	if s.Agent.OS != "" {
@@ -78,24 +138,37 @@ func (s Session) IsValid(r *http.Request) bool {
 // newSession creates a new Session with the data extracted from
 // the provided request, user key and a freshly generated ID.
 func (m *Manager) newSession(r *http.Request, key string, meta map[string]string) Session {
+	s := m.requestSession(r, key, meta)
+	s.ID = m.genID()
+	return s
+}
+
+// requestSession produces a session with the provided user key and
+// metadata and the request's data, but without an ID.
+func (m *Manager) requestSession(r *http.Request, key string, meta map[string]string) Session {
 	s := Session{
-		CreatedAt: time.Now(),
-		ExpiresAt: prepExpiresAt(m.expiresIn),
-		ID:        m.genID(),
+		CreatedAt: monotonicNow(),
+		ExpiresAt: prepExpiresAt(m.storeTTL(key)),
 		UserKey:   key,
+		OrgKey:    m.orgKey(key),
+		Affinity:  m.affinity.value,
 		Meta:      meta,
 	}
 
-	if m.withIP {
+	c := m.Config()
+	if c.WithIP {
 		s.IP = readIP(r)
 	}
 
-	if m.withAgent {
+	if c.WithAgent {
 		a := useragent.Parse(r.Header.Get("User-Agent"))
 		if a != nil {
 			s.Agent.OS = a.OS
 			s.Agent.Browser = a.Name
+			s.Agent.Version = agentVersion(a.Original, a.Name)
 		}
+
+		s.Agent.Device = deviceType(a)
 	}
 
 	return s
@@ -135,10 +208,44 @@ func NewContext(ctx context.Context, s Session) context.Context {
 
 // FromContext extracts Session from the context.
 func FromContext(ctx context.Context) (Session, bool) {
+	if ctx == nil {
+		return Session{}, false
+	}
+
 	s, ok := ctx.Value(sessionKey).(Session)
 	return s, ok
 }
 
+// MarkCurrent returns a copy of the provided sessions in which only the
+// session with the same ID as the one stored in the context has its
+// 'Current' field set to true. If the context session is not set, none
+// of the sessions are marked. The provided slice is never modified, so
+// it can be safely shared (e.g. cached) across requests.
+func MarkCurrent(ctx context.Context, ss []Session) []Session {
+	if ss == nil {
+		return nil
+	}
+
+	var id string
+	if cs, ok := FromContext(ctx); ok {
+		id = cs.ID
+	}
+
+	res := make([]Session, len(ss))
+	copy(res, ss)
+	markCurrent(res, id)
+
+	return res
+}
+
+// markCurrent sets the 'Current' field of the sessions in place. Only
+// the session with the provided ID is marked; empty ID marks none.
+func markCurrent(ss []Session, id string) {
+	for i := range ss {
+		ss[i].Current = id != "" && ss[i].ID == id
+	}
+}
+
 // Meta is a func that handles session's metadata map.
 type Meta func(map[string]string)
 
@@ -129,6 +129,7 @@ func TestNewSession(t *testing.T) {
 		IP      net.IP
 		OS      string
 		Browser string
+		Device  string
 	}{
 		"Session created without IP": {
 			Manager: func() Manager {
@@ -139,6 +140,7 @@ func TestNewSession(t *testing.T) {
 			Req:     req,
 			OS:      useragent.OSLinux,
 			Browser: browser,
+			Device:  DeviceDesktop,
 		},
 		"Session created without user agent data": {
 			Manager: func() Manager {
@@ -156,7 +158,8 @@ func TestNewSession(t *testing.T) {
 				creq.RemoteAddr = req.RemoteAddr
 				return creq
 			}(),
-			IP: net.ParseIP("127.0.0.1"),
+			IP:     net.ParseIP("127.0.0.1"),
+			Device: DeviceUnknown,
 		},
 		"Session created with all possible fields": {
 			Manager: m,
@@ -164,6 +167,7 @@ func TestNewSession(t *testing.T) {
 			IP:      net.ParseIP("127.0.0.1"),
 			OS:      useragent.OSLinux,
 			Browser: browser,
+			Device:  DeviceDesktop,
 		},
 	}
 
@@ -196,6 +200,10 @@ func TestNewSession(t *testing.T) {
 				t.Errorf("want %q, got %q", c.Browser, s.Agent.Browser)
 			}
 
+			if c.Device != s.Agent.Device {
+				t.Errorf("want %q, got %q", c.Device, s.Agent.Device)
+			}
+
 			if !reflect.DeepEqual(c.IP, s.IP) {
 				t.Errorf("want %v, got %v", c.IP, s.IP)
 			}
@@ -262,6 +270,53 @@ func TestFromContext(t *testing.T) {
 	}
 }
 
+func TestMarkCurrent(t *testing.T) {
+	ss := []Session{{ID: "id0", Current: true}, {ID: "id1"}}
+
+	cc := map[string]struct {
+		Ctx context.Context
+		Exp []Session
+	}{
+		"No session in the context": {
+			Ctx: context.Background(),
+			Exp: []Session{{ID: "id0"}, {ID: "id1"}},
+		},
+		"Session with unknown ID in the context": {
+			Ctx: NewContext(context.Background(), Session{ID: "id2"}),
+			Exp: []Session{{ID: "id0"}, {ID: "id1"}},
+		},
+		"Current session in the context": {
+			Ctx: NewContext(context.Background(), Session{ID: "id1"}),
+			Exp: []Session{{ID: "id0"}, {ID: "id1", Current: true}},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			res := MarkCurrent(c.Ctx, ss)
+			if !reflect.DeepEqual(c.Exp, res) {
+				t.Errorf("want %v, got %v", c.Exp, res)
+			}
+		})
+	}
+
+	if res := MarkCurrent(context.Background(), nil); res != nil {
+		t.Errorf("want nil, got %v", res)
+	}
+
+	t.Run("Original slice", func(t *testing.T) {
+		t.Parallel()
+
+		exp := []Session{{ID: "id0", Current: true}, {ID: "id1"}}
+		if !reflect.DeepEqual(exp, ss) {
+			t.Errorf("want %v, got %v", exp, ss)
+		}
+	})
+}
+
 func TestMetaEntry(t *testing.T) {
 	m := make(map[string]string)
 	MetaEntry("test2", "1")(m)
@@ -0,0 +1,54 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+)
+
+// Sessions is the set of Manager methods most application code depends
+// on. It is implemented by *Manager (including the ones returned by
+// NewNoopManager) and sessiontest.Recorder, so handlers can accept the
+// interface and have the implementation swapped in tests and
+// alternative deployments.
+type Sessions interface {
+	// Init creates a new session under the provided key and sets
+	// its cookie (see Manager.Init).
+	Init(w http.ResponseWriter, r *http.Request, key string, mm ...Meta) error
+
+	// Public makes the request's session, if it is valid, available
+	// to the wrapped handler (see Manager.Public).
+	Public(next http.Handler) http.Handler
+
+	// Auth activates the wrapped handler only if the request has a
+	// valid session (see Manager.Auth).
+	Auth(next http.Handler) http.Handler
+
+	// Revoke revokes the context session and deletes its cookie
+	// (see Manager.Revoke).
+	Revoke(ctx context.Context, w http.ResponseWriter) error
+
+	// RevokeOther revokes all sessions of the context session's user,
+	// except the context session (see Manager.RevokeOther).
+	RevokeOther(ctx context.Context) error
+
+	// RevokeAll revokes all sessions of the context session's user
+	// and deletes the cookie (see Manager.RevokeAll).
+	RevokeAll(ctx context.Context, w http.ResponseWriter) error
+
+	// RevokeByID revokes the session of the provided ID (see
+	// Manager.RevokeByID).
+	RevokeByID(ctx context.Context, id string) error
+
+	// RevokeByIDExt revokes the session of the provided ID, if it
+	// belongs to the context session's user (see
+	// Manager.RevokeByIDExt).
+	RevokeByIDExt(ctx context.Context, id string) error
+
+	// RevokeByUserKey revokes all sessions of the provided user key
+	// (see Manager.RevokeByUserKey).
+	RevokeByUserKey(ctx context.Context, key string) error
+
+	// FetchAll retrieves all sessions of the context session's user
+	// (see Manager.FetchAll).
+	FetchAll(ctx context.Context) ([]Session, error)
+}
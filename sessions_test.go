@@ -0,0 +1,8 @@
+package sessionup
+
+import "testing"
+
+func TestSessions(t *testing.T) {
+	var _ Sessions = NewManager(&StoreMock{})
+	var _ Sessions = NewNoopManager()
+}
@@ -0,0 +1,130 @@
+// Package sessiontest provides a recording sessionup.Sessions
+// implementation for testing handlers that depend on the interface
+// instead of *sessionup.Manager.
+package sessiontest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/swithek/sessionup"
+)
+
+var _ sessionup.Sessions = (*Recorder)(nil)
+
+// Call describes a single recorded method call.
+type Call struct {
+	// Method specifies the name of the called method, e.g. "Init".
+	Method string
+
+	// Arg specifies the key or ID the method was called with, if any.
+	Arg string
+}
+
+// Recorder is a sessionup.Sessions implementation that records the
+// calls of its methods and does not use a store. Its fields should be
+// set before the recorder is used.
+type Recorder struct {
+	// Session specifies the session that Auth and Public add to the
+	// requests' context. Auth rejects the requests with the 401
+	// status code if its ID is empty.
+	Session sessionup.Session
+
+	// Sessions specifies the sessions returned by FetchAll.
+	Sessions []sessionup.Session
+
+	// Err specifies the error returned by all methods.
+	Err error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Calls returns all recorded calls in the order they were made.
+func (rc *Recorder) Calls() []Call {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return append([]Call(nil), rc.calls...)
+}
+
+// record records the call and returns the configured error.
+func (rc *Recorder) record(method, arg string) error {
+	rc.mu.Lock()
+	rc.calls = append(rc.calls, Call{Method: method, Arg: arg})
+	rc.mu.Unlock()
+
+	return rc.Err
+}
+
+// Init implements sessionup.Sessions interface's Init method.
+func (rc *Recorder) Init(_ http.ResponseWriter, _ *http.Request, key string, _ ...sessionup.Meta) error {
+	return rc.record("Init", key)
+}
+
+// Public implements sessionup.Sessions interface's Public method.
+func (rc *Recorder) Public(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc.record("Public", "")
+
+		if rc.Session.ID != "" {
+			r = r.WithContext(sessionup.NewContext(r.Context(), rc.Session))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Auth implements sessionup.Sessions interface's Auth method.
+func (rc *Recorder) Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc.record("Auth", "")
+
+		if rc.Session.ID == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(sessionup.NewContext(r.Context(), rc.Session)))
+	})
+}
+
+// Revoke implements sessionup.Sessions interface's Revoke method.
+func (rc *Recorder) Revoke(_ context.Context, _ http.ResponseWriter) error {
+	return rc.record("Revoke", "")
+}
+
+// RevokeOther implements sessionup.Sessions interface's RevokeOther method.
+func (rc *Recorder) RevokeOther(_ context.Context) error {
+	return rc.record("RevokeOther", "")
+}
+
+// RevokeAll implements sessionup.Sessions interface's RevokeAll method.
+func (rc *Recorder) RevokeAll(_ context.Context, _ http.ResponseWriter) error {
+	return rc.record("RevokeAll", "")
+}
+
+// RevokeByID implements sessionup.Sessions interface's RevokeByID method.
+func (rc *Recorder) RevokeByID(_ context.Context, id string) error {
+	return rc.record("RevokeByID", id)
+}
+
+// RevokeByIDExt implements sessionup.Sessions interface's RevokeByIDExt method.
+func (rc *Recorder) RevokeByIDExt(_ context.Context, id string) error {
+	return rc.record("RevokeByIDExt", id)
+}
+
+// RevokeByUserKey implements sessionup.Sessions interface's RevokeByUserKey method.
+func (rc *Recorder) RevokeByUserKey(_ context.Context, key string) error {
+	return rc.record("RevokeByUserKey", key)
+}
+
+// FetchAll implements sessionup.Sessions interface's FetchAll method.
+func (rc *Recorder) FetchAll(_ context.Context) ([]sessionup.Session, error) {
+	if err := rc.record("FetchAll", ""); err != nil {
+		return nil, err
+	}
+
+	return rc.Sessions, nil
+}
@@ -0,0 +1,81 @@
+package sessiontest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/swithek/sessionup"
+)
+
+func TestRecorder(t *testing.T) {
+	rc := &Recorder{}
+
+	var ok bool
+	h := rc.Auth(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, ok = sessionup.FromContext(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if ok || rec.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	rc.Session = sessionup.Session{ID: "id", UserKey: "key"}
+	rc.Sessions = []sessionup.Session{rc.Session}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !ok {
+		t.Error("want true, got false")
+	}
+
+	ok = false
+	rc.Public(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, ok = sessionup.FromContext(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !ok {
+		t.Error("want true, got false")
+	}
+
+	ctx := context.Background()
+	if err := rc.Init(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "key"); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if ss, err := rc.FetchAll(ctx); err != nil || !reflect.DeepEqual(ss, rc.Sessions) {
+		t.Errorf("want %v, got %v and %v", rc.Sessions, ss, err)
+	}
+
+	rc.Err = errors.New("error")
+	for _, err := range []error{
+		rc.Revoke(ctx, httptest.NewRecorder()),
+		rc.RevokeOther(ctx),
+		rc.RevokeAll(ctx, httptest.NewRecorder()),
+		rc.RevokeByID(ctx, "id"),
+		rc.RevokeByIDExt(ctx, "id"),
+		rc.RevokeByUserKey(ctx, "key"),
+	} {
+		if err != rc.Err {
+			t.Errorf("want %v, got %v", rc.Err, err)
+		}
+	}
+
+	if _, err := rc.FetchAll(ctx); err != rc.Err {
+		t.Errorf("want %v, got %v", rc.Err, err)
+	}
+
+	exp := []Call{
+		{Method: "Auth"}, {Method: "Auth"}, {Method: "Public"},
+		{Method: "Init", Arg: "key"}, {Method: "FetchAll"},
+		{Method: "Revoke"}, {Method: "RevokeOther"}, {Method: "RevokeAll"},
+		{Method: "RevokeByID", Arg: "id"}, {Method: "RevokeByIDExt", Arg: "id"},
+		{Method: "RevokeByUserKey", Arg: "key"}, {Method: "FetchAll"},
+	}
+
+	if calls := rc.Calls(); !reflect.DeepEqual(exp, calls) {
+		t.Errorf("want %v, got %v", exp, calls)
+	}
+}
@@ -0,0 +1,269 @@
+// Package shardstore provides a sessionup.Store implementation that
+// distributes sessions across multiple underlying stores.
+package shardstore
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+// Error is returned when operations that are fanned out to all shards
+// fail on some of them. Results of the successful shards are still
+// returned alongside it.
+type Error struct {
+	// Errs holds errors keyed by the index of the shard that
+	// returned them.
+	Errs map[int]error
+}
+
+// Error implements error interface.
+func (e *Error) Error() string {
+	ii := make([]int, 0, len(e.Errs))
+	for i := range e.Errs {
+		ii = append(ii, i)
+	}
+	sort.Ints(ii)
+
+	msgs := make([]string, len(ii))
+	for j, i := range ii {
+		msgs[j] = fmt.Sprintf("shard %d: %v", i, e.Errs[i])
+	}
+
+	return fmt.Sprintf("%d shard(s) failed: %s", len(ii), strings.Join(msgs, "; "))
+}
+
+// ShardStore is a sessionup.Store implementation that distributes
+// sessions across multiple stores by hashing their IDs. Lookups by user
+// key are fanned out to all shards concurrently.
+type ShardStore struct {
+	shards   []sessionup.Store
+	parallel int
+}
+
+// New returns a fresh instance of ShardStore.
+// Parallel parameter determines how many shards can be queried
+// concurrently during fan-out operations; values <= 0 query all shards
+// at once.
+// The order of shards must not change between application restarts,
+// otherwise existing sessions will not be found.
+func New(parallel int, shards ...sessionup.Store) *ShardStore {
+	if parallel <= 0 || parallel > len(shards) {
+		parallel = len(shards)
+	}
+
+	return &ShardStore{
+		shards:   shards,
+		parallel: parallel,
+	}
+}
+
+// Create implements sessionup.Store interface's Create method.
+func (s *ShardStore) Create(ctx context.Context, ses sessionup.Session) error {
+	return s.shard(ses.ID).Create(ctx, ses)
+}
+
+// FetchByID implements sessionup.Store interface's FetchByID method.
+func (s *ShardStore) FetchByID(ctx context.Context, id string) (sessionup.Session, bool, error) {
+	return s.shard(id).FetchByID(ctx, id)
+}
+
+// FetchByUserKey implements sessionup.Store interface's FetchByUserKey method.
+// If some of the shards fail, sessions retrieved from the rest of them
+// are returned together with *Error.
+func (s *ShardStore) FetchByUserKey(ctx context.Context, key string) ([]sessionup.Session, error) {
+	var (
+		mu  sync.Mutex
+		res []sessionup.Session
+	)
+
+	err := s.fanOut(func(st sessionup.Store) error {
+		ss, err := st.FetchByUserKey(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		res = append(res, ss...)
+		mu.Unlock()
+		return nil
+	})
+
+	return res, err
+}
+
+// DeleteByID implements sessionup.Store interface's DeleteByID method.
+func (s *ShardStore) DeleteByID(ctx context.Context, id string) error {
+	return s.shard(id).DeleteByID(ctx, id)
+}
+
+// DeleteByUserKey implements sessionup.Store interface's DeleteByUserKey method.
+// If some of the shards fail, *Error is returned.
+func (s *ShardStore) DeleteByUserKey(ctx context.Context, key string, expID ...string) error {
+	return s.fanOut(func(st sessionup.Store) error {
+		return st.DeleteByUserKey(ctx, key, expID...)
+	})
+}
+
+// DeleteByOrgKey implements sessionup.OrgStore interface's DeleteByOrgKey method.
+// If some of the shards fail, *Error is returned.
+func (s *ShardStore) DeleteByOrgKey(ctx context.Context, org string) error {
+	for _, st := range s.shards {
+		if _, ok := st.(sessionup.OrgStore); !ok {
+			return sessionup.ErrNotSupported
+		}
+	}
+
+	return s.fanOut(func(st sessionup.Store) error {
+		return st.(sessionup.OrgStore).DeleteByOrgKey(ctx, org)
+	})
+}
+
+// Update implements sessionup.UpdateStore interface's Update method.
+func (s *ShardStore) Update(ctx context.Context, ses sessionup.Session) error {
+	us, ok := s.shard(ses.ID).(sessionup.UpdateStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	return us.Update(ctx, ses)
+}
+
+// CompareAndSwap implements sessionup.CASStore interface's CompareAndSwap method.
+func (s *ShardStore) CompareAndSwap(ctx context.Context, old uint64, ses sessionup.Session) (bool, error) {
+	cs, ok := s.shard(ses.ID).(sessionup.CASStore)
+	if !ok {
+		return false, sessionup.ErrNotSupported
+	}
+
+	return cs.CompareAndSwap(ctx, old, ses)
+}
+
+// CreateBlob implements sessionup.BlobStore interface's CreateBlob method.
+func (s *ShardStore) CreateBlob(ctx context.Context, ref string, data []byte, exp time.Time) error {
+	bs, ok := s.shard(ref).(sessionup.BlobStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	return bs.CreateBlob(ctx, ref, data, exp)
+}
+
+// FetchBlob implements sessionup.BlobStore interface's FetchBlob method.
+func (s *ShardStore) FetchBlob(ctx context.Context, ref string) ([]byte, bool, error) {
+	bs, ok := s.shard(ref).(sessionup.BlobStore)
+	if !ok {
+		return nil, false, sessionup.ErrNotSupported
+	}
+
+	return bs.FetchBlob(ctx, ref)
+}
+
+// DeleteBlob implements sessionup.BlobStore interface's DeleteBlob method.
+func (s *ShardStore) DeleteBlob(ctx context.Context, ref string) error {
+	bs, ok := s.shard(ref).(sessionup.BlobStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	return bs.DeleteBlob(ctx, ref)
+}
+
+// ExtendBlob implements sessionup.BlobStore interface's ExtendBlob method.
+func (s *ShardStore) ExtendBlob(ctx context.Context, ref string, exp time.Time) error {
+	bs, ok := s.shard(ref).(sessionup.BlobStore)
+	if !ok {
+		return sessionup.ErrNotSupported
+	}
+
+	return bs.ExtendBlob(ctx, ref, exp)
+}
+
+// ChangeCounter implements sessionup.ChangeCounterStore interface's
+// ChangeCounter method. Counters of all shards are combined into a
+// single value.
+func (s *ShardStore) ChangeCounter(ctx context.Context, key string) (uint64, error) {
+	for _, st := range s.shards {
+		if _, ok := st.(sessionup.ChangeCounterStore); !ok {
+			return 0, sessionup.ErrNotSupported
+		}
+	}
+
+	nn := make([]uint64, len(s.shards))
+	err := s.fanOutIndexed(func(i int, st sessionup.Store) error {
+		n, err := st.(sessionup.ChangeCounterStore).ChangeCounter(ctx, key)
+		nn[i] = n
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	h := fnv.New64a()
+	for _, n := range nn {
+		binary.Write(h, binary.BigEndian, n)
+	}
+
+	return h.Sum64(), nil
+}
+
+// shard picks the store responsible for the provided ID.
+func (s *ShardStore) shard(id string) sessionup.Store {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// fanOut calls the provided function for each shard, limiting the
+// number of concurrent calls. Errors of all failed shards are
+// collected into *Error.
+func (s *ShardStore) fanOut(fn func(sessionup.Store) error) error {
+	return s.fanOutIndexed(func(_ int, st sessionup.Store) error {
+		return fn(st)
+	})
+}
+
+// fanOutIndexed works the same way as fanOut, but also passes the
+// index of the shard to the provided function.
+func (s *ShardStore) fanOutIndexed(fn func(int, sessionup.Store) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs map[int]error
+	)
+
+	sem := make(chan struct{}, s.parallel)
+	for i, st := range s.shards {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, st sessionup.Store) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := fn(i, st); err != nil {
+				mu.Lock()
+				if errs == nil {
+					errs = make(map[int]error)
+				}
+				errs[i] = err
+				mu.Unlock()
+			}
+		}(i, st)
+	}
+
+	wg.Wait()
+	if errs != nil {
+		return &Error{Errs: errs}
+	}
+
+	return nil
+}
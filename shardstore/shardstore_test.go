@@ -0,0 +1,107 @@
+package shardstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/swithek/sessionup"
+	"github.com/swithek/sessionup/memstore"
+	"github.com/swithek/sessionup/storetest"
+)
+
+// failingStore is a sessionup.Store that fails all fan-out operations.
+type failingStore struct {
+	sessionup.Store
+}
+
+func (failingStore) FetchByUserKey(_ context.Context, _ string) ([]sessionup.Session, error) {
+	return nil, errors.New("error")
+}
+
+func (failingStore) DeleteByUserKey(_ context.Context, _ string, _ ...string) error {
+	return errors.New("error")
+}
+
+func TestType(t *testing.T) {
+	var _ sessionup.Store = &ShardStore{}
+	var _ sessionup.UpdateStore = &ShardStore{}
+	var _ sessionup.BlobStore = &ShardStore{}
+	var _ sessionup.CASStore = &ShardStore{}
+	var _ sessionup.ChangeCounterStore = &ShardStore{}
+	var _ sessionup.OrgStore = &ShardStore{}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunStoreTests(t, func() sessionup.Store {
+		return New(2, memstore.New(0), memstore.New(0), memstore.New(0))
+	})
+}
+
+func BenchmarkConformance(b *testing.B) {
+	storetest.RunStoreBenchmarks(b, func() sessionup.Store {
+		return New(2, memstore.New(0), memstore.New(0), memstore.New(0))
+	})
+}
+
+func TestNew(t *testing.T) {
+	s := New(0, memstore.New(0), memstore.New(0))
+	if s.parallel != 2 {
+		t.Errorf("want %d, got %d", 2, s.parallel)
+	}
+
+	s = New(1, memstore.New(0), memstore.New(0))
+	if s.parallel != 1 {
+		t.Errorf("want %d, got %d", 1, s.parallel)
+	}
+}
+
+func TestShard(t *testing.T) {
+	s := New(0, memstore.New(0), memstore.New(0), memstore.New(0))
+	used := make(map[sessionup.Store]struct{})
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		if s.shard(id) != s.shard(id) {
+			t.Fatalf("want stable shard for %q", id)
+		}
+		used[s.shard(id)] = struct{}{}
+	}
+
+	if len(used) < 2 {
+		t.Errorf("want >1 shards used, got %d", len(used))
+	}
+}
+
+func TestPartialFailure(t *testing.T) {
+	ctx := context.Background()
+	ok := memstore.New(0)
+	s := New(1, ok, failingStore{Store: memstore.New(0)})
+	if err := ok.Create(ctx, storetest.NewSession("id", "key")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	ss, err := s.FetchByUserKey(ctx, "key")
+	if len(ss) != 1 {
+		t.Errorf("want %d, got %d", 1, len(ss))
+	}
+
+	var serr *Error
+	if !errors.As(err, &serr) {
+		t.Fatalf("want %T, got %v", serr, err)
+	}
+
+	if _, ok := serr.Errs[1]; !ok || len(serr.Errs) != 1 {
+		t.Errorf("want error of shard %d, got %v", 1, serr.Errs)
+	}
+
+	if err = s.DeleteByUserKey(ctx, "key"); !errors.As(err, &serr) {
+		t.Fatalf("want %T, got %v", serr, err)
+	}
+
+	if ss, _ = ok.FetchByUserKey(ctx, "key"); ss != nil {
+		t.Errorf("want nil, got %v", ss)
+	}
+
+	if serr.Error() != "1 shard(s) failed: shard 1: error" {
+		t.Errorf("want %q, got %q", "1 shard(s) failed: shard 1: error", serr.Error())
+	}
+}
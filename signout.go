@@ -0,0 +1,251 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSignOutTTL is used as the lifetime of sign-out markers
+	// when neither the provided one nor the session lifetime is set.
+	defaultSignOutTTL = time.Hour * 24 * 30
+
+	defaultSignOutCache = time.Second * 5
+
+	// signOutSweep is the minimum number of cached markers after which
+	// the expired ones are removed.
+	signOutSweep = 1024
+)
+
+// SignOutCache sets how long the "sign out everywhere" markers (see
+// SignOutEverywhere) looked up by Auth (and other middlewares) are
+// cached in memory, so that requests do not fetch the marker of their
+// user from the store every time. Markers set through the same manager
+// or its clones take effect immediately, the ones set by other
+// instances within the provided duration.
+// Zero or negative duration disables the cache.
+// Defaults to the value stored in defaultSignOutCache.
+func SignOutCache(d time.Duration) Option {
+	return func(m *Manager) {
+		m.signOuts = &signOuts{ttl: d}
+	}
+}
+
+// SignOutStore is an optional Store capability used to persist the
+// "sign out everywhere" markers (see SignOutEverywhere).
+type SignOutStore interface {
+	// SetSignOut should insert the provided marker into the store,
+	// replacing the marker of the same user key, if it exists, and
+	// ensure that it is deleted when expiration time is due.
+	// Error should be returned on system errors only.
+	SetSignOut(ctx context.Context, mk SignOutMarker) error
+
+	// FetchSignOut should retrieve the marker of the provided user
+	// key. If the marker is not found or has expired, the second
+	// return value should be false.
+	// Error should be returned on system errors only.
+	FetchSignOut(ctx context.Context, key string) (SignOutMarker, bool, error)
+}
+
+// SignOutMarker holds the data of a pending "sign out everywhere" of a
+// single user.
+type SignOutMarker struct {
+	// UserKey specifies the user key whose sessions are signed out.
+	UserKey string
+
+	// At specifies a point in time when the sign out happened.
+	// Sessions created before it are rejected.
+	At time.Time
+
+	// ExpiresAt specifies a point in time when the marker stops
+	// being honored and is deleted.
+	ExpiresAt time.Time
+}
+
+// Pending checks whether the marker is still honored at the provided
+// time.
+func (mk SignOutMarker) Pending(t time.Time) bool {
+	return t.Before(mk.ExpiresAt)
+}
+
+// SignOutEverywhere deletes all sessions under the provided user key
+// (same as RevokeByUserKey) and persists a marker that makes Auth (and
+// other middlewares) reject all sessions of the user created before it,
+// until the marker expires. Unlike the revocation alone, the marker also
+// cuts off sessions that outlive the deletion: stateless tokens (see
+// Stateless), sessions remembered with ReadYourWrites and sessions of
+// store replicas that were not reachable at the time.
+// The marker expires after the provided ttl or, if it is not positive,
+// after the lifetime of the user's sessions in the store (see ExpiresIn,
+// TTLResolver and StoreTTL).
+// The store must implement SignOutStore interface, otherwise
+// ErrNotSupported is returned.
+func (m *Manager) SignOutEverywhere(ctx context.Context, key string, ttl time.Duration) (SignOutMarker, error) {
+	if ctx == nil {
+		return SignOutMarker{}, ErrNilContext
+	}
+
+	ss, ok := m.store.(SignOutStore)
+	if !ok {
+		return SignOutMarker{}, ErrNotSupported
+	}
+
+	if ttl <= 0 {
+		ttl = m.storeTTL(key)
+	}
+
+	if ttl <= 0 {
+		ttl = defaultSignOutTTL
+	}
+
+	now := time.Now()
+	mk := SignOutMarker{UserKey: key, At: now, ExpiresAt: now.Add(ttl)}
+
+	// the marker is set first, so that sessions are cut off even if
+	// the deletion fails.
+	err := ss.SetSignOut(ctx, mk)
+	if errors.Is(err, ErrNotSupported) {
+		return SignOutMarker{}, ErrNotSupported
+	}
+
+	if err = m.storeErr(err); err != nil {
+		return SignOutMarker{}, err
+	}
+
+	m.signOuts.store(m.signOutKey(key), mk, true)
+
+	if err := m.RevokeByUserKey(ctx, key); err != nil {
+		return SignOutMarker{}, err
+	}
+
+	return mk, nil
+}
+
+// SignOutPending retrieves the pending "sign out everywhere" marker of
+// the provided user key, e.g. to show it on an account security page.
+// The second return value is false if there is no pending marker or the
+// store does not implement SignOutStore interface.
+func (m *Manager) SignOutPending(ctx context.Context, key string) (SignOutMarker, bool, error) {
+	if ctx == nil {
+		return SignOutMarker{}, false, ErrNilContext
+	}
+
+	ss, ok := m.store.(SignOutStore)
+	if !ok {
+		return SignOutMarker{}, false, nil
+	}
+
+	mk, ok, err := ss.FetchSignOut(ctx, key)
+	if errors.Is(err, ErrNotSupported) {
+		return SignOutMarker{}, false, nil
+	}
+
+	if err = m.storeErr(err); err != nil || !ok || !mk.Pending(time.Now()) {
+		return SignOutMarker{}, false, err
+	}
+
+	return mk, true, nil
+}
+
+// checkSignOut rejects the session if it was created before the pending
+// sign-out marker of its user.
+func (m *Manager) checkSignOut(ctx context.Context, s Session) error {
+	if _, ok := m.store.(SignOutStore); !ok {
+		return nil
+	}
+
+	key := m.signOutKey(s.UserKey)
+	mk, ok, found := m.signOuts.lookup(key)
+	if !found {
+		var err error
+		mk, ok, err = m.SignOutPending(ctx, s.UserKey)
+		if err != nil {
+			return err
+		}
+
+		m.signOuts.store(key, mk, ok)
+	}
+
+	if ok && mk.Pending(time.Now()) && s.CreatedAt.Before(mk.At) {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+// signOutKey returns the key under which the marker of the provided
+// user key is cached, so that clones with different key prefixes (see
+// KeyPrefix) do not share markers.
+func (m *Manager) signOutKey(key string) string {
+	if ps, ok := m.store.(prefixStore); ok {
+		return ps.prefix + key
+	}
+
+	return key
+}
+
+// signOuts holds the cached sign-out markers of the manager and its
+// clones.
+type signOuts struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	mm      map[string]cachedSignOut
+	sweepAt int
+}
+
+// cachedSignOut holds the result of a sign-out marker lookup.
+type cachedSignOut struct {
+	mk  SignOutMarker
+	ok  bool
+	exp time.Time
+}
+
+// lookup retrieves the cached marker of the provided key. The last
+// return value is false if the lookup result is not cached.
+func (c *signOuts) lookup(key string) (SignOutMarker, bool, bool) {
+	if c == nil || c.ttl <= 0 {
+		return SignOutMarker{}, false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cs, ok := c.mm[key]
+	if !ok || !time.Now().Before(cs.exp) {
+		return SignOutMarker{}, false, false
+	}
+
+	return cs.mk, cs.ok, true
+}
+
+// store caches the marker lookup result of the provided key. Expired
+// results are removed once the cache doubles in size since the last
+// removal, so that their cost is spread across the calls.
+func (c *signOuts) store(key string, mk SignOutMarker, ok bool) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.mm == nil {
+		c.mm = make(map[string]cachedSignOut)
+	}
+
+	if len(c.mm) >= c.sweepAt {
+		for k, cs := range c.mm {
+			if !now.Before(cs.exp) {
+				delete(c.mm, k)
+			}
+		}
+
+		c.sweepAt = len(c.mm)*2 + signOutSweep
+	}
+
+	c.mm[key] = cachedSignOut{mk: mk, ok: ok, exp: now.Add(c.ttl)}
+}
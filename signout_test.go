@@ -0,0 +1,208 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type signOutStoreMock struct {
+	*StoreMock
+	marker  SignOutMarker
+	err     error
+	fetches int
+}
+
+func (s *signOutStoreMock) SetSignOut(_ context.Context, mk SignOutMarker) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.marker = mk
+	return nil
+}
+
+func (s *signOutStoreMock) FetchSignOut(_ context.Context, key string) (SignOutMarker, bool, error) {
+	s.fetches++
+	return s.marker, s.err == nil && s.marker.UserKey == key, s.err
+}
+
+func TestSignOutEverywhere(t *testing.T) {
+	var ctx context.Context
+	m := NewManager(mapStore())
+	if _, err := m.SignOutEverywhere(ctx, "key", 0); err != ErrNilContext {
+		t.Errorf("want %v, got %v", ErrNilContext, err)
+	}
+
+	if _, err := m.SignOutEverywhere(context.Background(), "key", 0); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+
+	if _, ok, err := m.SignOutPending(context.Background(), "key"); ok || err != nil {
+		t.Errorf("want false and nil, got %v and %v", ok, err)
+	}
+
+	store := &signOutStoreMock{StoreMock: mapStore()}
+	store.DeleteByUserKeyFunc = func(_ context.Context, _ string, _ ...string) error {
+		return nil
+	}
+
+	m = NewManager(store, ExpiresIn(time.Hour), SignOutCache(0))
+
+	init := func() *http.Cookie {
+		rec := httptest.NewRecorder()
+		if err := m.Init(rec, httptest.NewRequest("GET", "/", nil), "key"); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return rec.Result().Cookies()[0]
+	}
+
+	auth := func(c *http.Cookie) error {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(c)
+		_, err := m.authenticate(httptest.NewRecorder(), req, nil)
+		return err
+	}
+
+	// the session survives the deletion, e.g. in an unreachable
+	// replica of the store.
+	stale := init()
+
+	mk, err := m.SignOutEverywhere(context.Background(), "key", 0)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(store.DeleteByUserKeyCalls()) != 1 {
+		t.Errorf("want %d, got %d", 1, len(store.DeleteByUserKeyCalls()))
+	}
+
+	if d := mk.ExpiresAt.Sub(mk.At); d != time.Hour {
+		t.Errorf("want %v, got %v", time.Hour, d)
+	}
+
+	pending, ok, err := m.SignOutPending(context.Background(), "key")
+	if err != nil || !ok || pending != mk {
+		t.Errorf("want %v, got %v, %v and %v", mk, pending, ok, err)
+	}
+
+	if err = auth(stale); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	time.Sleep(time.Millisecond)
+	if err = auth(init()); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	store.marker.ExpiresAt = time.Now()
+	if err = auth(stale); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if _, ok, _ = m.SignOutPending(context.Background(), "key"); ok {
+		t.Error("want false, got true")
+	}
+
+	store.err = errors.New("error")
+	if _, err = m.SignOutEverywhere(context.Background(), "key", time.Minute); err != store.err {
+		t.Errorf("want %v, got %v", store.err, err)
+	}
+
+	if err = auth(stale); err != store.err {
+		t.Errorf("want %v, got %v", store.err, err)
+	}
+}
+
+func TestSignOutCache(t *testing.T) {
+	store := &signOutStoreMock{StoreMock: mapStore()}
+	store.DeleteByUserKeyFunc = func(_ context.Context, _ string, _ ...string) error {
+		return nil
+	}
+
+	init := func(m *Manager) *http.Cookie {
+		rec := httptest.NewRecorder()
+		if err := m.Init(rec, httptest.NewRequest("GET", "/", nil), "key"); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		return rec.Result().Cookies()[0]
+	}
+
+	auth := func(m *Manager, c *http.Cookie) error {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(c)
+		_, err := m.authenticate(httptest.NewRecorder(), req, nil)
+		return err
+	}
+
+	m := NewManager(store, KeyPrefix("app:"))
+	c := init(m)
+
+	for i := 0; i < 3; i++ {
+		if err := auth(m.Clone(), c); err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+	}
+
+	if store.fetches != 1 {
+		t.Errorf("want %d, got %d", 1, store.fetches)
+	}
+
+	// markers set through the manager take effect immediately and are
+	// namespaced by the key prefix.
+	if _, err := m.SignOutEverywhere(context.Background(), "key", 0); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if store.marker.UserKey != "app:key" {
+		t.Errorf("want %q, got %q", "app:key", store.marker.UserKey)
+	}
+
+	if err := auth(m, c); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	if mk, ok, err := m.SignOutPending(context.Background(), "key"); err != nil || !ok || mk.UserKey != "key" {
+		t.Errorf("want pending marker of %q, got %v, %v and %v", "key", mk, ok, err)
+	}
+
+	// stores without sign-out markers are not queried.
+	pm := NewManager(mapStore(), KeyPrefix("app:"))
+	if err := auth(pm, init(pm)); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	if _, err := pm.SignOutEverywhere(context.Background(), "key", 0); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestSignOuts(t *testing.T) {
+	var c *signOuts
+	c.store("key", SignOutMarker{}, true)
+	if _, _, found := c.lookup("key"); found {
+		t.Error("want false, got true")
+	}
+
+	c = &signOuts{ttl: time.Minute}
+	c.store("expired", SignOutMarker{}, false)
+	c.mm["expired"] = cachedSignOut{exp: time.Now()}
+	if _, _, found := c.lookup("expired"); found {
+		t.Error("want false, got true")
+	}
+
+	c.sweepAt = 0
+	c.store("key", SignOutMarker{UserKey: "key"}, true)
+	if _, ok := c.mm["expired"]; ok || c.sweepAt != signOutSweep {
+		t.Errorf("want expired results removed, got %v and %d", c.mm, c.sweepAt)
+	}
+
+	if mk, ok, found := c.lookup("key"); !found || !ok || mk.UserKey != "key" {
+		t.Errorf("want cached marker, got %v, %v and %v", mk, ok, found)
+	}
+}
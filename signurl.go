@@ -0,0 +1,130 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/swithek/sessionup/token"
+)
+
+const signedURLParam = "sig"
+
+var (
+	// ErrInvalidSignedURL is returned when the signed URL's token is
+	// missing, malformed, expired, issued for another URL or its
+	// session is no longer valid.
+	ErrInvalidSignedURL = errors.New("invalid or expired signed URL")
+
+	// ErrNoSigningKey is returned when URLs or webhooks are being
+	// signed or verified without the signing key set.
+	ErrNoSigningKey = errors.New("signing key is not set")
+)
+
+// SigningKey sets the key (token.KeySize bytes long) used to sign URLs
+// with SignURL and to verify them with VerifySignedURL, as well as to
+// derive the sessions' webhook secrets (see WebhookSecret).
+// All instances of the application must use the same key.
+// By default it is not set.
+func SigningKey(k []byte) Option {
+	return func(m *Manager) {
+		if len(k) != token.KeySize {
+			m.invalid("SigningKey", token.ErrKeySize)
+		}
+
+		m.signingKey = k
+	}
+}
+
+// SignURL produces a time-limited URL, bound to the session stored in
+// the context, that can be used to access subresources (e.g. downloads
+// or media segments) without cookies. The token, appended to the URL's
+// query, is encrypted, so that the session ID is never exposed, and is
+// valid only for the same path and query parameters. The token follows
+// the version 1 wire format of the token package: its message holds
+// the big-endian Unix expiration time (8 bytes) followed by the session
+// ID, its implicit assertion is the URL's path, "?" and the sorted,
+// encoded query (without the token parameter).
+// ErrUnauthorized is returned, if context session is not set.
+func (m *Manager) SignURL(ctx context.Context, rawurl string, ttl time.Duration) (string, error) {
+	if ctx == nil {
+		return "", ErrNilContext
+	}
+
+	s, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrUnauthorized
+	}
+
+	if len(m.signingKey) == 0 {
+		return "", ErrNoSigningKey
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Del(signedURLParam)
+
+	payload := make([]byte, 8, 8+len(s.ID))
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Add(ttl).Unix()))
+	payload = append(payload, s.ID...)
+
+	tok, err := token.Encrypt(m.signingKey, payload, nil, []byte(u.Path+"?"+q.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	q.Set(signedURLParam, tok)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// VerifySignedURL checks the token of the URL produced by SignURL and
+// returns the session it is bound to. The session is retrieved from
+// the store, so revoked sessions invalidate their signed URLs as well.
+// ErrInvalidSignedURL is returned if the token or its session is not
+// valid.
+func (m *Manager) VerifySignedURL(r *http.Request) (Session, error) {
+	if r == nil || r.URL == nil {
+		return Session{}, ErrNilRequest
+	}
+
+	if len(m.signingKey) == 0 {
+		return Session{}, ErrNoSigningKey
+	}
+
+	q := r.URL.Query()
+	tok := q.Get(signedURLParam)
+	q.Del(signedURLParam)
+
+	payload, _, err := token.Decrypt(m.signingKey, tok, []byte(r.URL.Path+"?"+q.Encode()))
+	if errors.Is(err, token.ErrKeySize) {
+		return Session{}, err
+	}
+
+	if err != nil || len(payload) < 8 {
+		return Session{}, ErrInvalidSignedURL
+	}
+
+	if time.Now().Add(-m.Config().ClockSkew).Unix() > int64(binary.BigEndian.Uint64(payload)) {
+		return Session{}, ErrInvalidSignedURL
+	}
+
+	s, ok, err := m.store.FetchByID(r.Context(), string(payload[8:]))
+	if err = m.storeErr(err); err != nil {
+		return Session{}, err
+	}
+
+	if !ok || s.Kind != "" {
+		return Session{}, ErrInvalidSignedURL
+	}
+
+	return s, nil
+}
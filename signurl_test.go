@@ -0,0 +1,206 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup/token"
+)
+
+func TestSigningKey(t *testing.T) {
+	m := Manager{}
+	val := []byte("0123456789abcdef0123456789abcdef")
+	SigningKey(val)(&m)
+	if string(m.signingKey) != string(val) {
+		t.Errorf("want %q, got %q", val, m.signingKey)
+	}
+}
+
+func TestSignURL(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	ctx := NewContext(context.Background(), Session{ID: "id"})
+
+	cc := map[string]struct {
+		Key []byte
+		Ctx context.Context
+		URL string
+		Err error
+	}{
+		"No context session": {
+			Key: key,
+			Ctx: context.Background(),
+			URL: "/file",
+			Err: ErrUnauthorized,
+		},
+		"No signing key": {
+			Ctx: ctx,
+			URL: "/file",
+			Err: ErrNoSigningKey,
+		},
+		"Successful signing": {
+			Key: key,
+			Ctx: ctx,
+			URL: "https://cdn.example.com/file?b=2&a=1&sig=old",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := Manager{signingKey: c.Key}
+			res, err := m.SignURL(c.Ctx, c.URL, time.Minute)
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if c.Err != nil {
+				return
+			}
+
+			u, err := url.Parse(res)
+			if err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			q := u.Query()
+			if u.Host != "cdn.example.com" || q.Get("a") != "1" || q.Get("b") != "2" {
+				t.Errorf("want original URL preserved, got %q", res)
+			}
+
+			if sig := q.Get(signedURLParam); !strings.HasPrefix(sig, token.HeaderV1) {
+				t.Errorf("want new opaque token, got %q", sig)
+			}
+		})
+	}
+}
+
+func TestVerifySignedURL(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	errTest := errors.New("error")
+	ctx := NewContext(context.Background(), Session{ID: "id"})
+	sign := func(rawurl string, ttl time.Duration) string {
+		res, err := (&Manager{signingKey: key}).SignURL(ctx, rawurl, ttl)
+		if err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+		return res
+	}
+
+	store := func(ok bool, err error) Store {
+		return &StoreMock{
+			FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+				return Session{ID: id}, ok, err
+			},
+		}
+	}
+
+	cc := map[string]struct {
+		Store Store
+		Key   []byte
+		URL   string
+		Err   error
+	}{
+		"No signing key": {
+			Store: store(true, nil),
+			URL:   sign("/file", time.Minute),
+			Err:   ErrNoSigningKey,
+		},
+		"Missing token": {
+			Store: store(true, nil),
+			Key:   key,
+			URL:   "/file",
+			Err:   ErrInvalidSignedURL,
+		},
+		"Malformed token": {
+			Store: store(true, nil),
+			Key:   key,
+			URL:   "/file?sig=abc",
+			Err:   ErrInvalidSignedURL,
+		},
+		"Token signed with another key": {
+			Store: store(true, nil),
+			Key:   []byte("fedcba9876543210fedcba9876543210"),
+			URL:   sign("/file", time.Minute),
+			Err:   ErrInvalidSignedURL,
+		},
+		"Token of another path": {
+			Store: store(true, nil),
+			Key:   key,
+			URL:   strings.Replace(sign("/file", time.Minute), "/file", "/other", 1),
+			Err:   ErrInvalidSignedURL,
+		},
+		"Token with tampered query": {
+			Store: store(true, nil),
+			Key:   key,
+			URL:   sign("/file?a=1", time.Minute) + "&a=2",
+			Err:   ErrInvalidSignedURL,
+		},
+		"Expired token": {
+			Store: store(true, nil),
+			Key:   key,
+			URL:   sign("/file", -time.Minute),
+			Err:   ErrInvalidSignedURL,
+		},
+		"Error returned by store.FetchByID": {
+			Store: store(false, errTest),
+			Key:   key,
+			URL:   sign("/file", time.Minute),
+			Err:   errTest,
+		},
+		"Revoked session": {
+			Store: store(false, nil),
+			Key:   key,
+			URL:   sign("/file", time.Minute),
+			Err:   ErrInvalidSignedURL,
+		},
+		"Valid token": {
+			Store: store(true, nil),
+			Key:   key,
+			URL:   sign("/file?a=1", time.Minute),
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := Manager{store: c.Store, signingKey: c.Key}
+			s, err := m.VerifySignedURL(httptest.NewRequest("GET", c.URL, nil))
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if c.Err == nil && s.ID != "id" {
+				t.Errorf("want %q, got %q", "id", s.ID)
+			}
+		})
+	}
+}
+
+func TestVerifySignedURLClockSkew(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	m := Manager{
+		store: &StoreMock{
+			FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+				return Session{ID: id}, true, nil
+			},
+		},
+		signingKey: key,
+		clockSkew:  time.Minute,
+	}
+
+	u, err := m.SignURL(NewContext(context.Background(), Session{ID: "id"}), "/file", -time.Second*30)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, err = m.VerifySignedURL(httptest.NewRequest("GET", u, nil)); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
@@ -0,0 +1,409 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The simulation drives a manager that combines rolling expiration
+// (HeartbeatHandler), ID rotation (Revoke followed by Init), revocation
+// and caching (ReadYourWrites) through random, but reproducible,
+// sequences of requests of multiple clients, while the store randomly
+// fails before or after applying the operations. After every
+// authenticated request the invariants of the session lifecycle are
+// checked. Failures report the seed and the steps that led to them.
+
+// errSimFault is the error injected by the simulation store.
+var errSimFault = errors.New("simulated fault")
+
+// simStore is an in-memory store whose operations fail randomly. Write
+// operations may fail either before they are applied or after (i.e.
+// the acknowledgement is lost). Time is virtual: advancing it moves the
+// timestamps of all stored sessions into the past.
+type simStore struct {
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	rate  float64
+	ss    map[string]Session
+	users map[string][]string
+}
+
+func newSimStore(rnd *rand.Rand, rate float64) *simStore {
+	return &simStore{
+		rnd:   rnd,
+		rate:  rate,
+		ss:    make(map[string]Session),
+		users: make(map[string][]string),
+	}
+}
+
+// fail reports whether the operation should fail now.
+func (s *simStore) fail() bool {
+	return s.rnd.Float64() < s.rate
+}
+
+// write runs the write operation, injecting faults around it.
+func (s *simStore) write(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail() {
+		return errSimFault
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	if s.fail() {
+		return errSimFault
+	}
+
+	return nil
+}
+
+func (s *simStore) Create(_ context.Context, ses Session) error {
+	return s.write(func() error {
+		if _, ok := s.ss[ses.ID]; ok {
+			return ErrDuplicateID
+		}
+
+		s.ss[ses.ID] = ses
+		s.users[ses.UserKey] = append(s.users[ses.UserKey], ses.ID)
+		return nil
+	})
+}
+
+func (s *simStore) FetchByID(_ context.Context, id string) (Session, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail() {
+		return Session{}, false, errSimFault
+	}
+
+	ses, ok := s.ss[id]
+	if !ok || IsExpired(ses, time.Now()) {
+		return Session{}, false, nil
+	}
+
+	return ses, true, nil
+}
+
+func (s *simStore) FetchByUserKey(_ context.Context, key string) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail() {
+		return nil, errSimFault
+	}
+
+	var ss []Session
+	for _, id := range s.users[key] {
+		ss = append(ss, s.ss[id])
+	}
+
+	return ExpiredFilter(ss, time.Now()), nil
+}
+
+func (s *simStore) Update(_ context.Context, ses Session) error {
+	return s.write(func() error {
+		old, ok := s.ss[ses.ID]
+		if !ok || old.UserKey != ses.UserKey {
+			return ErrNotFound
+		}
+
+		s.ss[ses.ID] = ses
+		return nil
+	})
+}
+
+func (s *simStore) DeleteByID(_ context.Context, id string) error {
+	return s.write(func() error {
+		s.del(id)
+		return nil
+	})
+}
+
+func (s *simStore) DeleteByUserKey(_ context.Context, key string, expID ...string) error {
+	return s.write(func() error {
+	Outer:
+		for _, id := range append([]string(nil), s.users[key]...) {
+			for _, eid := range expID {
+				if id == eid {
+					continue Outer
+				}
+			}
+
+			s.del(id)
+		}
+
+		return nil
+	})
+}
+
+// del deletes the session and its index entry.
+func (s *simStore) del(id string) {
+	ses, ok := s.ss[id]
+	if !ok {
+		return
+	}
+
+	delete(s.ss, id)
+
+	ids := s.users[ses.UserKey]
+	for i := range ids {
+		if ids[i] == id {
+			s.users[ses.UserKey] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// advance moves the virtual clock forward.
+func (s *simStore) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, ses := range s.ss {
+		s.ss[id] = shiftSession(ses, d)
+	}
+}
+
+// shiftSession moves the session's timestamps into the past.
+func shiftSession(s Session, d time.Duration) Session {
+	s.CreatedAt = s.CreatedAt.Add(-d)
+	s.ExpiresAt = s.ExpiresAt.Add(-d)
+	if !s.CookieExpiresAt.IsZero() {
+		s.CookieExpiresAt = s.CookieExpiresAt.Add(-d)
+	}
+
+	return s
+}
+
+// simClient is a browser of a single user.
+type simClient struct {
+	user   string
+	cookie string
+}
+
+// simulation holds the state of a single simulation run and the model
+// of the sessions that it expects.
+type simulation struct {
+	t     *testing.T
+	seed  int64
+	rnd   *rand.Rand
+	store *simStore
+	m     *Manager
+	h     http.Handler
+	cc    []*simClient
+	log   []string
+
+	// owners holds the users that the session IDs were last issued
+	// to.
+	owners map[string]string
+
+	// revoked holds the IDs whose revocation was acknowledged after
+	// they were last issued.
+	revoked map[string]bool
+}
+
+func newSimulation(t *testing.T, seed int64) *simulation {
+	rnd := rand.New(rand.NewSource(seed))
+	sim := &simulation{
+		t:       t,
+		seed:    seed,
+		rnd:     rnd,
+		store:   newSimStore(rnd, 0.05),
+		owners:  make(map[string]string),
+		revoked: make(map[string]bool),
+	}
+
+	// the ID space is kept small, so that collisions happen.
+	sim.m = NewManager(sim.store,
+		Validate(false),
+		ExpiresIn(time.Hour),
+		ReadYourWrites(time.Hour),
+		GenID(NewGenID(rnd, "abcd", 5)),
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		sim.init(w, r, r.URL.Query().Get("user"))
+	})
+	mux.Handle("/auth", sim.m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		sim.check(r)
+	})))
+	mux.Handle("/heartbeat", sim.m.Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sim.check(r)
+		sim.m.HeartbeatHandler(time.Second).ServeHTTP(w, r)
+	})))
+	mux.Handle("/rotate", sim.m.Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := sim.check(r)
+		if sim.m.Revoke(r.Context(), w) != nil {
+			return
+		}
+
+		sim.revoked[s.ID] = true
+		sim.init(w, r, s.UserKey)
+	})))
+	mux.Handle("/logout", sim.m.Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := sim.check(r)
+		if sim.m.Revoke(r.Context(), w) == nil {
+			sim.revoked[s.ID] = true
+		}
+	})))
+	mux.Handle("/revoke-other", sim.m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		s := sim.check(r)
+		if sim.m.RevokeOther(r.Context()) == nil {
+			sim.revokeUser(s.UserKey, s.ID)
+		}
+	})))
+
+	sim.h = mux
+
+	for i := 0; i < 6; i++ {
+		sim.cc = append(sim.cc, &simClient{user: fmt.Sprintf("user%d", i%3)})
+	}
+
+	return sim
+}
+
+// init creates a new session and records its owner.
+func (sim *simulation) init(w http.ResponseWriter, r *http.Request, key string) {
+	rec := httptest.NewRecorder()
+	if sim.m.Init(rec, r, key) != nil {
+		return
+	}
+
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == defaultName {
+			sim.owners[c.Value] = key
+			delete(sim.revoked, c.Value)
+			http.SetCookie(w, c)
+		}
+	}
+}
+
+// revokeUser records the revocation of all sessions of the user, except
+// the one with the provided ID.
+func (sim *simulation) revokeUser(key, exceptID string) {
+	for id, owner := range sim.owners {
+		if owner == key && id != exceptID {
+			sim.revoked[id] = true
+		}
+	}
+}
+
+// check verifies the invariants for the authenticated session.
+func (sim *simulation) check(r *http.Request) Session {
+	s, _ := FromContext(r.Context())
+
+	switch {
+	case sim.revoked[s.ID]:
+		sim.fail("session %q survived revocation", s.ID)
+	case sim.owners[s.ID] != s.UserKey:
+		sim.fail("session %q was issued to %q, but belongs to %q", s.ID, sim.owners[s.ID], s.UserKey)
+	case !s.ExpiresAt.After(time.Now()):
+		sim.fail("session %q is expired", s.ID)
+	}
+
+	return s
+}
+
+// fail reports the broken invariant with the steps that led to it.
+func (sim *simulation) fail(format string, args ...interface{}) {
+	sim.t.Helper()
+	sim.t.Errorf("seed %d: %s\nsteps:\n%s", sim.seed, fmt.Sprintf(format, args...), strings.Join(sim.log, "\n"))
+}
+
+// step performs a random action of a random client.
+func (sim *simulation) step() {
+	c := sim.cc[sim.rnd.Intn(len(sim.cc))]
+	target := "/auth"
+
+	switch n := sim.rnd.Intn(100); {
+	case n < 15 || c.cookie == "":
+		target = "/login?user=" + c.user
+	case n < 25:
+		target = "/heartbeat"
+	case n < 35:
+		target = "/rotate"
+	case n < 40:
+		target = "/logout"
+	case n < 45:
+		target = "/revoke-other"
+	case n < 50:
+		if sim.m.RevokeByUserKey(context.Background(), c.user) == nil {
+			sim.revokeUser(c.user, "")
+		}
+
+		sim.log = append(sim.log, "revoke all of "+c.user)
+		return
+	case n < 60:
+		d := time.Duration(sim.rnd.Int63n(int64(time.Minute * 40)))
+		sim.advance(d)
+		sim.log = append(sim.log, "advance "+d.String())
+		return
+	}
+
+	req := httptest.NewRequest("GET", target, nil)
+	if c.cookie != "" {
+		req.AddCookie(&http.Cookie{Name: defaultName, Value: c.cookie})
+	}
+
+	rec := httptest.NewRecorder()
+	sim.h.ServeHTTP(rec, req)
+
+	for _, ck := range rec.Result().Cookies() {
+		if ck.Name == defaultName {
+			c.cookie = ck.Value
+			if ck.MaxAge < 0 {
+				c.cookie = ""
+			}
+		}
+	}
+
+	sim.log = append(sim.log, fmt.Sprintf("%s %s with %q: %d", c.user, target, req.Header.Get("Cookie"), rec.Code))
+}
+
+// advance moves the virtual clock of the store and the manager's cache
+// forward.
+func (sim *simulation) advance(d time.Duration) {
+	sim.store.advance(d)
+
+	h := sim.m.hints
+	h.mu.Lock()
+	for id, ht := range h.ss {
+		h.ss[id] = hint{s: shiftSession(ht.s, d), exp: ht.exp.Add(-d)}
+	}
+	h.mu.Unlock()
+}
+
+func TestSimulation(t *testing.T) {
+	seeds, steps := 50, 300
+	if testing.Short() {
+		seeds = 10
+	}
+
+	for seed := int64(1); seed <= int64(seeds); seed++ {
+		seed := seed
+
+		t.Run(fmt.Sprint(seed), func(t *testing.T) {
+			t.Parallel()
+
+			sim := newSimulation(t, seed)
+			for i := 0; i < steps && !t.Failed(); i++ {
+				sim.step()
+			}
+		})
+	}
+}
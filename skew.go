@@ -0,0 +1,93 @@
+package sessionup
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// cookieSkewTolerance specifies the maximum allowed difference between
+// the cookie's expiration time and the session's expiration time in the
+// store. Cookie expiration times are precise only to a second.
+const cookieSkewTolerance = time.Second
+
+// RepairSkew determines whether Auth and Public middlewares should
+// detect persistent sessions whose cookie expiration time drifted away
+// from the expiration time in the store (e.g. when the latter was
+// extended or shortened directly in the store) and re-issue the cookie
+// with the store's expiration time. The new cookie expiration time is
+// saved in the session if the store implements UpdateStore interface.
+// By default it is not set.
+func RepairSkew(r bool) Option {
+	return func(m *Manager) {
+		m.repairSkew = r
+	}
+}
+
+// ClockSkew sets the tolerated clock difference between application
+// instances. Cookies are issued to expire earlier than their sessions
+// by this duration, so that the client stops sending them before any
+// instance considers the session expired, and timestamp checks (signed
+// URLs, RequireVerified) accept values that are off by this duration.
+// By default it is not set.
+func ClockSkew(d time.Duration) Option {
+	return func(m *Manager) {
+		if d < 0 {
+			m.invalid("ClockSkew", ErrNegative)
+		}
+
+		m.clockSkew = d
+	}
+}
+
+// cookieExpiry determines the cookie expiration time of the session
+// that expires at the provided time.
+func (m *Manager) cookieExpiry(exp time.Time) time.Time {
+	if exp.IsZero() {
+		return exp
+	}
+
+	return exp.Add(-m.Config().ClockSkew)
+}
+
+// hasSkew checks whether the cookie and store expiration times of the
+// persistent session differ or not.
+func (m *Manager) hasSkew(s Session) bool {
+	if s.CookieExpiresAt.IsZero() || m.lifetime.cookie > 0 {
+		return false
+	}
+
+	d := s.CookieExpiresAt.Sub(m.cookieExpiry(s.ExpiresAt))
+	return d > cookieSkewTolerance || d < -cookieSkewTolerance
+}
+
+// fixSkew re-issues the cookie of the session with skewed expiration
+// times and records the new cookie expiration time in the store.
+// Failures to update the session are ignored, since the cookie is
+// already fixed and the skew will be detected again otherwise.
+func (m *Manager) fixSkew(w http.ResponseWriter, r *http.Request, s Session) Session {
+	if !m.hasSkew(s) {
+		return s
+	}
+
+	exp := m.cookieExpiry(s.ExpiresAt)
+	s.CookieExpiresAt = exp
+	if err := m.setSessionCookie(w, exp, s); err != nil {
+		return s
+	}
+
+	if m.csrfActive() {
+		m.setCSRFCookie(w, exp, s.CSRFToken)
+	}
+
+	us, err := m.update(r.Context(), s)
+	if err != nil {
+		if !errors.Is(err, ErrNotSupported) && !errors.Is(err, ErrConflict) {
+			m.storeErr(err)
+		}
+
+		return s
+	}
+
+	return us
+}
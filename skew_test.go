@@ -0,0 +1,194 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRepairSkew(t *testing.T) {
+	m := Manager{}
+	val := true
+	RepairSkew(val)(&m)
+	if m.repairSkew != val {
+		t.Errorf("want %t, got %t", val, m.repairSkew)
+	}
+}
+
+func TestHasSkew(t *testing.T) {
+	now := time.Now()
+	cc := map[string]struct {
+		Cookie time.Time
+		Store  time.Time
+		Skew   bool
+	}{
+		"Temporary session": {
+			Store: now,
+		},
+		"Equal expiration times": {
+			Cookie: now,
+			Store:  now,
+		},
+		"Difference within tolerance": {
+			Cookie: now.Add(time.Millisecond * 500),
+			Store:  now,
+		},
+		"Store expiration extended": {
+			Cookie: now,
+			Store:  now.Add(time.Hour),
+			Skew:   true,
+		},
+		"Store expiration shortened": {
+			Cookie: now.Add(time.Hour),
+			Store:  now,
+			Skew:   true,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			if skew := (&Manager{}).hasSkew(Session{CookieExpiresAt: c.Cookie, ExpiresAt: c.Store}); skew != c.Skew {
+				t.Errorf("want %t, got %t", c.Skew, skew)
+			}
+		})
+	}
+}
+
+func TestFixSkew(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	skewed := Session{ID: "id", ExpiresAt: now.Add(time.Hour), CookieExpiresAt: now}
+
+	cc := map[string]struct {
+		Store   Store
+		Session Session
+		Cookie  bool
+		Updated int
+	}{
+		"No skew": {
+			Store:   &updateStoreMock{StoreMock: &StoreMock{}},
+			Session: Session{ID: "id", ExpiresAt: now, CookieExpiresAt: now},
+		},
+		"Store without update support": {
+			Store:   &StoreMock{},
+			Session: skewed,
+			Cookie:  true,
+		},
+		"Error returned by store.Update": {
+			Store: &updateStoreMock{StoreMock: &StoreMock{}, UpdateFunc: func(_ context.Context, _ Session) error {
+				return errors.New("error")
+			}},
+			Session: skewed,
+			Cookie:  true,
+			Updated: 1,
+		},
+		"Skew repaired": {
+			Store: &updateStoreMock{StoreMock: &StoreMock{}, UpdateFunc: func(_ context.Context, _ Session) error {
+				return nil
+			}},
+			Session: skewed,
+			Cookie:  true,
+			Updated: 1,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			m := NewManager(c.Store)
+			rec := httptest.NewRecorder()
+			s := m.fixSkew(rec, httptest.NewRequest("GET", "http://example.com/", nil), c.Session)
+
+			cookies := rec.Result().Cookies()
+			if (len(cookies) == 1) != c.Cookie {
+				t.Fatalf("want cookie %t, got %v", c.Cookie, cookies)
+			}
+
+			if c.Cookie && !cookies[0].Expires.Equal(c.Session.ExpiresAt) {
+				t.Errorf("want %v, got %v", c.Session.ExpiresAt, cookies[0].Expires)
+			}
+
+			if !s.CookieExpiresAt.Equal(s.ExpiresAt) {
+				t.Errorf("want %v, got %v", s.ExpiresAt, s.CookieExpiresAt)
+			}
+
+			if us, ok := c.Store.(*updateStoreMock); ok && len(us.updated) != c.Updated {
+				t.Errorf("want %d, got %d", c.Updated, len(us.updated))
+			}
+		})
+	}
+}
+
+func TestAuthRepairSkew(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	s := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id, ExpiresAt: now.Add(time.Hour), CookieExpiresAt: now}, true, nil
+		},
+	}
+
+	for _, repair := range []bool{false, true} {
+		m := NewManager(s, RepairSkew(repair))
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.AddCookie(&http.Cookie{Name: defaultName, Value: "id"})
+		m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(rec, req)
+
+		if n := len(rec.Result().Cookies()); (n == 1) != repair {
+			t.Errorf("repair %t: want cookie %t, got %d cookies", repair, repair, n)
+		}
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	m := Manager{}
+	val := time.Second * 5
+	ClockSkew(val)(&m)
+	if m.clockSkew != val {
+		t.Errorf("want %v, got %v", val, m.clockSkew)
+	}
+}
+
+func TestCookieExpiry(t *testing.T) {
+	now := time.Now()
+	m := Manager{clockSkew: time.Second * 5}
+	if exp := m.cookieExpiry(time.Time{}); !exp.IsZero() {
+		t.Errorf("want zero, got %v", exp)
+	}
+
+	if exp := m.cookieExpiry(now); !exp.Equal(now.Add(-time.Second * 5)) {
+		t.Errorf("want %v, got %v", now.Add(-time.Second*5), exp)
+	}
+
+	if m.hasSkew(Session{ExpiresAt: now, CookieExpiresAt: m.cookieExpiry(now)}) {
+		t.Error("want no skew, got skew")
+	}
+}
+
+func TestInitClockSkew(t *testing.T) {
+	s := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+	}
+
+	m := NewManager(s, ExpiresIn(time.Hour), ClockSkew(time.Minute))
+	rec := httptest.NewRecorder()
+	if err := m.Init(rec, httptest.NewRequest("GET", "http://example.com/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	ses := s.CreateCalls()[0].S
+	if d := ses.ExpiresAt.Sub(ses.CookieExpiresAt); d != time.Minute {
+		t.Errorf("want %v, got %v", time.Minute, d)
+	}
+
+	if c := rec.Result().Cookies()[0]; !c.Expires.Equal(ses.CookieExpiresAt.Truncate(time.Second)) {
+		t.Errorf("want %v, got %v", ses.CookieExpiresAt, c.Expires)
+	}
+}
@@ -0,0 +1,161 @@
+package sessionup
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/swithek/sessionup/token"
+)
+
+const (
+	// defaultRefresh is the default interval after which stateless
+	// sessions are checked against the store.
+	defaultRefresh = time.Minute * 5
+
+	// statelessAssertion is the implicit assertion of stateless
+	// session tokens. It separates them from signed URL tokens,
+	// whose assertions always start with a slash.
+	statelessAssertion = "session"
+)
+
+// Stateless activates the stateless mode: session cookies hold the
+// whole session, encrypted with the provided key (token.KeySize bytes
+// long), instead of its ID. Auth and Public middlewares accept such
+// cookies without querying the store until the refresh interval
+// passes; after that the session is checked against the store and, if
+// it still exists, its cookie is rewritten. Revocations therefore take
+// effect within the refresh interval.
+// Refresh intervals <= 0 default to 5 minutes.
+// By default it is not set.
+func Stateless(key []byte, refresh time.Duration) Option {
+	return func(m *Manager) {
+		if refresh <= 0 {
+			refresh = defaultRefresh
+		}
+
+		if len(key) != token.KeySize {
+			m.invalid("Stateless", token.ErrKeySize)
+		}
+
+		m.stateless.key = key
+		m.stateless.refresh = refresh
+	}
+}
+
+// SignStateless makes stateless session tokens (see Stateless) signed
+// with the provided Ed25519 key instead of encrypted with the
+// symmetric one, so that they can be validated by services that hold
+// only the public key (see ExportBundle). Signed tokens are not
+// encrypted: clients can read the sessions they hold.
+// By default it is not set.
+func SignStateless(key ed25519.PrivateKey) Option {
+	return func(m *Manager) {
+		if len(key) != ed25519.PrivateKeySize {
+			m.invalid("SignStateless", token.ErrKeySize)
+		}
+
+		m.stateless.signer = key
+	}
+}
+
+// statelessPayload is the message of stateless session tokens. It is
+// encoded as a JSON object that holds the session's stored fields
+// under the same names as WriteNDJSON records (e.g. "id", "user_key",
+// "expires_at") and the "checked_at" time of the session's last check
+// against the store, so that services written in other languages can
+// read the tokens.
+type statelessPayload struct {
+	sessionRecord
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// cookieValue produces the value of the session cookie: session's ID
+// or, in stateless mode, its token.
+func (m *Manager) cookieValue(s Session) (string, error) {
+	if m.stateless.key == nil {
+		return s.ID, nil
+	}
+
+	msg, err := json.Marshal(statelessPayload{sessionRecord: newSessionRecord(s), CheckedAt: time.Now()})
+	if err != nil {
+		return "", err
+	}
+
+	if m.stateless.signer != nil {
+		return token.Sign(m.stateless.signer, msg, nil, []byte(statelessAssertion))
+	}
+
+	return token.Encrypt(m.stateless.key, msg, nil, []byte(statelessAssertion))
+}
+
+// openToken verifies the stateless session token and returns its
+// message.
+func (m *Manager) openToken(val string) ([]byte, error) {
+	if m.stateless.signer != nil {
+		msg, _, err := token.Verify(m.stateless.signer.Public().(ed25519.PublicKey), val, []byte(statelessAssertion))
+		return msg, err
+	}
+
+	msg, _, err := token.Decrypt(m.stateless.key, val, []byte(statelessAssertion))
+	return msg, err
+}
+
+// setSessionCookie sets the session cookie with the provided
+// expiration time.
+func (m *Manager) setSessionCookie(w http.ResponseWriter, exp time.Time, s Session) error {
+	val, err := m.cookieValue(s)
+	if err != nil {
+		return err
+	}
+
+	m.setCookie(w, exp, val)
+	return nil
+}
+
+// loadSession retrieves the session the cookie value belongs to. In
+// stateless mode the session is extracted from the token and, once
+// the refresh interval passes, checked against the store and its
+// cookie rewritten.
+func (m *Manager) loadSession(ctx context.Context, w http.ResponseWriter, val string) (Session, bool, error) {
+	if m.stateless.key == nil {
+		s, ok, err := m.fetch(ctx, val)
+		if err == nil && !ok {
+			s, ok = m.hints.lookup(val)
+		}
+
+		return s, ok, m.storeErr(err)
+	}
+
+	msg, err := m.openToken(val)
+	if err != nil {
+		return Session{}, false, nil
+	}
+
+	var p statelessPayload
+	if err = json.Unmarshal(msg, &p); err != nil {
+		return Session{}, false, nil
+	}
+
+	s := p.session()
+	if IsExpired(s, time.Now().Add(-m.Config().ClockSkew)) {
+		return Session{}, false, nil
+	}
+
+	if time.Since(p.CheckedAt) < m.stateless.refresh {
+		return s, true, nil
+	}
+
+	s, ok, err := m.fetch(ctx, s.ID)
+	if err = m.storeErr(err); err != nil || !ok {
+		return Session{}, false, err
+	}
+
+	if err = m.setSessionCookie(w, s.CookieExpiresAt, s); err != nil {
+		return Session{}, false, err
+	}
+
+	return s, true, nil
+}
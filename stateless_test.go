@@ -0,0 +1,204 @@
+package sessionup
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup/token"
+)
+
+func TestStateless(t *testing.T) {
+	m := Manager{}
+	Stateless([]byte("key"), 0)(&m)
+	if string(m.stateless.key) != "key" {
+		t.Errorf("want %q, got %q", "key", m.stateless.key)
+	}
+
+	if m.stateless.refresh != defaultRefresh {
+		t.Errorf("want %v, got %v", defaultRefresh, m.stateless.refresh)
+	}
+
+	Stateless([]byte("key"), time.Minute)(&m)
+	if m.stateless.refresh != time.Minute {
+		t.Errorf("want %v, got %v", time.Minute, m.stateless.refresh)
+	}
+}
+
+func TestStatelessAuth(t *testing.T) {
+	key := make([]byte, 32)
+	store := mapStore()
+	m := NewManager(store, Stateless(key, time.Minute))
+
+	rec := httptest.NewRecorder()
+	if err := m.Init(rec, httptest.NewRequest("GET", "/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("want %d, got %d", 1, len(cookies))
+	}
+
+	auth := func(m *Manager, val string) (*httptest.ResponseRecorder, Session) {
+		var s Session
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: defaultName, Value: val})
+		rec := httptest.NewRecorder()
+		m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			s, _ = FromContext(r.Context())
+		})).ServeHTTP(rec, req)
+		return rec, s
+	}
+
+	tok := cookies[0].Value
+	res, s := auth(m, tok)
+	if res.Code != http.StatusOK || s.UserKey != "key" || s.ID == "" || s.ID == tok {
+		t.Fatalf("want authenticated session, got %d/%v", res.Code, s)
+	}
+
+	if n := len(store.FetchByIDCalls()); n != 0 {
+		t.Errorf("want %d store calls, got %d", 0, n)
+	}
+
+	if res, _ = auth(m, tok+"x"); res.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+
+	if res, _ = auth(m, s.ID); res.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+
+	// a negative refresh interval forces the check against the store
+	rm := m.Clone()
+	rm.stateless.refresh = -time.Second
+
+	res, _ = auth(rm, tok)
+	if res.Code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, res.Code)
+	}
+
+	if n := len(store.FetchByIDCalls()); n != 1 {
+		t.Errorf("want %d store calls, got %d", 1, n)
+	}
+
+	cookies = res.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "" || cookies[0].Value == tok {
+		t.Errorf("want rewritten cookie, got %v", cookies)
+	}
+
+	store.DeleteByID(context.Background(), s.ID)
+
+	if res, _ = auth(m, tok); res.Code != http.StatusOK {
+		t.Errorf("within refresh interval: want %d, got %d", http.StatusOK, res.Code)
+	}
+
+	if res, _ = auth(rm, tok); res.Code != http.StatusUnauthorized {
+		t.Errorf("after refresh interval: want %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+}
+
+func TestSignStateless(t *testing.T) {
+	signer := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	if _, err := New(mapStore(), SignStateless(signer[1:])); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+
+	key := make([]byte, 32)
+	m := NewManager(mapStore(), Stateless(key, time.Minute), SignStateless(signer))
+
+	rec := httptest.NewRecorder()
+	if err := m.Init(rec, httptest.NewRequest("GET", "/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	tok := rec.Result().Cookies()[0].Value
+	if !strings.HasPrefix(tok, token.HeaderPublicV1) {
+		t.Errorf("want signed token, got %q", tok)
+	}
+
+	if s, ok, err := m.loadSession(context.Background(), httptest.NewRecorder(), tok); err != nil || !ok || s.UserKey != "key" {
+		t.Errorf("want valid session, got %v/%v/%v", s, ok, err)
+	}
+
+	// tokens encrypted with the symmetric key are no longer accepted.
+	em := NewManager(mapStore(), Stateless(key, time.Minute))
+	rec = httptest.NewRecorder()
+	em.Init(rec, httptest.NewRequest("GET", "/", nil), "key")
+
+	if _, ok, _ := m.loadSession(context.Background(), httptest.NewRecorder(), rec.Result().Cookies()[0].Value); ok {
+		t.Error("want invalid session, got valid")
+	}
+}
+
+func TestStatelessPayload(t *testing.T) {
+	signer := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	m := NewManager(mapStore(), Stateless(make([]byte, 32), time.Minute), SignStateless(signer))
+
+	exp := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	val, err := m.cookieValue(Session{ID: "id", UserKey: "key", ExpiresAt: exp, Meta: map[string]string{"k": "v"}})
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	msg, _, err := token.Verify(signer.Public().(ed25519.PublicKey), val, []byte(statelessAssertion))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	var p map[string]interface{}
+	if err = json.Unmarshal(msg, &p); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if p["id"] != "id" || p["user_key"] != "key" || p["expires_at"] != "2030-01-01T00:00:00Z" || p["checked_at"] == nil {
+		t.Errorf("want documented fields, got %v", p)
+	}
+
+	if meta, _ := p["meta"].(map[string]interface{}); meta["k"] != "v" {
+		t.Errorf("want %q, got %v", "v", p["meta"])
+	}
+}
+
+func TestStatelessClockSkew(t *testing.T) {
+	m := NewManager(mapStore(), Stateless(make([]byte, 32), time.Minute), ClockSkew(time.Minute))
+
+	cc := map[string]struct {
+		ExpiresIn time.Duration
+		OK        bool
+	}{
+		"Not expired":         {ExpiresIn: time.Minute, OK: true},
+		"Expired within skew": {ExpiresIn: -time.Second * 30, OK: true},
+		"Expired beyond skew": {ExpiresIn: -time.Minute * 2},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := m.cookieValue(Session{ID: "id", ExpiresAt: time.Now().Add(c.ExpiresIn)})
+			if err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if _, ok, err := m.loadSession(context.Background(), httptest.NewRecorder(), val); err != nil || ok != c.OK {
+				t.Errorf("want %v/nil, got %v/%v", c.OK, ok, err)
+			}
+		})
+	}
+}
+
+func TestStatelessInvalidKey(t *testing.T) {
+	m := NewManager(mapStore(), Stateless([]byte("short"), 0))
+	if err := m.Init(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "key"); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
@@ -0,0 +1,65 @@
+package sessionup
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+)
+
+const (
+	statCreated          = "sessions_created"
+	statAuthSuccesses    = "auth_successes"
+	statAuthFailures     = "auth_failures"
+	statStoreErrors      = "store_errors"
+	statRejectCacheHits  = "reject_cache_hits"
+	statCoalescedFetches = "coalesced_fetches"
+)
+
+// statsMu guards the registration of expvar maps.
+var statsMu sync.Mutex
+
+// Expvar publishes the manager's counters (sessions created, auth
+// successes and failures, store errors) as an expvar map under the
+// provided name, so that they can be inspected via /debug/vars.
+// Managers (and NegotiatedReject functions, see RejectConfig) that use
+// the same name share the same counters.
+// By default it is not set and counters are not collected.
+func Expvar(name string) Option {
+	return func(m *Manager) {
+		m.stats = publishStats(name)
+	}
+}
+
+// publishStats retrieves the expvar map published under the provided
+// name or publishes a new one. Nil is returned for empty names.
+func publishStats(name string) *expvar.Map {
+	if name == "" {
+		return nil
+	}
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return v
+	}
+
+	return expvar.NewMap(name)
+}
+
+// stat increments the counter of the provided name.
+func (m *Manager) stat(name string) {
+	if m.stats != nil {
+		m.stats.Add(name, 1)
+	}
+}
+
+// storeErr counts the non-nil error returned by the store and returns
+// it unchanged.
+func (m *Manager) storeErr(err error) error {
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrDuplicateID) {
+		m.stat(statStoreErrors)
+	}
+
+	return err
+}
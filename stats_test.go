@@ -0,0 +1,146 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// statsSeq numbers the expvar maps published by uniqueStats.
+var statsSeq uint64
+
+// uniqueStats produces an expvar name that was not published yet, so
+// that counters start from zero even when tests are run repeatedly in
+// the same process (e.g. with -count).
+func uniqueStats(name string) string {
+	return name + "_" + strconv.FormatUint(atomic.AddUint64(&statsSeq, 1), 10)
+}
+
+func TestExpvar(t *testing.T) {
+	m := Manager{}
+	Expvar("sessionup_test_option")(&m)
+	if m.stats == nil {
+		t.Fatal("want non-nil, got nil")
+	}
+
+	m1 := Manager{}
+	Expvar("sessionup_test_option")(&m1)
+	if m1.stats != m.stats {
+		t.Error("want shared map, got a new one")
+	}
+
+	if publishStats("") != nil {
+		t.Error("want nil, got non-nil")
+	}
+}
+
+func TestStats(t *testing.T) {
+	fail := false
+	s := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			if fail {
+				return Session{}, false, errors.New("error")
+			}
+			return Session{ID: id}, id == "id", nil
+		},
+	}
+
+	m := NewManager(s, Expvar(uniqueStats("sessionup_test_stats")), WithIP(false), WithAgent(false))
+	if err := m.Init(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	auth := func(id string) {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.AddCookie(&http.Cookie{Name: defaultName, Value: id})
+		m.Auth(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	auth("id")
+	auth("id")
+	auth("id1")
+	fail = true
+	auth("id")
+
+	exp := map[string]string{
+		statCreated:       "1",
+		statAuthSuccesses: "2",
+		statAuthFailures:  "2",
+		statStoreErrors:   "1",
+	}
+
+	for k, v := range exp {
+		if res := m.stats.Get(k); res == nil || res.String() != v {
+			t.Errorf("%s: want %s, got %v", k, v, res)
+		}
+	}
+}
+
+func TestRevocationStats(t *testing.T) {
+	errTest := errors.New("error")
+	s := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return errTest
+		},
+		FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+			return Session{}, false, errTest
+		},
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return nil, errTest
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return errTest
+		},
+	}
+
+	m := NewManager(s, Expvar(uniqueStats("sessionup_test_revocation_stats")))
+	ctx := NewContext(context.Background(), Session{ID: "id", UserKey: "key"})
+
+	if err := m.RevokeByIDExt(ctx, "other"); err != errTest {
+		t.Errorf("want %v, got %v", errTest, err)
+	}
+
+	if err := m.RevokeOther(ctx); err != errTest {
+		t.Errorf("want %v, got %v", errTest, err)
+	}
+
+	if _, err := m.PlantCanary(ctx, "key"); err != errTest {
+		t.Errorf("want %v, got %v", errTest, err)
+	}
+
+	if err := m.RevokeByDevice(ctx, "key", DeviceMobile); err != errTest {
+		t.Errorf("want %v, got %v", errTest, err)
+	}
+
+	if res := m.stats.Get(statStoreErrors); res == nil || res.String() != "4" {
+		t.Errorf("want %s, got %v", "4", res)
+	}
+}
+
+func TestRejectCacheStats(t *testing.T) {
+	name := uniqueStats("sessionup_test_reject")
+	rej := NegotiatedReject(RejectConfig{
+		Template:  template.Must(template.New("").Parse(`<p>{{ .Error }}</p>`)),
+		CacheSize: 1,
+		Expvar:    name,
+	})
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("Accept", "text/html")
+		rej(ErrUnauthorized).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if res := expvar.Get(name).(*expvar.Map).Get(statRejectCacheHits); res == nil || res.String() != "2" {
+		t.Errorf("want %s, got %v", "2", res)
+	}
+}
@@ -3,17 +3,43 @@ package sessionup
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
 	// ErrDuplicateID should be returned by Store implementations upon
 	// ID collision.
 	ErrDuplicateID = errors.New("duplicate ID")
+
+	// ErrNotFound may be returned by Store implementations whose
+	// underlying data store reports missing records on deletion.
+	// Manager treats it the same way as a successful deletion.
+	ErrNotFound = errors.New("session not found")
+
+	// ErrConflict is returned when the session was modified
+	// concurrently and the update was discarded.
+	ErrConflict = errors.New("session was modified concurrently")
+
+	// ErrNotSupported is returned when the store does not implement
+	// the optional capability required by the operation.
+	ErrNotSupported = errors.New("operation not supported by the store")
 )
 
 // Store provides an easy access to the underlying data store, without
 // exposing any of its internal logic, but providing all the mandatory
 // methods accordingly.
+// All methods must be safe for concurrent use. Deletions must be
+// idempotent, i.e. deleting an already deleted session must not
+// produce an error, and a session created concurrently with its user
+// key's deletion must end up either fully present (retrievable by both
+// its ID and user key) or fully absent.
+// Sessions passed to Create always satisfy ValidateSession (apart from
+// the prefix added by KeyPrefix), so implementations may rely on its
+// length and charset rules when designing their schemas. Errors may be
+// wrapped (e.g. with StoreError), as long as errors.Is still matches
+// the errors defined in this package.
+// The conformance of an implementation can be verified with the
+// storetest package.
 type Store interface {
 	// Create should insert the new provided session into the store and
 	// ensure that it is deleted when expiration time due.
@@ -36,14 +62,104 @@ type Store interface {
 	// DeleteByID should delete the session from the store by the
 	// provided ID.
 	// If session is not found, this function should be no-op and
-	// return nil.
+	// return nil (or ErrNotFound).
 	// Error should be returned on system errors only.
 	DeleteByID(ctx context.Context, id string) error
 
 	// DeleteByUserKey should delete all sessions associated with the
 	// provided user key, except those whose IDs are provided as the
-	// last argument.
-	// If none are found, this function should be no-op and return nil.
+	// last argument. The provided IDs slice must not be modified.
+	// If none are found, this function should be no-op and return nil
+	// (or ErrNotFound).
 	// Error should be returned on system errors only.
 	DeleteByUserKey(ctx context.Context, key string, expID ...string) error
 }
+
+// UpdateStore is an optional Store capability used to modify sessions
+// that already exist in the store.
+type UpdateStore interface {
+	// Update should replace the session, that has the same ID as the
+	// provided one, with the provided session.
+	// ErrNotFound should be returned if the session is not found.
+	// Error should be returned on system errors as well.
+	Update(ctx context.Context, s Session) error
+}
+
+// CASStore is an optional Store capability used to detect concurrent
+// modifications of sessions (optimistic concurrency control).
+type CASStore interface {
+	// CompareAndSwap should replace the session, that has the same ID
+	// as the provided one, with the provided session only if the
+	// version of the stored session is equal to the old parameter.
+	// The first returned value indicates whether the session was
+	// replaced or not (true == replaced).
+	// ErrNotFound should be returned if the session is not found.
+	// Error should be returned on system errors as well.
+	CompareAndSwap(ctx context.Context, old uint64, s Session) (bool, error)
+}
+
+// ignoreNotFound converts ErrNotFound into nil, so that deletions
+// remain idempotent regardless of the store's behaviour.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// BlobStore is an optional Store capability used to keep large
+// session metadata values as separate records, referenced from
+// the main session record.
+type BlobStore interface {
+	// CreateBlob should insert the provided data into the store under
+	// the provided reference and ensure that it is deleted when
+	// expiration time is due.
+	// Error should be returned on reference collision or other system
+	// errors.
+	CreateBlob(ctx context.Context, ref string, data []byte, exp time.Time) error
+
+	// FetchBlob should retrieve the data from the store by the
+	// provided reference.
+	// The second returned value indicates whether the data was found
+	// or not (true == found), error should be nil if data is not found.
+	// Error should be returned on system errors only.
+	FetchBlob(ctx context.Context, ref string) ([]byte, bool, error)
+
+	// DeleteBlob should delete the data from the store by the
+	// provided reference.
+	// If data is not found, this function should be no-op and
+	// return nil.
+	// Error should be returned on system errors only.
+	DeleteBlob(ctx context.Context, ref string) error
+
+	// ExtendBlob should change the expiration time of the data
+	// stored under the provided reference to the provided time.
+	// If data is not found, this function should be no-op and
+	// return nil (or ErrNotFound).
+	// Error should be returned on system errors only.
+	ExtendBlob(ctx context.Context, ref string, exp time.Time) error
+}
+
+// ChangeCounterStore is an optional Store capability used to cheaply
+// detect whether the sessions of a user have changed or not (e.g. to
+// answer conditional session listing requests without fetching the
+// sessions).
+type ChangeCounterStore interface {
+	// ChangeCounter should return a value that changes whenever the
+	// set of sessions, returned by FetchByUserKey for the provided
+	// user key, changes (including session expiration).
+	// Error should be returned on system errors only.
+	ChangeCounter(ctx context.Context, key string) (uint64, error)
+}
+
+// OrgStore is an optional Store capability used to index sessions by
+// their organization keys (see OrgSeparator).
+type OrgStore interface {
+	// DeleteByOrgKey should delete all sessions, that have the provided
+	// organization key, from the store.
+	// If no sessions are found, this function should be no-op and
+	// return nil.
+	// Error should be returned on system errors only.
+	DeleteByOrgKey(ctx context.Context, org string) error
+}
@@ -0,0 +1,140 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/swithek/sessionup"
+)
+
+// RunStoreBenchmarks runs the benchmark suite against the stores
+// produced by the provided factory. It measures the latency and
+// allocations of the most common store operations, so that backends
+// can be compared and regressions caught.
+func RunStoreBenchmarks(b *testing.B, f Factory) {
+	b.Run("Create", func(b *testing.B) { benchCreate(b, f()) })
+	b.Run("CreateParallel", func(b *testing.B) { benchCreateParallel(b, f()) })
+	b.Run("FetchByID", func(b *testing.B) { benchFetchByID(b, f()) })
+	b.Run("FetchByIDParallel", func(b *testing.B) { benchFetchByIDParallel(b, f()) })
+	b.Run("FetchByUserKey", func(b *testing.B) { benchFetchByUserKey(b, f()) })
+
+	for _, n := range []int{10, 100, 1000} {
+		n := n
+		b.Run(fmt.Sprintf("DeleteByUserKey/%d", n), func(b *testing.B) {
+			benchDeleteByUserKey(b, f(), n)
+		})
+	}
+}
+
+func benchCreate(b *testing.B, s sessionup.Store) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := s.Create(ctx, NewSession(strconv.Itoa(i), "key")); err != nil {
+			b.Fatalf("want nil, got %v", err)
+		}
+	}
+}
+
+func benchCreateParallel(b *testing.B, s sessionup.Store) {
+	ctx := context.Background()
+	var n int64
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&n, 1)
+			id := strconv.FormatInt(i, 10)
+			if err := s.Create(ctx, NewSession(id, "key"+strconv.FormatInt(i%100, 10))); err != nil {
+				b.Errorf("want nil, got %v", err)
+				return
+			}
+		}
+	})
+}
+
+func benchFetchByID(b *testing.B, s sessionup.Store) {
+	ctx := context.Background()
+	mustSeed(b, s, 1000, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, ok, err := s.FetchByID(ctx, strconv.Itoa(i%1000)); err != nil || !ok {
+			b.Fatalf("want nil/true, got %v/%t", err, ok)
+		}
+	}
+}
+
+func benchFetchByIDParallel(b *testing.B, s sessionup.Store) {
+	ctx := context.Background()
+	mustSeed(b, s, 1000, 10)
+	var n int64
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&n, 1)
+			if _, ok, err := s.FetchByID(ctx, strconv.FormatInt(i%1000, 10)); err != nil || !ok {
+				b.Errorf("want nil/true, got %v/%t", err, ok)
+				return
+			}
+		}
+	})
+}
+
+func benchFetchByUserKey(b *testing.B, s sessionup.Store) {
+	ctx := context.Background()
+	mustSeed(b, s, 1000, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ss, err := s.FetchByUserKey(ctx, "key"+strconv.Itoa(i%10))
+		if err != nil || len(ss) != 100 {
+			b.Fatalf("want nil/%d, got %v/%d", 100, err, len(ss))
+		}
+	}
+}
+
+func benchDeleteByUserKey(b *testing.B, s sessionup.Store, n int) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		key := "key" + strconv.Itoa(i)
+		for j := 0; j < n; j++ {
+			if err := s.Create(ctx, NewSession(key+"-"+strconv.Itoa(j), key)); err != nil {
+				b.Fatalf("want nil, got %v", err)
+			}
+		}
+		b.StartTimer()
+
+		if err := s.DeleteByUserKey(ctx, key); err != nil {
+			b.Fatalf("want nil, got %v", err)
+		}
+	}
+}
+
+// mustSeed inserts n sessions, distributed evenly across the provided
+// number of user keys, and stops the benchmark on failure.
+// Session IDs are their sequence numbers.
+func mustSeed(b *testing.B, s sessionup.Store, n, users int) {
+	b.Helper()
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		ses := NewSession(strconv.Itoa(i), "key"+strconv.Itoa(i%users))
+		if err := s.Create(ctx, ses); err != nil {
+			b.Fatalf("want nil, got %v", err)
+		}
+	}
+}
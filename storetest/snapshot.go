@@ -0,0 +1,76 @@
+package storetest
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/swithek/sessionup"
+)
+
+// Snapshot captures all sessions of the store in the NDJSON format (see
+// sessionup.WriteNDJSON), so that fixtures of any store can be put back
+// with Restore. Blobs are not captured.
+// The store must implement sessionup.IterStore interface, otherwise
+// sessionup.ErrNotSupported is returned.
+func Snapshot(ctx context.Context, s sessionup.Store) ([]byte, error) {
+	ss, err := all(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = sessionup.WriteNDJSON(&buf, ss); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Restore deletes all sessions of the store and creates the ones
+// captured by Snapshot.
+// The store must implement sessionup.IterStore interface, otherwise
+// sessionup.ErrNotSupported is returned.
+func Restore(ctx context.Context, s sessionup.Store, snap []byte) error {
+	ss, err := sessionup.ReadNDJSON(bytes.NewReader(snap))
+	if err != nil {
+		return err
+	}
+
+	old, err := all(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range old {
+		if err = s.DeleteByID(ctx, o.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, ns := range ss {
+		if err = s.Create(ctx, ns); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// all retrieves all sessions of the store.
+func all(ctx context.Context, s sessionup.Store) ([]sessionup.Session, error) {
+	is, ok := s.(sessionup.IterStore)
+	if !ok {
+		return nil, sessionup.ErrNotSupported
+	}
+
+	var ss []sessionup.Session
+	err := is.Iterate(ctx, func(s sessionup.Session) bool {
+		ss = append(ss, s)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
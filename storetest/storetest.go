@@ -0,0 +1,552 @@
+// Package storetest provides a conformance test and benchmark suite for
+// sessionup.Store implementations.
+// Store authors should call RunStoreTests from their own tests to
+// verify that the implementation behaves the way the Manager expects,
+// and RunStoreBenchmarks from their benchmarks to measure its performance.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swithek/sessionup"
+)
+
+// Factory should return a fresh, empty store instance on every call.
+type Factory func() sessionup.Store
+
+// RunStoreTests runs the full conformance test suite against the
+// stores produced by the provided factory.
+func RunStoreTests(t *testing.T, f Factory) {
+	t.Run("Create", func(t *testing.T) { testCreate(t, f()) })
+	t.Run("FieldLimits", func(t *testing.T) { testFieldLimits(t, f()) })
+	t.Run("FetchByID", func(t *testing.T) { testFetchByID(t, f()) })
+	t.Run("FetchByUserKey", func(t *testing.T) { testFetchByUserKey(t, f()) })
+	t.Run("DeleteByID", func(t *testing.T) { testDeleteByID(t, f()) })
+	t.Run("DeleteByUserKey", func(t *testing.T) { testDeleteByUserKey(t, f()) })
+	t.Run("ConcurrentDeleteByID", func(t *testing.T) { testConcurrentDeleteByID(t, f()) })
+	t.Run("DeleteByUserKeyRacingCreate", func(t *testing.T) { testDeleteByUserKeyRacingCreate(t, f()) })
+	t.Run("DoubleRevoke", func(t *testing.T) { testDoubleRevoke(t, f()) })
+	t.Run("RevokeRacingAuth", func(t *testing.T) { testRevokeRacingAuth(t, f()) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, f()) })
+	t.Run("CompareAndSwap", func(t *testing.T) { testCompareAndSwap(t, f()) })
+	t.Run("ChangeCounter", func(t *testing.T) { testChangeCounter(t, f()) })
+	t.Run("DeleteByOrgKey", func(t *testing.T) { testDeleteByOrgKey(t, f()) })
+}
+
+// NewSession creates a valid session, that expires in an hour, with
+// the provided ID and user key.
+func NewSession(id, key string) sessionup.Session {
+	return sessionup.Session{
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		ID:        id,
+		UserKey:   key,
+	}
+}
+
+func testCreate(t *testing.T, s sessionup.Store) {
+	ctx := context.Background()
+	if err := s.Create(ctx, NewSession("id", "key")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err := s.Create(ctx, NewSession("id", "key1")); !errors.Is(err, sessionup.ErrDuplicateID) {
+		t.Errorf("want %v, got %v", sessionup.ErrDuplicateID, err)
+	}
+}
+
+func testFieldLimits(t *testing.T, s sessionup.Store) {
+	ctx := context.Background()
+	exp := NewSession(strings.Repeat("!", sessionup.MaxIDLength-len(sessionup.IDCharset))+sessionup.IDCharset,
+		strings.Repeat("ü", sessionup.MaxUserKeyLength/2))
+	exp.Meta = map[string]string{strings.Repeat("k", sessionup.MaxMetaKeyLength): "1"}
+
+	if err := sessionup.ValidateSession(exp); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	mustCreate(t, s, exp)
+
+	res, ok, err := s.FetchByID(ctx, exp.ID)
+	if err != nil || !ok {
+		t.Fatalf("want nil/true, got %v/%t", err, ok)
+	}
+
+	if res.ID != exp.ID || res.UserKey != exp.UserKey || !reflect.DeepEqual(exp.Meta, res.Meta) {
+		t.Errorf("want %v, got %v", exp, res)
+	}
+
+	ss, err := s.FetchByUserKey(ctx, exp.UserKey)
+	if err != nil || len(ss) != 1 {
+		t.Fatalf("want nil/1, got %v/%d", err, len(ss))
+	}
+
+	if ss, err = s.FetchByUserKey(ctx, sessionup.UserKeyIndexKey(exp.UserKey)); err != nil || len(ss) != 0 {
+		t.Errorf("want nil/0, got %v/%d", err, len(ss))
+	}
+}
+
+func testFetchByID(t *testing.T, s sessionup.Store) {
+	ctx := context.Background()
+	exp := NewSession("id", "key")
+	exp.Meta = map[string]string{"test": "1"}
+	mustCreate(t, s, exp)
+
+	expired := NewSession("id1", "key")
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	mustCreate(t, s, expired)
+
+	res, ok, err := s.FetchByID(ctx, "id")
+	if err != nil || !ok {
+		t.Fatalf("want nil/true, got %v/%t", err, ok)
+	}
+
+	if res.ID != exp.ID || res.UserKey != exp.UserKey || res.Meta["test"] != "1" {
+		t.Errorf("want %v, got %v", exp, res)
+	}
+
+	_, ok, err = s.FetchByID(ctx, "id1")
+	if err != nil || ok {
+		t.Errorf("expired session: want nil/false, got %v/%t", err, ok)
+	}
+
+	_, ok, err = s.FetchByID(ctx, "id2")
+	if err != nil || ok {
+		t.Errorf("missing session: want nil/false, got %v/%t", err, ok)
+	}
+}
+
+func testFetchByUserKey(t *testing.T, s sessionup.Store) {
+	ctx := context.Background()
+	mustCreate(t, s, NewSession("id1", "key"))
+	mustCreate(t, s, NewSession("id2", "key"))
+	mustCreate(t, s, NewSession("id3", "key1"))
+
+	expired := NewSession("id4", "key")
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	mustCreate(t, s, expired)
+
+	ss, err := s.FetchByUserKey(ctx, "key")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(ss) != 2 {
+		t.Errorf("want %d, got %d", 2, len(ss))
+	}
+
+	ss, err = s.FetchByUserKey(ctx, "key2")
+	if err != nil || ss != nil {
+		t.Errorf("want nil/nil, got %v/%v", err, ss)
+	}
+}
+
+func testDeleteByID(t *testing.T, s sessionup.Store) {
+	ctx := context.Background()
+	mustCreate(t, s, NewSession("id1", "key"))
+	mustCreate(t, s, NewSession("id2", "key"))
+
+	for i := 0; i < 2; i++ {
+		if err := s.DeleteByID(ctx, "id1"); err != nil {
+			t.Fatalf("attempt %d: want nil, got %v", i+1, err)
+		}
+	}
+
+	if err := s.DeleteByID(ctx, "id3"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, _ := s.FetchByID(ctx, "id1"); ok {
+		t.Errorf("want %t, got %t", false, ok)
+	}
+
+	ss, _ := s.FetchByUserKey(ctx, "key")
+	if len(ss) != 1 || ss[0].ID != "id2" {
+		t.Errorf("want only %q, got %v", "id2", ss)
+	}
+}
+
+func testDeleteByUserKey(t *testing.T, s sessionup.Store) {
+	ctx := context.Background()
+	mustCreate(t, s, NewSession("id1", "key"))
+	mustCreate(t, s, NewSession("id2", "key"))
+	mustCreate(t, s, NewSession("id3", "key"))
+	mustCreate(t, s, NewSession("id4", "key1"))
+
+	exp := []string{"id2", "id3"}
+	if err := s.DeleteByUserKey(ctx, "key", exp...); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if exp[0] != "id2" || exp[1] != "id3" {
+		t.Errorf("excluded IDs must not be modified, got %v", exp)
+	}
+
+	ss, _ := s.FetchByUserKey(ctx, "key")
+	if len(ss) != 2 {
+		t.Errorf("want %d, got %d", 2, len(ss))
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.DeleteByUserKey(ctx, "key"); err != nil {
+			t.Fatalf("attempt %d: want nil, got %v", i+1, err)
+		}
+	}
+
+	if ss, _ = s.FetchByUserKey(ctx, "key"); ss != nil {
+		t.Errorf("want nil, got %v", ss)
+	}
+
+	if _, ok, _ := s.FetchByID(ctx, "id4"); !ok {
+		t.Errorf("want %t, got %t", true, ok)
+	}
+}
+
+func testConcurrentDeleteByID(t *testing.T, s sessionup.Store) {
+	ctx := context.Background()
+	mustCreate(t, s, NewSession("id", "key"))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.DeleteByID(ctx, "id")
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("want nil, got %v", err)
+		}
+	}
+}
+
+func testDeleteByUserKeyRacingCreate(t *testing.T, s sessionup.Store) {
+	ctx := context.Background()
+	const n = 50
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if err := s.Create(ctx, NewSession(fmt.Sprintf("id%d", i), "key")); err != nil {
+				t.Errorf("want nil, got %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if err := s.DeleteByUserKey(ctx, "key"); err != nil {
+				t.Errorf("want nil, got %v", err)
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	// every session must be either fully present (retrievable by
+	// both ID and user key) or fully absent.
+	ss, err := s.FetchByUserKey(ctx, "key")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	listed := make(map[string]bool)
+	for _, ses := range ss {
+		listed[ses.ID] = true
+	}
+
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("id%d", i)
+		_, ok, err := s.FetchByID(ctx, id)
+		if err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+
+		if ok != listed[id] {
+			t.Errorf("session %q: fetched %t, listed %t", id, ok, listed[id])
+		}
+	}
+}
+
+func testDoubleRevoke(t *testing.T, s sessionup.Store) {
+	m := sessionup.NewManager(s, sessionup.WithIP(false), sessionup.WithAgent(false))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if err := m.Init(rec, req, "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	ss, err := s.FetchByUserKey(context.Background(), "key")
+	if err != nil || len(ss) != 1 {
+		t.Fatalf("want nil/1, got %v/%d", err, len(ss))
+	}
+
+	ctx := sessionup.NewContext(context.Background(), ss[0])
+	for i := 0; i < 2; i++ {
+		if err = m.Revoke(ctx, httptest.NewRecorder()); err != nil {
+			t.Errorf("attempt %d: want nil, got %v", i+1, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = m.RevokeAll(ctx, httptest.NewRecorder()); err != nil {
+			t.Errorf("attempt %d: want nil, got %v", i+1, err)
+		}
+	}
+}
+
+func testRevokeRacingAuth(t *testing.T, s sessionup.Store) {
+	m := sessionup.NewManager(s, sessionup.WithIP(false), sessionup.WithAgent(false))
+	rec := httptest.NewRecorder()
+	if err := m.Init(rec, httptest.NewRequest("GET", "http://example.com/", nil), "key"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	c := rec.Result().Cookies()[0]
+	ss, _ := s.FetchByUserKey(context.Background(), "key")
+	if len(ss) != 1 {
+		t.Fatalf("want %d, got %d", 1, len(ss))
+	}
+
+	auth := func() int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.AddCookie(c)
+		m.Auth(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code := auth(); code != http.StatusNoContent && code != http.StatusUnauthorized {
+				t.Errorf("want %d or %d, got %d", http.StatusNoContent, http.StatusUnauthorized, code)
+			}
+		}()
+	}
+
+	ctx := sessionup.NewContext(context.Background(), ss[0])
+	if err := m.Revoke(ctx, httptest.NewRecorder()); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	wg.Wait()
+
+	// once Revoke returns, the session must not be accepted anymore.
+	if code := auth(); code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, code)
+	}
+}
+
+func testUpdate(t *testing.T, s sessionup.Store) {
+	us, ok := s.(sessionup.UpdateStore)
+	if !ok {
+		t.Skip("store does not implement sessionup.UpdateStore")
+	}
+
+	ctx := context.Background()
+	if err := us.Update(ctx, NewSession("id", "key")); !errors.Is(err, sessionup.ErrNotFound) {
+		t.Errorf("want %v, got %v", sessionup.ErrNotFound, err)
+	}
+
+	exp := NewSession("id", "key")
+	mustCreate(t, s, exp)
+
+	exp.Meta = map[string]string{"test": "1"}
+	exp.Version = 1
+	if err := us.Update(ctx, exp); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	res, _, _ := s.FetchByID(ctx, "id")
+	if res.Meta["test"] != "1" || res.Version != 1 {
+		t.Errorf("want %v, got %v", exp, res)
+	}
+}
+
+func testCompareAndSwap(t *testing.T, s sessionup.Store) {
+	cs, ok := s.(sessionup.CASStore)
+	if !ok {
+		t.Skip("store does not implement sessionup.CASStore")
+	}
+
+	ctx := context.Background()
+	if _, err := cs.CompareAndSwap(ctx, 0, NewSession("id", "key")); !errors.Is(err, sessionup.ErrNotFound) {
+		t.Errorf("want %v, got %v", sessionup.ErrNotFound, err)
+	}
+
+	mustCreate(t, s, NewSession("id", "key"))
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		swapped int
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ses := NewSession("id", "key")
+			ses.Version = 1
+			ok, err := cs.CompareAndSwap(ctx, 0, ses)
+			if err != nil {
+				t.Errorf("want nil, got %v", err)
+			}
+
+			if ok {
+				mu.Lock()
+				swapped++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	if swapped != 1 {
+		t.Errorf("want exactly %d successful swap, got %d", 1, swapped)
+	}
+}
+
+func testChangeCounter(t *testing.T, s sessionup.Store) {
+	cs, ok := s.(sessionup.ChangeCounterStore)
+	if !ok {
+		t.Skip("store does not implement sessionup.ChangeCounterStore")
+	}
+
+	ctx := context.Background()
+	counter := func() uint64 {
+		t.Helper()
+		n, err := cs.ChangeCounter(ctx, "key")
+		if err != nil {
+			t.Fatalf("want nil, got %v", err)
+		}
+		return n
+	}
+
+	n := counter()
+	if counter() != n {
+		t.Error("want unchanged counter without modifications, got changed")
+	}
+
+	steps := []struct {
+		Name    string
+		Apply   func()
+		Changed bool
+	}{
+		{
+			Name: "Create",
+			Apply: func() {
+				mustCreate(t, s, NewSession("id1", "key"))
+				mustCreate(t, s, NewSession("id2", "key"))
+			},
+			Changed: true,
+		},
+		{
+			Name: "Expiration",
+			Apply: func() {
+				ses := NewSession("id3", "key")
+				ses.ExpiresAt = time.Now().Add(time.Millisecond * 50)
+				mustCreate(t, s, ses)
+				n = counter()
+				time.Sleep(time.Millisecond * 100)
+			},
+			Changed: true,
+		},
+		{
+			Name:    "DeleteByID",
+			Apply:   func() { s.DeleteByID(ctx, "id1") },
+			Changed: true,
+		},
+		{
+			Name:  "Unrelated user key",
+			Apply: func() { mustCreate(t, s, NewSession("id4", "key1")) },
+		},
+		{
+			Name:    "DeleteByUserKey",
+			Apply:   func() { s.DeleteByUserKey(ctx, "key") },
+			Changed: true,
+		},
+	}
+
+	for _, st := range steps {
+		st.Apply()
+		m := counter()
+		if changed := m != n; changed != st.Changed {
+			t.Errorf("%s: want changed %t, got %t", st.Name, st.Changed, changed)
+		}
+		n = m
+	}
+}
+
+func testDeleteByOrgKey(t *testing.T, s sessionup.Store) {
+	os, ok := s.(sessionup.OrgStore)
+	if !ok {
+		t.Skip("store does not implement sessionup.OrgStore")
+	}
+
+	ctx := context.Background()
+	member := func(id, org, user string) sessionup.Session {
+		ses := NewSession(id, sessionup.CompositeKey(org, user))
+		ses.OrgKey = org
+		return ses
+	}
+
+	mustCreate(t, s, member("id1", "org", "user1"))
+	mustCreate(t, s, member("id2", "org", "user1"))
+	mustCreate(t, s, member("id3", "org", "user2"))
+	mustCreate(t, s, member("id4", "org1", "user3"))
+	mustCreate(t, s, NewSession("id5", "user4"))
+
+	for i := 0; i < 2; i++ {
+		if err := os.DeleteByOrgKey(ctx, "org"); err != nil {
+			t.Fatalf("attempt %d: want nil, got %v", i+1, err)
+		}
+	}
+
+	for _, id := range []string{"id1", "id2", "id3"} {
+		if _, ok, _ := s.FetchByID(ctx, id); ok {
+			t.Errorf("session %q: want %t, got %t", id, false, ok)
+		}
+	}
+
+	if ss, _ := s.FetchByUserKey(ctx, sessionup.CompositeKey("org", "user1")); ss != nil {
+		t.Errorf("want nil, got %v", ss)
+	}
+
+	for _, id := range []string{"id4", "id5"} {
+		if _, ok, _ := s.FetchByID(ctx, id); !ok {
+			t.Errorf("session %q: want %t, got %t", id, true, ok)
+		}
+	}
+}
+
+// mustCreate inserts the session into the store and stops the test
+// on failure.
+func mustCreate(t *testing.T, s sessionup.Store, ses sessionup.Session) {
+	t.Helper()
+	if err := s.Create(context.Background(), ses); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package sessionup
+
+import "errors"
+
+// Errors returned by Manager methods instead of panicking when they are
+// called with nil arguments.
+var (
+	// ErrNilContext is returned when a nil context is provided.
+	ErrNilContext = errors.New("nil context")
+
+	// ErrNilWriter is returned when a nil response writer is provided.
+	ErrNilWriter = errors.New("nil response writer")
+
+	// ErrNilRequest is returned when a nil request (or a request
+	// without URL) is provided.
+	ErrNilRequest = errors.New("nil request")
+)
@@ -0,0 +1,203 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// TestNoPanics calls every exported Manager method with nil contexts,
+// writers and requests, as well as with malformed sessions, and
+// verifies that none of them panics. New methods are covered
+// automatically.
+func TestNoPanics(t *testing.T) {
+	malformed := Session{ID: "id", IP: []byte{1}, Meta: nil}
+	malformed.Agent.OS = "Linux"
+	malformed.Agent.Browser = "Firefox"
+
+	store := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+			return malformed, true, nil
+		},
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return []Session{malformed, {}}, nil
+		},
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}
+
+	cc := map[string]struct {
+		Opts    []Option
+		Agent   string
+		Ctx     context.Context
+		Writer  http.ResponseWriter
+		Request *http.Request
+	}{
+		"Nil values": {},
+		"Nil writer and request": {
+			Ctx: NewContext(context.Background(), malformed),
+		},
+		"Malformed session": {
+			Ctx:     NewContext(context.Background(), malformed),
+			Writer:  httptest.NewRecorder(),
+			Request: httptest.NewRequest("GET", "/", nil),
+		},
+		"Empty session": {
+			Ctx:     NewContext(context.Background(), Session{}),
+			Writer:  httptest.NewRecorder(),
+			Request: httptest.NewRequest("GET", "/", nil).WithContext(NewContext(context.Background(), Session{})),
+		},
+		"Validation with missing User-Agent": {
+			Opts:    []Option{Validate(true)},
+			Ctx:     NewContext(context.Background(), malformed),
+			Writer:  httptest.NewRecorder(),
+			Request: httptest.NewRequest("GET", "/", nil),
+		},
+		"Validation with garbage User-Agent": {
+			Opts:    []Option{Validate(true)},
+			Agent:   "\x00garbage",
+			Ctx:     NewContext(context.Background(), malformed),
+			Writer:  httptest.NewRecorder(),
+			Request: httptest.NewRequest("GET", "/", nil),
+		},
+	}
+
+	ctxType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	writerType := reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
+	handlerType := reflect.TypeOf((*http.Handler)(nil)).Elem()
+	next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			m := NewManager(store, append([]Option{SigningKey(make([]byte, 32)), WithIP(true)}, c.Opts...)...)
+			mv := reflect.ValueOf(m)
+
+			for i := 0; i < mv.NumMethod(); i++ {
+				name := mv.Type().Method(i).Name
+				meth := mv.Method(i)
+				mt := meth.Type()
+
+				args := make([]reflect.Value, mt.NumIn())
+				for j := range args {
+					at := mt.In(j)
+					switch {
+					case at == ctxType && c.Ctx != nil:
+						args[j] = reflect.ValueOf(&c.Ctx).Elem()
+					case at == writerType && c.Writer != nil:
+						args[j] = reflect.ValueOf(&c.Writer).Elem()
+					case at == reflect.TypeOf(c.Request):
+						args[j] = reflect.ValueOf(c.Request)
+					case at == handlerType:
+						args[j] = reflect.ValueOf(next)
+					default:
+						args[j] = reflect.Zero(at)
+					}
+				}
+
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("%s: want no panic, got %v", name, r)
+						}
+					}()
+
+					var out []reflect.Value
+					if mt.IsVariadic() {
+						out = meth.CallSlice(args)
+					} else {
+						out = meth.Call(args)
+					}
+
+					for _, o := range out {
+						serve(o, c.Agent)
+					}
+				}()
+			}
+		})
+	}
+}
+
+// serve activates the handlers and middlewares returned by Manager
+// methods with a request that has the provided User-Agent.
+func serve(v reflect.Value, agent string) {
+	var h http.Handler
+	switch f := v.Interface().(type) {
+	case http.Handler:
+		h = f
+	case func(http.Handler) http.Handler:
+		h = f(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	default:
+		return
+	}
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.AddCookie(&http.Cookie{Name: defaultName, Value: "id"})
+	if agent != "" {
+		req.Header.Set("User-Agent", agent)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestNilArguments(t *testing.T) {
+	m := NewManager(&StoreMock{}, SigningKey(make([]byte, 32)))
+	ctx := NewContext(context.Background(), Session{ID: "id"})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	cc := map[string]struct {
+		Call func() error
+		Err  error
+	}{
+		"Init with nil writer": {
+			Call: func() error { return m.Init(nil, req, "key") },
+			Err:  ErrNilWriter,
+		},
+		"Init with nil request": {
+			Call: func() error { return m.Init(rec, nil, "key") },
+			Err:  ErrNilRequest,
+		},
+		"Revoke with nil context": {
+			Call: func() error { return m.Revoke(nil, rec) },
+			Err:  ErrNilContext,
+		},
+		"RevokeAll with nil writer": {
+			Call: func() error { return m.RevokeAll(ctx, nil) },
+			Err:  ErrNilWriter,
+		},
+		"FetchAll with nil context": {
+			Call: func() error {
+				_, err := m.FetchAll(nil)
+				return err
+			},
+			Err: ErrNilContext,
+		},
+		"VerifySignedURL with nil request": {
+			Call: func() error {
+				_, err := m.VerifySignedURL(nil)
+				return err
+			},
+			Err: ErrNilRequest,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			if err := c.Call(); err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+		})
+	}
+}
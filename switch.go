@@ -0,0 +1,71 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+)
+
+// Switch signs the current user out and the user of the provided key in
+// as a single operation (e.g. for account switchers): a fresh session
+// (with a new ID) is created for the new key first and the session
+// stored in the context is revoked only afterwards, so the client never
+// ends up without a valid cookie or with a stale one. Metadata entries
+// of the current session under the provided keys (e.g. a device label)
+// are copied to the new session.
+// If the current session cannot be revoked, the new session is deleted
+// again and the current one remains in use; errors that occur while
+// deleting the current session's blobs (see BlobThreshold) are returned
+// after the switch is complete.
+// ErrUnauthorized is returned if the context session is not set.
+func (m *Manager) Switch(ctx context.Context, w http.ResponseWriter, r *http.Request, key string, keep ...string) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if w == nil {
+		return ErrNilWriter
+	}
+
+	if r == nil {
+		return ErrNilRequest
+	}
+
+	cs, ok := FromContext(ctx)
+	if !ok {
+		return ErrUnauthorized
+	}
+
+	var meta map[string]string
+	for _, k := range keep {
+		v, ok := cs.Meta[k]
+		if !ok {
+			continue
+		}
+
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+
+		meta[k] = v
+	}
+
+	ns, err := m.create(r.WithContext(ctx), key, meta)
+	if err != nil {
+		return err
+	}
+
+	if err = m.deleteSession(ctx, cs.ID); err != nil {
+		// the new session is not usable without its cookie, so its
+		// deletion errors are ignored.
+		m.store.DeleteByID(ctx, ns.s.ID)
+		m.hints.forget(Revocation{ID: ns.s.ID})
+		m.deleteBlobs(ctx, ns.s)
+		return err
+	}
+
+	m.activate(w, ns)
+
+	// the switch is complete even if the previous session's blobs
+	// cannot be deleted.
+	return m.deleteBlobs(ctx, cs)
+}
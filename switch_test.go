@@ -0,0 +1,107 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSwitch(t *testing.T) {
+	ms := mapStore()
+	m := NewManager(ms)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if err := m.Init(rec, req, "alice", MetaEntry("device", "laptop"), MetaEntry("cart", "1")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	old, _, _ := ms.FetchByID(context.Background(), rec.Result().Cookies()[0].Value)
+
+	if err := m.Switch(context.Background(), httptest.NewRecorder(), req, "bob"); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	ctx := NewContext(context.Background(), old)
+	rec = httptest.NewRecorder()
+	if err := m.Switch(ctx, rec, req, "bob", "device", "missing"); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if _, ok, _ := ms.FetchByID(ctx, old.ID); ok {
+		t.Error("want old session revoked")
+	}
+
+	cc := rec.Result().Cookies()
+	if len(cc) != 1 || cc[0].Value == old.ID {
+		t.Fatalf("want new session cookie, got %v", cc)
+	}
+
+	s, ok, _ := ms.FetchByID(ctx, cc[0].Value)
+	if !ok {
+		t.Fatal("want new session stored")
+	}
+
+	if s.UserKey != "bob" {
+		t.Errorf("want %q, got %q", "bob", s.UserKey)
+	}
+
+	if len(s.Meta) != 1 || s.Meta["device"] != "laptop" {
+		t.Errorf("want only device metadata, got %v", s.Meta)
+	}
+}
+
+func TestSwitchRollback(t *testing.T) {
+	ms := mapStore()
+	del := ms.DeleteByIDFunc
+
+	var deleted []string
+	ms.DeleteByIDFunc = func(ctx context.Context, id string) error {
+		deleted = append(deleted, id)
+		if id == "old" {
+			return errors.New("error")
+		}
+
+		return del(ctx, id)
+	}
+
+	m := NewManager(ms)
+	ctx := NewContext(context.Background(), Session{ID: "old", UserKey: "alice"})
+	rec := httptest.NewRecorder()
+	err := m.Switch(ctx, rec, httptest.NewRequest("GET", "http://example.com/", nil), "bob")
+	if err == nil || err.Error() != "error" {
+		t.Errorf("want %v, got %v", errors.New("error"), err)
+	}
+
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("want %d, got %d", 0, len(rec.Result().Cookies()))
+	}
+
+	if len(deleted) != 2 || deleted[0] != "old" {
+		t.Fatalf("want old and new sessions deleted, got %v", deleted)
+	}
+
+	if len(ms.CreateCalls()) != 1 || ms.CreateCalls()[0].S.ID != deleted[1] {
+		t.Errorf("want new session %q deleted", deleted[1])
+	}
+}
+
+func TestSwitchNil(t *testing.T) {
+	m := NewManager(mapStore())
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	rec := httptest.NewRecorder()
+
+	var ctx context.Context
+	if err := m.Switch(ctx, rec, req, "key"); err != ErrNilContext {
+		t.Errorf("want %v, got %v", ErrNilContext, err)
+	}
+
+	if err := m.Switch(context.Background(), nil, req, "key"); err != ErrNilWriter {
+		t.Errorf("want %v, got %v", ErrNilWriter, err)
+	}
+
+	if err := m.Switch(context.Background(), rec, nil, "key"); err != ErrNilRequest {
+		t.Errorf("want %v, got %v", ErrNilRequest, err)
+	}
+}
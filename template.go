@@ -0,0 +1,37 @@
+package sessionup
+
+import "context"
+
+// TemplateData produces a flattened view of the session stored in the
+// context, suitable for html/template render contexts. The produced
+// map contains "CreatedAt", "ExpiresAt", "Current", "IP", "OS",
+// "Browser", "Device" and "Meta" entries; the session ID, user key,
+// anti-CSRF token and blob references are never included.
+// Function returns nil, if context session is not set.
+func TemplateData(ctx context.Context) map[string]interface{} {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	meta := make(map[string]string, len(s.Meta))
+	for k, v := range s.Meta {
+		meta[k] = v
+	}
+
+	var ip string
+	if len(s.IP) != 0 {
+		ip = s.IP.String()
+	}
+
+	return map[string]interface{}{
+		"CreatedAt": s.CreatedAt,
+		"ExpiresAt": s.ExpiresAt,
+		"Current":   s.Current,
+		"IP":        ip,
+		"OS":        s.Agent.OS,
+		"Browser":   s.Agent.Browser,
+		"Device":    s.Agent.Device,
+		"Meta":      meta,
+	}
+}
@@ -0,0 +1,51 @@
+package sessionup
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net"
+	"testing"
+)
+
+func TestTemplateData(t *testing.T) {
+	if d := TemplateData(context.Background()); d != nil {
+		t.Errorf("want nil, got %v", d)
+	}
+
+	s := Session{
+		Current:   true,
+		ID:        "id",
+		UserKey:   "key",
+		IP:        net.ParseIP("127.0.0.1"),
+		Meta:      map[string]string{"name": "<b>John</b>"},
+		CSRFToken: "token",
+	}
+	s.Agent.OS = "Linux"
+	s.Agent.Browser = "Firefox"
+
+	d := TemplateData(NewContext(context.Background(), s))
+	for _, k := range []string{"ID", "UserKey", "CSRFToken", "Blobs"} {
+		if _, ok := d[k]; ok {
+			t.Errorf("want no %q entry, got %v", k, d[k])
+		}
+	}
+
+	d["Meta"].(map[string]string)["name"] = "changed"
+	if s.Meta["name"] != "<b>John</b>" {
+		t.Error("want session metadata untouched, got modified")
+	}
+
+	d = TemplateData(NewContext(context.Background(), s))
+	tmpl := template.Must(template.New("").Parse(`{{ .OS }}/{{ .Browser }} {{ .IP }} {{ .Current }} {{ .Meta.name }}`))
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	exp := "Linux/Firefox 127.0.0.1 true &lt;b&gt;John&lt;/b&gt;"
+	if buf.String() != exp {
+		t.Errorf("want %q, got %q", exp, buf.String())
+	}
+}
@@ -0,0 +1,151 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// KindTicket marks one-time tickets created with IssueTicket.
+const KindTicket = "ticket"
+
+// kindRedeemed marks tickets that were consumed by RedeemTicket but
+// are not deleted from the store yet.
+const kindRedeemed = "ticket-redeemed"
+
+// ErrInvalidTicket is returned when the ticket is unknown, expired,
+// already redeemed or its session is no longer valid.
+var ErrInvalidTicket = errors.New("invalid or expired ticket")
+
+// IssueTicket produces a one-time, short-lived ticket bound to the
+// session stored in the context, that can be redeemed with
+// RedeemTicket, e.g. by a WebSocket endpoint during the upgrade
+// handshake. This way the session cookie can remain HttpOnly and is
+// never exposed to JavaScript or placed in long-lived socket URLs.
+// The ticket is stored as a separate record (of KindTicket) under the
+// session's user key; its TTL is capped by the session's expiration
+// time. Tickets can only be redeemed if the store implements CASStore
+// interface.
+// ErrUnauthorized is returned if the context session is not set.
+func (m *Manager) IssueTicket(ctx context.Context, ttl time.Duration) (string, error) {
+	if ctx == nil {
+		return "", ErrNilContext
+	}
+
+	p, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrUnauthorized
+	}
+
+	s, err := m.createChild(ctx, p, KindTicket, ttl, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return s.ID, nil
+}
+
+// createChild inserts a new record of the provided kind, bound to the
+// provided session, into the store. Its TTL is capped by the session's
+// expiration time.
+func (m *Manager) createChild(ctx context.Context, p Session, kind string, ttl time.Duration, meta map[string]string) (Session, error) {
+	s := Session{
+		CreatedAt: monotonicNow(),
+		ExpiresAt: time.Now().Add(ttl),
+		ID:        m.genID(),
+		UserKey:   p.UserKey,
+		OrgKey:    p.OrgKey,
+		IP:        p.IP,
+		Agent:     p.Agent,
+		ParentID:  p.ID,
+		Kind:      kind,
+		Meta:      meta,
+	}
+
+	if s.ID == "" {
+		return Session{}, ErrGenID
+	}
+
+	if !p.ExpiresAt.IsZero() && p.ExpiresAt.Before(s.ExpiresAt) {
+		s.ExpiresAt = p.ExpiresAt
+	}
+
+	if err := m.storeErr(m.store.Create(ctx, s)); err != nil {
+		return Session{}, err
+	}
+
+	return s, nil
+}
+
+// RedeemTicket consumes the ticket produced by IssueTicket and returns
+// the session it is bound to. The ticket is consumed atomically with
+// the store's CompareAndSwap (see CASStore) before its session is
+// checked, so it can never be redeemed twice, not even concurrently;
+// the session is retrieved from the store, so revoked sessions
+// invalidate their tickets as well.
+// ErrInvalidTicket is returned if the ticket or its session is not
+// valid. ErrNotSupported is returned if the store does not implement
+// CASStore interface, as tickets could be redeemed more than once
+// otherwise.
+func (m *Manager) RedeemTicket(ctx context.Context, ticket string) (Session, error) {
+	if ctx == nil {
+		return Session{}, ErrNilContext
+	}
+
+	if ticket == "" {
+		return Session{}, ErrInvalidTicket
+	}
+
+	t, ok, err := m.store.FetchByID(ctx, ticket)
+	if err = m.storeErr(err); err != nil {
+		return Session{}, err
+	}
+
+	if !ok || t.Kind != KindTicket {
+		return Session{}, ErrInvalidTicket
+	}
+
+	if err = m.consumeTicket(ctx, t); err != nil {
+		return Session{}, err
+	}
+
+	if t.ExpiresAt.Before(time.Now().Add(-m.Config().ClockSkew)) {
+		return Session{}, ErrInvalidTicket
+	}
+
+	s, ok, err := m.store.FetchByID(ctx, t.ParentID)
+	if err = m.storeErr(err); err != nil {
+		return Session{}, err
+	}
+
+	if !ok || s.Kind != "" || IsExpired(s, time.Now()) {
+		return Session{}, ErrInvalidTicket
+	}
+
+	return s, nil
+}
+
+// consumeTicket marks the ticket as redeemed, only if it was not
+// modified (i.e. redeemed by a concurrent call) since it was
+// retrieved, and deletes it from the store.
+func (m *Manager) consumeTicket(ctx context.Context, t Session) error {
+	cs, ok := m.store.(CASStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	old := t.Version
+	t.Version++
+	t.Kind = kindRedeemed
+
+	swapped, err := cs.CompareAndSwap(ctx, old, t)
+	if errors.Is(err, ErrNotFound) || err == nil && !swapped {
+		return ErrInvalidTicket
+	}
+
+	if err = m.storeErr(err); err != nil {
+		return err
+	}
+
+	return m.storeErr(ignoreNotFound(m.store.DeleteByID(ctx, t.ID)))
+}
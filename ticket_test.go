@@ -0,0 +1,166 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// casMapStore is a mapStore with CASStore capability.
+type casMapStore struct {
+	*StoreMock
+	mu sync.Mutex
+}
+
+func newCASMapStore() *casMapStore {
+	return &casMapStore{StoreMock: mapStore()}
+}
+
+func (c *casMapStore) CompareAndSwap(ctx context.Context, old uint64, s Session) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cur, ok, _ := c.StoreMock.FetchByID(ctx, s.ID)
+	if !ok {
+		return false, ErrNotFound
+	}
+
+	if cur.Version != old {
+		return false, nil
+	}
+
+	return true, c.StoreMock.Create(ctx, s)
+}
+
+func TestTicket(t *testing.T) {
+	ms := newCASMapStore()
+	m := NewManager(ms)
+
+	p := Session{ID: "parent", UserKey: "key", ExpiresAt: time.Now().Add(time.Minute)}
+	ms.Create(context.Background(), p)
+
+	if _, err := m.IssueTicket(context.Background(), time.Second); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	ctx := NewContext(context.Background(), p)
+	ticket, err := m.IssueTicket(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	ts, ok, _ := ms.FetchByID(ctx, ticket)
+	if !ok || ts.Kind != KindTicket || ts.ParentID != p.ID || ts.UserKey != p.UserKey {
+		t.Fatalf("want ticket record, got %v", ts)
+	}
+
+	if !ts.ExpiresAt.Equal(p.ExpiresAt) {
+		t.Errorf("want %v, got %v", p.ExpiresAt, ts.ExpiresAt)
+	}
+
+	s, err := m.RedeemTicket(context.Background(), ticket)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if s.ID != p.ID {
+		t.Errorf("want %q, got %q", p.ID, s.ID)
+	}
+
+	if _, err = m.RedeemTicket(context.Background(), ticket); err != ErrInvalidTicket {
+		t.Errorf("want %v, got %v", ErrInvalidTicket, err)
+	}
+
+	if _, err = m.RedeemTicket(context.Background(), p.ID); err != ErrInvalidTicket {
+		t.Errorf("want %v, got %v", ErrInvalidTicket, err)
+	}
+
+	ticket, _ = m.IssueTicket(ctx, -time.Second)
+	if _, err = m.RedeemTicket(context.Background(), ticket); err != ErrInvalidTicket {
+		t.Errorf("want %v, got %v", ErrInvalidTicket, err)
+	}
+
+	ticket, _ = m.IssueTicket(ctx, time.Second)
+	ms.DeleteByID(ctx, p.ID)
+	if _, err = m.RedeemTicket(context.Background(), ticket); err != ErrInvalidTicket {
+		t.Errorf("want %v, got %v", ErrInvalidTicket, err)
+	}
+}
+
+func TestRedeemTicketErrors(t *testing.T) {
+	var ctx context.Context
+	m := NewManager(mapStore())
+	if _, err := m.RedeemTicket(ctx, "ticket"); err != ErrNilContext {
+		t.Errorf("want %v, got %v", ErrNilContext, err)
+	}
+
+	if _, err := m.RedeemTicket(context.Background(), ""); err != ErrInvalidTicket {
+		t.Errorf("want %v, got %v", ErrInvalidTicket, err)
+	}
+
+	ctx = NewContext(context.Background(), Session{ID: "parent", ExpiresAt: time.Now().Add(time.Minute)})
+	ticket, _ := m.IssueTicket(ctx, time.Minute)
+	if _, err := m.RedeemTicket(ctx, ticket); err != ErrNotSupported {
+		t.Errorf("want %v, got %v", ErrNotSupported, err)
+	}
+
+	ms := newCASMapStore()
+	m = NewManager(ms)
+	ticket, _ = m.IssueTicket(ctx, time.Minute)
+	ms.DeleteByIDFunc = func(_ context.Context, _ string) error {
+		return errors.New("error")
+	}
+
+	if _, err := m.RedeemTicket(ctx, ticket); err == nil || err.Error() != "error" {
+		t.Errorf("want %v, got %v", errors.New("error"), err)
+	}
+
+	if _, err := m.RedeemTicket(ctx, ticket); err != ErrInvalidTicket {
+		t.Errorf("want %v, got %v", ErrInvalidTicket, err)
+	}
+}
+
+func TestRedeemTicketConcurrently(t *testing.T) {
+	ms := newCASMapStore()
+	fetch := ms.FetchByIDFunc
+	ms.FetchByIDFunc = func(ctx context.Context, id string) (Session, bool, error) {
+		s, ok, err := fetch(ctx, id)
+		time.Sleep(10 * time.Millisecond)
+		return s, ok, err
+	}
+
+	m := NewManager(ms)
+	p := Session{ID: "parent", UserKey: "key", ExpiresAt: time.Now().Add(time.Minute)}
+	ms.Create(context.Background(), p)
+
+	ticket, err := m.IssueTicket(NewContext(context.Background(), p), time.Minute)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		redeemed int
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.RedeemTicket(context.Background(), ticket); err == nil {
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if redeemed != 1 {
+		t.Errorf("want %d, got %d", 1, redeemed)
+	}
+}
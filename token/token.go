@@ -0,0 +1,235 @@
+// Package token implements the versioned wire format of tokens issued
+// by sessionup (e.g. signed URLs), so that services written in other
+// languages can validate them.
+//
+// Version 1 tokens have the following form:
+//
+//	sup.v1.local.<payload>[.<footer>]
+//
+// where payload and footer are base64url-encoded (RFC 4648, section 5,
+// without padding). Tokens without a footer omit the last part
+// together with its separator.
+//
+// Payload holds the 12 byte nonce followed by the message encrypted
+// with AES-256-GCM (including the 16 byte authentication tag). The
+// key is 32 bytes long. Footer is not encrypted, but it is
+// authenticated together with the header ("sup.v1.local.") and the
+// implicit assertion (data that is known to both parties, e.g. the
+// URL the token was issued for, and is not included in the token).
+// The additional data of AES-256-GCM is produced by the pre-auth
+// encoding (PAE) of the header, footer and implicit assertion:
+//
+//	PAE(p1, ..., pn) = LE64(n) || LE64(len(p1)) || p1 || ... || LE64(len(pn)) || pn
+//
+// where LE64 is the 64-bit little-endian encoding of an unsigned
+// integer with the most significant bit cleared.
+//
+// Public version 1 tokens have the following form:
+//
+//	sup.v1.public.<payload>[.<footer>]
+//
+// where payload holds the plain text message followed by its 64 byte
+// Ed25519 signature. The signature covers the pre-auth encoding of the
+// header ("sup.v1.public."), message, footer and implicit assertion.
+// Public tokens are not encrypted: anyone can read their messages, but
+// only the holder of the private key can issue them.
+//
+// Test vectors can be found in the testdata directory.
+package token
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	// HeaderV1 is the header of version 1 tokens.
+	HeaderV1 = "sup.v1.local."
+
+	// HeaderPublicV1 is the header of public version 1 tokens.
+	HeaderPublicV1 = "sup.v1.public."
+
+	// KeySize is the size of version 1 keys.
+	KeySize = 32
+
+	nonceSize = 12
+)
+
+var (
+	// ErrInvalid is returned when the token is malformed, its version
+	// or purpose is not supported or it fails authentication.
+	ErrInvalid = errors.New("invalid token")
+
+	// ErrKeySize is returned when the key is not KeySize bytes long
+	// or, for public tokens, is not a valid Ed25519 key.
+	ErrKeySize = errors.New("invalid token key size")
+)
+
+// Encrypt produces a version 1 token that holds the encrypted message
+// and the plain text footer, both authenticated together with the
+// implicit assertion.
+func Encrypt(key, msg, footer, implicit []byte) (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return encrypt(key, nonce, msg, footer, implicit)
+}
+
+// encrypt produces a version 1 token with the provided nonce.
+func encrypt(key, nonce, msg, footer, implicit []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	payload := aead.Seal(append([]byte(nil), nonce...), nonce, msg, pae([]byte(HeaderV1), footer, implicit))
+
+	tok := HeaderV1 + base64.RawURLEncoding.EncodeToString(payload)
+	if len(footer) > 0 {
+		tok += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+
+	return tok, nil
+}
+
+// Decrypt verifies the version 1 token against the implicit assertion
+// and returns its decrypted message and footer.
+func Decrypt(key []byte, tok string, implicit []byte) ([]byte, []byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, footer, err := parse(HeaderV1, tok)
+	if err != nil || len(payload) < nonceSize+aead.Overhead() {
+		return nil, nil, ErrInvalid
+	}
+
+	msg, err := aead.Open(nil, payload[:nonceSize], payload[nonceSize:], pae([]byte(HeaderV1), footer, implicit))
+	if err != nil {
+		return nil, nil, ErrInvalid
+	}
+
+	return msg, footer, nil
+}
+
+// Sign produces a public version 1 token that holds the message and
+// the footer, both signed together with the implicit assertion.
+func Sign(key ed25519.PrivateKey, msg, footer, implicit []byte) (string, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return "", ErrKeySize
+	}
+
+	sig := ed25519.Sign(key, pae([]byte(HeaderPublicV1), msg, footer, implicit))
+	payload := append(append([]byte(nil), msg...), sig...)
+
+	tok := HeaderPublicV1 + base64.RawURLEncoding.EncodeToString(payload)
+	if len(footer) > 0 {
+		tok += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+
+	return tok, nil
+}
+
+// Verify verifies the public version 1 token against the implicit
+// assertion and returns its message and footer.
+func Verify(key ed25519.PublicKey, tok string, implicit []byte) ([]byte, []byte, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, nil, ErrKeySize
+	}
+
+	payload, footer, err := parse(HeaderPublicV1, tok)
+	if err != nil || len(payload) < ed25519.SignatureSize {
+		return nil, nil, ErrInvalid
+	}
+
+	msg, sig := payload[:len(payload)-ed25519.SignatureSize], payload[len(payload)-ed25519.SignatureSize:]
+	if !ed25519.Verify(key, pae([]byte(HeaderPublicV1), msg, footer, implicit), sig) {
+		return nil, nil, ErrInvalid
+	}
+
+	return msg, footer, nil
+}
+
+// Footer extracts the footer of the token without verifying it.
+// It is useful to read key identifiers before the token is decrypted
+// or verified.
+func Footer(tok string) ([]byte, error) {
+	header := HeaderV1
+	if strings.HasPrefix(tok, HeaderPublicV1) {
+		header = HeaderPublicV1
+	}
+
+	_, footer, err := parse(header, tok)
+	return footer, err
+}
+
+// parse splits the token with the provided header into its decoded
+// payload and footer.
+func parse(header, tok string) ([]byte, []byte, error) {
+	if !strings.HasPrefix(tok, header) {
+		return nil, nil, ErrInvalid
+	}
+
+	parts := strings.Split(tok[len(header):], ".")
+	if len(parts) > 2 {
+		return nil, nil, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, ErrInvalid
+	}
+
+	var footer []byte
+	if len(parts) == 2 {
+		footer, err = base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil || len(footer) == 0 {
+			return nil, nil, ErrInvalid
+		}
+	}
+
+	return payload, footer, nil
+}
+
+// newAEAD creates AES-256-GCM cipher from the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrKeySize
+	}
+
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(b)
+}
+
+// pae produces the pre-auth encoding of the provided pieces.
+func pae(pp ...[]byte) []byte {
+	var buf bytes.Buffer
+	le64 := func(n int) {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(n)&^(1<<63))
+		buf.Write(b[:])
+	}
+
+	le64(len(pp))
+	for _, p := range pp {
+		le64(len(p))
+		buf.Write(p)
+	}
+
+	return buf.Bytes()
+}
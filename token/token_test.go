@@ -0,0 +1,312 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/v1.json")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	var vv struct {
+		Vectors []struct {
+			Name     string `json:"name"`
+			Key      string `json:"key"`
+			Nonce    string `json:"nonce"`
+			Message  string `json:"message"`
+			Footer   string `json:"footer"`
+			Implicit string `json:"implicit"`
+			Token    string `json:"token"`
+		} `json:"vectors"`
+	}
+
+	if err = json.Unmarshal(data, &vv); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(vv.Vectors) == 0 {
+		t.Fatal("want vectors, got none")
+	}
+
+	for _, v := range vv.Vectors {
+		key, _ := hex.DecodeString(v.Key)
+		nonce, _ := hex.DecodeString(v.Nonce)
+
+		tok, err := encrypt(key, nonce, []byte(v.Message), []byte(v.Footer), []byte(v.Implicit))
+		if err != nil {
+			t.Fatalf("%s: want nil, got %v", v.Name, err)
+		}
+
+		if tok != v.Token {
+			t.Errorf("%s: want %q, got %q", v.Name, v.Token, tok)
+		}
+
+		msg, footer, err := Decrypt(key, v.Token, []byte(v.Implicit))
+		if err != nil {
+			t.Fatalf("%s: want nil, got %v", v.Name, err)
+		}
+
+		if string(msg) != v.Message || string(footer) != v.Footer {
+			t.Errorf("%s: want %q/%q, got %q/%q", v.Name, v.Message, v.Footer, msg, footer)
+		}
+	}
+}
+
+func TestPublicVectors(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/v1-public.json")
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	var vv struct {
+		Vectors []struct {
+			Name      string `json:"name"`
+			Seed      string `json:"seed"`
+			PublicKey string `json:"public_key"`
+			Message   string `json:"message"`
+			Footer    string `json:"footer"`
+			Implicit  string `json:"implicit"`
+			Token     string `json:"token"`
+		} `json:"vectors"`
+	}
+
+	if err = json.Unmarshal(data, &vv); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if len(vv.Vectors) == 0 {
+		t.Fatal("want vectors, got none")
+	}
+
+	for _, v := range vv.Vectors {
+		seed, _ := hex.DecodeString(v.Seed)
+		pub, _ := hex.DecodeString(v.PublicKey)
+
+		tok, err := Sign(ed25519.NewKeyFromSeed(seed), []byte(v.Message), []byte(v.Footer), []byte(v.Implicit))
+		if err != nil {
+			t.Fatalf("%s: want nil, got %v", v.Name, err)
+		}
+
+		if tok != v.Token {
+			t.Errorf("%s: want %q, got %q", v.Name, v.Token, tok)
+		}
+
+		msg, footer, err := Verify(pub, v.Token, []byte(v.Implicit))
+		if err != nil {
+			t.Fatalf("%s: want nil, got %v", v.Name, err)
+		}
+
+		if string(msg) != v.Message || string(footer) != v.Footer {
+			t.Errorf("%s: want %q/%q, got %q/%q", v.Name, v.Message, v.Footer, msg, footer)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	tok, err := Encrypt(key, []byte("msg"), []byte("footer"), []byte("implicit"))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	tok1, _ := Encrypt(key, []byte("msg"), []byte("footer"), []byte("implicit"))
+	if tok == tok1 {
+		t.Error("want unique tokens, got equal ones")
+	}
+
+	cc := map[string]struct {
+		Key      []byte
+		Token    string
+		Implicit string
+		Err      error
+	}{
+		"Invalid key size": {
+			Key:   key[1:],
+			Token: tok,
+			Err:   ErrKeySize,
+		},
+		"Wrong key": {
+			Key:      bytes.Repeat([]byte{2}, KeySize),
+			Token:    tok,
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Unsupported version": {
+			Key:      key,
+			Token:    strings.Replace(tok, "v1", "v2", 1),
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Tampered footer": {
+			Key:      key,
+			Token:    tok[:strings.LastIndex(tok, ".")+1] + "b3RoZXI",
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Removed footer": {
+			Key:      key,
+			Token:    tok[:strings.LastIndex(tok, ".")],
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Extra part": {
+			Key:      key,
+			Token:    tok + ".x",
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Truncated payload": {
+			Key:      key,
+			Token:    HeaderV1 + "AAAA",
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Wrong implicit assertion": {
+			Key:      key,
+			Token:    tok,
+			Implicit: "other",
+			Err:      ErrInvalid,
+		},
+		"Valid token": {
+			Key:      key,
+			Token:    tok,
+			Implicit: "implicit",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			msg, footer, err := Decrypt(c.Key, c.Token, []byte(c.Implicit))
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if c.Err == nil && (string(msg) != "msg" || string(footer) != "footer") {
+				t.Errorf("want %q/%q, got %q/%q", "msg", "footer", msg, footer)
+			}
+		})
+	}
+}
+
+func TestFooter(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	tok, _ := Encrypt(key, []byte("msg"), []byte("kid"), nil)
+	if f, err := Footer(tok); err != nil || string(f) != "kid" {
+		t.Errorf("want %q/nil, got %q/%v", "kid", f, err)
+	}
+
+	tok, _ = Encrypt(key, []byte("msg"), nil, nil)
+	if f, err := Footer(tok); err != nil || f != nil {
+		t.Errorf("want nil/nil, got %q/%v", f, err)
+	}
+
+	if _, err := Footer("v1.local.x"); err != ErrInvalid {
+		t.Errorf("want %v, got %v", ErrInvalid, err)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	pub := key.Public().(ed25519.PublicKey)
+
+	if _, err := Sign(key[1:], []byte("msg"), nil, nil); err != ErrKeySize {
+		t.Errorf("want %v, got %v", ErrKeySize, err)
+	}
+
+	tok, err := Sign(key, []byte("msg"), []byte("footer"), []byte("implicit"))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+	local, _ := Encrypt(bytes.Repeat([]byte{1}, KeySize), []byte("msg"), []byte("footer"), []byte("implicit"))
+
+	cc := map[string]struct {
+		Key      ed25519.PublicKey
+		Token    string
+		Implicit string
+		Err      error
+	}{
+		"Invalid key size": {
+			Key:   pub[1:],
+			Token: tok,
+			Err:   ErrKeySize,
+		},
+		"Wrong key": {
+			Key:      other,
+			Token:    tok,
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Local token": {
+			Key:      pub,
+			Token:    local,
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Tampered message": {
+			Key:      pub,
+			Token:    HeaderPublicV1 + "c" + tok[len(HeaderPublicV1)+1:],
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Tampered footer": {
+			Key:      pub,
+			Token:    tok[:strings.LastIndex(tok, ".")+1] + "b3RoZXI",
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Removed footer": {
+			Key:      pub,
+			Token:    tok[:strings.LastIndex(tok, ".")],
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Truncated payload": {
+			Key:      pub,
+			Token:    HeaderPublicV1 + "AAAA",
+			Implicit: "implicit",
+			Err:      ErrInvalid,
+		},
+		"Wrong implicit assertion": {
+			Key:      pub,
+			Token:    tok,
+			Implicit: "other",
+			Err:      ErrInvalid,
+		},
+		"Valid token": {
+			Key:      pub,
+			Token:    tok,
+			Implicit: "implicit",
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			msg, footer, err := Verify(c.Key, c.Token, []byte(c.Implicit))
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if c.Err == nil && (string(msg) != "msg" || string(footer) != "footer") {
+				t.Errorf("want %q/%q, got %q/%q", "msg", "footer", msg, footer)
+			}
+		})
+	}
+
+	if f, err := Footer(tok); err != nil || string(f) != "footer" {
+		t.Errorf("want %q/nil, got %q/%v", "footer", f, err)
+	}
+}
@@ -0,0 +1,152 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the steps recorded by the authentication trace.
+const (
+	// TraceCookie is the step during which the session cookie is
+	// extracted from the request.
+	TraceCookie = "cookie"
+
+	// TraceToken is the step during which the stateless session
+	// token is decrypted and verified (see Stateless) and, once the
+	// refresh interval passes, checked against the store.
+	TraceToken = "token"
+
+	// TraceStore is the step during which the session is fetched
+	// from the store.
+	TraceStore = "store"
+
+	// TraceValidate is the step during which the session's kind,
+	// sign-out marker, scope, access rules and the anti-CSRF token
+	// are checked.
+	TraceValidate = "validate"
+
+	// TraceFingerprint is the step during which the request's
+	// fingerprint is checked (see Fingerprint).
+	TraceFingerprint = "fingerprint"
+
+	// TraceMaintain is the step during which the session's cookies
+	// are maintained (e.g. migrated, re-issued or repaired).
+	TraceMaintain = "maintain"
+
+	// TraceContext is the step during which the session is added to
+	// the request's context.
+	TraceContext = "context"
+)
+
+// traceKey is the context key of the authentication trace.
+const traceKey contextKey = 2
+
+// serverTimingPrefix is prepended to the names of the steps in the
+// Server-Timing header.
+const serverTimingPrefix = "auth-"
+
+// TraceStep holds the duration of a single authentication step.
+type TraceStep struct {
+	// Name specifies the name of the step, e.g. TraceStore.
+	Name string
+
+	// Duration specifies how long the step took.
+	Duration time.Duration
+}
+
+// Trace holds the breakdown of the time spent authenticating a request
+// (see AuthTrace). Steps that were not reached (e.g. because the
+// request was rejected) are not included.
+type Trace struct {
+	Steps []TraceStep
+}
+
+// Total returns the duration of all steps.
+func (t Trace) Total() time.Duration {
+	var d time.Duration
+	for _, s := range t.Steps {
+		d += s.Duration
+	}
+
+	return d
+}
+
+// AuthTrace activates the debug mode in which Auth, Public and the
+// requirement middlewares record how long each step of the
+// authentication took. The trace can be retrieved from the request's
+// context with TraceFromContext (by the wrapped handler as well as by
+// the rejection function) and, if serverTiming is true, is also
+// emitted as the Server-Timing header, so it is visible in browsers'
+// developer tools.
+// By default it is not set.
+func AuthTrace(serverTiming bool) Option {
+	return func(m *Manager) {
+		m.trace.on = true
+		m.trace.header = serverTiming
+	}
+}
+
+// TraceFromContext extracts the authentication trace from the context.
+func TraceFromContext(ctx context.Context) (Trace, bool) {
+	if ctx == nil {
+		return Trace{}, false
+	}
+
+	t, ok := ctx.Value(traceKey).(*tracer)
+	if !ok {
+		return Trace{}, false
+	}
+
+	return t.trace, true
+}
+
+// tracer records the steps of a single authentication. Nil tracers
+// record nothing.
+type tracer struct {
+	trace  Trace
+	last   time.Time
+	header bool
+}
+
+// tracer returns a new tracer or, if the trace mode is not active, nil.
+func (m *Manager) tracer() *tracer {
+	if !m.trace.on {
+		return nil
+	}
+
+	return &tracer{last: time.Now(), header: m.trace.header}
+}
+
+// step records the time elapsed since the previous step.
+func (t *tracer) step(name string) {
+	if t == nil {
+		return
+	}
+
+	now := time.Now()
+	t.trace.Steps = append(t.trace.Steps, TraceStep{Name: name, Duration: now.Sub(t.last)})
+	t.last = now
+}
+
+// finish emits the Server-Timing header, if needed, and returns the
+// request with the trace added to its context.
+func (t *tracer) finish(w http.ResponseWriter, r *http.Request) *http.Request {
+	if t == nil {
+		return r
+	}
+
+	if t.header && len(t.trace.Steps) > 0 {
+		vv := make([]string, len(t.trace.Steps))
+		for i, s := range t.trace.Steps {
+			ms := float64(s.Duration) / float64(time.Millisecond)
+			vv[i] = serverTimingPrefix + s.Name + ";dur=" + strconv.FormatFloat(ms, 'f', 3, 64)
+		}
+
+		w.Header().Add("Server-Timing", strings.Join(vv, ", "))
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), traceKey, t))
+}
@@ -0,0 +1,98 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthTrace(t *testing.T) {
+	store := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id}, id == "id", nil
+		},
+	}
+
+	names := func(tr Trace) []string {
+		var nn []string
+		for _, s := range tr.Steps {
+			nn = append(nn, s.Name)
+		}
+
+		return nn
+	}
+
+	serve := func(m *Manager, mw func(http.Handler) http.Handler, val string) (*httptest.ResponseRecorder, Trace, bool) {
+		var (
+			tr Trace
+			ok bool
+		)
+
+		m.reject = func(_ error) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tr, ok = TraceFromContext(r.Context())
+				w.WriteHeader(http.StatusUnauthorized)
+			})
+		}
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: defaultName, Value: val})
+		rec := httptest.NewRecorder()
+		mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			tr, ok = TraceFromContext(r.Context())
+		})).ServeHTTP(rec, req)
+
+		return rec, tr, ok
+	}
+
+	m := NewManager(store, Validate(false), AuthTrace(true))
+	full := []string{TraceCookie, TraceStore, TraceValidate, TraceFingerprint, TraceMaintain, TraceContext}
+
+	for name, mw := range map[string]func(http.Handler) http.Handler{"Auth": m.Auth, "RequireValid": m.RequireValid()} {
+		rec, tr, ok := serve(m, mw, "id")
+		if !ok {
+			t.Fatalf("%s: want trace, got none", name)
+		}
+
+		if !reflect.DeepEqual(names(tr), full) {
+			t.Errorf("%s: want %v, got %v", name, full, names(tr))
+		}
+
+		if tr.Total() <= 0 || tr.Total() > time.Second {
+			t.Errorf("%s: want positive total, got %v", name, tr.Total())
+		}
+
+		h := rec.Header().Get("Server-Timing")
+		if !strings.HasPrefix(h, "auth-cookie;dur=") || strings.Count(h, ";dur=") != len(full) {
+			t.Errorf("%s: want Server-Timing header, got %q", name, h)
+		}
+
+		rec, tr, ok = serve(m, mw, "missing")
+		if want := []string{TraceCookie, TraceStore}; !ok || !reflect.DeepEqual(names(tr), want) {
+			t.Errorf("%s: want %v, got %v", name, want, names(tr))
+		}
+
+		if rec.Header().Get("Server-Timing") == "" {
+			t.Errorf("%s: want Server-Timing header, got none", name)
+		}
+	}
+
+	m = NewManager(store, Validate(false), AuthTrace(false))
+	if rec, _, ok := serve(m, m.Auth, "id"); !ok || rec.Header().Get("Server-Timing") != "" {
+		t.Errorf("want trace without header, got %v and %q", ok, rec.Header().Get("Server-Timing"))
+	}
+
+	m = NewManager(store, Validate(false))
+	if rec, _, ok := serve(m, m.Auth, "id"); ok || rec.Header().Get("Server-Timing") != "" {
+		t.Errorf("want no trace, got %v and %q", ok, rec.Header().Get("Server-Timing"))
+	}
+
+	var ctx context.Context
+	if _, ok := TraceFromContext(ctx); ok {
+		t.Error("want false, got true")
+	}
+}
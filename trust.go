@@ -0,0 +1,121 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	// KindTrustedDevice marks device trust records created with
+	// TrustDevice.
+	KindTrustedDevice = "trusted_device"
+
+	trustCookieSuffix = "_trust"
+)
+
+// TrustDevice marks the browser of the session stored in the context as
+// trusted for the provided duration, e.g. after a successful 2FA
+// check: a separate record (of KindTrustedDevice) is stored under the
+// session's user key and its ID is set in a long-lived cookie that
+// outlives the session. IsTrustedDevice can then be used by the login
+// flow to skip 2FA on that browser.
+// Trust records are listed by TrustedDevices and can be revoked with
+// RevokeByIDExt; they are also deleted by RevokeOther and RevokeByUserKey.
+// ErrUnauthorized is returned if the context session is not set.
+func (m *Manager) TrustDevice(ctx context.Context, w http.ResponseWriter, ttl time.Duration) error {
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	if w == nil {
+		return ErrNilWriter
+	}
+
+	p, ok := FromContext(ctx)
+	if !ok {
+		return ErrUnauthorized
+	}
+
+	s := Session{
+		CreatedAt: monotonicNow(),
+		ExpiresAt: time.Now().Add(ttl),
+		ID:        m.genID(),
+		UserKey:   p.UserKey,
+		OrgKey:    p.OrgKey,
+		IP:        p.IP,
+		Agent:     p.Agent,
+		Kind:      KindTrustedDevice,
+	}
+
+	if s.ID == "" {
+		return ErrGenID
+	}
+
+	if err := m.storeErr(m.store.Create(ctx, s)); err != nil {
+		return err
+	}
+
+	m.writeCookie(w, &http.Cookie{
+		Name:     m.cookie.name + trustCookieSuffix,
+		Value:    s.ID,
+		Path:     m.cookie.path,
+		Domain:   m.cookie.domain,
+		Expires:  m.cookieExpiry(s.ExpiresAt),
+		Secure:   m.cookie.secure,
+		HttpOnly: true,
+		SameSite: m.cookie.sameSite,
+	})
+
+	return nil
+}
+
+// IsTrustedDevice checks whether the request comes from a browser that
+// was trusted with TrustDevice by the user of the provided key and the
+// trust is neither expired nor revoked.
+func (m *Manager) IsTrustedDevice(r *http.Request, key string) (bool, error) {
+	if r == nil {
+		return false, ErrNilRequest
+	}
+
+	c, err := r.Cookie(m.cookie.name + trustCookieSuffix)
+	if err != nil || c.Value == "" {
+		return false, nil
+	}
+
+	s, ok, err := m.store.FetchByID(r.Context(), c.Value)
+	if err = m.storeErr(err); err != nil {
+		return false, err
+	}
+
+	return ok && s.Kind == KindTrustedDevice && s.UserKey == key && s.ExpiresAt.After(time.Now()), nil
+}
+
+// TrustedDevices retrieves all device trust records (see TrustDevice)
+// of the same user key as session stored in the context currently has.
+// If no records are found or the context session is not set, both
+// return values will be nil.
+func (m *Manager) TrustedDevices(ctx context.Context) ([]Session, error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+
+	cs, ok := FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	ss, err := m.store.FetchByUserKey(ctx, cs.UserKey)
+	if err = m.storeErr(err); err != nil {
+		return nil, err
+	}
+
+	var res []Session
+	for _, s := range ss {
+		if s.Kind == KindTrustedDevice {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
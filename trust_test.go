@@ -0,0 +1,188 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrustDevice(t *testing.T) {
+	store := mapStore()
+	m := NewManager(store)
+	ctx := NewContext(context.Background(), Session{ID: "id", UserKey: "key", OrgKey: "org"})
+
+	if err := m.TrustDevice(context.Background(), httptest.NewRecorder(), time.Hour); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := m.TrustDevice(ctx, rec, time.Hour*24*30); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	cc := rec.Result().Cookies()
+	if len(cc) != 1 {
+		t.Fatalf("want %d, got %d", 1, len(cc))
+	}
+
+	c := cc[0]
+	if c.Name != defaultName+trustCookieSuffix || !c.HttpOnly || !c.Secure {
+		t.Errorf("want trust cookie, got %v", c)
+	}
+
+	if exp := time.Now().Add(time.Hour * 24 * 29); c.Expires.Before(exp) {
+		t.Errorf("want after %v, got %v", exp, c.Expires)
+	}
+
+	ff := store.CreateCalls()
+	if len(ff) != 1 {
+		t.Fatalf("want %d, got %d", 1, len(ff))
+	}
+
+	s := ff[0].S
+	if s.ID != c.Value || s.Kind != KindTrustedDevice || s.UserKey != "key" || s.OrgKey != "org" {
+		t.Errorf("want trust record, got %v", s)
+	}
+
+	store.CreateFunc = func(_ context.Context, _ Session) error {
+		return errors.New("error")
+	}
+
+	rec = httptest.NewRecorder()
+	if err := m.TrustDevice(ctx, rec, time.Hour); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("want %d, got %d", 0, len(rec.Result().Cookies()))
+	}
+}
+
+func TestIsTrustedDevice(t *testing.T) {
+	store := mapStore()
+	m := NewManager(store)
+	now := time.Now()
+
+	for _, s := range []Session{
+		{ID: "trusted", UserKey: "key", Kind: KindTrustedDevice, ExpiresAt: now.Add(time.Hour)},
+		{ID: "expired", UserKey: "key", Kind: KindTrustedDevice, ExpiresAt: now.Add(-time.Hour)},
+		{ID: "session", UserKey: "key", ExpiresAt: now.Add(time.Hour)},
+	} {
+		store.Create(context.Background(), s)
+	}
+
+	cc := map[string]struct {
+		Cookie  *http.Cookie
+		Key     string
+		Store   *StoreMock
+		Trusted bool
+		Err     bool
+	}{
+		"No cookie": {
+			Key: "key",
+		},
+		"Unknown record": {
+			Cookie: &http.Cookie{Name: defaultName + trustCookieSuffix, Value: "unknown"},
+			Key:    "key",
+		},
+		"Expired record": {
+			Cookie: &http.Cookie{Name: defaultName + trustCookieSuffix, Value: "expired"},
+			Key:    "key",
+		},
+		"Regular session": {
+			Cookie: &http.Cookie{Name: defaultName + trustCookieSuffix, Value: "session"},
+			Key:    "key",
+		},
+		"Other user": {
+			Cookie: &http.Cookie{Name: defaultName + trustCookieSuffix, Value: "trusted"},
+			Key:    "key1",
+		},
+		"Error returned by store.FetchByID": {
+			Cookie: &http.Cookie{Name: defaultName + trustCookieSuffix, Value: "trusted"},
+			Key:    "key",
+			Store: &StoreMock{
+				FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+					return Session{}, false, errors.New("error")
+				},
+			},
+			Err: true,
+		},
+		"Trusted device": {
+			Cookie:  &http.Cookie{Name: defaultName + trustCookieSuffix, Value: "trusted"},
+			Key:     "key",
+			Trusted: true,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			m := m
+			if c.Store != nil {
+				m = NewManager(c.Store)
+			}
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			if c.Cookie != nil {
+				req.AddCookie(c.Cookie)
+			}
+
+			ok, err := m.IsTrustedDevice(req, c.Key)
+			if c.Err != (err != nil) {
+				t.Errorf("want %t, got %v", c.Err, err)
+			}
+
+			if ok != c.Trusted {
+				t.Errorf("want %t, got %t", c.Trusted, ok)
+			}
+		})
+	}
+}
+
+func TestTrustedDevices(t *testing.T) {
+	ss := []Session{
+		{ID: "1", Kind: KindTrustedDevice},
+		{ID: "2"},
+		{ID: "3", Kind: KindCanary},
+		{ID: "4", Kind: KindTrustedDevice},
+	}
+
+	store := &StoreMock{
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return ss, nil
+		},
+	}
+
+	m := NewManager(store)
+	res, err := m.TrustedDevices(context.Background())
+	if err != nil || res != nil {
+		t.Errorf("want nil/nil, got %v/%v", err, res)
+	}
+
+	res, err = m.TrustedDevices(NewContext(context.Background(), Session{UserKey: "key"}))
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if exp := []Session{ss[0], ss[3]}; !reflect.DeepEqual(exp, res) {
+		t.Errorf("want %v, got %v", exp, res)
+	}
+
+	if ff := store.FetchByUserKeyCalls(); len(ff) != 1 || ff[0].Key != "key" {
+		t.Errorf("want %q, got %v", "key", ff)
+	}
+
+	store.FetchByUserKeyFunc = func(_ context.Context, _ string) ([]Session, error) {
+		return nil, errors.New("error")
+	}
+
+	if _, err = m.TrustedDevices(NewContext(context.Background(), Session{UserKey: "key"})); err == nil {
+		t.Error("want non-nil, got nil")
+	}
+}
@@ -0,0 +1,186 @@
+package sessionup
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ID is a unique session ID.
+type ID string
+
+// UserKey is a non-unique key used to group sessions of the same user.
+type UserKey string
+
+// TypedStore wraps the Store and exposes its methods with distinct ID
+// and UserKey parameter types, so that the arguments cannot be
+// accidentally swapped (e.g. when calling DeleteByUserKey).
+// The Store interface itself keeps plain string parameters, so that
+// existing implementations remain compatible.
+type TypedStore struct {
+	Store Store
+}
+
+// Create inserts the session into the store.
+func (t TypedStore) Create(ctx context.Context, s Session) error {
+	return t.Store.Create(ctx, s)
+}
+
+// FetchByID retrieves the session by the provided ID.
+func (t TypedStore) FetchByID(ctx context.Context, id ID) (Session, bool, error) {
+	return t.Store.FetchByID(ctx, string(id))
+}
+
+// FetchByUserKey retrieves all sessions associated with the provided
+// user key.
+func (t TypedStore) FetchByUserKey(ctx context.Context, key UserKey) ([]Session, error) {
+	return t.Store.FetchByUserKey(ctx, string(key))
+}
+
+// DeleteByID deletes the session by the provided ID.
+func (t TypedStore) DeleteByID(ctx context.Context, id ID) error {
+	return t.Store.DeleteByID(ctx, string(id))
+}
+
+// DeleteByUserKey deletes all sessions associated with the provided
+// user key, except those whose IDs are provided as the last argument.
+func (t TypedStore) DeleteByUserKey(ctx context.Context, key UserKey, expID ...ID) error {
+	ids := make([]string, len(expID))
+	for i, id := range expID {
+		ids[i] = string(id)
+	}
+
+	return t.Store.DeleteByUserKey(ctx, string(key), ids...)
+}
+
+// SessionID returns the session's ID as a typed value.
+func (s Session) SessionID() ID {
+	return ID(s.ID)
+}
+
+// SessionUserKey returns the session's user key as a typed value.
+func (s Session) SessionUserKey() UserKey {
+	return UserKey(s.UserKey)
+}
+
+// TypedManager wraps the Manager and exposes its methods that accept
+// session IDs or user keys with distinct ID and UserKey parameter
+// types, so that the arguments cannot be accidentally swapped (e.g.
+// when calling RevokeByDevice). All other Manager methods are
+// available as well.
+// The Manager's own methods keep plain string parameters, so that
+// existing call sites remain compatible.
+type TypedManager struct {
+	*Manager
+}
+
+// Typed returns the TypedManager that wraps the manager.
+func (m *Manager) Typed() TypedManager {
+	return TypedManager{Manager: m}
+}
+
+// Init creates a new session for the user of the provided key (see
+// Manager.Init).
+func (t TypedManager) Init(w http.ResponseWriter, r *http.Request, key UserKey, mm ...Meta) error {
+	return t.Manager.Init(w, r, string(key), mm...)
+}
+
+// Switch signs the current user out and the user of the provided key
+// in (see Manager.Switch).
+func (t TypedManager) Switch(ctx context.Context, w http.ResponseWriter, r *http.Request, key UserKey, keep ...string) error {
+	return t.Manager.Switch(ctx, w, r, string(key), keep...)
+}
+
+// RevokeByID deletes the session by the provided ID (see
+// Manager.RevokeByID).
+func (t TypedManager) RevokeByID(ctx context.Context, id ID) error {
+	return t.Manager.RevokeByID(ctx, string(id))
+}
+
+// RevokeByIDExt deletes the session by the provided ID after checking
+// if it belongs to the same user as the one in the context (see
+// Manager.RevokeByIDExt).
+func (t TypedManager) RevokeByIDExt(ctx context.Context, id ID) error {
+	return t.Manager.RevokeByIDExt(ctx, string(id))
+}
+
+// RevokeByUserKey deletes all sessions under the provided user key
+// (see Manager.RevokeByUserKey).
+func (t TypedManager) RevokeByUserKey(ctx context.Context, key UserKey) error {
+	return t.Manager.RevokeByUserKey(ctx, string(key))
+}
+
+// RevokeByDevice deletes all sessions under the provided user key that
+// were created on the provided device type (see Manager.RevokeByDevice).
+func (t TypedManager) RevokeByDevice(ctx context.Context, key UserKey, device string) error {
+	return t.Manager.RevokeByDevice(ctx, string(key), device)
+}
+
+// RevokeCreatedBetween deletes all sessions under the provided user key
+// that were created in the provided time range (see
+// Manager.RevokeCreatedBetween).
+func (t TypedManager) RevokeCreatedBetween(ctx context.Context, key UserKey, from, to time.Time) error {
+	return t.Manager.RevokeCreatedBetween(ctx, string(key), from, to)
+}
+
+// OnUserDeleted revokes all sessions under the provided user key and
+// purges its data (see Manager.OnUserDeleted).
+func (t TypedManager) OnUserDeleted(ctx context.Context, key UserKey) error {
+	return t.Manager.OnUserDeleted(ctx, string(key))
+}
+
+// OnUsersDeleted calls OnUserDeleted for each of the provided user
+// keys (see Manager.OnUsersDeleted).
+func (t TypedManager) OnUsersDeleted(ctx context.Context, keys ...UserKey) error {
+	kk := make([]string, len(keys))
+	for i, key := range keys {
+		kk[i] = string(key)
+	}
+
+	return t.Manager.OnUsersDeleted(ctx, kk...)
+}
+
+// SignOutEverywhere revokes all sessions under the provided user key
+// and records a sign out marker (see Manager.SignOutEverywhere).
+func (t TypedManager) SignOutEverywhere(ctx context.Context, key UserKey, ttl time.Duration) (SignOutMarker, error) {
+	return t.Manager.SignOutEverywhere(ctx, string(key), ttl)
+}
+
+// SignOutPending retrieves the pending sign out marker of the provided
+// user key (see Manager.SignOutPending).
+func (t TypedManager) SignOutPending(ctx context.Context, key UserKey) (SignOutMarker, bool, error) {
+	return t.Manager.SignOutPending(ctx, string(key))
+}
+
+// IsTrustedDevice checks whether the request comes from a browser
+// trusted by the user of the provided key (see
+// Manager.IsTrustedDevice).
+func (t TypedManager) IsTrustedDevice(r *http.Request, key UserKey) (bool, error) {
+	return t.Manager.IsTrustedDevice(r, string(key))
+}
+
+// PlantCanary creates a decoy session under the provided user key and
+// returns its ID (see Manager.PlantCanary).
+func (t TypedManager) PlantCanary(ctx context.Context, key UserKey) (ID, error) {
+	id, err := t.Manager.PlantCanary(ctx, string(key))
+	return ID(id), err
+}
+
+// UpdateMeta applies the provided metadata changes to the session with
+// the provided ID (see Manager.UpdateMeta).
+func (t TypedManager) UpdateMeta(ctx context.Context, id ID, mm ...Meta) error {
+	return t.Manager.UpdateMeta(ctx, string(id), mm...)
+}
+
+// SignWebhook signs the body of the outbound webhook with the secret of
+// the session with the provided ID (see Manager.SignWebhook).
+func (t TypedManager) SignWebhook(ctx context.Context, id ID, body []byte) (string, error) {
+	return t.Manager.SignWebhook(ctx, string(id), body)
+}
+
+// VerifyWebhook checks the signature of the inbound webhook produced
+// with the secret of the session with the provided ID (see
+// Manager.VerifyWebhook).
+func (t TypedManager) VerifyWebhook(ctx context.Context, id ID, body []byte, sig string) error {
+	return t.Manager.VerifyWebhook(ctx, string(id), body, sig)
+}
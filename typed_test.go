@@ -0,0 +1,113 @@
+package sessionup
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTypedStore(t *testing.T) {
+	ctx := context.Background()
+	s := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			return Session{ID: id}, true, nil
+		},
+		FetchByUserKeyFunc: func(_ context.Context, key string) ([]Session, error) {
+			return []Session{{UserKey: key}}, nil
+		},
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}
+
+	ts := TypedStore{Store: s}
+	if err := ts.Create(ctx, Session{ID: "id"}); err != nil || len(s.CreateCalls()) != 1 {
+		t.Errorf("want nil/1, got %v/%d", err, len(s.CreateCalls()))
+	}
+
+	ses, ok, err := ts.FetchByID(ctx, ID("id"))
+	if err != nil || !ok || ses.SessionID() != "id" {
+		t.Errorf("want nil/true/%q, got %v/%t/%q", "id", err, ok, ses.SessionID())
+	}
+
+	ss, err := ts.FetchByUserKey(ctx, UserKey("key"))
+	if err != nil || len(ss) != 1 || ss[0].SessionUserKey() != "key" {
+		t.Errorf("want nil/%q, got %v/%v", "key", err, ss)
+	}
+
+	if err = ts.DeleteByID(ctx, ID("id")); err != nil || s.DeleteByIDCalls()[0].ID != "id" {
+		t.Errorf("want nil/%q, got %v/%v", "id", err, s.DeleteByIDCalls())
+	}
+
+	if err = ts.DeleteByUserKey(ctx, UserKey("key"), ID("id1"), ID("id2")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	c := s.DeleteByUserKeyCalls()[0]
+	if c.Key != "key" || !reflect.DeepEqual([]string{"id1", "id2"}, c.ExpID) {
+		t.Errorf("want %q/%v, got %q/%v", "key", []string{"id1", "id2"}, c.Key, c.ExpID)
+	}
+}
+
+func TestTypedManager(t *testing.T) {
+	ctx := context.Background()
+	s := &StoreMock{
+		CreateFunc: func(_ context.Context, _ Session) error {
+			return nil
+		},
+		FetchByIDFunc: func(_ context.Context, _ string) (Session, bool, error) {
+			return Session{}, false, nil
+		},
+		FetchByUserKeyFunc: func(_ context.Context, _ string) ([]Session, error) {
+			return nil, nil
+		},
+		DeleteByIDFunc: func(_ context.Context, _ string) error {
+			return nil
+		},
+		DeleteByUserKeyFunc: func(_ context.Context, _ string, _ ...string) error {
+			return nil
+		},
+	}
+
+	tm := NewManager(s).Typed()
+	if err := tm.Init(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), UserKey("key")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if key := s.CreateCalls()[0].S.UserKey; key != "key" {
+		t.Errorf("want %q, got %q", "key", key)
+	}
+
+	id, err := tm.PlantCanary(ctx, UserKey("key"))
+	if err != nil || id != s.CreateCalls()[1].S.SessionID() {
+		t.Errorf("want nil/%q, got %v/%q", s.CreateCalls()[1].S.ID, err, id)
+	}
+
+	if err = tm.RevokeByID(ctx, ID("id")); err != nil || s.DeleteByIDCalls()[0].ID != "id" {
+		t.Errorf("want nil/%q, got %v/%v", "id", err, s.DeleteByIDCalls())
+	}
+
+	if err = tm.RevokeByUserKey(ctx, UserKey("key1")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if err = tm.OnUsersDeleted(ctx, UserKey("key2"), UserKey("key3")); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	var keys []string
+	for _, c := range s.DeleteByUserKeyCalls() {
+		keys = append(keys, c.Key)
+	}
+
+	if exp := []string{"key1", "key2", "key3"}; !reflect.DeepEqual(exp, keys) {
+		t.Errorf("want %v, got %v", exp, keys)
+	}
+}
@@ -0,0 +1,104 @@
+package sessionup
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNotVerified is returned when the session's verification
+	// timestamp is missing or too old and the verification callback
+	// did not confirm it.
+	ErrNotVerified = errors.New("session verification required")
+)
+
+// MetaTime adds a new timestamp entry into the session's metadata map.
+// Timestamps are stored as Unix time in seconds and can be checked with
+// IsVerified function.
+func MetaTime(key string, t time.Time) Meta {
+	return MetaEntry(key, strconv.FormatInt(t.Unix(), 10))
+}
+
+// IsVerified checks whether the session's metadata entry, stored under
+// the provided key, holds a timestamp that is not older than maxAge or
+// not.
+func IsVerified(s Session, key string, maxAge time.Duration) bool {
+	v, ok := s.Meta[key]
+	if !ok {
+		return false
+	}
+
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Since(time.Unix(sec, 0)) <= maxAge
+}
+
+// RequireVerified produces a middleware that checks whether the session,
+// stored in the request's context, was verified (e.g. e-mail confirmed or
+// 2FA passed) not earlier than maxAge ago, based on the timestamp stored
+// in the metadata entry under the provided key.
+// If the timestamp is missing or too old, the verify callback is called
+// to re-check the verification status. Upon confirmation, the timestamp
+// is updated (the store must implement UpdateStore interface) and the
+// wrapped handler is activated, otherwise the manager's rejection
+// function is called with ErrNotVerified or the error returned by the
+// callback. Nil callback rejects all sessions that are not verified.
+// The middleware must be used inside Auth or Public middlewares.
+func (m *Manager) RequireVerified(key string, maxAge time.Duration, verify func(*http.Request) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.noop {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			s, ok := FromContext(ctx)
+			if !ok {
+				m.reject(ErrUnauthorized).ServeHTTP(w, r)
+				return
+			}
+
+			if IsVerified(s, key, maxAge+m.Config().ClockSkew) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if verify == nil {
+				m.reject(ErrNotVerified).ServeHTTP(w, r)
+				return
+			}
+
+			ok, err := verify(r)
+			if err != nil {
+				m.reject(err).ServeHTTP(w, r)
+				return
+			}
+
+			if !ok {
+				m.reject(ErrNotVerified).ServeHTTP(w, r)
+				return
+			}
+
+			meta := make(map[string]string, len(s.Meta)+1)
+			for k, v := range s.Meta {
+				meta[k] = v
+			}
+
+			MetaTime(key, time.Now())(meta)
+			s.Meta = meta
+
+			if s, err = m.update(ctx, s); err != nil {
+				m.reject(err).ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(ctx, s)))
+		})
+	}
+}
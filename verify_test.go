@@ -0,0 +1,164 @@
+package sessionup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// updateStoreMock is a Store with UpdateStore capability, backed by
+// StoreMock.
+type updateStoreMock struct {
+	*StoreMock
+	UpdateFunc func(ctx context.Context, s Session) error
+	updated    []Session
+}
+
+func (u *updateStoreMock) Update(ctx context.Context, s Session) error {
+	u.updated = append(u.updated, s)
+	return u.UpdateFunc(ctx, s)
+}
+
+func TestMetaTime(t *testing.T) {
+	m := make(map[string]string)
+	tm := time.Now()
+	MetaTime("test", tm)(m)
+	if m["test"] != strconv.FormatInt(tm.Unix(), 10) {
+		t.Errorf("want %d, got %s", tm.Unix(), m["test"])
+	}
+}
+
+func TestIsVerified(t *testing.T) {
+	cc := map[string]struct {
+		Meta map[string]string
+		Res  bool
+	}{
+		"Missing entry": {
+			Res: false,
+		},
+		"Invalid entry": {
+			Meta: map[string]string{"2fa": "yesterday"},
+			Res:  false,
+		},
+		"Outdated entry": {
+			Meta: map[string]string{"2fa": strconv.FormatInt(time.Now().Add(-time.Hour*2).Unix(), 10)},
+			Res:  false,
+		},
+		"Fresh entry": {
+			Meta: map[string]string{"2fa": strconv.FormatInt(time.Now().Unix(), 10)},
+			Res:  true,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			res := IsVerified(Session{Meta: c.Meta}, "2fa", time.Hour)
+			if res != c.Res {
+				t.Errorf("want %t, got %t", c.Res, res)
+			}
+		})
+	}
+}
+
+func TestRequireVerified(t *testing.T) {
+	fresh := Session{ID: "id", Meta: map[string]string{"2fa": strconv.FormatInt(time.Now().Unix(), 10)}}
+	stale := Session{ID: "id", Meta: map[string]string{"other": "1"}}
+
+	verifier := func(ok bool, err error) func(*http.Request) (bool, error) {
+		return func(_ *http.Request) (bool, error) {
+			return ok, err
+		}
+	}
+
+	cc := map[string]struct {
+		Ctx       context.Context
+		Verify    func(*http.Request) (bool, error)
+		UpdateErr error
+		Code      int
+		Updated   int
+	}{
+		"No session in the context": {
+			Ctx:  context.Background(),
+			Code: http.StatusUnauthorized,
+		},
+		"Fresh session": {
+			Ctx:  NewContext(context.Background(), fresh),
+			Code: http.StatusOK,
+		},
+		"No verify callback": {
+			Ctx:  NewContext(context.Background(), stale),
+			Code: http.StatusUnauthorized,
+		},
+		"Error returned by verify callback": {
+			Ctx:    NewContext(context.Background(), stale),
+			Verify: verifier(true, errors.New("error")),
+			Code:   http.StatusUnauthorized,
+		},
+		"Verification denied": {
+			Ctx:    NewContext(context.Background(), stale),
+			Verify: verifier(false, nil),
+			Code:   http.StatusUnauthorized,
+		},
+		"Error returned by store.Update": {
+			Ctx:       NewContext(context.Background(), stale),
+			Verify:    verifier(true, nil),
+			UpdateErr: errors.New("error"),
+			Code:      http.StatusUnauthorized,
+			Updated:   1,
+		},
+		"Successful verification": {
+			Ctx:     NewContext(context.Background(), stale),
+			Verify:  verifier(true, nil),
+			Code:    http.StatusOK,
+			Updated: 1,
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+			s := &updateStoreMock{
+				StoreMock: &StoreMock{},
+				UpdateFunc: func(_ context.Context, _ Session) error {
+					return c.UpdateErr
+				},
+			}
+
+			m := Manager{store: s}
+			m.Defaults()
+
+			next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				cs, _ := FromContext(r.Context())
+				if !IsVerified(cs, "2fa", time.Minute) {
+					t.Error("want verified session, got unverified")
+				}
+			})
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://example.com/", nil).WithContext(c.Ctx)
+			m.RequireVerified("2fa", time.Minute, c.Verify)(next).ServeHTTP(rec, req)
+			if rec.Code != c.Code {
+				t.Errorf("want %d, got %d", c.Code, rec.Code)
+			}
+
+			if len(s.updated) != c.Updated {
+				t.Fatalf("want %d, got %d", c.Updated, len(s.updated))
+			}
+
+			if c.Updated > 0 && s.updated[0].Meta["other"] != "1" {
+				t.Errorf("want %q, got %q", "1", s.updated[0].Meta["other"])
+			}
+
+			if _, ok := stale.Meta["2fa"]; ok {
+				t.Error("context session's metadata must not be modified")
+			}
+		})
+	}
+}
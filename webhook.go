@@ -0,0 +1,133 @@
+package sessionup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// webhookLabel separates the webhook secrets from other values that
+// might be derived from the signing key in the future.
+const webhookLabel = "sessionup webhook secret"
+
+var (
+	// ErrInvalidWebhookSession is returned when the session, that the
+	// webhook secret is derived from, is not found, expired or is not
+	// a regular session.
+	ErrInvalidWebhookSession = errors.New("webhook session is no longer valid")
+
+	// ErrInvalidWebhookSignature is returned when the webhook's
+	// signature does not match its body.
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+)
+
+// WebhookSecret returns the signing secret of the session stored in the
+// context, e.g. to hand it to the client that registers a user-scoped
+// callback. The secret is derived from the signing key (see SigningKey)
+// and the session, so it does not need to be stored, changes whenever
+// the session is replaced by a new one (e.g. on rotation) and, since
+// SignWebhook and VerifyWebhook check that the session is still valid,
+// dies together with the session.
+// ErrUnauthorized is returned if the context session is not set.
+func (m *Manager) WebhookSecret(ctx context.Context) (string, error) {
+	if ctx == nil {
+		return "", ErrNilContext
+	}
+
+	s, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrUnauthorized
+	}
+
+	if len(m.signingKey) == 0 {
+		return "", ErrNoSigningKey
+	}
+
+	return m.webhookSecret(s), nil
+}
+
+// SignWebhook signs the body of the outbound webhook (or callback)
+// with the secret of the session with the provided ID and returns the
+// hex-encoded HMAC-SHA256 signature. Receivers verify it by computing
+// the same HMAC with the secret returned by WebhookSecret.
+// ErrInvalidWebhookSession is returned if the session is not valid
+// anymore, in which case the webhook should not be sent.
+func (m *Manager) SignWebhook(ctx context.Context, id string, body []byte) (string, error) {
+	secret, err := m.webhookSecretByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(webhookMAC(secret, body)), nil
+}
+
+// VerifyWebhook checks the signature of the inbound webhook (or
+// callback) produced with the secret of the session with the provided
+// ID, in the same format as SignWebhook.
+// ErrInvalidWebhookSession is returned if the session is not valid
+// anymore and ErrInvalidWebhookSignature if the signature does not
+// match the body.
+func (m *Manager) VerifyWebhook(ctx context.Context, id string, body []byte, sig string) error {
+	secret, err := m.webhookSecretByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	mac, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, webhookMAC(secret, body)) {
+		return ErrInvalidWebhookSignature
+	}
+
+	return nil
+}
+
+// webhookSecretByID retrieves the session with the provided ID from the
+// store and returns its webhook secret.
+func (m *Manager) webhookSecretByID(ctx context.Context, id string) (string, error) {
+	if ctx == nil {
+		return "", ErrNilContext
+	}
+
+	if len(m.signingKey) == 0 {
+		return "", ErrNoSigningKey
+	}
+
+	s, ok, err := m.store.FetchByID(ctx, id)
+	if err = m.storeErr(err); err != nil {
+		return "", err
+	}
+
+	if !ok || s.Kind != "" || IsExpired(s, time.Now().Add(-m.Config().ClockSkew)) {
+		return "", ErrInvalidWebhookSession
+	}
+
+	return m.webhookSecret(s), nil
+}
+
+// webhookSecret derives the webhook secret of the session. The
+// session's creation time is included, so that sessions that reuse the
+// ID of a deleted one get different secrets.
+func (m *Manager) webhookSecret(s Session) string {
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(s.CreatedAt.UnixNano()))
+
+	h := hmac.New(sha256.New, m.signingKey)
+	h.Write([]byte(webhookLabel))
+	h.Write(ts[:])
+	h.Write([]byte(s.ID))
+
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// webhookMAC returns the HMAC-SHA256 of the body keyed with the secret.
+func webhookMAC(secret string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,131 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWebhookSecret(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	s := Session{ID: "id", UserKey: "key", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	var nilCtx context.Context
+
+	m := NewManager(&StoreMock{}, SigningKey(key))
+	if _, err := m.WebhookSecret(nilCtx); err != ErrNilContext {
+		t.Errorf("want %v, got %v", ErrNilContext, err)
+	}
+
+	if _, err := m.WebhookSecret(context.Background()); err != ErrUnauthorized {
+		t.Errorf("want %v, got %v", ErrUnauthorized, err)
+	}
+
+	if _, err := NewManager(&StoreMock{}).WebhookSecret(NewContext(context.Background(), s)); err != ErrNoSigningKey {
+		t.Errorf("want %v, got %v", ErrNoSigningKey, err)
+	}
+
+	secret, err := m.WebhookSecret(NewContext(context.Background(), s))
+	if err != nil || secret == "" {
+		t.Fatalf("want secret, got %q and %v", secret, err)
+	}
+
+	rotated := s
+	rotated.ID = "id2"
+
+	recreated := s
+	recreated.CreatedAt = now.Add(time.Second)
+
+	for name, o := range map[string]Session{"rotated": rotated, "recreated": recreated} {
+		if res, _ := m.WebhookSecret(NewContext(context.Background(), o)); res == secret {
+			t.Errorf("%s: want different secret, got the same", name)
+		}
+	}
+
+	if res, _ := NewManager(&StoreMock{}, SigningKey([]byte("abcdef0123456789abcdef0123456789"))).WebhookSecret(NewContext(context.Background(), s)); res == secret {
+		t.Error("want different secret, got the same")
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	body := []byte(`{"event":"done"}`)
+	errStore := errors.New("error")
+
+	store := &StoreMock{
+		FetchByIDFunc: func(_ context.Context, id string) (Session, bool, error) {
+			switch id {
+			case "error":
+				return Session{}, false, errStore
+			case "expired":
+				return Session{ID: id, ExpiresAt: now.Add(-time.Minute)}, true, nil
+			case "ticket":
+				return Session{ID: id, Kind: KindTicket, ExpiresAt: now.Add(time.Hour)}, true, nil
+			case "id":
+				return Session{ID: id, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, true, nil
+			}
+
+			return Session{}, false, nil
+		},
+	}
+
+	m := NewManager(store, SigningKey(key))
+	secret, _ := m.WebhookSecret(NewContext(context.Background(), Session{ID: "id", CreatedAt: now}))
+	sig, err := m.SignWebhook(context.Background(), "id", body)
+	if err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+
+	if want := hexMAC(secret, body); sig != want {
+		t.Errorf("want %q, got %q", want, sig)
+	}
+
+	var nilCtx context.Context
+
+	cc := map[string]struct {
+		Manager *Manager
+		Ctx     context.Context
+		ID      string
+		Sig     string
+		Err     error
+	}{
+		"Nil context":             {Manager: m, Ctx: nilCtx, ID: "id", Sig: sig, Err: ErrNilContext},
+		"No signing key":          {Manager: NewManager(store), Ctx: context.Background(), ID: "id", Sig: sig, Err: ErrNoSigningKey},
+		"Store error":             {Manager: m, Ctx: context.Background(), ID: "error", Sig: sig, Err: errStore},
+		"Revoked session":         {Manager: m, Ctx: context.Background(), ID: "missing", Sig: sig, Err: ErrInvalidWebhookSession},
+		"Expired session":         {Manager: m, Ctx: context.Background(), ID: "expired", Sig: sig, Err: ErrInvalidWebhookSession},
+		"Temporary session":       {Manager: m, Ctx: context.Background(), ID: "ticket", Sig: sig, Err: ErrInvalidWebhookSession},
+		"Malformed signature":     {Manager: m, Ctx: context.Background(), ID: "id", Sig: "x", Err: ErrInvalidWebhookSignature},
+		"Invalid signature":       {Manager: m, Ctx: context.Background(), ID: "id", Sig: hexMAC("other", body), Err: ErrInvalidWebhookSignature},
+		"Successful verification": {Manager: m, Ctx: context.Background(), ID: "id", Sig: sig},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			err := c.Manager.VerifyWebhook(c.Ctx, c.ID, body, c.Sig)
+			if err != c.Err {
+				t.Errorf("want %v, got %v", c.Err, err)
+			}
+
+			if c.Err == ErrInvalidWebhookSignature || c.Err == nil {
+				return
+			}
+
+			if _, err = c.Manager.SignWebhook(c.Ctx, c.ID, body); err == nil {
+				t.Error("want non-nil, got nil")
+			}
+		})
+	}
+}
+
+func hexMAC(secret string, body []byte) string {
+	return hex.EncodeToString(webhookMAC(secret, body))
+}
@@ -0,0 +1,129 @@
+package sessionup
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// WellKnownPath is the path at which ConfigHandler is conventionally
+// served.
+const WellKnownPath = "/.well-known/session-configuration"
+
+// StepUp describes a step-up requirement of the application's routes
+// (see RequireStepUp), so that clients can ask users to re-verify
+// before they reach the route.
+type StepUp struct {
+	// Name specifies the name of the protected action or group of
+	// routes, e.g. "payments".
+	Name string
+
+	// Key specifies the metadata key under which the verification
+	// time is stored (see MetaTime).
+	Key string
+
+	// MaxAge specifies how long the verification is accepted.
+	MaxAge time.Duration
+}
+
+// DescribeConfig holds the data that ConfigHandler publishes in
+// addition to the manager's own settings.
+type DescribeConfig struct {
+	// Heartbeat specifies the interval of the application's
+	// HeartbeatHandler. If it is set, sessions are renewed while they
+	// are used, so their lifetime is published as an idle timeout
+	// rather than an absolute one.
+	// By default it is not set.
+	Heartbeat time.Duration
+
+	// StepUps specifies the step-up requirements of the application's
+	// routes.
+	// By default it is not set.
+	StepUps []StepUp
+}
+
+// sessionConfig is the response of the configuration handler. All
+// durations are in seconds.
+type sessionConfig struct {
+	CookieName        string       `json:"cookie_name"`
+	CSRFHeader        string       `json:"csrf_header,omitempty"`
+	Lifetime          int64        `json:"lifetime"`
+	IdleTimeout       int64        `json:"idle_timeout,omitempty"`
+	AbsoluteLifetime  int64        `json:"absolute_lifetime,omitempty"`
+	HeartbeatInterval int64        `json:"heartbeat_interval,omitempty"`
+	StepUps           []stepUpJSON `json:"step_up,omitempty"`
+}
+
+// stepUpJSON is the JSON representation of StepUp.
+type stepUpJSON struct {
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	MaxAge int64  `json:"max_age"`
+}
+
+// ConfigHandler produces a handler that describes the non-sensitive
+// session configuration as JSON (conventionally at WellKnownPath), so
+// that frontends and API clients can configure their timeout warnings
+// and token handling instead of hard-coding values that drift from the
+// server's. The response holds:
+//   - 'cookie_name': the name of the session cookie;
+//   - 'csrf_header': the header that must carry the anti-CSRF token,
+//     if the token check is active;
+//   - 'lifetime': the lifetime of new sessions (zero for temporary
+//     sessions that end when the browser is closed);
+//   - 'idle_timeout' and 'heartbeat_interval': the time after which
+//     unused sessions expire (the minimum with AdaptiveTTL) and the
+//     heartbeat interval, if DescribeConfig's Heartbeat is set;
+//   - 'absolute_lifetime': the time after which sessions expire
+//     regardless of their use, if they are not renewed;
+//   - 'step_up': the step-up requirements of DescribeConfig.
+//
+// All durations are in seconds. If the handler is wrapped with Public,
+// the lifetime of the current user's sessions is described (see
+// TTLResolver).
+func (m *Manager) ConfigHandler(cfg DescribeConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ttl := m.lifetime.store
+		if ttl <= 0 {
+			ttl = m.Config().ExpiresIn
+			if s, ok := FromContext(r.Context()); ok {
+				ttl = m.ttl(s.UserKey)
+			}
+		}
+
+		res := sessionConfig{
+			CookieName: m.cookie.name,
+			Lifetime:   seconds(ttl),
+		}
+
+		if m.csrfActive() && m.csrf.token {
+			res.CSRFHeader = m.csrf.header
+		}
+
+		switch {
+		case ttl <= 0:
+		case cfg.Heartbeat > 0:
+			res.HeartbeatInterval = seconds(cfg.Heartbeat)
+			res.IdleTimeout = res.Lifetime
+			if m.adaptive != nil {
+				res.IdleTimeout = seconds(m.adaptive.Min)
+			}
+		default:
+			res.AbsoluteLifetime = res.Lifetime
+		}
+
+		for _, su := range cfg.StepUps {
+			res.StepUps = append(res.StepUps, stepUpJSON{Name: su.Name, Key: su.Key, MaxAge: seconds(su.MaxAge)})
+		}
+
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// seconds returns the duration in whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
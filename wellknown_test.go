@@ -0,0 +1,118 @@
+package sessionup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigHandler(t *testing.T) {
+	steps := []StepUp{{Name: "payments", Key: "mfa", MaxAge: time.Minute * 5}}
+
+	cc := map[string]struct {
+		Manager *Manager
+		Config  DescribeConfig
+		Session *Session
+		Result  map[string]interface{}
+	}{
+		"Absolute lifetime": {
+			Manager: NewManager(&StoreMock{}, ExpiresIn(time.Hour)),
+			Result: map[string]interface{}{
+				"cookie_name":       defaultName,
+				"lifetime":          3600.0,
+				"absolute_lifetime": 3600.0,
+			},
+		},
+		"Temporary sessions": {
+			Manager: NewManager(&StoreMock{}, CookieName("sid")),
+			Result: map[string]interface{}{
+				"cookie_name": "sid",
+				"lifetime":    0.0,
+			},
+		},
+		"Idle timeout": {
+			Manager: NewManager(&StoreMock{}, ExpiresIn(time.Hour), SameSite(http.SameSiteNoneMode), CSRFHeader("X-Token")),
+			Config:  DescribeConfig{Heartbeat: time.Minute, StepUps: steps},
+			Result: map[string]interface{}{
+				"cookie_name":        defaultName,
+				"csrf_header":        "X-Token",
+				"lifetime":           3600.0,
+				"idle_timeout":       3600.0,
+				"heartbeat_interval": 60.0,
+				"step_up": []interface{}{
+					map[string]interface{}{"name": "payments", "key": "mfa", "max_age": 300.0},
+				},
+			},
+		},
+		"Adaptive idle timeout": {
+			Manager: NewManager(&StoreMock{}, ExpiresIn(time.Hour), AdaptiveTTL(AdaptiveConfig{Min: time.Minute * 10, Max: time.Hour * 2})),
+			Config:  DescribeConfig{Heartbeat: time.Minute},
+			Result: map[string]interface{}{
+				"cookie_name":        defaultName,
+				"lifetime":           3600.0,
+				"idle_timeout":       600.0,
+				"heartbeat_interval": 60.0,
+			},
+		},
+		"Store TTL": {
+			Manager: NewManager(&StoreMock{}, ExpiresIn(time.Hour), StoreTTL(time.Hour*2)),
+			Result: map[string]interface{}{
+				"cookie_name":       defaultName,
+				"lifetime":          7200.0,
+				"absolute_lifetime": 7200.0,
+			},
+		},
+		"User's TTL": {
+			Manager: NewManager(&StoreMock{}, ExpiresIn(time.Hour), TTLResolver(func(key string) time.Duration {
+				if key == "admin" {
+					return time.Minute * 15
+				}
+
+				return time.Hour
+			})),
+			Session: &Session{UserKey: "admin"},
+			Result: map[string]interface{}{
+				"cookie_name":       defaultName,
+				"lifetime":          900.0,
+				"absolute_lifetime": 900.0,
+			},
+		},
+	}
+
+	for cn, c := range cc {
+		c := c
+
+		t.Run(cn, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", WellKnownPath, nil)
+			if c.Session != nil {
+				req = req.WithContext(NewContext(context.Background(), *c.Session))
+			}
+
+			rec := httptest.NewRecorder()
+			c.Manager.ConfigHandler(c.Config).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("want %d, got %d", http.StatusOK, rec.Code)
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("want %q, got %q", "application/json", ct)
+			}
+
+			var res map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("want nil, got %v", err)
+			}
+
+			if !reflect.DeepEqual(res, c.Result) {
+				t.Errorf("want %v, got %v", c.Result, res)
+			}
+		})
+	}
+}